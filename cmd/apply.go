@@ -0,0 +1,464 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/engine"
+	"github.com/kkauto-net/kk-install/pkg/license"
+	"github.com/kkauto-net/kk-install/pkg/spec"
+	"github.com/kkauto-net/kk-install/pkg/templates"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+	"github.com/kkauto-net/kk-install/pkg/validator"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Render the stack from kk.yaml and write what changed",
+	Long: `Render the stack described by kk.yaml in the current directory, show a
+diff against the files on disk and write only the files that changed.
+Values kk.yaml leaves out, including generated secrets, are kept from the
+existing .env and docker-compose.yml, so applying the same spec again
+changes nothing. Run kk start afterwards to recreate changed services.`,
+	Annotations: map[string]string{"group": "core"},
+	Args:        cobra.NoArgs,
+	RunE:        runApply,
+}
+
+var (
+	applyFile   string
+	applyDryRun bool
+)
+
+func init() {
+	applyCmd.Flags().StringVarP(&applyFile, "file", "f", spec.FileName, "Stack spec to apply")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "Show the diff without writing any file")
+	rootCmd.AddCommand(applyCmd)
+}
+
+// applyResult is the structured output of kk apply.
+type applyResult struct {
+	Changed bool          `json:"changed" yaml:"changed"`
+	DryRun  bool          `json:"dry_run" yaml:"dry_run"`
+	Files   []spec.Change `json:"files" yaml:"files"`
+}
+
+// applySecrets are the generated secrets with kk init's minimum and
+// generated lengths.
+var applySecrets = []struct {
+	name     string
+	minLen   int
+	generate func() (string, error)
+}{
+	{"jwt_secret", templates.MinJWTSecretLength, func() (string, error) { return generatePasswordWithRetry(32) }},
+	{"db_password", templates.MinDBPasswordLength, func() (string, error) { return generatePasswordWithRetry(24) }},
+	{"db_root_password", templates.MinDBPasswordLength, func() (string, error) { return generatePasswordWithRetry(24) }},
+	{"redis_password", templates.MinDBPasswordLength, func() (string, error) { return generatePasswordWithRetry(24) }},
+	{"s3_access_key", templates.MinS3AccessKeyLength, func() (string, error) { return generateS3AccessKeyWithRetry(20) }},
+	{"s3_secret_key", templates.MinS3SecretKeyLength, func() (string, error) { return generatePasswordWithRetry(40) }},
+}
+
+func runApply(cmd *cobra.Command, args []string) error {
+	structured := ui.IsStructuredOutput()
+	if !structured {
+		ui.ShowCommandBanner("kk apply", ui.Msg("apply_desc"))
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	specPath := applyFile
+	if !filepath.IsAbs(specPath) {
+		specPath = filepath.Join(cwd, specPath)
+	}
+	s, err := spec.Load(specPath)
+	if err == nil {
+		err = validateDomain(s.Domain)
+	}
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("apply_spec_invalid"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("apply_spec_invalid_suggestion"),
+		})
+		return NewExitError(exitCodeInputValidation, err)
+	}
+
+	tmplCfg, err := buildApplyConfig(s, cwd)
+	if err != nil {
+		return err
+	}
+
+	renderDir, err := os.MkdirTemp("", "kk-apply-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		warnOnError(os.RemoveAll(renderDir))
+	}()
+	if err = renderTemplates(tmplCfg, renderDir); err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:   ui.Msg("error_create_file"),
+			Message: ui.SanitizeError(err),
+		})
+		return NewExitError(exitCodeRenderFailure, err)
+	}
+
+	changes, err := spec.Plan(renderDir, cwd)
+	if err != nil {
+		return err
+	}
+	changed := spec.Changed(changes)
+	if structured {
+		if err = ui.WriteStructured(os.Stdout, applyResult{Changed: changed, DryRun: applyDryRun, Files: changes}); err != nil {
+			return err
+		}
+	} else {
+		printApplyPlan(changes)
+	}
+
+	if !changed {
+		// Nothing is written, but projects rendered before the manifest
+		// existed get one.
+		if !applyDryRun {
+			if err = spec.Apply(changes, renderDir, cwd); err != nil && !structured {
+				ui.ShowWarning(fmt.Sprintf("Cannot record generated files: %v", err))
+			}
+		}
+		if !structured {
+			ui.ShowSuccess(ui.IconCheck + " " + ui.Msg("apply_no_changes"))
+		}
+		return nil
+	}
+	if applyDryRun {
+		if !structured {
+			ui.ShowInfo(ui.MsgF("apply_dry_run", countApplyChanges(changes)))
+		}
+		return nil
+	}
+	if err = resolveLocalEdits(changes, !structured && validator.IsInteractiveTTY()); err != nil {
+		return err
+	}
+
+	if hasApplyUpdates(changes) {
+		if err = backupExistingConfigs(cwd); err != nil && !structured {
+			ui.ShowWarning(ui.MsgF("config_backup_failed", err))
+		}
+	}
+	if err = spec.Apply(changes, renderDir, cwd); err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:   ui.Msg("error_create_file"),
+			Message: ui.SanitizeError(err),
+		})
+		return NewExitError(exitCodeRenderFailure, err)
+	}
+
+	if cfg, loadErr := config.Load(); loadErr == nil {
+		if setErr := cfg.SetInitializedDir(cwd); setErr == nil {
+			if saveErr := cfg.Save(); saveErr != nil && !structured {
+				ui.ShowWarning(fmt.Sprintf("Cannot save config: %v", saveErr))
+			}
+		}
+	}
+
+	if !structured {
+		showKeptLocalEdits(changes)
+		ui.ShowSuccess(ui.IconCheck + " " + ui.MsgF("apply_done", countApplyChanges(changes)))
+		ui.ShowNote(ui.Msg("apply_next_step"))
+	}
+	return nil
+}
+
+// buildApplyConfig resolves s against the files already in dir: anything s
+// leaves out keeps its current value, falling back to kk init's defaults.
+func buildApplyConfig(s *spec.Spec, dir string) (templates.Config, error) {
+	existingEnv := loadExistingEnv(dir)
+
+	licenseKey, publicKey, err := resolveApplyLicense(s.License, existingEnv)
+	if err != nil {
+		return templates.Config{}, err
+	}
+	secrets, err := resolveApplySecrets(s, existingEnv)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("apply_spec_invalid"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("apply_spec_invalid_suggestion"),
+		})
+		return templates.Config{}, NewExitError(exitCodeInputValidation, err)
+	}
+
+	domain := s.Domain
+	if domain == "" {
+		domain = existingEnv["SYSTEM_DOMAIN"]
+	}
+	if domain == "" {
+		domain = "localhost"
+	}
+	timezone := s.Timezone
+	if timezone == "" {
+		timezone = existingEnv["TZ"]
+	}
+	if timezone == "" {
+		timezone = getSystemTimezone()
+	}
+
+	stackPrefix, hostPorts := existingStackLayout(dir)
+	if s.StackPrefix != "" {
+		stackPrefix = s.StackPrefix
+	}
+	hostPorts = mergeHostPorts(s.HostPorts(), hostPorts)
+	dbExposure := existingDBExposure(dir)
+	if s.DBExposure != "" {
+		dbExposure = s.DBExposure
+	}
+	enableSeaweedFS, enableCaddy := existingServices(dir)
+	if err = hostPorts.Validate(dbExposure, s.Caddy(enableCaddy)); err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("apply_spec_invalid"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("apply_spec_invalid_suggestion"),
+		})
+		return templates.Config{}, NewExitError(exitCodeInputValidation, err)
+	}
+	resources := s.TemplateResources()
+	if resources == nil {
+		resources = existingResources(dir)
+	}
+
+	tmplCfg := templates.Config{
+		EnableSeaweedFS: s.SeaweedFS(enableSeaweedFS),
+		EnableCaddy:     s.Caddy(enableCaddy),
+		Domain:          domain,
+		Timezone:        timezone,
+		JWTSecret:       secrets["JWT_SECRET"],
+		LicenseKey:      licenseKey,
+		ServerPublicKey: publicKey,
+		DBPassword:      secrets["DB_PASSWORD"],
+		DBRootPassword:  secrets["DB_ROOT_PASSWORD"],
+		RedisPassword:   secrets["REDIS_PASSWORD"],
+		S3AccessKey:     secrets["S3_ACCESS_KEY"],
+		S3SecretKey:     secrets["S3_SECRET_KEY"],
+		StackPrefix:     stackPrefix,
+		Ports:           hostPorts,
+		DBExposure:      dbExposure,
+		Podman:          engine.CurrentRuntime() == engine.RuntimePodman,
+		Resources:       resources,
+		OverrideDir:     filepath.Join(dir, templates.OverridesDirName),
+	}
+	if lock, lockErr := updater.LoadLock(dir); lockErr != nil {
+		ui.ShowWarning(fmt.Sprintf("Cannot read %s: %v", updater.LockFileName, lockErr))
+	} else if lock != nil {
+		tmplCfg.ImagePins = lock.Digests()
+	}
+	return tmplCfg, nil
+}
+
+// resolveApplyLicense returns the license key and its server public key. A
+// key already in .env keeps its public key, so re-applying works offline;
+// a new key is validated like kk init does.
+func resolveApplyLicense(src spec.Source, existingEnv map[string]string) (string, string, error) {
+	key := existingEnv["LICENSE_KEY"]
+	if src.External() {
+		var err error
+		if key, err = src.Read(); err != nil {
+			err = fmt.Errorf("license: %w", err)
+			ui.ShowBoxedError(ui.ErrorSuggestion{
+				Title:      ui.Msg("apply_spec_invalid"),
+				Message:    ui.SanitizeError(err),
+				Suggestion: ui.Msg("apply_spec_invalid_suggestion"),
+			})
+			return "", "", NewExitError(exitCodeInputValidation, err)
+		}
+	}
+	if key == "" || !license.ValidateFormat(key) {
+		msg := ui.Msg("apply_license_missing")
+		if key != "" {
+			msg = ui.Msg("license_invalid_format")
+		}
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("apply_spec_invalid"),
+			Message:    msg,
+			Suggestion: ui.Msg("apply_license_missing_suggestion"),
+		})
+		return "", "", NewExitError(exitCodeInputValidation, errors.New(msg))
+	}
+
+	if publicKey := existingEnv["SERVER_PUBLIC_KEY_ENCRYPTED"]; key == existingEnv["LICENSE_KEY"] && publicKey != "" {
+		return key, publicKey, nil
+	}
+	if os.Getenv("KK_TEST_SKIP_LICENSE_VALIDATION") == "true" {
+		return key, "TEST-PUBLIC-KEY", nil
+	}
+	resp, err := newLicenseClient().Validate(key)
+	if err != nil {
+		safeMessage := sanitizeLicenseError(err.Error(), key)
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("license_validation_failed"),
+			Message:    safeMessage,
+			Suggestion: ui.Msg("license_check_key"),
+		})
+		return "", "", NewExitError(exitCodeLicenseValidation, errors.New(safeMessage))
+	}
+	return key, resp.PublicKey, nil
+}
+
+// resolveApplySecrets returns the secrets by .env key. env: and file:
+// sources are read; otherwise the value in .env is kept when it is long
+// enough and a new one is generated when it is not.
+func resolveApplySecrets(s *spec.Spec, existingEnv map[string]string) (map[string]string, error) {
+	values := make(map[string]string, len(applySecrets))
+	for _, secret := range applySecrets {
+		envKey := spec.SecretKeys[secret.name]
+		value := existingEnv[envKey]
+		if src := s.Secrets[secret.name]; src.External() {
+			var err error
+			if value, err = src.Read(); err != nil {
+				return nil, fmt.Errorf("secrets.%s: %w", secret.name, err)
+			}
+			if len(value) < secret.minLen {
+				return nil, fmt.Errorf("secrets.%s: %s must be at least %d characters (got %d)", secret.name, envKey, secret.minLen, len(value))
+			}
+		} else if len(value) < secret.minLen {
+			var err error
+			if value, err = secret.generate(); err != nil {
+				return nil, fmt.Errorf("generate %s: %w", envKey, err)
+			}
+		}
+		values[envKey] = value
+	}
+	return values, nil
+}
+
+// printApplyPlan lists every generated file and the diff of those that change.
+func printApplyPlan(changes []spec.Change) {
+	for _, c := range changes {
+		switch c.Action {
+		case spec.ActionCreate:
+			fmt.Println(ui.Success("+ " + c.File + " " + ui.Msg("apply_file_create")))
+		case spec.ActionUpdate:
+			fmt.Println(ui.Warning("~ " + c.File + " " + ui.Msg("apply_file_update")))
+		case spec.ActionMerge:
+			fmt.Println(ui.Warning("~ " + c.File + " " + ui.Msg("apply_file_merge")))
+		case spec.ActionKeepLocal:
+			fmt.Println(ui.Error("! " + c.File + " " + ui.Msg("apply_file_keep_local")))
+		case spec.ActionUnchanged:
+			if c.Edited {
+				fmt.Println(ui.Hint("  " + c.File + " " + ui.Msg("apply_file_edited")))
+			} else {
+				fmt.Println(ui.Hint("  " + c.File + " " + ui.Msg("apply_file_unchanged")))
+			}
+		}
+		if c.Action != spec.ActionUnchanged && c.Action != spec.ActionCreate {
+			printApplyDiff(c.Diff)
+		}
+	}
+	fmt.Println()
+}
+
+// printApplyDiff prints a unified diff with added and removed lines coloured.
+// The headers repeat the file name and are skipped.
+func printApplyDiff(diff string) {
+	for _, line := range strings.Split(strings.TrimRight(diff, "\n"), "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "@@"):
+			fmt.Println("    " + ui.Info(line))
+		case strings.HasPrefix(line, "+"):
+			fmt.Println("    " + ui.Success(line))
+		case strings.HasPrefix(line, "-"):
+			fmt.Println("    " + ui.Error(line))
+		default:
+			fmt.Println("    " + line)
+		}
+	}
+}
+
+func countApplyChanges(changes []spec.Change) int {
+	n := 0
+	for _, c := range changes {
+		if c.Action != spec.ActionUnchanged {
+			n++
+		}
+	}
+	return n
+}
+
+func hasApplyUpdates(changes []spec.Change) bool {
+	for _, c := range changes {
+		if c.Action == spec.ActionUpdate || c.Action == spec.ActionMerge {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveLocalEdits asks what to do with each generated file edited by hand
+// since kk wrote it. Otherwise clean merges are written and files whose
+// edits overlap the template changes are kept.
+func resolveLocalEdits(changes []spec.Change, interactive bool) error {
+	if !interactive {
+		return nil
+	}
+	for i := range changes {
+		c := &changes[i]
+		if !c.Edited || c.Action == spec.ActionUnchanged {
+			continue
+		}
+		choice, err := chooseLocalEdit(*c)
+		if err != nil {
+			return err
+		}
+		switch choice {
+		case spec.ActionMerge:
+			c.Merge()
+		case spec.ActionUpdate:
+			c.Overwrite()
+		default:
+			c.KeepLocal()
+		}
+	}
+	return nil
+}
+
+// chooseLocalEdit asks how to resolve an edited file; swapped in tests.
+var chooseLocalEdit = func(c spec.Change) (string, error) {
+	choice := c.Action
+	var options []huh.Option[string]
+	if c.CanMerge() {
+		options = append(options, huh.NewOption(ui.Msg("local_edit_merge"), spec.ActionMerge))
+	}
+	options = append(options,
+		huh.NewOption(ui.MsgF("local_edit_keep", c.File+spec.NewSuffix), spec.ActionKeepLocal),
+		huh.NewOption(ui.Msg("local_edit_overwrite"), spec.ActionUpdate),
+	)
+	form := huh.NewForm(huh.NewGroup(
+		huh.NewSelect[string]().
+			Title(ui.MsgF("local_edit_prompt", c.File)).
+			Options(options...).
+			Value(&choice),
+	))
+	if err := form.Run(); err != nil {
+		return "", err
+	}
+	return choice, nil
+}
+
+// showKeptLocalEdits warns about the files kept with their local edits
+// instead of the new template output.
+func showKeptLocalEdits(changes []spec.Change) {
+	for _, c := range changes {
+		if c.Action == spec.ActionKeepLocal {
+			ui.ShowWarning(ui.MsgF("local_edit_kept", c.File, c.File+spec.NewSuffix))
+		}
+	}
+}
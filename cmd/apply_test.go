@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/license"
+	"github.com/kkauto-net/kk-install/pkg/spec"
+	"github.com/kkauto-net/kk-install/pkg/templates"
+	"github.com/spf13/cobra"
+)
+
+func TestRunApplyIsIdempotent(t *testing.T) {
+	oldFile, oldDryRun, oldNewLicenseClient := applyFile, applyDryRun, newLicenseClient
+	t.Cleanup(func() { applyFile, applyDryRun, newLicenseClient = oldFile, oldDryRun, oldNewLicenseClient })
+	applyFile, applyDryRun = spec.FileName, false
+	validations := 0
+	newLicenseClient = func() *license.LicenseClient {
+		validations++
+		return successfulLicenseClient()
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() error = %v", err)
+	}
+	tmp := t.TempDir()
+	if chdirErr := os.Chdir(tmp); chdirErr != nil {
+		t.Fatalf("Chdir() error = %v", chdirErr)
+	}
+	t.Cleanup(func() {
+		if chdirErr := os.Chdir(cwd); chdirErr != nil {
+			t.Logf("restore working directory: %v", chdirErr)
+		}
+	})
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("KK_TEST_SKIP_LICENSE_VALIDATION", "")
+	t.Setenv("KK_APPLY_LICENSE", "LICENSE-ABCDEF0123456789")
+
+	kkYAML := `domain: example.com
+timezone: UTC
+services:
+  seaweedfs: false
+ports:
+  app: 9019
+resources:
+  kkengine: {cpus: 2, memory: 1g}
+license: env:KK_APPLY_LICENSE
+`
+	if err = os.WriteFile(filepath.Join(tmp, spec.FileName), []byte(kkYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = runApply(&cobra.Command{}, nil); err != nil {
+		t.Fatalf("first runApply() error = %v", err)
+	}
+	first := readProjectFiles(t, tmp)
+	for _, name := range []string{".env", "docker-compose.yml", "kkphp.conf", "Caddyfile"} {
+		if _, ok := first[name]; !ok {
+			t.Fatalf("%s not written; have %v", name, first)
+		}
+	}
+	if _, ok := first["kkfiler.toml"]; ok {
+		t.Fatal("kkfiler.toml written with seaweedfs disabled")
+	}
+	if !strings.Contains(first["docker-compose.yml"], `"9019:8019"`) || !strings.Contains(first["docker-compose.yml"], "mem_limit: 1g") {
+		t.Fatalf("docker-compose.yml does not follow kk.yaml:\n%s", first["docker-compose.yml"])
+	}
+
+	if err = runApply(&cobra.Command{}, nil); err != nil {
+		t.Fatalf("second runApply() error = %v", err)
+	}
+	second := readProjectFiles(t, tmp)
+	for name, content := range first {
+		if second[name] != content {
+			t.Errorf("%s changed on the second apply", name)
+		}
+	}
+	if len(second) != len(first) {
+		t.Errorf("second apply created files: %d -> %d", len(first), len(second))
+	}
+	if validations != 1 {
+		t.Errorf("license validated %d times, want once", validations)
+	}
+}
+
+func TestResolveApplySecrets(t *testing.T) {
+	secretFile := filepath.Join(t.TempDir(), "db_root")
+	if err := os.WriteFile(secretFile, []byte("root-password-from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KK_APPLY_DB_PASSWORD", "db-password-from-env")
+	s := &spec.Spec{Secrets: map[string]spec.Source{
+		"db_password":      {Kind: spec.SourceEnv, Ref: "KK_APPLY_DB_PASSWORD"},
+		"db_root_password": {Kind: spec.SourceFile, Ref: secretFile},
+	}}
+	existing := map[string]string{
+		"JWT_SECRET":     strings.Repeat("j", 40),
+		"REDIS_PASSWORD": "short",
+	}
+
+	values, err := resolveApplySecrets(s, existing)
+	if err != nil {
+		t.Fatalf("resolveApplySecrets() error = %v", err)
+	}
+	if values["JWT_SECRET"] != existing["JWT_SECRET"] {
+		t.Error("existing JWT_SECRET was not kept")
+	}
+	if values["DB_PASSWORD"] != "db-password-from-env" {
+		t.Errorf("DB_PASSWORD = %q, want the env value", values["DB_PASSWORD"])
+	}
+	if values["DB_ROOT_PASSWORD"] != "root-password-from-file" {
+		t.Errorf("DB_ROOT_PASSWORD = %q, want the trimmed file content", values["DB_ROOT_PASSWORD"])
+	}
+	if len(values["REDIS_PASSWORD"]) < templates.MinDBPasswordLength {
+		t.Errorf("short REDIS_PASSWORD was not regenerated: %q", values["REDIS_PASSWORD"])
+	}
+
+	t.Setenv("KK_APPLY_DB_PASSWORD", "too-short")
+	if _, err = resolveApplySecrets(s, existing); err == nil || !strings.Contains(err.Error(), "secrets.db_password") {
+		t.Fatalf("resolveApplySecrets() error = %v, want a short db_password error", err)
+	}
+}
+
+func readProjectFiles(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || entry.Name() == spec.FileName {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[entry.Name()] = string(data)
+	}
+	return files
+}
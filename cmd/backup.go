@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/backup"
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up database, volumes, and config into one archive",
+	Long: `Take a consistent snapshot of the running stack: a MariaDB dump taken inside
+the db container, the SeaweedFS filestore, the redis_data and caddy_data volumes,
+and the rendered config files. Everything is written into one versioned tar.gz
+with a manifest (including image digests) and a .sha256 checksum file.`,
+	Annotations: map[string]string{"group": "management"},
+	RunE:        runBackup,
+}
+
+var backupFile string
+
+func init() {
+	backupCmd.Flags().StringVarP(&backupFile, "file", "f", "", "Archive path (default: <project>/backups/kk-backup-<timestamp>.tar.gz)")
+	rootCmd.AddCommand(backupCmd)
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	cwd, err := config.EnsureProjectDir()
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("project_not_configured"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("run_init_to_configure"),
+			Command:    "kk init",
+		})
+		return err
+	}
+
+	ui.ShowCommandBanner(ui.Msg("cmd_backup_title"), ui.Msg("backup_desc"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		fmt.Println("\n\n" + ui.Msg("stopping"))
+		cancel()
+	}()
+
+	composeFile, err := compose.ParseComposeFile(cwd)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("backup_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("err_compose_file_missing"),
+			Command:    "kk init",
+		})
+		return err
+	}
+	services := composeFile.GetServiceNames()
+	volumes := backup.VolumesFor(services)
+	executor := compose.NewExecutor(cwd)
+
+	// Step 1: Stack must be running so the dump and volume copies are live
+	ui.ShowStepHeader(1, 4, ui.Msg("step_backup_check"))
+	statusCtx, statusCancel := context.WithTimeout(ctx, 30*time.Second)
+	defer statusCancel()
+	statuses, err := monitor.GetStatusWithServices(statusCtx, executor, services)
+	if err != nil {
+		return showBackupError(err, ui.Msg("err_check_docker_running"))
+	}
+	if stopped := stoppedBackupServices(statuses, volumes); len(stopped) > 0 {
+		err = fmt.Errorf("%s", ui.MsgF("backup_stack_not_running", strings.Join(stopped, ", ")))
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("backup_failed"),
+			Message:    err.Error(),
+			Suggestion: ui.Msg("backup_start_stack_suggestion"),
+			Command:    "kk start",
+		})
+		return err
+	}
+	ui.ShowSuccess(ui.Msg("backup_stack_running"))
+
+	// Step 2: Record image digests
+	ui.ShowStepHeader(2, 4, ui.Msg("step_backup_images"))
+	spinner := ui.StartPtermSpinner(ui.Msg("backup_recording_images"))
+	inspectCtx, inspectCancel := context.WithTimeout(ctx, compose.DefaultTimeout)
+	defer inspectCancel()
+	images, err := updater.SnapshotImages(inspectCtx, composeFile.GetServiceImages(), updater.NewDockerImageInspector())
+	if err != nil {
+		spinner.Fail(ui.Msg("backup_failed"))
+		return showBackupError(err, ui.Msg("err_check_docker_running"))
+	}
+	spinner.Success(ui.Msg("backup_images_recorded"))
+
+	staging, err := os.MkdirTemp("", "kk-backup-*")
+	if err != nil {
+		return showBackupError(err, ui.Msg("backup_check_disk_suggestion"))
+	}
+	defer func() {
+		warnOnError(os.RemoveAll(staging))
+	}()
+
+	snapshot := backup.NewSnapshot(staging, executor, &backup.Manifest{
+		CreatedAt:  time.Now().UTC(),
+		KKVersion:  Version,
+		ProjectDir: cwd,
+		Services:   services,
+		Images:     backup.ImageRecords(images),
+	})
+
+	// Step 3: Config files, database dump, volumes
+	ui.ShowStepHeader(3, 4, ui.Msg("step_backup_data"))
+	dataCtx, dataCancel := context.WithTimeout(ctx, backupTimeout)
+	defer dataCancel()
+
+	if err := runBackupStep(ui.Msg("backup_copying_configs"), ui.Msg("backup_configs_copied"), func() error {
+		return snapshot.AddConfigs(cwd)
+	}); err != nil {
+		return err
+	}
+
+	if _, ok := composeFile.Services[backup.DatabaseService]; ok {
+		if err := runBackupStep(ui.Msg("backup_dumping_db"), ui.Msg("backup_db_dumped"), func() error {
+			return snapshot.AddDatabase(dataCtx)
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, volume := range volumes {
+		if err := runBackupStep(ui.MsgF("backup_archiving_volume", volume.Name), ui.MsgF("backup_volume_archived", volume.Name), func() error {
+			if volume.Service == backup.RedisService {
+				if err := snapshot.SaveRedis(dataCtx, config.ReadEnvValue(cwd, "REDIS_PASSWORD")); err != nil {
+					return err
+				}
+			}
+			return snapshot.AddVolume(dataCtx, volume)
+		}); err != nil {
+			return err
+		}
+	}
+
+	// Step 4: Pack everything into one checksummed archive
+	ui.ShowStepHeader(4, 4, ui.Msg("step_backup_archive"))
+	dest := backupFile
+	if dest == "" {
+		dest = defaultBackupPath(cwd, time.Now())
+	}
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		return showBackupError(err, ui.Msg("backup_check_disk_suggestion"))
+	}
+
+	var sum string
+	if err := runBackupStep(ui.Msg("backup_writing_archive"), ui.Msg("backup_archive_written"), func() error {
+		var finishErr error
+		sum, finishErr = snapshot.Finish(dest)
+		return finishErr
+	}); err != nil {
+		return err
+	}
+
+	size := int64(0)
+	if info, statErr := os.Stat(dest); statErr == nil {
+		size = info.Size()
+	}
+
+	fmt.Println()
+	ui.ShowCompletionBanner(true, ui.IconComplete+" "+ui.Msg("backup_complete"), ui.MsgF("backup_summary", dest, ui.FormatSize(size), sum))
+	return nil
+}
+
+// backupTimeout bounds the dump and volume copy phase, which can be large.
+const backupTimeout = 2 * time.Hour
+
+func runBackupStep(progress, done string, step func() error) error {
+	spinner := ui.StartPtermSpinner(progress)
+	if err := step(); err != nil {
+		spinner.Fail(ui.Msg("backup_failed"))
+		return showBackupError(err, ui.Msg("backup_check_disk_suggestion"))
+	}
+	spinner.Success(done)
+	return nil
+}
+
+func showBackupError(err error, suggestion string) error {
+	command := ""
+	if ui.IsDockerPermissionError(err) {
+		suggestion, command = ui.DockerPermissionSuggestion()
+	}
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("backup_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: suggestion,
+		Command:    command,
+	})
+	return err
+}
+
+// stoppedBackupServices returns the services a backup needs that are not running.
+func stoppedBackupServices(statuses []monitor.ServiceStatus, volumes []backup.Volume) []string {
+	needed := map[string]bool{}
+	for _, v := range volumes {
+		needed[v.Service] = true
+	}
+	var stopped []string
+	for _, s := range statuses {
+		if (s.Name == backup.DatabaseService || needed[s.Name]) && !s.Running {
+			stopped = append(stopped, s.Name)
+		}
+	}
+	return stopped
+}
+
+func defaultBackupPath(projectDir string, now time.Time) string {
+	return filepath.Join(projectDir, "backups", "kk-backup-"+now.Format("20060102150405")+".tar.gz")
+}
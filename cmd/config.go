@@ -1,7 +1,15 @@
 package cmd
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 
 	"github.com/kkauto-net/kk-install/pkg/config"
 	"github.com/kkauto-net/kk-install/pkg/ui"
@@ -21,9 +29,59 @@ var configShowCmd = &cobra.Command{
 	RunE:  runConfigShow,
 }
 
+var configGetCmd = &cobra.Command{
+	Use:   "get [key]",
+	Short: "Print a setting, or all settings",
+	Long: `Print the value of a setting: language, runtime, domain (SYSTEM_DOMAIN),
+timezone (TZ), services.seaweedfs, services.caddy or project_dir.
+Without a key, print every setting.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runConfigGet,
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Change a setting and re-render the files it affects",
+	Long: `Change a setting. Stack settings re-render only the generated files they
+affect and show a diff before writing; the services using changed files can
+then be restarted.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runConfigSet,
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit settings in $EDITOR",
+	Long:  `Open the settings in $VISUAL or $EDITOR and apply what changed, like kk config set.`,
+	Args:  cobra.NoArgs,
+	RunE:  runConfigEdit,
+}
+
+var configApplyOpts configApplyOptions
+
+// editorCommand runs the editor on path; swapped in tests.
+var editorCommand = func(path string) *exec.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// The editor may carry arguments, e.g. "code --wait".
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd
+}
+
 func init() {
 	rootCmd.AddCommand(configCmd)
-	configCmd.AddCommand(configShowCmd)
+	for _, c := range []*cobra.Command{configSetCmd, configEditCmd} {
+		c.Flags().BoolVarP(&configApplyOpts.Yes, "yes", "y", false, "Write and restart affected services without asking")
+		c.Flags().BoolVar(&configApplyOpts.DryRun, "dry-run", false, "Show the diff without writing anything")
+		c.Flags().BoolVar(&configApplyOpts.NoRestart, "no-restart", false, "Do not restart services after writing")
+	}
+	configCmd.AddCommand(configShowCmd, configGetCmd, configSetCmd, configEditCmd)
 }
 
 func runConfigShow(cmd *cobra.Command, args []string) error {
@@ -41,3 +99,148 @@ func runConfigShow(cmd *cobra.Command, args []string) error {
 	ui.PrintConfigSummary(cfg)
 	return nil
 }
+
+func runConfigGet(cmd *cobra.Command, args []string) error {
+	cfg, dir, err := loadConfigContext()
+	if err != nil {
+		return err
+	}
+	settings := loadConfigSettings(cfg, dir)
+
+	if len(args) == 1 {
+		key, keyErr := findConfigKey(args[0])
+		if keyErr != nil {
+			return keyErr
+		}
+		fmt.Println(key.get(&settings))
+		return nil
+	}
+
+	if ui.IsStructuredOutput() {
+		values := make(map[string]string, len(configKeys))
+		for _, key := range configKeys {
+			values[key.name] = key.get(&settings)
+		}
+		return ui.WriteStructured(os.Stdout, values)
+	}
+	for _, key := range configKeys {
+		fmt.Printf("%s=%s\n", key.name, key.get(&settings))
+	}
+	return nil
+}
+
+func runConfigSet(cmd *cobra.Command, args []string) error {
+	key, err := findConfigKey(args[0])
+	if err != nil {
+		return err
+	}
+	if key.set == nil {
+		err = NewExitError(exitCodeInputValidation, errors.New(ui.MsgF("config_read_only", key.name)))
+		return showConfigError(err, ui.Msg("config_key_hint"), "kk config get")
+	}
+	cfg, dir, err := loadConfigContext()
+	if err != nil {
+		return err
+	}
+	current := loadConfigSettings(cfg, dir)
+	next := current
+	if err = key.set(&next, strings.TrimSpace(args[1])); err != nil {
+		return showConfigError(NewExitError(exitCodeInputValidation, err), "", "")
+	}
+	return applyConfigSettings(cfg, dir, current, next, configApplyOpts)
+}
+
+func runConfigEdit(cmd *cobra.Command, args []string) error {
+	cfg, dir, err := loadConfigContext()
+	if err != nil {
+		return err
+	}
+	current := loadConfigSettings(cfg, dir)
+
+	data, err := yaml.Marshal(&current)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp("", "kk-config-*.yaml")
+	if err != nil {
+		return err
+	}
+	path := f.Name()
+	defer func() {
+		warnOnError(os.Remove(path))
+	}()
+	_, writeErr := f.WriteString(ui.Msg("config_edit_header") + "\n" + string(data))
+	if closeErr := f.Close(); writeErr != nil || closeErr != nil {
+		return errors.Join(writeErr, closeErr)
+	}
+
+	if err = editorCommand(path).Run(); err != nil {
+		return showConfigError(err, ui.Msg("config_editor_hint"), "")
+	}
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	next, err := parseEditedSettings(edited, current)
+	if err != nil {
+		return showConfigError(NewExitError(exitCodeInputValidation, err), ui.Msg("config_key_hint"), "kk config edit")
+	}
+	return applyConfigSettings(cfg, dir, current, next, configApplyOpts)
+}
+
+// parseEditedSettings reads the edited settings document and validates each
+// value the way kk config set does.
+func parseEditedSettings(data []byte, current configSettings) (configSettings, error) {
+	edited := current
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&edited); err != nil {
+		return current, err
+	}
+	next := current
+	for _, key := range configKeys {
+		if key.set == nil {
+			continue
+		}
+		if value := key.get(&edited); value != key.get(&current) {
+			if err := key.set(&next, strings.TrimSpace(value)); err != nil {
+				return current, fmt.Errorf("%s: %w", key.name, err)
+			}
+		}
+	}
+	return next, nil
+}
+
+// loadConfigContext loads the CLI config and the project directory, which
+// is "" when no project is configured.
+func loadConfigContext() (*config.Config, string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, "", showConfigError(err, ui.Msg("run_init_to_configure"), "kk init")
+	}
+	dir, dirErr := config.EnsureProjectDir()
+	if dirErr != nil {
+		dir = ""
+	}
+	return cfg, dir, nil
+}
+
+func findConfigKey(name string) (configKey, error) {
+	key, ok := lookupConfigKey(name)
+	if !ok {
+		err := NewExitError(exitCodeInputValidation, errors.New(ui.MsgF("config_unknown_key", name)))
+		return key, showConfigError(err, ui.Msg("config_key_hint"), "kk config get")
+	}
+	return key, nil
+}
+
+func showConfigError(err error, suggestion, command string) error {
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("config_change_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: suggestion,
+		Command:    command,
+	})
+	return err
+}
@@ -0,0 +1,387 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/engine"
+	"github.com/kkauto-net/kk-install/pkg/spec"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/validator"
+)
+
+// configSettings are the values kk config get, set and edit work on: CLI
+// settings saved in ~/.kk/config.yaml and stack settings rendered into the
+// project's generated files.
+type configSettings struct {
+	Language string `yaml:"language"`
+	Runtime  string `yaml:"runtime"`
+	Domain   string `yaml:"domain"`
+	Timezone string `yaml:"timezone"`
+	Services struct {
+		SeaweedFS bool `yaml:"seaweedfs"`
+		Caddy     bool `yaml:"caddy"`
+	} `yaml:"services"`
+	ProjectDir string `yaml:"-"`
+}
+
+// configKey is one setting. Keys that render into generated files name
+// them; only those files are re-rendered when the setting changes.
+type configKey struct {
+	name    string
+	aliases []string
+	cli     bool // Saved in ~/.kk/config.yaml, so it works without a project
+	files   []string
+	get     func(*configSettings) string
+	set     func(*configSettings, string) error // nil for read-only keys
+}
+
+var configKeys = []configKey{
+	{
+		name: "language",
+		cli:  true,
+		get:  func(s *configSettings) string { return s.Language },
+		set: func(s *configSettings, v string) error {
+			if v != string(ui.LangEN) && v != string(ui.LangVI) {
+				return fmt.Errorf("invalid language %q (use en or vi)", v)
+			}
+			s.Language = v
+			return nil
+		},
+	},
+	{
+		name:  "runtime",
+		cli:   true,
+		files: []string{"docker-compose.yml"},
+		get:   func(s *configSettings) string { return s.Runtime },
+		set: func(s *configSettings, v string) error {
+			r, err := engine.ParseRuntime(v)
+			if err != nil {
+				return err
+			}
+			s.Runtime = runtimeSetting(r)
+			return nil
+		},
+	},
+	{
+		name:    "domain",
+		aliases: []string{"SYSTEM_DOMAIN"},
+		files:   []string{".env"},
+		get:     func(s *configSettings) string { return s.Domain },
+		set: func(s *configSettings, v string) error {
+			if v == "" {
+				return errors.New("domain cannot be empty")
+			}
+			if err := validateDomain(v); err != nil {
+				return err
+			}
+			s.Domain = v
+			return nil
+		},
+	},
+	{
+		name:    "timezone",
+		aliases: []string{"TZ"},
+		files:   []string{".env"},
+		get:     func(s *configSettings) string { return s.Timezone },
+		set: func(s *configSettings, v string) error {
+			if v == "" || strings.ContainsAny(v, " \t") {
+				return fmt.Errorf("invalid timezone %q (e.g. Asia/Ho_Chi_Minh)", v)
+			}
+			s.Timezone = v
+			return nil
+		},
+	},
+	{
+		name:  "services.seaweedfs",
+		files: []string{"docker-compose.yml", "kkfiler.toml"},
+		get:   func(s *configSettings) string { return strconv.FormatBool(s.Services.SeaweedFS) },
+		set: func(s *configSettings, v string) error {
+			on, err := parseSwitch(v)
+			s.Services.SeaweedFS = on
+			return err
+		},
+	},
+	{
+		name:  "services.caddy",
+		files: []string{"docker-compose.yml", "Caddyfile"},
+		get:   func(s *configSettings) string { return strconv.FormatBool(s.Services.Caddy) },
+		set: func(s *configSettings, v string) error {
+			on, err := parseSwitch(v)
+			s.Services.Caddy = on
+			return err
+		},
+	},
+	{
+		name: "project_dir",
+		get:  func(s *configSettings) string { return s.ProjectDir },
+	},
+}
+
+// lookupConfigKey finds a key by name or alias, ignoring case.
+func lookupConfigKey(name string) (configKey, bool) {
+	for _, key := range configKeys {
+		if strings.EqualFold(key.name, name) || slices.ContainsFunc(key.aliases, func(a string) bool { return strings.EqualFold(a, name) }) {
+			return key, true
+		}
+	}
+	return configKey{}, false
+}
+
+func parseSwitch(v string) (bool, error) {
+	switch strings.ToLower(v) {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+	on, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid value %q (use true or false)", v)
+	}
+	return on, nil
+}
+
+func runtimeSetting(r engine.Runtime) string {
+	if r == "" {
+		return "auto"
+	}
+	return string(r)
+}
+
+// loadConfigSettings reads the current settings. Stack settings are read
+// from the project in dir and left empty when dir is "".
+func loadConfigSettings(cfg *config.Config, dir string) configSettings {
+	s := configSettings{Language: cfg.Language, ProjectDir: dir}
+	if s.Language == "" {
+		s.Language = string(ui.LangEN)
+	}
+	// An unknown saved runtime shows as auto, so saving fixes it.
+	r, err := engine.ParseRuntime(cfg.Runtime)
+	if err != nil {
+		r = ""
+	}
+	s.Runtime = runtimeSetting(r)
+	if dir != "" {
+		env := loadExistingEnv(dir)
+		s.Domain = env["SYSTEM_DOMAIN"]
+		s.Timezone = env["TZ"]
+		s.Services.SeaweedFS, s.Services.Caddy = existingServices(dir)
+	}
+	return s
+}
+
+// configApplyOptions are the flags of kk config set and edit.
+type configApplyOptions struct {
+	Yes       bool // Write and restart without asking
+	DryRun    bool // Only show the diff
+	NoRestart bool // Never restart services
+}
+
+// applyConfigSettings saves the settings that differ between current and
+// next. Stack settings re-render only the files they affect, after showing
+// the diff, and the services using changed files can then be restarted.
+// Errors are shown before they are returned.
+func applyConfigSettings(cfg *config.Config, dir string, current, next configSettings, opts configApplyOptions) error {
+	var changed []configKey
+	files := map[string]bool{}
+	for _, key := range configKeys {
+		if key.get(&current) == key.get(&next) {
+			continue
+		}
+		if key.set == nil {
+			err := NewExitError(exitCodeInputValidation, errors.New(ui.MsgF("config_read_only", key.name)))
+			return showConfigError(err, ui.Msg("config_key_hint"), "kk config get")
+		}
+		if !key.cli && dir == "" {
+			return showConfigError(errors.New(ui.Msg("project_not_configured")), ui.Msg("run_init_to_configure"), "kk init")
+		}
+		changed = append(changed, key)
+		for _, f := range key.files {
+			files[f] = true
+		}
+	}
+	if len(changed) == 0 {
+		ui.ShowInfo(ui.Msg("config_no_changes"))
+		return nil
+	}
+
+	if next.Runtime != current.Runtime {
+		r, err := engine.ParseRuntime(next.Runtime)
+		if err == nil {
+			err = engine.SetRuntime(r)
+		}
+		if err != nil {
+			return showConfigError(err, "", "")
+		}
+	}
+
+	var services compose.ServiceChanges
+	if dir != "" && len(files) > 0 {
+		var err error
+		var written bool
+		if services, written, err = rerenderConfigFiles(dir, next, files, opts); err != nil || !written {
+			return err
+		}
+	} else if opts.DryRun {
+		return nil
+	}
+
+	cfg.Language = next.Language
+	cfg.Runtime = next.Runtime
+	if cfg.Runtime == "auto" {
+		cfg.Runtime = ""
+	}
+	if err := cfg.Save(); err != nil {
+		return showConfigError(err, "", "")
+	}
+	ui.SetLanguage(ui.Language(cfg.Language))
+	for _, key := range changed {
+		ui.ShowSuccess(ui.IconCheck + " " + ui.MsgF("config_saved", key.name, key.get(&next)))
+	}
+
+	if !services.Empty() && !opts.NoRestart {
+		return restartConfigServices(dir, services, opts)
+	}
+	return nil
+}
+
+// rerenderConfigFiles renders the stack with next and writes the files in
+// affected that differ from disk. It returns the services using the written
+// files, and false when nothing may be saved: a dry run or a declined write.
+func rerenderConfigFiles(dir string, next configSettings, affected map[string]bool, opts configApplyOptions) (compose.ServiceChanges, bool, error) {
+	s := &spec.Spec{Domain: next.Domain, Timezone: next.Timezone}
+	s.Services.SeaweedFS = &next.Services.SeaweedFS
+	s.Services.Caddy = &next.Services.Caddy
+	// buildApplyConfig shows its own errors.
+	tmplCfg, err := buildApplyConfig(s, dir)
+	if err != nil {
+		return compose.ServiceChanges{}, false, err
+	}
+
+	renderDir, err := os.MkdirTemp("", "kk-config-")
+	if err != nil {
+		return compose.ServiceChanges{}, false, showConfigError(err, "", "")
+	}
+	defer func() {
+		warnOnError(os.RemoveAll(renderDir))
+	}()
+	if err = renderTemplates(tmplCfg, renderDir); err != nil {
+		return compose.ServiceChanges{}, false, showConfigError(NewExitError(exitCodeRenderFailure, err), "", "")
+	}
+	plan, err := spec.Plan(renderDir, dir)
+	if err != nil {
+		return compose.ServiceChanges{}, false, showConfigError(err, "", "")
+	}
+	var changes []spec.Change
+	var names []string
+	for _, c := range plan {
+		if affected[c.File] && c.Action != spec.ActionUnchanged {
+			changes = append(changes, c)
+			names = append(names, c.File)
+		}
+	}
+	if len(changes) > 0 {
+		printApplyPlan(changes)
+	}
+	if opts.DryRun {
+		ui.ShowInfo(ui.MsgF("apply_dry_run", len(changes)))
+		return compose.ServiceChanges{}, false, nil
+	}
+	if len(changes) == 0 {
+		return compose.ServiceChanges{}, true, nil
+	}
+	if !opts.Yes && validator.IsInteractiveTTY() {
+		confirmed, confirmErr := confirmConfig(ui.Msg("config_confirm_write"))
+		if confirmErr != nil || !confirmed {
+			if confirmErr == nil {
+				ui.ShowInfo(ui.Msg("config_cancelled"))
+			}
+			return compose.ServiceChanges{}, false, confirmErr
+		}
+		if err = resolveLocalEdits(changes, true); err != nil {
+			return compose.ServiceChanges{}, false, err
+		}
+	}
+
+	services, err := compose.ChangedServices(dir, renderDir, names)
+	if err != nil {
+		return compose.ServiceChanges{}, false, showConfigError(err, "", "")
+	}
+	if hasApplyUpdates(changes) {
+		if backupErr := backupExistingConfigs(dir); backupErr != nil {
+			ui.ShowWarning(ui.MsgF("config_backup_failed", backupErr))
+		}
+	}
+	if err = spec.Apply(changes, renderDir, dir); err != nil {
+		return compose.ServiceChanges{}, false, showConfigError(NewExitError(exitCodeRenderFailure, err), "", "")
+	}
+	showKeptLocalEdits(changes)
+	return services, true, nil
+}
+
+// restartConfigServices recreates or restarts the services that use changed
+// files, if the stack is running and the user agrees.
+func restartConfigServices(dir string, services compose.ServiceChanges, opts configApplyOptions) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	executor := compose.NewExecutor(dir)
+	if running, err := executor.HasContainers(ctx); err != nil || !running {
+		return nil
+	}
+	names := strings.Join(services.All(), ", ")
+	if !opts.Yes {
+		if !validator.IsInteractiveTTY() {
+			ui.ShowNote(ui.MsgF("config_restart_hint", names))
+			return nil
+		}
+		confirmed, err := confirmConfig(ui.MsgF("config_confirm_restart", names))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			ui.ShowNote(ui.MsgF("config_restart_hint", names))
+			return nil
+		}
+	}
+
+	ui.ShowInfo(ui.MsgF("config_restarting", names))
+	if len(services.Recreate) > 0 || len(services.Removed) > 0 {
+		if err := executor.UpRemoveOrphans(ctx, services.Recreate...); err != nil {
+			return showConfigError(err, ui.Msg("config_restart_failed_hint"), "kk restart")
+		}
+	}
+	if len(services.Restart) > 0 {
+		if err := executor.RestartServices(ctx, services.Restart...); err != nil {
+			return showConfigError(err, ui.Msg("config_restart_failed_hint"), "kk restart")
+		}
+	}
+	ui.ShowSuccess(ui.IconCheck + " " + ui.MsgF("config_restarted", names))
+	return nil
+}
+
+var confirmConfig = func(title string) (bool, error) {
+	confirmed := true
+	form := huh.NewForm(huh.NewGroup(
+		huh.NewConfirm().
+			Title(title).
+			Affirmative(ui.Msg("yes")).
+			Negative(ui.Msg("no")).
+			Value(&confirmed),
+	))
+	if err := form.Run(); err != nil {
+		return false, err
+	}
+	return confirmed, nil
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/spec"
+	"github.com/spf13/cobra"
+)
+
+func TestLookupConfigKey(t *testing.T) {
+	for name, want := range map[string]string{
+		"domain":         "domain",
+		"SYSTEM_DOMAIN":  "domain",
+		"tz":             "timezone",
+		"Services.Caddy": "services.caddy",
+	} {
+		key, ok := lookupConfigKey(name)
+		if !ok || key.name != want {
+			t.Errorf("lookupConfigKey(%q) = %q, %t; want %q", name, key.name, ok, want)
+		}
+	}
+	if _, ok := lookupConfigKey("db_password"); ok {
+		t.Error("lookupConfigKey(db_password) found a key")
+	}
+}
+
+func TestParseEditedSettings(t *testing.T) {
+	current := configSettings{Language: "en", Runtime: "auto", Domain: "example.com", Timezone: "UTC"}
+	current.Services.SeaweedFS, current.Services.Caddy = true, true
+
+	next, err := parseEditedSettings([]byte("# header\nlanguage: vi\ndomain: new.example.com\nservices:\n  caddy: false\n"), current)
+	if err != nil {
+		t.Fatalf("parseEditedSettings() error = %v", err)
+	}
+	if next.Language != "vi" || next.Domain != "new.example.com" || next.Services.Caddy || !next.Services.SeaweedFS || next.Timezone != "UTC" {
+		t.Errorf("parseEditedSettings() = %+v", next)
+	}
+
+	for _, doc := range []string{"domian: example.com\n", "language: fr\n", "domain: 'bad domain'\n"} {
+		if _, docErr := parseEditedSettings([]byte(doc), current); docErr == nil {
+			t.Errorf("parseEditedSettings(%q) succeeded", doc)
+		}
+	}
+}
+
+func TestRunConfigSetRerendersAffectedFiles(t *testing.T) {
+	oldFile, oldDryRun, oldOpts := applyFile, applyDryRun, configApplyOpts
+	t.Cleanup(func() { applyFile, applyDryRun, configApplyOpts = oldFile, oldDryRun, oldOpts })
+	applyFile, applyDryRun = spec.FileName, false
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() error = %v", err)
+	}
+	tmp := t.TempDir()
+	if chdirErr := os.Chdir(tmp); chdirErr != nil {
+		t.Fatalf("Chdir() error = %v", chdirErr)
+	}
+	t.Cleanup(func() {
+		if chdirErr := os.Chdir(cwd); chdirErr != nil {
+			t.Logf("restore working directory: %v", chdirErr)
+		}
+	})
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("KK_TEST_SKIP_LICENSE_VALIDATION", "true")
+	t.Setenv("KK_CONFIG_LICENSE", "LICENSE-ABCDEF0123456789")
+
+	kkYAML := "domain: example.com\ntimezone: UTC\nlicense: env:KK_CONFIG_LICENSE\n"
+	if err = os.WriteFile(filepath.Join(tmp, spec.FileName), []byte(kkYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = runApply(&cobra.Command{}, nil); err != nil {
+		t.Fatalf("runApply() error = %v", err)
+	}
+	before := readProjectFiles(t, tmp)
+
+	configApplyOpts = configApplyOptions{DryRun: true}
+	if err = runConfigSet(&cobra.Command{}, []string{"SYSTEM_DOMAIN", "new.example.com"}); err != nil {
+		t.Fatalf("dry-run runConfigSet() error = %v", err)
+	}
+	if got := readProjectFiles(t, tmp)[".env"]; got != before[".env"] {
+		t.Fatal("dry run changed .env")
+	}
+
+	configApplyOpts = configApplyOptions{Yes: true, NoRestart: true}
+	if err = runConfigSet(&cobra.Command{}, []string{"domain", "new.example.com"}); err != nil {
+		t.Fatalf("runConfigSet() error = %v", err)
+	}
+	after := readProjectFiles(t, tmp)
+	if !strings.Contains(after[".env"], "SYSTEM_DOMAIN=new.example.com") {
+		t.Errorf(".env was not updated:\n%s", after[".env"])
+	}
+	for name, content := range before {
+		if name != ".env" && after[name] != content {
+			t.Errorf("%s changed, want only .env re-rendered", name)
+		}
+	}
+
+	if err = runConfigSet(&cobra.Command{}, []string{"project_dir", "/elsewhere"}); err == nil {
+		t.Error("setting project_dir succeeded")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load() error = %v", err)
+	}
+	if dir := filepath.Clean(cfg.ProjectDir); dir == "/elsewhere" {
+		t.Errorf("project dir = %q", dir)
+	}
+}
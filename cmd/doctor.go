@@ -0,0 +1,368 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/doctor"
+	"github.com/kkauto-net/kk-install/pkg/engine"
+	"github.com/kkauto-net/kk-install/pkg/logs"
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/validator"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the install and write a support bundle",
+	Long: `Run the preflight checks, inspect Docker, ports and disk space, and collect
+compose status, image digests, recent container logs and the .env keys into one
+tar.gz with a readable summary.txt. Anything that could not be collected is
+listed in errors.txt.
+
+Secret values are masked before anything is written, so the bundle can be
+attached to a support ticket.`,
+	Annotations: map[string]string{"group": "management"},
+	RunE:        runDoctor,
+}
+
+var (
+	doctorFile     string
+	doctorLogLines int
+)
+
+// doctorTimeout bounds the docker calls made while collecting diagnostics.
+const doctorTimeout = 2 * time.Minute
+
+func init() {
+	doctorCmd.Flags().StringVarP(&doctorFile, "file", "f", "", "Bundle path (default: ./kk-doctor-<timestamp>.tar.gz)")
+	doctorCmd.Flags().IntVar(&doctorLogLines, "log-lines", 200, "Number of recent log lines collected per service")
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor(cmd *cobra.Command, args []string) error {
+	// Resolve the bundle path before EnsureProjectDir changes directory.
+	dest, err := doctorBundlePath(doctorFile, time.Now())
+	if err != nil {
+		return err
+	}
+
+	ui.ShowCommandBanner(ui.Msg("cmd_doctor_title"), ui.Msg("doctor_desc"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		<-sigChan
+		fmt.Println("\n\n" + ui.Msg("stopping"))
+		cancel()
+	}()
+
+	// A broken install is what doctor is for: keep going without a project.
+	projectDir, projectErr := config.EnsureProjectDir()
+	var secrets []string
+	if projectErr == nil {
+		secrets = logs.EnvSecrets(projectDir)
+	}
+
+	staging, err := os.MkdirTemp("", "kk-doctor-*")
+	if err != nil {
+		return showDoctorError(err)
+	}
+	defer func() {
+		warnOnError(os.RemoveAll(staging))
+	}()
+
+	bundle := doctor.NewBundle(staging, secrets)
+	bundle.SetHeader(
+		"Generated: "+time.Now().UTC().Format(time.RFC3339),
+		"kk version: "+Version,
+		"Host: "+runtime.GOOS+"/"+runtime.GOARCH,
+		"Project: "+projectDir,
+	)
+
+	// Step 1: Checks
+	ui.ShowStepHeader(1, 3, ui.Msg("step_doctor_checks"))
+	spinner := ui.StartPtermSpinner(ui.Msg("doctor_running_checks"))
+	composeFile := runDoctorChecks(bundle, projectDir, projectErr)
+	spinner.Success(ui.Msg("doctor_checks_done"))
+
+	// Step 2: Stack state and logs
+	ui.ShowStepHeader(2, 3, ui.Msg("step_doctor_collect"))
+	spinner = ui.StartPtermSpinner(ui.Msg("doctor_collecting"))
+	if composeFile != nil {
+		collectDoctorStack(ctx, bundle, projectDir, composeFile)
+	}
+	spinner.Success(ui.Msg("doctor_collected"))
+
+	// Step 3: Bundle
+	ui.ShowStepHeader(3, 3, ui.Msg("step_doctor_bundle"))
+	if err := bundle.Write(dest); err != nil {
+		return showDoctorError(err)
+	}
+
+	printDoctorChecks(bundle.Checks())
+	ui.ShowSuccess(ui.MsgF("doctor_bundle_written", dest))
+	ui.ShowNote(ui.Msg("doctor_attach_hint"))
+	return nil
+}
+
+// runDoctorChecks records the project, preflight, Docker, disk and port
+// checks. It returns the parsed compose file, or nil when there is none.
+func runDoctorChecks(bundle *doctor.Bundle, projectDir string, projectErr error) *compose.ComposeFile {
+	dv := validator.NewDockerValidator()
+
+	if projectErr != nil {
+		bundle.AddCheck(ui.Msg("doctor_check_project"), doctor.StatusFail, ui.SanitizeError(projectErr))
+	} else {
+		bundle.AddCheck(ui.Msg("doctor_check_project"), doctor.StatusOK, projectDir)
+	}
+
+	var composeFile *compose.ComposeFile
+	var ports []validator.StackPort
+	var portStatuses []validator.PortStatus
+	portsChecked := false
+	if projectErr == nil {
+		includeCaddy := false
+		if cf, err := compose.ParseComposeFile(projectDir); err == nil {
+			composeFile = cf
+			_, includeCaddy = cf.Services["caddy"]
+		}
+		results, preflightErr := validator.RunPreflight(projectDir, includeCaddy)
+		for _, r := range results {
+			if r.CheckName == ui.Msg("preflight_check_ports") {
+				ports, portStatuses, portsChecked = r.Ports, r.PortStatuses, true
+			}
+			switch {
+			case !r.Passed:
+				detail := ui.Msg("preflight_result_failed")
+				if r.Error != nil {
+					detail = ui.SanitizeError(r.Error)
+				}
+				bundle.AddCheck(r.CheckName, doctor.StatusFail, detail)
+			case r.Warning != "":
+				bundle.AddCheck(r.CheckName, doctor.StatusWarn, r.Warning)
+			default:
+				bundle.AddCheck(r.CheckName, doctor.StatusOK, "")
+			}
+		}
+		if preflightErr != nil {
+			bundle.AddCheck(ui.Msg("step_preflight"), doctor.StatusFail, ui.Msg("preflight_checks_failed"))
+		}
+	}
+
+	engineTarget := engine.CurrentTarget().String()
+	if engineTarget == "" {
+		engineTarget = ui.Msg("doctor_engine_local")
+	}
+	bundle.AddCheck(ui.Msg("doctor_check_engine"), doctor.StatusInfo, engineTarget)
+	containerRuntime := engine.CurrentRuntime()
+	runtimeDetail := string(containerRuntime)
+	if containerRuntime == engine.RuntimePodman {
+		runtimeDetail = fmt.Sprintf("podman rootless=%t socket=%s", engine.Rootless(), engine.PodmanSocket())
+	}
+	bundle.AddCheck(ui.Msg("doctor_check_runtime"), doctor.StatusInfo, runtimeDetail)
+
+	if err := dv.CheckComposeVersion(); err != nil {
+		bundle.AddCheck(ui.Msg("doctor_check_compose_version"), doctor.StatusFail, ui.SanitizeError(err))
+	} else {
+		bundle.AddCheck(ui.Msg("doctor_check_compose_version"), doctor.StatusOK, "")
+	}
+
+	group := dv.DockerGroupState()
+	groupStatus := doctor.StatusInfo
+	// Podman has no docker group: rootless runs as the user
+	if containerRuntime == engine.RuntimeDocker && !group.InDockerGroup && !group.SudoFallback && os.Geteuid() != 0 {
+		groupStatus = doctor.StatusWarn
+	}
+	bundle.AddCheck(ui.Msg("doctor_check_docker_group"), groupStatus, fmt.Sprintf(
+		"user=%s in_docker_group=%t group_runner=%t sudo_fallback=%t",
+		group.User, group.InDockerGroup, group.GroupRunner, group.SudoFallback))
+	bundle.AddError(bundle.AddJSON("docker-group.json", group))
+
+	diskDir := projectDir
+	if diskDir == "" {
+		diskDir = "."
+	}
+	if gb, err := validator.CheckDiskSpace(diskDir); err != nil {
+		bundle.AddCheck(ui.Msg("doctor_check_disk"), doctor.StatusWarn, ui.SanitizeError(err))
+	} else {
+		status := doctor.StatusOK
+		if gb < validator.MinDiskSpaceGB {
+			status = doctor.StatusWarn
+		}
+		bundle.AddCheck(ui.Msg("doctor_check_disk"), status, fmt.Sprintf("%.1f GB free", gb))
+	}
+
+	// Preflight already checked the ports of a project.
+	if !portsChecked {
+		ports = validator.DefaultStackPorts(true)
+		var portsErr error
+		portStatuses, portsErr = validator.CheckAllPorts(ports, nil)
+		if portsErr != nil {
+			bundle.AddCheck(ui.Msg("preflight_check_ports"), doctor.StatusFail, ui.SanitizeError(portsErr))
+		} else {
+			bundle.AddCheck(ui.Msg("preflight_check_ports"), doctor.StatusOK, "")
+		}
+	}
+	report := doctorPortReport(ports, portStatuses)
+	for _, p := range report {
+		bundle.AddCheck(ui.MsgF("doctor_check_port", p.Port, p.Name), p.status(), p.detail())
+	}
+	bundle.AddError(bundle.AddJSON("ports.json", report))
+
+	return composeFile
+}
+
+// collectDoctorStack stages compose ps output, service images and digests,
+// recent logs, the masked .env and the compose file.
+func collectDoctorStack(ctx context.Context, bundle *doctor.Bundle, projectDir string, composeFile *compose.ComposeFile) {
+	executor := compose.NewExecutor(projectDir)
+
+	if ps, err := executor.Ps(ctx); err != nil {
+		bundle.AddCheck(ui.Msg("doctor_check_compose_ps"), doctor.StatusFail, ui.SanitizeError(err))
+	} else {
+		bundle.AddError(bundle.AddFile("compose-ps.json", []byte(ps)))
+	}
+
+	if statuses, err := monitor.GetStatusWithServices(ctx, executor, composeFile.GetServiceNames()); err == nil {
+		if cli, err := monitor.NewDockerClient(); err == nil {
+			monitor.EnrichStatuses(ctx, cli, statuses, time.Now())
+			bundle.AddError(cli.Close())
+		}
+		bundle.AddError(bundle.AddJSON("services.json", statuses))
+		for _, s := range statuses {
+			status := doctor.StatusOK
+			if !s.Running || (s.Health != "" && s.Health != "healthy") {
+				status = doctor.StatusWarn
+			}
+			bundle.AddCheck(ui.MsgF("doctor_check_service", s.Name), status, strings.Join(strings.Fields(s.Status+" "+s.Health+" "+s.Image+" "+s.ImageDigest), " "))
+		}
+	}
+
+	var out bytes.Buffer
+	err := executor.Logs(ctx, &out, compose.LogOptions{Tail: fmt.Sprint(doctorLogLines), Timestamps: true})
+	if err != nil {
+		bundle.AddCheck(ui.Msg("doctor_check_logs"), doctor.StatusWarn, ui.SanitizeError(err))
+	}
+	if out.Len() > 0 {
+		bundle.AddError(bundle.AddFile("logs.txt", out.Bytes()))
+	}
+
+	if err := bundle.AddMaskedEnv(projectDir); err != nil {
+		bundle.AddCheck(".env", doctor.StatusWarn, ui.SanitizeError(err))
+	}
+	for _, path := range compose.ComposeFiles(projectDir) {
+		data, readErr := os.ReadFile(path)
+		if readErr != nil {
+			bundle.AddError(readErr)
+			continue
+		}
+		bundle.AddError(bundle.AddFile(filepath.Base(path), data))
+	}
+}
+
+// doctorPort is one stack port and who owns it, as written to ports.json.
+type doctorPort struct {
+	Name        string `json:"name"`
+	Port        int    `json:"port"`
+	InUse       bool   `json:"in_use"`
+	PID         int    `json:"pid,omitempty"`
+	Process     string `json:"process,omitempty"`
+	UsedByStack bool   `json:"used_by_stack"`
+}
+
+func (p doctorPort) status() doctor.Status {
+	if p.InUse && !p.UsedByStack {
+		return doctor.StatusFail
+	}
+	return doctor.StatusOK
+}
+
+func (p doctorPort) detail() string {
+	switch {
+	case !p.InUse:
+		return "free"
+	case p.UsedByStack:
+		return "published by the stack"
+	case p.Process != "":
+		return fmt.Sprintf("in use by %s (pid %d)", p.Process, p.PID)
+	default:
+		return "in use by another process"
+	}
+}
+
+// doctorPortReport pairs CheckAllPorts results with the ports they were
+// checked for; CheckAllPorts returns one result per port, in order.
+func doctorPortReport(ports []validator.StackPort, results []validator.PortStatus) []doctorPort {
+	var report []doctorPort
+	for i, r := range results {
+		if i >= len(ports) {
+			break
+		}
+		report = append(report, doctorPort{
+			Name:        ports[i].Name,
+			Port:        r.Port,
+			InUse:       r.InUse,
+			PID:         r.PID,
+			Process:     r.Process,
+			UsedByStack: r.UsedByKKEngine,
+		})
+	}
+	return report
+}
+
+// doctorBundlePath returns file as an absolute path, or a timestamped name
+// in the current directory when file is empty.
+func doctorBundlePath(file string, now time.Time) (string, error) {
+	if file == "" {
+		file = doctor.DefaultFileName(now)
+	}
+	return filepath.Abs(file)
+}
+
+func printDoctorChecks(checks []doctor.Check) {
+	tableData := pterm.TableData{{ui.Msg("check"), ui.Msg("result")}}
+	for _, c := range checks {
+		var result string
+		switch c.Status {
+		case doctor.StatusOK:
+			result = pterm.Green("✓ " + c.Detail)
+		case doctor.StatusWarn:
+			result = pterm.Yellow("⚠ " + c.Detail)
+		case doctor.StatusFail:
+			result = pterm.Red("✗ " + c.Detail)
+		default:
+			result = c.Detail
+		}
+		tableData = append(tableData, []string{c.Name, strings.TrimSpace(result)})
+	}
+	if err := pterm.DefaultTable.WithHasHeader(true).WithBoxed(true).WithData(tableData).Render(); err != nil {
+		ui.ShowWarningf(ui.Msg("preflight_table_render_failed"), err)
+	}
+}
+
+func showDoctorError(err error) error {
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("doctor_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: ui.Msg("backup_check_disk_suggestion"),
+	})
+	return err
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/doctor"
+	"github.com/kkauto-net/kk-install/pkg/validator"
+)
+
+func TestDoctorPortReport(t *testing.T) {
+	ports := []validator.StackPort{{Name: "kkengine", Port: 8019}, {Name: "caddy", Port: 80}, {Name: "caddy", Port: 443}}
+	results := []validator.PortStatus{
+		{Port: 8019, InUse: true, UsedByKKEngine: true},
+		{Port: 80, InUse: true, PID: 812, Process: "nginx"},
+		{Port: 443},
+	}
+
+	report := doctorPortReport(ports, results)
+	if len(report) != 3 {
+		t.Fatalf("doctorPortReport() returned %d ports, want 3", len(report))
+	}
+	want := []struct {
+		status doctor.Status
+		detail string
+	}{
+		{doctor.StatusOK, "published by the stack"},
+		{doctor.StatusFail, "in use by nginx (pid 812)"},
+		{doctor.StatusOK, "free"},
+	}
+	for i, w := range want {
+		if report[i].status() != w.status || report[i].detail() != w.detail {
+			t.Errorf("port %d: got %s %q, want %s %q", report[i].Port, report[i].status(), report[i].detail(), w.status, w.detail)
+		}
+	}
+	if report[1].Name != "caddy" {
+		t.Errorf("port 80 name = %q, want caddy", report[1].Name)
+	}
+}
+
+func TestDoctorBundlePath(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	got, err := doctorBundlePath("", time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "kk-doctor-20260304-050607.tar.gz"); got != want {
+		t.Fatalf("doctorBundlePath() = %q, want %q", got, want)
+	}
+
+	got, err = doctorBundlePath("out/bundle.tar.gz", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "out", "bundle.tar.gz"); got != want {
+		t.Fatalf("doctorBundlePath() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/events"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/validator"
+)
+
+var (
+	eventsFormat string
+	eventsFD     int
+)
+
+// setupEvents starts the --events stream. When events go to stdout, all
+// other output is moved to stderr so the stream stays parseable.
+func setupEvents(cmd *cobra.Command) error {
+	if eventsFormat == "" && cmd.Flags().Changed("events-fd") {
+		eventsFormat = events.FormatNDJSON
+	}
+	format, err := events.ParseFormat(eventsFormat)
+	if err == nil && format != "" {
+		var w io.Writer
+		w, err = eventsWriter(eventsFD)
+		if err == nil {
+			events.Enable(w, cmd.CommandPath())
+		}
+	}
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("invalid_events_flag"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("invalid_events_flag_suggestion"),
+		})
+		return NewExitError(exitCodeInputValidation, err)
+	}
+	return nil
+}
+
+func eventsWriter(fd int) (io.Writer, error) {
+	switch {
+	case fd < 1:
+		return nil, fmt.Errorf("invalid --events-fd %d", fd)
+	case fd == 1:
+		if ui.IsStructuredOutput() {
+			return nil, errors.New("--events on stdout cannot be combined with --output json|yaml")
+		}
+		w := os.Stdout
+		os.Stdout = os.Stderr
+		pterm.SetDefaultOutput(os.Stderr)
+		return w, nil
+	default:
+		f := os.NewFile(uintptr(fd), "events")
+		if f == nil {
+			return nil, fmt.Errorf("invalid --events-fd %d", fd)
+		}
+		if _, err := f.Stat(); err != nil {
+			return nil, fmt.Errorf("--events-fd %d is not open: %w", fd, err)
+		}
+		return f, nil
+	}
+}
+
+// finishEvents emits command.finished with the exit code and UserError key
+// of err, and warns when the stream could not be written.
+func finishEvents(err error) {
+	code, key := 0, ""
+	if err != nil {
+		code = ExitCode(err)
+		var userErr *validator.UserError
+		if errors.As(err, &userErr) {
+			key = userErr.Key
+		}
+	}
+	events.Finish(code, err, key)
+	warnOnError(events.Err())
+}
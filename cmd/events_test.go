@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/events"
+	"github.com/kkauto-net/kk-install/pkg/validator"
+)
+
+func TestFinishEventsReportsExitCodeAndKey(t *testing.T) {
+	var buf bytes.Buffer
+	events.Enable(&buf, "kk init")
+	defer events.Disable()
+	buf.Reset()
+
+	err := NewExitError(exitCodeDockerValidation, &validator.UserError{Key: "docker_not_installed"})
+	finishEvents(err)
+
+	var ev events.Event
+	if err := json.Unmarshal(buf.Bytes(), &ev); err != nil {
+		t.Fatalf("event is not JSON: %v\n%s", err, buf.String())
+	}
+	if ev.Type != events.TypeCommandFinished || ev.ExitCode == nil || *ev.ExitCode != exitCodeDockerValidation {
+		t.Fatalf("event = %+v", ev)
+	}
+	if ev.Error == nil || ev.Error.Key != "docker_not_installed" {
+		t.Fatalf("event error = %+v", ev.Error)
+	}
+}
+
+func TestEventsWriterRejectsBadFD(t *testing.T) {
+	if _, err := eventsWriter(0); err == nil {
+		t.Fatal("eventsWriter(0) should fail")
+	}
+	if _, err := eventsWriter(987); err == nil {
+		t.Fatal("eventsWriter(987) should fail for a closed descriptor")
+	}
+}
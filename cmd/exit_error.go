@@ -8,6 +8,7 @@ const (
 	exitCodeLicenseValidation = 3
 	exitCodeDockerValidation  = 4
 	exitCodeRenderFailure     = 5
+	exitCodeUnhealthy         = 6
 )
 
 type ExitError struct {
@@ -0,0 +1,291 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/exporter"
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/scheduler"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+var exporterCmd = &cobra.Command{
+	Use:   "exporter",
+	Short: "Serve stack health as Prometheus metrics",
+	Long: `Run a long-lived HTTP server that exposes /metrics for Prometheus.
+
+Every scrape reads the compose services and reports, per service, whether the
+container is up and healthy, its restart count and the age of its image. It
+also reports when the last successful 'kk update' finished and, with Caddy
+enabled, the days left until the TLS certificate expires.
+
+Use 'kk exporter install' to run it as a systemd service that starts at boot.`,
+	Example: `  kk exporter
+  kk exporter --listen :9796
+  sudo kk exporter install --listen :9796`,
+	Annotations: map[string]string{"group": "management"},
+	Args:        cobra.NoArgs,
+	RunE:        runExporter,
+}
+
+var exporterInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the exporter as a systemd service",
+	Long: `Write a systemd unit that runs 'kk exporter' at boot and restarts it when it
+exits, then start it. Requires root.`,
+	Example: `  sudo kk exporter install --listen :9796
+  sudo kk exporter install --remove`,
+	Args: cobra.NoArgs,
+	RunE: runExporterInstall,
+}
+
+// exporterUnitName is the base name of the systemd service running kk
+// exporter; see exporterUnit.
+const exporterUnitName = "kk-exporter"
+
+// exporterUnit is the unit of the selected project's exporter.
+func exporterUnit() string {
+	return scheduler.ProjectUnitName(exporterUnitName, config.ProjectName())
+}
+
+var (
+	exporterListen  string
+	exporterTimeout time.Duration
+	exporterRemove  bool
+)
+
+func init() {
+	for _, c := range []*cobra.Command{exporterCmd, exporterInstallCmd} {
+		c.Flags().StringVar(&exporterListen, "listen", "127.0.0.1:9796", "Address to serve /metrics on (use :9796 to listen on all interfaces)")
+		c.Flags().DurationVar(&exporterTimeout, "timeout", 15*time.Second, "Time limit for collecting one scrape")
+	}
+	exporterInstallCmd.Flags().BoolVar(&exporterRemove, "remove", false, "Stop and remove the systemd service")
+	exporterCmd.AddCommand(exporterInstallCmd)
+	rootCmd.AddCommand(exporterCmd)
+}
+
+func validateExporterFlags() error {
+	if _, _, err := net.SplitHostPort(exporterListen); err != nil {
+		return NewExitError(exitCodeInputValidation, fmt.Errorf("invalid --listen address %q: %w", exporterListen, err))
+	}
+	if exporterTimeout <= 0 {
+		return NewExitError(exitCodeInputValidation, fmt.Errorf("--timeout must be positive"))
+	}
+	return nil
+}
+
+func runExporter(cmd *cobra.Command, args []string) error {
+	if err := validateExporterFlags(); err != nil {
+		return err
+	}
+
+	cwd, err := config.EnsureProjectDir()
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("project_not_configured"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("run_init_to_configure"),
+			Command:    "kk init",
+		})
+		return err
+	}
+
+	cli, err := monitor.NewDockerClient()
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("exporter_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("err_check_docker_running"),
+			Command:    ui.Msg("docker_start_command"),
+		})
+		return err
+	}
+	defer func() {
+		warnOnError(cli.Close())
+	}()
+
+	collector := newExporterCollector(cwd, cli)
+	server := &http.Server{
+		Handler:           collector.Handler(exporterTimeout),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	listener, err := net.Listen("tcp", exporterListen)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("exporter_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("exporter_listen_suggestion"),
+		})
+		return err
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		<-sigChan
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		warnOnError(server.Shutdown(ctx))
+	}()
+
+	ui.ShowInfo(ui.MsgF("exporter_serving", "http://"+listener.Addr().String()+"/metrics"))
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newExporterCollector reads the stack from projectDir on every scrape, so
+// the exporter follows kk init and kk update without a restart.
+func newExporterCollector(projectDir string, cli *client.Client) *exporter.Collector {
+	health := monitor.NewHealthMonitorWithClient(cli)
+	return &exporter.Collector{
+		Statuses: func(ctx context.Context) ([]monitor.ServiceStatus, error) {
+			composeFile, err := compose.ParseComposeFile(projectDir)
+			if err != nil {
+				return nil, err
+			}
+			statuses, err := monitor.GetStatusWithServices(ctx, compose.NewExecutor(projectDir), composeFile.GetServiceNames())
+			if err != nil {
+				return nil, err
+			}
+			monitor.EnrichStatuses(ctx, cli, statuses, time.Now())
+			return statuses, nil
+		},
+		Health: health.Check,
+		LastUpdate: func() (time.Time, bool, error) {
+			records, err := updater.ReadRecords(updateHistoryDir(), 0)
+			if err != nil {
+				return time.Time{}, false, err
+			}
+			r, ok := updater.LastSuccessful(records, projectDir)
+			return r.FinishedAt(), ok, nil
+		},
+		Cert: func(ctx context.Context) (exporter.Cert, error) {
+			composeFile, err := compose.ParseComposeFile(projectDir)
+			if err != nil {
+				return exporter.Cert{}, err
+			}
+			addr, ok := caddyTLSAddr(composeFile)
+			if !ok {
+				return exporter.Cert{}, exporter.ErrNoTLS
+			}
+			domain := config.ReadEnvValue(projectDir, "SYSTEM_DOMAIN")
+			if domain == "" {
+				return exporter.Cert{}, errors.New("SYSTEM_DOMAIN is not set in .env")
+			}
+			return exporter.FetchCert(ctx, addr, domain)
+		},
+		OnWriteError: func(err error) {
+			ui.ShowWarning(ui.MsgF("exporter_write_failed", ui.SanitizeError(err)))
+		},
+	}
+}
+
+// caddyTLSAddr returns the local address Caddy serves HTTPS on.
+func caddyTLSAddr(composeFile *compose.ComposeFile) (string, bool) {
+	for _, p := range composeFile.GetPublishedPorts("caddy") {
+		if p.ContainerPort != 443 {
+			continue
+		}
+		host := p.HostIP
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			host = "127.0.0.1"
+		}
+		return net.JoinHostPort(host, strconv.Itoa(p.HostPort)), true
+	}
+	return "", false
+}
+
+func runExporterInstall(cmd *cobra.Command, args []string) error {
+	if err := validateExporterFlags(); err != nil {
+		return err
+	}
+
+	ui.ShowCommandBanner(ui.Msg("cmd_exporter_install_title"), ui.Msg("exporter_install_desc"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	manager := scheduler.NewManager()
+
+	if exporterRemove {
+		spinner := ui.StartPtermSpinner(ui.Msg("exporter_removing"))
+		if err := manager.RemoveService(ctx, exporterUnit()); err != nil {
+			spinner.Fail(ui.Msg("exporter_install_failed"))
+			return showExporterInstallError(err)
+		}
+		spinner.Success(ui.Msg("exporter_removed"))
+		return nil
+	}
+
+	if _, err := config.EnsureProjectDir(); err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("project_not_configured"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("run_init_to_configure"),
+			Command:    "kk init",
+		})
+		return err
+	}
+
+	service, err := buildExporterService()
+	if err != nil {
+		return showExporterInstallError(err)
+	}
+
+	spinner := ui.StartPtermSpinner(ui.Msg("exporter_installing"))
+	if err := manager.InstallService(ctx, service); err != nil {
+		spinner.Fail(ui.Msg("exporter_install_failed"))
+		return showExporterInstallError(err)
+	}
+	spinner.Success(ui.MsgF("exporter_installed", exporterListen))
+	ui.ShowInfo(ui.MsgF("exporter_installed_hint", exporterUnit()))
+	return nil
+}
+
+// buildExporterService assembles the unit running kk exporter with the
+// current flags and project.
+func buildExporterService() (scheduler.Service, error) {
+	exe, err := kkExecutable()
+	if err != nil {
+		return scheduler.Service{}, err
+	}
+
+	command := []string{exe, "exporter", "--listen", exporterListen, "--timeout", exporterTimeout.String()}
+	if name := config.ProjectName(); name != "" {
+		command = append(command, "--project", name)
+	}
+
+	return scheduler.Service{
+		Name:        exporterUnit(),
+		Description: "kk Prometheus exporter",
+		Command:     command,
+		Env:         unattendedEnv(),
+	}, nil
+}
+
+func showExporterInstallError(err error) error {
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("exporter_install_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: ui.Msg("exporter_install_failed_suggestion"),
+	})
+	return err
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+)
+
+func TestCaddyTLSAddr(t *testing.T) {
+	tests := []struct {
+		ports []string
+		want  string
+		ok    bool
+	}{
+		{[]string{"80:80", "443:443"}, "127.0.0.1:443", true},
+		{[]string{"8080:80", "0.0.0.0:8443:443/tcp"}, "127.0.0.1:8443", true},
+		{[]string{"10.0.0.5:443:443"}, "10.0.0.5:443", true},
+		{[]string{"80:80"}, "", false},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		cf := &compose.ComposeFile{Services: map[string]compose.Service{}}
+		if tt.ports != nil {
+			cf.Services["caddy"] = compose.Service{Ports: tt.ports}
+		}
+		got, ok := caddyTLSAddr(cf)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("caddyTLSAddr(%v) = %q, %v; want %q, %v", tt.ports, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBuildExporterService(t *testing.T) {
+	exporterListen, exporterTimeout = ":9796", 20*time.Second
+	t.Cleanup(func() { exporterListen, exporterTimeout = "127.0.0.1:9796", 15*time.Second })
+	config.SelectProject("shop")
+	t.Cleanup(func() { config.SelectProject("") })
+
+	svc, err := buildExporterService()
+	if err != nil {
+		t.Fatalf("buildExporterService() error = %v", err)
+	}
+	if svc.Name != "kk-exporter@shop" {
+		t.Fatalf("Name = %q", svc.Name)
+	}
+	if !slices.Equal(svc.Command[1:], []string{"exporter", "--listen", ":9796", "--timeout", "20s", "--project", "shop"}) {
+		t.Fatalf("Command = %v", svc.Command)
+	}
+	if svc.Env["HOME"] == "" {
+		t.Fatalf("Env = %v", svc.Env)
+	}
+}
+
+func TestValidateExporterFlags(t *testing.T) {
+	t.Cleanup(func() { exporterListen, exporterTimeout = "127.0.0.1:9796", 15*time.Second })
+
+	exporterListen, exporterTimeout = ":9796", time.Second
+	if err := validateExporterFlags(); err != nil {
+		t.Fatalf("validateExporterFlags() error = %v", err)
+	}
+	exporterListen = "9796"
+	if err := validateExporterFlags(); ExitCode(err) != exitCodeInputValidation {
+		t.Fatalf("missing port separator: exit code = %d", ExitCode(err))
+	}
+	exporterListen, exporterTimeout = ":9796", 0
+	if err := validateExporterFlags(); ExitCode(err) != exitCodeInputValidation {
+		t.Fatalf("zero timeout: exit code = %d", ExitCode(err))
+	}
+}
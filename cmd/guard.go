@@ -0,0 +1,379 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/guard"
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/notify"
+	"github.com/kkauto-net/kk-install/pkg/scheduler"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+var guardCmd = &cobra.Command{
+	Use:   "guard",
+	Short: "Restart services that stay unhealthy",
+	Long: `Watch the healthchecks of the compose services and restart a service whose
+container reports "unhealthy". Docker's restart policies only act when a
+container exits, so a hung service otherwise stays down.
+
+Only the affected service is restarted. Restarts of the same service back off
+from --initial-delay, doubling up to --max-delay. After --budget restarts
+within --window, kk guard stops restarting that service until it recovers.
+Every action is appended to ~/.kk/guard-audit.log (see 'kk guard log') and
+sent to the configured notification targets.
+
+Use 'kk guard install' to run it as a systemd service that starts at boot.`,
+	Example: `  kk guard
+  kk guard --budget 3 --window 30m
+  sudo kk guard install
+  kk guard log -n 50`,
+	Annotations: map[string]string{"group": "management"},
+	Args:        cobra.NoArgs,
+	RunE:        runGuard,
+}
+
+var guardInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install kk guard as a systemd service",
+	Long: `Write a systemd unit that runs 'kk guard' at boot and restarts it when it
+exits, then start it. Requires root.`,
+	Example: `  sudo kk guard install --budget 3
+  sudo kk guard install --remove`,
+	Args: cobra.NoArgs,
+	RunE: runGuardInstall,
+}
+
+var guardLogCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Show the kk guard audit log",
+	Example: `  kk guard log
+  kk guard log -n 100 -o json`,
+	Args: cobra.NoArgs,
+	RunE: runGuardLog,
+}
+
+// guardUnitName is the base name of the systemd service running kk guard;
+// see guardUnit.
+const guardUnitName = "kk-guard"
+
+// guardUnit is the unit of the selected project's guard.
+func guardUnit() string {
+	return scheduler.ProjectUnitName(guardUnitName, config.ProjectName())
+}
+
+// guardRestartTimeout bounds one docker compose restart.
+const guardRestartTimeout = 2 * time.Minute
+
+var (
+	guardInterval time.Duration
+	guardPolicy   = guard.DefaultPolicy()
+	guardRemove   bool
+	guardLogLimit int
+)
+
+func init() {
+	defaults := guard.DefaultPolicy()
+	for _, c := range []*cobra.Command{guardCmd, guardInstallCmd} {
+		c.Flags().DurationVar(&guardInterval, "interval", 10*time.Second, "Time between health checks")
+		c.Flags().IntVar(&guardPolicy.Budget, "budget", defaults.Budget, "Restarts allowed per service within --window")
+		c.Flags().DurationVar(&guardPolicy.Window, "window", defaults.Window, "Period the restart budget applies to")
+		c.Flags().DurationVar(&guardPolicy.InitialDelay, "initial-delay", defaults.InitialDelay, "Wait after the first restart before restarting again")
+		c.Flags().DurationVar(&guardPolicy.MaxDelay, "max-delay", defaults.MaxDelay, "Longest wait between restarts of one service")
+	}
+	guardInstallCmd.Flags().BoolVar(&guardRemove, "remove", false, "Stop and remove the systemd service")
+	guardLogCmd.Flags().IntVarP(&guardLogLimit, "lines", "n", 20, "Number of entries to show (0 for all)")
+	guardCmd.AddCommand(guardInstallCmd, guardLogCmd)
+	rootCmd.AddCommand(guardCmd)
+}
+
+func guardAuditPath() string {
+	return filepath.Join(config.StateDir(), "guard-audit.log")
+}
+
+func validateGuardFlags() error {
+	switch {
+	case guardInterval <= 0:
+		return NewExitError(exitCodeInputValidation, fmt.Errorf("--interval must be positive"))
+	case guardPolicy.Budget < 1:
+		return NewExitError(exitCodeInputValidation, fmt.Errorf("--budget must be at least 1"))
+	case guardPolicy.Window <= 0:
+		return NewExitError(exitCodeInputValidation, fmt.Errorf("--window must be positive"))
+	case guardPolicy.InitialDelay <= 0:
+		return NewExitError(exitCodeInputValidation, fmt.Errorf("--initial-delay must be positive"))
+	case guardPolicy.MaxDelay < guardPolicy.InitialDelay:
+		return NewExitError(exitCodeInputValidation, fmt.Errorf("--max-delay must not be shorter than --initial-delay"))
+	}
+	return nil
+}
+
+func runGuard(cmd *cobra.Command, args []string) error {
+	if err := validateGuardFlags(); err != nil {
+		return err
+	}
+
+	cwd, err := config.EnsureProjectDir()
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("project_not_configured"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("run_init_to_configure"),
+			Command:    "kk init",
+		})
+		return err
+	}
+
+	composeFile, err := compose.ParseComposeFile(cwd)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("guard_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("run_init_to_configure"),
+			Command:    "kk init",
+		})
+		return err
+	}
+
+	cli, err := monitor.NewDockerClient()
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("guard_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("err_check_docker_running"),
+			Command:    ui.Msg("docker_start_command"),
+		})
+		return err
+	}
+	defer func() {
+		warnOnError(cli.Close())
+	}()
+
+	audit, err := guard.NewAuditLog(guardAuditPath())
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("guard_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("guard_audit_suggestion"),
+		})
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	// Notifications are delivered in the background so retries never delay
+	// the next health check; wait for pending ones before exiting.
+	var notifications sync.WaitGroup
+	defer notifications.Wait()
+
+	executor := compose.NewExecutor(cwd)
+	health := monitor.NewHealthMonitorWithClient(cli)
+	g := &guard.Guard{
+		Policy:   guardPolicy,
+		Services: guardServices(composeFile),
+		Check:    health.Check,
+		Restart: func(ctx context.Context, service string) error {
+			restartCtx, restartCancel := context.WithTimeout(ctx, guardRestartTimeout)
+			defer restartCancel()
+			return executor.RestartServices(restartCtx, service)
+		},
+		Record: func(e guard.Entry) {
+			if appendErr := audit.Append(e); appendErr != nil && !ui.IsStructuredOutput() {
+				ui.ShowWarning(ui.MsgF("guard_audit_failed", ui.SanitizeError(appendErr)))
+			}
+			if ui.IsStructuredOutput() {
+				if writeErr := ui.WriteStructuredRecord(os.Stdout, e); writeErr != nil {
+					ui.ShowWarning(ui.MsgF("guard_output_failed", ui.SanitizeError(writeErr)))
+				}
+			} else {
+				fmt.Println(formatGuardEntry(e))
+			}
+			if ev, ok := guardNotification(e); ok {
+				notifications.Add(1)
+				go func() {
+					defer notifications.Done()
+					notifyOrWarn(ev)
+				}()
+			}
+		},
+	}
+
+	if !ui.IsStructuredOutput() {
+		ui.ShowInfo(ui.MsgF("guard_watching", len(g.Services), audit.Path()))
+	}
+	g.Run(ctx, guardInterval)
+	return nil
+}
+
+// guardServices lists every compose service with its container name.
+func guardServices(composeFile *compose.ComposeFile) []guard.Service {
+	var services []guard.Service
+	for _, name := range composeFile.GetServiceNames() {
+		services = append(services, guard.Service{Name: name, Container: composeFile.GetServiceContainerName(name)})
+	}
+	return services
+}
+
+// guardNotification maps the actions worth paging someone about to a health
+// notification.
+func guardNotification(e guard.Entry) (notify.Event, bool) {
+	switch e.Action {
+	case guard.ActionRestart:
+		return notify.HealthEvent(e.Service, e.Status, fmt.Sprintf("restarted by kk guard (attempt %d)", e.Attempt)), true
+	case guard.ActionFailed:
+		return notify.HealthEvent(e.Service, e.Status, "kk guard restart failed: "+e.Message), true
+	case guard.ActionExhausted:
+		return notify.HealthEvent(e.Service, e.Status, "kk guard gave up: "+e.Message), true
+	case guard.ActionRecovered:
+		return notify.HealthEvent(e.Service, e.Status, "recovered after kk guard restarts"), true
+	}
+	return notify.Event{}, false
+}
+
+// formatGuardEntry renders one audit entry as a log line.
+func formatGuardEntry(e guard.Entry) string {
+	line := e.Time.Local().Format("2006-01-02 15:04:05") + "  " + e.Action
+	if e.Service != "" {
+		line += "  " + e.Service
+	}
+	if e.Attempt > 0 {
+		line += " #" + strconv.Itoa(e.Attempt)
+	}
+	if e.Message != "" {
+		line += "  " + e.Message
+	}
+	switch e.Action {
+	case guard.ActionUnhealthy, guard.ActionFailed, guard.ActionExhausted:
+		return pterm.Red(line)
+	case guard.ActionRestart:
+		return pterm.Yellow(line)
+	case guard.ActionRecovered:
+		return pterm.Green(line)
+	}
+	return line
+}
+
+func runGuardLog(cmd *cobra.Command, args []string) error {
+	entries, err := guard.ReadAudit(guardAuditPath(), guardLogLimit)
+	if err != nil {
+		return err
+	}
+	if ui.IsStructuredOutput() {
+		if entries == nil {
+			entries = []guard.Entry{}
+		}
+		return ui.WriteStructured(os.Stdout, entries)
+	}
+	if len(entries) == 0 {
+		ui.ShowInfo(ui.Msg("guard_log_empty"))
+		return nil
+	}
+	for _, e := range entries {
+		fmt.Println(formatGuardEntry(e))
+	}
+	return nil
+}
+
+func runGuardInstall(cmd *cobra.Command, args []string) error {
+	if err := validateGuardFlags(); err != nil {
+		return err
+	}
+
+	ui.ShowCommandBanner(ui.Msg("cmd_guard_install_title"), ui.Msg("guard_install_desc"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	manager := scheduler.NewManager()
+
+	if guardRemove {
+		spinner := ui.StartPtermSpinner(ui.Msg("guard_removing"))
+		if err := manager.RemoveService(ctx, guardUnit()); err != nil {
+			spinner.Fail(ui.Msg("guard_install_failed"))
+			return showGuardInstallError(err)
+		}
+		spinner.Success(ui.Msg("guard_removed"))
+		return nil
+	}
+
+	if _, err := config.EnsureProjectDir(); err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("project_not_configured"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("run_init_to_configure"),
+			Command:    "kk init",
+		})
+		return err
+	}
+
+	service, err := buildGuardService()
+	if err != nil {
+		return showGuardInstallError(err)
+	}
+
+	spinner := ui.StartPtermSpinner(ui.Msg("guard_installing"))
+	if err := manager.InstallService(ctx, service); err != nil {
+		spinner.Fail(ui.Msg("guard_install_failed"))
+		return showGuardInstallError(err)
+	}
+	spinner.Success(ui.Msg("guard_installed"))
+	ui.ShowInfo(ui.MsgF("guard_installed_hint", guardUnit()))
+	return nil
+}
+
+// buildGuardService assembles the unit running kk guard with the current
+// flags and project.
+func buildGuardService() (scheduler.Service, error) {
+	exe, err := kkExecutable()
+	if err != nil {
+		return scheduler.Service{}, err
+	}
+
+	command := []string{
+		exe, "guard",
+		"--interval", guardInterval.String(),
+		"--budget", strconv.Itoa(guardPolicy.Budget),
+		"--window", guardPolicy.Window.String(),
+		"--initial-delay", guardPolicy.InitialDelay.String(),
+		"--max-delay", guardPolicy.MaxDelay.String(),
+	}
+	if name := config.ProjectName(); name != "" {
+		command = append(command, "--project", name)
+	}
+
+	return scheduler.Service{
+		Name:        guardUnit(),
+		Description: "kk guard: restart unhealthy services",
+		Command:     command,
+		Env:         unattendedEnv(),
+	}, nil
+}
+
+func showGuardInstallError(err error) error {
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("guard_install_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: ui.Msg("guard_install_failed_suggestion"),
+	})
+	return err
+}
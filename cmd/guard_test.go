@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/guard"
+)
+
+func resetGuardFlags(t *testing.T) {
+	t.Cleanup(func() {
+		guardInterval = 10 * time.Second
+		guardPolicy = guard.DefaultPolicy()
+	})
+	guardInterval = 10 * time.Second
+	guardPolicy = guard.DefaultPolicy()
+}
+
+func TestValidateGuardFlags(t *testing.T) {
+	resetGuardFlags(t)
+	if err := validateGuardFlags(); err != nil {
+		t.Fatalf("defaults: validateGuardFlags() error = %v", err)
+	}
+
+	invalid := map[string]func(){
+		"zero interval":      func() { guardInterval = 0 },
+		"zero budget":        func() { guardPolicy.Budget = 0 },
+		"zero window":        func() { guardPolicy.Window = 0 },
+		"zero initial delay": func() { guardPolicy.InitialDelay = 0 },
+		"max below initial":  func() { guardPolicy.InitialDelay, guardPolicy.MaxDelay = time.Minute, time.Second },
+	}
+	for name, set := range invalid {
+		guardInterval = 10 * time.Second
+		guardPolicy = guard.DefaultPolicy()
+		set()
+		if err := validateGuardFlags(); ExitCode(err) != exitCodeInputValidation {
+			t.Errorf("%s: exit code = %d", name, ExitCode(err))
+		}
+	}
+}
+
+func TestBuildGuardService(t *testing.T) {
+	resetGuardFlags(t)
+	guardInterval = 30 * time.Second
+	guardPolicy = guard.Policy{InitialDelay: 5 * time.Second, MaxDelay: time.Minute, Budget: 3, Window: 30 * time.Minute}
+	config.SelectProject("shop")
+	t.Cleanup(func() { config.SelectProject("") })
+
+	svc, err := buildGuardService()
+	if err != nil {
+		t.Fatalf("buildGuardService() error = %v", err)
+	}
+	if svc.Name != "kk-guard@shop" {
+		t.Fatalf("Name = %q", svc.Name)
+	}
+	want := []string{"guard", "--interval", "30s", "--budget", "3", "--window", "30m0s", "--initial-delay", "5s", "--max-delay", "1m0s", "--project", "shop"}
+	if !slices.Equal(svc.Command[1:], want) {
+		t.Fatalf("Command = %v", svc.Command)
+	}
+}
+
+func TestGuardServices(t *testing.T) {
+	cf := &compose.ComposeFile{Services: map[string]compose.Service{
+		"db":       {ContainerName: "kkengine_db"},
+		"kkengine": {},
+	}}
+	got := guardServices(cf)
+	if len(got) != 2 {
+		t.Fatalf("guardServices() = %v", got)
+	}
+	for _, s := range got {
+		if s.Container != cf.GetServiceContainerName(s.Name) {
+			t.Errorf("%s: Container = %q", s.Name, s.Container)
+		}
+	}
+}
+
+func TestGuardNotification(t *testing.T) {
+	ev, ok := guardNotification(guard.Entry{Action: guard.ActionRestart, Service: "db", Status: "unhealthy", Attempt: 2})
+	if !ok || ev.Service != "db" || !strings.Contains(ev.Text, "attempt 2") {
+		t.Fatalf("restart: %+v, %v", ev, ok)
+	}
+	ev, ok = guardNotification(guard.Entry{Action: guard.ActionExhausted, Service: "db", Status: "unhealthy", Message: "5 restarts within 1h0m0s"})
+	if !ok || !strings.Contains(ev.Text, "gave up") {
+		t.Fatalf("exhausted: %+v, %v", ev, ok)
+	}
+	for _, action := range []string{guard.ActionStarted, guard.ActionStopped, guard.ActionUnhealthy} {
+		if _, ok := guardNotification(guard.Entry{Action: action, Service: "db"}); ok {
+			t.Errorf("%s should not notify", action)
+		}
+	}
+}
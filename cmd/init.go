@@ -15,10 +15,14 @@ import (
 	"github.com/charmbracelet/huh"
 	"github.com/spf13/cobra"
 
+	"github.com/kkauto-net/kk-install/pkg/backup"
 	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/engine"
 	"github.com/kkauto-net/kk-install/pkg/license"
+	"github.com/kkauto-net/kk-install/pkg/spec"
 	"github.com/kkauto-net/kk-install/pkg/templates"
 	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/updater"
 	"github.com/kkauto-net/kk-install/pkg/validator"
 )
 
@@ -39,6 +43,10 @@ var (
 	initLicenseStdin        bool
 	initDomain              string
 	initLanguage            string
+	initStackPrefix         string
+	initPorts               templates.HostPorts
+	initDBExposure          string
+	initRuntime             string
 	DockerValidatorInstance *validator.DockerValidator
 	newLicenseClient        = license.NewClient
 	renderTemplates         = templates.RenderAll
@@ -63,6 +71,13 @@ func init() {
 	initCmd.Flags().BoolVar(&initLicenseStdin, "license-stdin", false, "Read license key from stdin for unattended init")
 	initCmd.Flags().StringVar(&initDomain, "domain", "", "Domain for unattended init")
 	initCmd.Flags().StringVar(&initLanguage, "language", "", "Language for unattended init (en or vi)")
+	initCmd.Flags().StringVar(&initStackPrefix, "stack-prefix", "", "Container/network name prefix (default: project name or kkengine)")
+	initCmd.Flags().IntVar(&initPorts.App, "app-port", 0, "Host port for the kkengine API (default 8019)")
+	initCmd.Flags().IntVar(&initPorts.DB, "db-port", 0, "Host port for MariaDB when --db-exposure is localhost or public (default 3306)")
+	initCmd.Flags().IntVar(&initPorts.HTTP, "http-port", 0, "Host port for Caddy HTTP (default 80)")
+	initCmd.Flags().IntVar(&initPorts.HTTPS, "https-port", 0, "Host port for Caddy HTTPS (default 443)")
+	initCmd.Flags().StringVar(&initDBExposure, "db-exposure", "", "Publish MariaDB on the host: internal (default), localhost or public")
+	initCmd.Flags().StringVar(&initRuntime, "runtime", "", "Container runtime: docker, podman or auto (default: the saved or detected runtime)")
 	DockerValidatorInstance = validator.NewDockerValidator()
 }
 
@@ -169,6 +184,10 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 	// Step 1: Check Docker
 	ui.ShowStepHeader(2, 7, ui.Msg("step_docker_check"))
+	containerRuntime, err := selectInitRuntime(opts)
+	if err != nil {
+		return err
+	}
 	ui.ShowInfo(ui.IconDocker + " " + ui.MsgCheckingDocker())
 
 	if err = ensureInitDocker(opts, licenseData.Key, licenseData.PublicKey); err != nil {
@@ -214,6 +233,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 	} else {
 		cfg.Language = langChoice
 	}
+	cfg.Runtime = string(containerRuntime)
 	if saveErr := cfg.Save(); saveErr != nil {
 		ui.ShowWarning(fmt.Sprintf("Cannot save config: %v", saveErr))
 	}
@@ -246,7 +266,7 @@ func runInit(cmd *cobra.Command, args []string) error {
 
 		// Backup existing config files before overwrite
 		if err := backupExistingConfigs(cwd); err != nil {
-			ui.ShowWarning(fmt.Sprintf("Cannot backup existing files: %v", err))
+			ui.ShowWarning(ui.MsgF("config_backup_failed", err))
 		}
 	}
 
@@ -279,6 +299,27 @@ func runInit(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Stack naming and ports: flags, then the existing compose file, then defaults
+	stackPrefix, hostPorts := existingStackLayout(cwd)
+	if opts.StackPrefix != "" {
+		stackPrefix = opts.StackPrefix
+	}
+	hostPorts = mergeHostPorts(opts.Ports, hostPorts)
+	dbExposure := existingDBExposure(cwd)
+	if opts.DBExposure != "" {
+		dbExposure = opts.DBExposure
+	}
+	if !opts.NonInteractive && !opts.Force {
+		if err := promptStackLayout(&stackPrefix, &hostPorts, &dbExposure, enableCaddy); err != nil {
+			return err
+		}
+	}
+	if err := hostPorts.Validate(dbExposure, enableCaddy); err != nil {
+		err = NewExitError(exitCodeInputValidation, err)
+		showInitInputError(err)
+		return err
+	}
+
 	// Step 4: Domain Configuration
 	ui.ShowStepHeader(5, 7, ui.Msg("step_domain"))
 	// Pre-fill domain from existing env or use localhost
@@ -456,9 +497,6 @@ func runInit(cmd *cobra.Command, args []string) error {
 	// Step 6: Generate Files + Complete
 	ui.ShowStepHeader(7, 7, ui.Msg("step_generate"))
 
-	// Render templates with spinner
-	spinner = startInitSpinner(ui.IconWrite + " " + ui.Msg("generating_files"))
-
 	tmplCfg := templates.Config{
 		EnableSeaweedFS: enableSeaweedFS,
 		EnableCaddy:     enableCaddy,
@@ -472,18 +510,56 @@ func runInit(cmd *cobra.Command, args []string) error {
 		RedisPassword:   redisPass,
 		S3AccessKey:     s3AccessKey,
 		S3SecretKey:     s3SecretKey,
+		StackPrefix:     stackPrefix,
+		Ports:           hostPorts,
+		DBExposure:      dbExposure,
+		Podman:          containerRuntime == engine.RuntimePodman,
+		OverrideDir:     filepath.Join(cwd, templates.OverridesDirName),
+	}
+
+	// Keep images pinned to the digests recorded by kk update
+	if lock, lockErr := updater.LoadLock(cwd); lockErr != nil {
+		ui.ShowWarning(fmt.Sprintf("Cannot read %s: %v", updater.LockFileName, lockErr))
+	} else if lock != nil {
+		tmplCfg.ImagePins = lock.Digests()
+	}
+
+	// Render into a temporary directory first, so files edited by hand
+	// since kk wrote them can be merged instead of overwritten.
+	renderDir, err := os.MkdirTemp("", "kk-init-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		warnOnError(os.RemoveAll(renderDir))
+	}()
+	if err = renderTemplates(tmplCfg, renderDir); err != nil {
+		ui.ShowError(fmt.Sprintf("%s: %v", ui.Msg("error_create_file"), err))
+		return NewExitError(exitCodeRenderFailure, fmt.Errorf("%s: %w", ui.Msg("error_create_file"), err))
+	}
+	changes, err := spec.Plan(renderDir, cwd)
+	if err != nil {
+		ui.ShowError(fmt.Sprintf("%s: %v", ui.Msg("error_create_file"), err))
+		return NewExitError(exitCodeRenderFailure, fmt.Errorf("%s: %w", ui.Msg("error_create_file"), err))
+	}
+	interactive := !opts.NonInteractive && !opts.Force && validator.IsInteractiveTTY()
+	if err = resolveLocalEdits(changes, interactive); err != nil {
+		return err
 	}
 
-	if err := renderTemplates(tmplCfg, cwd); err != nil {
+	spinner = startInitSpinner(ui.IconWrite + " " + ui.Msg("generating_files"))
+	if err = spec.Apply(changes, renderDir, cwd); err != nil {
 		spinner.Fail(fmt.Sprintf("%s: %v", ui.Msg("error_create_file"), err))
 		return NewExitError(exitCodeRenderFailure, fmt.Errorf("%s: %w", ui.Msg("error_create_file"), err))
 	}
 
 	spinner.Success(ui.IconCheck + " " + ui.Msg("files_generated"))
+	showKeptLocalEdits(changes)
 
 	// Save project directory to config
-	cfg.ProjectDir = cwd
-	if saveErr := cfg.Save(); saveErr != nil {
+	if err := cfg.SetInitializedDir(cwd); err != nil {
+		ui.ShowWarning(fmt.Sprintf("Cannot save config: %v", err))
+	} else if saveErr := cfg.Save(); saveErr != nil {
 		ui.ShowWarning(fmt.Sprintf("Cannot save config: %v", saveErr))
 	}
 
@@ -520,21 +596,13 @@ func showInitInputError(err error) {
 
 // backupExistingConfigs creates a timestamped backup folder and copies existing config files into it
 func backupExistingConfigs(dir string) error {
-	configFiles := []string{
-		"docker-compose.yml",
-		".env",
-		"Caddyfile",
-		"kkfiler.toml",
-		"kkphp.conf",
-	}
-
 	timestamp := time.Now().Format("20060102150405")
 	backupDirName := "backup-" + timestamp
 	backupDir := filepath.Join(dir, backupDirName)
 
 	// First pass: check which files exist
 	var toBackup []string
-	for _, filename := range configFiles {
+	for _, filename := range backup.ConfigFiles {
 		srcPath := filepath.Join(dir, filename)
 		if _, err := os.Stat(srcPath); err == nil {
 			toBackup = append(toBackup, filename)
@@ -620,15 +688,68 @@ func generateS3AccessKeyWithRetry(length int) (string, error) {
 	return "", lastErr
 }
 
+// selectInitRuntime picks the container runtime: --runtime, else a prompt
+// defaulting to the saved or detected one. Unattended and forced runs use
+// that default.
+func selectInitRuntime(opts initOptions) (engine.Runtime, error) {
+	choice, err := engine.ParseRuntime(opts.Runtime)
+	if err != nil {
+		return "", err
+	}
+	if choice == "" {
+		choice = engine.CurrentRuntime()
+		if !opts.NonInteractive && !opts.Force {
+			runtimeForm := huh.NewForm(
+				huh.NewGroup(
+					huh.NewSelect[engine.Runtime]().
+						Title(ui.IconDocker+" "+ui.Msg("select_runtime")).
+						Description(ui.Msg("select_runtime_desc")).
+						Options(
+							huh.NewOption(ui.Msg("runtime_docker"), engine.RuntimeDocker),
+							huh.NewOption(ui.Msg("runtime_podman"), engine.RuntimePodman),
+						).
+						Value(&choice),
+				),
+			)
+			if formErr := runtimeForm.Run(); formErr != nil {
+				return "", formErr
+			}
+		}
+	}
+	if err = engine.SetRuntime(choice); err != nil {
+		return "", err
+	}
+	return choice, nil
+}
+
+// runtimeMsg returns the Podman variant of a Docker message when Podman is
+// the runtime.
+func runtimeMsg(dockerKey, podmanKey string) string {
+	if engine.CurrentRuntime() == engine.RuntimePodman {
+		return ui.Msg(podmanKey)
+	}
+	return ui.Msg(dockerKey)
+}
+
 func ensureInitDocker(opts initOptions, licenseKey, licensePublicKey string) error {
 	if opts.Force {
-		if err := DockerValidatorInstance.CheckDockerInstalled(); err != nil {
-			ui.ShowWarning(ui.Msg("docker_not_installed_force_init"))
-		} else if err := DockerValidatorInstance.CheckDockerDaemon(); err != nil {
-			ui.ShowWarning(ui.Msg("docker_daemon_not_running_force_init"))
-		} else if err := DockerValidatorInstance.CheckComposeVersion(); err != nil {
-			ui.ShowWarning(ui.Msg("docker_compose_issue_force_init"))
+		var checkErr error
+		var warning string
+		if checkErr = DockerValidatorInstance.CheckDockerInstalled(); checkErr != nil {
+			warning = ui.Msg("docker_not_installed_force_init")
+		} else if checkErr = DockerValidatorInstance.CheckDockerDaemon(); checkErr != nil {
+			warning = ui.Msg("docker_daemon_not_running_force_init")
+		} else if checkErr = DockerValidatorInstance.CheckComposeVersion(); checkErr != nil {
+			warning = ui.Msg("docker_compose_issue_force_init")
 		}
+		results := validator.DockerPreflight(checkErr)
+		if warning != "" {
+			ui.ShowWarning(warning)
+			// --force goes on, so the failed check is only a warning.
+			last := &results[len(results)-1]
+			last.Passed, last.Error, last.Warning = true, nil, warning
+		}
+		validator.EmitPreflightResults(results)
 		return nil
 	}
 
@@ -641,13 +762,13 @@ func ensureInitDocker(opts initOptions, licenseKey, licensePublicKey string) err
 
 	if !opts.NonInteractive {
 		ensureOpts.ConfirmInstall = func() (bool, error) {
-			ui.ShowInfo(ui.IconDocker + " " + ui.Msg("docker_not_installed"))
+			ui.ShowInfo(ui.IconDocker + " " + runtimeMsg("docker_not_installed", "podman_not_installed"))
 			var installDocker bool
 			installForm := huh.NewForm(
 				huh.NewGroup(
 					huh.NewConfirm().
-						Title(ui.IconDocker + " " + ui.Msg("ask_install_docker")).
-						Description(ui.Msg("ask_install_docker_desc")).
+						Title(ui.IconDocker + " " + runtimeMsg("ask_install_docker", "ask_install_podman")).
+						Description(runtimeMsg("ask_install_docker_desc", "ask_install_podman_desc")).
 						Affirmative(ui.Msg("yes_install")).
 						Negative(ui.Msg("no_manual")).
 						Value(&installDocker),
@@ -659,12 +780,12 @@ func ensureInitDocker(opts initOptions, licenseKey, licensePublicKey string) err
 			return installDocker, nil
 		}
 		ensureOpts.ConfirmStart = func() (bool, error) {
-			ui.ShowInfo(ui.IconDocker + " " + ui.Msg("docker_not_running"))
+			ui.ShowInfo(ui.IconDocker + " " + runtimeMsg("docker_not_running", "podman_socket_stopped"))
 			var startDocker bool
 			startForm := huh.NewForm(
 				huh.NewGroup(
 					huh.NewConfirm().
-						Title(ui.IconDocker + " " + ui.Msg("ask_start_docker")).
+						Title(ui.IconDocker + " " + runtimeMsg("ask_start_docker", "ask_start_podman")).
 						Affirmative(ui.Msg("yes")).
 						Negative(ui.Msg("no")).
 						Value(&startDocker),
@@ -676,7 +797,7 @@ func ensureInitDocker(opts initOptions, licenseKey, licensePublicKey string) err
 			return startDocker, nil
 		}
 		ensureOpts.Install = func() error {
-			ui.ShowInfo(ui.IconDocker + " " + ui.Msg("installing_docker"))
+			ui.ShowInfo(ui.IconDocker + " " + runtimeMsg("installing_docker", "installing_podman"))
 			ui.ShowNote(ui.Msg("docker_install_in_progress_note"))
 			if validator.IsInteractiveTTY() {
 				ui.ShowNote(ui.Msg("docker_sudo_password_hint"))
@@ -686,32 +807,35 @@ func ensureInitDocker(opts initOptions, licenseKey, licensePublicKey string) err
 				return err
 			}
 			if DockerValidatorInstance.CheckDockerDaemon() == nil {
-				ui.ShowSuccess(ui.IconCheck + " " + ui.Msg("docker_installed"))
+				ui.ShowSuccess(ui.IconCheck + " " + runtimeMsg("docker_installed", "podman_installed"))
 			} else if DockerValidatorInstance.IsDockerDaemonRunningPrivileged() {
 				ui.ShowSuccess(ui.IconCheck + " " + ui.Msg("docker_installed_daemon_running"))
 				ui.ShowNote(ui.Msg("docker_group_activate_note"))
 			} else {
-				ui.ShowSuccess(ui.IconCheck + " " + ui.Msg("docker_installed"))
+				ui.ShowSuccess(ui.IconCheck + " " + runtimeMsg("docker_installed", "podman_installed"))
 			}
 			return nil
 		}
 		ensureOpts.Start = func() error {
-			ui.ShowInfo(ui.IconDocker + " " + ui.Msg("starting_docker"))
+			ui.ShowInfo(ui.IconDocker + " " + runtimeMsg("starting_docker", "starting_podman"))
 			err := DockerValidatorInstance.StartDockerDaemon()
 			if err != nil {
 				return err
 			}
-			ui.ShowSuccess(ui.IconCheck + " " + ui.Msg("docker_started"))
+			ui.ShowSuccess(ui.IconCheck + " " + runtimeMsg("docker_started", "podman_started"))
 			return nil
 		}
 	}
 
 	err := DockerValidatorInstance.EnsureDockerReady(ensureOpts)
 	if err == nil {
+		validator.EmitPreflightResults(validator.DockerPreflight(nil))
 		return nil
 	}
 
+	// A successful re-exec exits here; the new process reports its own checks.
 	if reexecErr := tryReexecInitWithDockerGroup(err, licenseKey, licensePublicKey); reexecErr != nil {
+		validator.EmitPreflightResults(validator.DockerPreflight(reexecErr))
 		return formatInitDockerError(opts, reexecErr)
 	}
 
@@ -781,6 +905,16 @@ func formatInitDockerError(opts initOptions, err error) error {
 			Command:    command,
 		})
 		return err
+	case strings.HasPrefix(key, "podman_"):
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("podman_not_ready"),
+			Message:    validator.FormatUserErrorForBox(err),
+			Suggestion: validator.UserErrorSuggestion(err),
+		})
+		if opts.NonInteractive {
+			return NewExitError(exitCodeDockerValidation, err)
+		}
+		return err
 	case key == "compose_not_found", key == "compose_version_old":
 		ui.ShowBoxedError(ui.ErrorSuggestion{
 			Title:      ui.Msg("docker_compose_issue"),
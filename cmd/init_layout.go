@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/templates"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+// existingStackLayout reads the stack prefix and host ports from a previously
+// generated compose file in dir. Missing values come back as defaults: the
+// selected project name (or kkengine) and DefaultHostPorts.
+func existingStackLayout(dir string) (string, templates.HostPorts) {
+	prefix := defaultStackPrefix()
+	ports := templates.DefaultHostPorts
+
+	composeFile, err := compose.ParseComposeFile(dir)
+	if err != nil {
+		return prefix, ports
+	}
+	if app, ok := composeFile.Services["kkengine"]; ok {
+		if p, found := strings.CutSuffix(app.ContainerName, "_app"); found && templates.ValidateStackPrefix(p) == nil {
+			prefix = p
+		}
+	}
+
+	for service, targets := range map[string][]struct {
+		containerPort int
+		hostPort      *int
+	}{
+		"kkengine": {{8019, &ports.App}},
+		"db":       {{3306, &ports.DB}},
+		"caddy":    {{80, &ports.HTTP}, {443, &ports.HTTPS}},
+	} {
+		for _, published := range composeFile.GetPublishedPorts(service) {
+			for _, target := range targets {
+				if published.ContainerPort == target.containerPort {
+					*target.hostPort = published.HostPort
+				}
+			}
+		}
+	}
+	return prefix, ports
+}
+
+// existingDBExposure infers the MariaDB exposure of a previously generated
+// compose file. A loopback binding is kept; anything else falls back to
+// internal, so re-running init closes a database published on all interfaces
+// unless public is chosen again explicitly.
+func existingDBExposure(dir string) string {
+	composeFile, err := compose.ParseComposeFile(dir)
+	if err != nil {
+		return templates.DBExposureInternal
+	}
+	for _, published := range composeFile.GetPublishedPorts("db") {
+		if published.ContainerPort == 3306 && published.HostIP == "127.0.0.1" {
+			return templates.DBExposureLocalhost
+		}
+	}
+	return templates.DBExposureInternal
+}
+
+// existingServices reports which optional services a previously generated
+// compose file in dir runs. Without one, both are on as in a new install.
+func existingServices(dir string) (seaweedFS, caddy bool) {
+	composeFile, err := compose.ParseComposeFile(dir)
+	if err != nil {
+		return true, true
+	}
+	_, seaweedFS = composeFile.Services["seaweedfs"]
+	_, caddy = composeFile.Services["caddy"]
+	return seaweedFS, caddy
+}
+
+// existingResources reads the cpus and mem_limit of each service from a
+// previously generated compose file in dir.
+func existingResources(dir string) map[string]templates.Resources {
+	composeFile, err := compose.ParseComposeFile(dir)
+	if err != nil {
+		return nil
+	}
+	var resources map[string]templates.Resources
+	for name, service := range composeFile.Services {
+		if service.CPUs == "" && service.MemLimit == "" {
+			continue
+		}
+		if resources == nil {
+			resources = make(map[string]templates.Resources)
+		}
+		resources[name] = templates.Resources{CPUs: service.CPUs, Memory: service.MemLimit}
+	}
+	return resources
+}
+
+// defaultStackPrefix names a new stack after the selected project so two
+// projects get distinct container names out of the box.
+func defaultStackPrefix() string {
+	if name := config.SelectedProjectName(); name != "" {
+		return name
+	}
+	return templates.DefaultStackPrefix
+}
+
+// mergeHostPorts returns flags with zero ports taken from fallback.
+func mergeHostPorts(flags, fallback templates.HostPorts) templates.HostPorts {
+	if flags.App == 0 {
+		flags.App = fallback.App
+	}
+	if flags.DB == 0 {
+		flags.DB = fallback.DB
+	}
+	if flags.HTTP == 0 {
+		flags.HTTP = fallback.HTTP
+	}
+	if flags.HTTPS == 0 {
+		flags.HTTPS = fallback.HTTPS
+	}
+	return flags
+}
+
+// promptStackLayout asks for the stack prefix, the published host ports and
+// how MariaDB is exposed.
+func promptStackLayout(prefix *string, ports *templates.HostPorts, dbExposure *string, enableCaddy bool) error {
+	appPort := strconv.Itoa(ports.App)
+	dbPort := strconv.Itoa(ports.DB)
+	httpPort := strconv.Itoa(ports.HTTP)
+	httpsPort := strconv.Itoa(ports.HTTPS)
+
+	fields := []huh.Field{
+		huh.NewInput().
+			Title(ui.Msg("enter_stack_prefix")).
+			Description(ui.Msg("stack_prefix_desc")).
+			Value(prefix).
+			Validate(templates.ValidateStackPrefix),
+		huh.NewInput().Title(ui.Msg("enter_app_port")).Value(&appPort).Validate(validatePortInput),
+		huh.NewSelect[string]().
+			Title(ui.Msg("select_db_exposure")).
+			Description(ui.Msg("db_exposure_desc")).
+			Options(
+				huh.NewOption(ui.Msg("db_exposure_internal"), templates.DBExposureInternal),
+				huh.NewOption(ui.Msg("db_exposure_localhost"), templates.DBExposureLocalhost),
+				huh.NewOption(ui.Msg("db_exposure_public"), templates.DBExposurePublic),
+			).
+			Value(dbExposure),
+		huh.NewInput().Title(ui.Msg("enter_db_port")).Value(&dbPort).Validate(validatePortInput),
+	}
+	if enableCaddy {
+		fields = append(fields,
+			huh.NewInput().Title(ui.Msg("enter_http_port")).Value(&httpPort).Validate(validatePortInput),
+			huh.NewInput().Title(ui.Msg("enter_https_port")).Value(&httpsPort).Validate(validatePortInput),
+		)
+	}
+	if err := huh.NewForm(huh.NewGroup(fields...).Title(ui.Msg("group_stack_layout"))).Run(); err != nil {
+		return err
+	}
+
+	for _, field := range []struct {
+		input string
+		port  *int
+	}{
+		{appPort, &ports.App},
+		{dbPort, &ports.DB},
+		{httpPort, &ports.HTTP},
+		{httpsPort, &ports.HTTPS},
+	} {
+		port, err := parsePortInput(field.input)
+		if err != nil {
+			return err
+		}
+		*field.port = port
+	}
+	return nil
+}
+
+func validatePortInput(s string) error {
+	_, err := parsePortInput(s)
+	return err
+}
+
+func parsePortInput(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, errors.New(ui.Msg("invalid_port"))
+	}
+	return port, nil
+}
@@ -7,7 +7,9 @@ import (
 	"os"
 	"strings"
 
+	"github.com/kkauto-net/kk-install/pkg/engine"
 	"github.com/kkauto-net/kk-install/pkg/license"
+	"github.com/kkauto-net/kk-install/pkg/templates"
 )
 
 const maxInitLicenseSourceBytes = 4096
@@ -21,6 +23,10 @@ type initOptions struct {
 	LicenseStdin   bool
 	Domain         string
 	Language       string
+	StackPrefix    string
+	Ports          templates.HostPorts
+	DBExposure     string
+	Runtime        string
 }
 
 func collectInitOptions() initOptions {
@@ -33,6 +39,10 @@ func collectInitOptions() initOptions {
 		LicenseStdin:   initLicenseStdin,
 		Domain:         strings.TrimSpace(initDomain),
 		Language:       strings.TrimSpace(initLanguage),
+		StackPrefix:    strings.TrimSpace(initStackPrefix),
+		Ports:          initPorts,
+		DBExposure:     strings.TrimSpace(initDBExposure),
+		Runtime:        strings.TrimSpace(initRuntime),
 	}
 }
 
@@ -129,6 +139,25 @@ func normalizeInitLicenseSource(source, value string) (string, error) {
 }
 
 func validateInitOptions(opts initOptions) error {
+	if opts.StackPrefix != "" {
+		if err := templates.ValidateStackPrefix(opts.StackPrefix); err != nil {
+			return NewExitError(exitCodeInputValidation, fmt.Errorf("--stack-prefix is invalid: %w", err))
+		}
+	}
+	if opts.DBExposure != "" {
+		if _, err := templates.ParseDBExposure(opts.DBExposure); err != nil {
+			return NewExitError(exitCodeInputValidation, fmt.Errorf("--db-exposure is invalid: %w", err))
+		}
+	}
+	// Caddy may still be turned off at the prompt; its ports are checked then.
+	if err := opts.Ports.WithDefaults().Validate(opts.DBExposure, false); err != nil {
+		return NewExitError(exitCodeInputValidation, fmt.Errorf("port flags are invalid: %w", err))
+	}
+
+	if _, err := engine.ParseRuntime(opts.Runtime); err != nil {
+		return NewExitError(exitCodeInputValidation, fmt.Errorf("--runtime is invalid: %w", err))
+	}
+
 	if !opts.NonInteractive {
 		return nil
 	}
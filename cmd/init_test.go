@@ -12,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/kkauto-net/kk-install/pkg/engine"
 	"github.com/kkauto-net/kk-install/pkg/license"
 	"github.com/kkauto-net/kk-install/pkg/templates"
 	"github.com/kkauto-net/kk-install/pkg/validator"
@@ -582,3 +583,80 @@ type roundTripFunc func(*http.Request) (*http.Response, error)
 func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
 	return f(req)
 }
+
+func TestValidateInitOptionsStackLayout(t *testing.T) {
+	if err := validateInitOptions(initOptions{StackPrefix: "-bad"}); ExitCode(err) != exitCodeInputValidation {
+		t.Fatalf("invalid --stack-prefix: ExitCode() = %d", ExitCode(err))
+	}
+	if err := validateInitOptions(initOptions{Ports: templates.HostPorts{DB: 8019}, DBExposure: templates.DBExposureLocalhost}); ExitCode(err) != exitCodeInputValidation {
+		t.Fatalf("--db-port clashing with the default app port: ExitCode() = %d", ExitCode(err))
+	}
+	if err := validateInitOptions(initOptions{Ports: templates.HostPorts{App: 3306}}); err != nil {
+		t.Fatalf("--app-port 3306 with an internal database: %v", err)
+	}
+	if err := validateInitOptions(initOptions{DBExposure: "everywhere"}); ExitCode(err) != exitCodeInputValidation {
+		t.Fatalf("invalid --db-exposure: ExitCode() = %d", ExitCode(err))
+	}
+	if err := validateInitOptions(initOptions{StackPrefix: "staging", Ports: templates.HostPorts{App: 9019, DB: 13306}, DBExposure: templates.DBExposureLocalhost}); err != nil {
+		t.Fatalf("validateInitOptions() error = %v", err)
+	}
+}
+
+func TestExistingStackLayout(t *testing.T) {
+	dir := t.TempDir()
+
+	prefix, ports := existingStackLayout(dir)
+	if prefix != templates.DefaultStackPrefix || ports != templates.DefaultHostPorts {
+		t.Fatalf("no compose file: got %q %+v", prefix, ports)
+	}
+
+	err := templates.RenderTemplate("docker-compose.yml", templates.Config{
+		EnableCaddy: true,
+		StackPrefix: "staging",
+		Ports:       templates.HostPorts{App: 9019, HTTPS: 8443},
+	}, filepath.Join(dir, "docker-compose.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix, ports = existingStackLayout(dir)
+	want := templates.HostPorts{App: 9019, DB: 3306, HTTP: 80, HTTPS: 8443}
+	if prefix != "staging" || ports != want {
+		t.Fatalf("existingStackLayout() = %q %+v, want staging %+v", prefix, ports, want)
+	}
+}
+
+func TestExistingDBExposure(t *testing.T) {
+	for _, tt := range []struct{ rendered, want string }{
+		{templates.DBExposureInternal, templates.DBExposureInternal},
+		{templates.DBExposureLocalhost, templates.DBExposureLocalhost},
+		{templates.DBExposurePublic, templates.DBExposureInternal},
+	} {
+		dir := t.TempDir()
+		err := templates.RenderTemplate("docker-compose.yml", templates.Config{DBExposure: tt.rendered}, filepath.Join(dir, "docker-compose.yml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := existingDBExposure(dir); got != tt.want {
+			t.Errorf("existingDBExposure(%s) = %q, want %q", tt.rendered, got, tt.want)
+		}
+	}
+}
+
+func TestSelectInitRuntime(t *testing.T) {
+	t.Setenv(engine.RuntimeEnv, "")
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	got, err := selectInitRuntime(initOptions{NonInteractive: true, Runtime: "podman"})
+	if err != nil || got != engine.RuntimePodman {
+		t.Fatalf("selectInitRuntime() = %q, %v, want podman", got, err)
+	}
+	if engine.CurrentRuntime() != engine.RuntimePodman || !strings.HasSuffix(os.Getenv("DOCKER_HOST"), "/podman/podman.sock") {
+		t.Fatalf("runtime = %q, DOCKER_HOST = %q", engine.CurrentRuntime(), os.Getenv("DOCKER_HOST"))
+	}
+
+	if err := validateInitOptions(initOptions{Runtime: "lxc"}); err == nil {
+		t.Fatal("validateInitOptions() accepted --runtime lxc")
+	}
+}
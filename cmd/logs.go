@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strconv"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/logs"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [service...]",
+	Short: "View logs of the stack's services",
+	Long: `Print logs of all services, or only the given ones.
+
+Secrets from .env (database, Redis, JWT, S3 and license values) are replaced
+with [REDACTED], so the output can be shared in support tickets.`,
+	Example: `  kk logs kkengine db --since 30m
+  kk logs -f --grep 'error|panic'
+  kk logs --json --tail all > logs.ndjson`,
+	Annotations: map[string]string{"group": "core"},
+	RunE:        runLogs,
+}
+
+var (
+	logsFollow bool
+	logsTail   string
+	logsSince  string
+	logsUntil  string
+	logsGrep   string
+	logsJSON   bool
+)
+
+func init() {
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Follow log output")
+	logsCmd.Flags().StringVarP(&logsTail, "tail", "n", "100", `Number of lines per service, or "all"`)
+	logsCmd.Flags().StringVar(&logsSince, "since", "", "Show logs since a timestamp or relative time (e.g. 2026-01-02T15:04:05, 42m)")
+	logsCmd.Flags().StringVar(&logsUntil, "until", "", "Show logs before a timestamp or relative time")
+	logsCmd.Flags().StringVar(&logsGrep, "grep", "", "Only show lines matching this regular expression")
+	logsCmd.Flags().BoolVar(&logsJSON, "json", false, "Print one JSON record per line with time, service, container and message")
+	rootCmd.AddCommand(logsCmd)
+}
+
+func runLogs(cmd *cobra.Command, args []string) error {
+	cwd, err := config.EnsureProjectDir()
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("project_not_configured"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("run_init_to_configure"),
+			Command:    "kk init",
+		})
+		return err
+	}
+
+	composeFile, err := compose.ParseComposeFile(cwd)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("logs_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("err_compose_file_missing"),
+			Command:    "kk init",
+		})
+		return err
+	}
+
+	opts, err := buildLogOptions(cwd, composeFile, args)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("logs_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("logs_usage_hint"),
+			Command:    "kk logs --help",
+		})
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	w := logs.NewWriter(os.Stdout, opts)
+	err = compose.NewExecutor(cwd).Logs(ctx, w, compose.LogOptions{
+		Follow:     logsFollow,
+		Tail:       logsTail,
+		Since:      logsSince,
+		Until:      logsUntil,
+		Timestamps: logsJSON,
+		Services:   args,
+	})
+	if flushErr := w.Flush(); err == nil {
+		err = flushErr
+	}
+	if ctx.Err() != nil {
+		// Interrupted while following: not an error.
+		return nil
+	}
+	if err != nil {
+		suggestion := ui.Msg("err_check_docker_running")
+		command := ui.Msg("docker_start_command")
+		if ui.IsDockerPermissionError(err) {
+			suggestion, command = ui.DockerPermissionSuggestion()
+		}
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("logs_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: suggestion,
+			Command:    command,
+		})
+		return err
+	}
+	return nil
+}
+
+// buildLogOptions validates the logs flags and services and returns the
+// writer options, redacting the secrets of the .env in dir.
+func buildLogOptions(dir string, composeFile *compose.ComposeFile, services []string) (logs.Options, error) {
+	for _, service := range services {
+		if _, ok := composeFile.Services[service]; !ok {
+			return logs.Options{}, NewExitError(exitCodeInputValidation, errors.New(ui.MsgF("logs_unknown_service", service)))
+		}
+	}
+	if logsTail != "all" {
+		if n, err := strconv.Atoi(logsTail); err != nil || n < 0 {
+			return logs.Options{}, NewExitError(exitCodeInputValidation, fmt.Errorf("--tail must be a number or \"all\", got %q", logsTail))
+		}
+	}
+
+	opts := logs.Options{
+		Redactor:   logs.NewRedactor(logs.EnvSecrets(dir)),
+		JSON:       logsJSON,
+		Timestamps: logsJSON,
+		Containers: make(map[string]string),
+	}
+	for _, service := range composeFile.GetServiceNames() {
+		opts.Containers[composeFile.GetServiceContainerName(service)] = service
+	}
+	if logsGrep != "" {
+		re, err := regexp.Compile(logsGrep)
+		if err != nil {
+			return logs.Options{}, NewExitError(exitCodeInputValidation, fmt.Errorf("--grep is invalid: %w", err))
+		}
+		opts.Grep = re
+	}
+	return opts, nil
+}
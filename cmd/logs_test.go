@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+)
+
+func TestBuildLogOptions(t *testing.T) {
+	oldTail, oldGrep, oldJSON := logsTail, logsGrep, logsJSON
+	defer func() { logsTail, logsGrep, logsJSON = oldTail, oldGrep, oldJSON }()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".env"), []byte("DB_PASSWORD=dbsecret123\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	composeFile := &compose.ComposeFile{Services: map[string]compose.Service{
+		"db":       {ContainerName: "staging_db"},
+		"kkengine": {ContainerName: "staging_app"},
+	}}
+
+	logsTail, logsGrep, logsJSON = "all", "error", true
+	opts, err := buildLogOptions(dir, composeFile, []string{"db"})
+	if err != nil {
+		t.Fatalf("buildLogOptions() error = %v", err)
+	}
+	if opts.Containers["staging_app"] != "kkengine" || !opts.JSON || opts.Grep == nil {
+		t.Fatalf("unexpected options %+v", opts)
+	}
+	if got := opts.Redactor.Redact("pw dbsecret123"); got != "pw [REDACTED]" {
+		t.Fatalf("Redact() = %q", got)
+	}
+
+	for name, setup := range map[string]func(){
+		"unknown service": func() {},
+		"bad tail":        func() { logsTail = "-1" },
+		"bad grep":        func() { logsGrep = "(" },
+	} {
+		logsTail, logsGrep = "100", ""
+		setup()
+		services := []string{"db"}
+		if name == "unknown service" {
+			services = []string{"web"}
+		}
+		if _, err := buildLogOptions(dir, composeFile, services); ExitCode(err) != exitCodeInputValidation {
+			t.Errorf("%s: ExitCode() = %d, want %d", name, ExitCode(err), exitCodeInputValidation)
+		}
+	}
+}
@@ -1,15 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh"
+	"github.com/docker/docker/api/types/network"
 	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/engine"
 	"github.com/kkauto-net/kk-install/pkg/n8n"
 	"github.com/kkauto-net/kk-install/pkg/ui"
 	"github.com/kkauto-net/kk-install/pkg/validator"
@@ -127,7 +132,8 @@ func runN8nInstall(cmd *cobra.Command, args []string) error {
 	// Check kkengine network and ask about connection
 	cfg.ConnectKKEngine = false
 	if !forceN8nInstall {
-		kkengineNetExists := checkKKEngineNetwork()
+		cfg.KKEngineNetwork = kkengineNetworkName()
+		kkengineNetExists := checkKKEngineNetwork(cfg.KKEngineNetwork)
 		if kkengineNetExists {
 			form := huh.NewForm(
 				huh.NewGroup(
@@ -258,10 +264,30 @@ func runN8nInstall(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// checkKKEngineNetwork checks if kkengine_net docker network exists
-func checkKKEngineNetwork() bool {
-	cmd := exec.Command("docker", "network", "inspect", "kkengine_net")
-	return cmd.Run() == nil
+// kkengineNetworkName returns the network of the active kkengine project,
+// falling back to the default name when no project is configured.
+func kkengineNetworkName() string {
+	cfg, err := config.Load()
+	if err != nil || cfg.ActiveProjectDir() == "" {
+		return n8n.DefaultKKEngineNetwork
+	}
+	composeFile, err := compose.ParseComposeFile(cfg.ActiveProjectDir())
+	if err != nil {
+		return n8n.DefaultKKEngineNetwork
+	}
+	return composeFile.GetNetworkName(compose.StackNetworkKey)
+}
+
+// checkKKEngineNetwork checks if the kkengine docker network exists
+func checkKKEngineNetwork(name string) bool {
+	cli, err := engine.Shared()
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = cli.NetworkInspect(ctx, name, network.InspectOptions{})
+	return err == nil
 }
 
 // validateN8nDomain validates domain format
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/notify"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+var notifyCmd = &cobra.Command{
+	Use:   "notify",
+	Short: "Manage health and update notifications",
+	Long: `Notifications are sent to the targets in the 'notifications' section of
+~/.kk/config.yaml when a service turns unhealthy (kk start, kk status --watch)
+and when kk update replaces images.
+
+  notifications:
+    attempts: 3          # deliveries per target, with exponential backoff
+    timeout: 10s         # per attempt
+    targets:
+      - name: ops
+        type: slack      # slack, telegram or webhook
+        url: https://hooks.slack.com/services/...
+        events: [health] # health, update; empty means all
+      - name: oncall
+        type: telegram
+        bot_token: "123456:ABC..."
+        chat_id: "-1001234567890"
+      - name: pager
+        type: webhook
+        url: https://example.com/kk
+        headers: {Authorization: "Bearer ..."}
+        template: '{"summary": {{json .Text}}, "severity": "{{.Status}}"}'
+
+Templates use Go text/template with the event fields: .Kind, .Time, .Host,
+.Project, .Service, .Status, .Message, .Images, .Text and .ChatID. Without a
+template, Slack and Telegram get .Text and webhooks the event as JSON.`,
+	Annotations: map[string]string{"group": "management"},
+}
+
+var notifyTestCmd = &cobra.Command{
+	Use:   "test [target]",
+	Short: "Send a test notification",
+	Long:  `Send a test notification to one target, or to every configured target.`,
+	Example: `  kk notify test
+  kk notify test ops`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runNotifyTest,
+}
+
+// notifyTimeout bounds one notification, retries included.
+const notifyTimeout = time.Minute
+
+func init() {
+	notifyCmd.AddCommand(notifyTestCmd)
+	rootCmd.AddCommand(notifyCmd)
+}
+
+func runNotifyTest(cmd *cobra.Command, args []string) error {
+	ui.ShowCommandBanner(ui.Msg("cmd_notify_test_title"), ui.Msg("notify_test_desc"))
+
+	notifier, err := loadNotifier()
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("notify_config_invalid"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("notify_config_suggestion"),
+			Command:    "kk notify --help",
+		})
+		return NewExitError(exitCodeInputValidation, err)
+	}
+	if notifier == nil {
+		ui.ShowWarning(ui.Msg("notify_none_configured"))
+		ui.ShowNote(ui.Msg("notify_config_suggestion"))
+		return nil
+	}
+
+	targets := notifier.Targets()
+	if len(args) == 1 {
+		targets = nil
+		for _, t := range notifier.Targets() {
+			if t.Name == args[0] {
+				targets = append(targets, t)
+			}
+		}
+		if len(targets) == 0 {
+			err := fmt.Errorf("no notification target named %q", args[0])
+			ui.ShowBoxedError(ui.ErrorSuggestion{
+				Title:      ui.Msg("notify_config_invalid"),
+				Message:    err.Error(),
+				Suggestion: ui.Msg("notify_config_suggestion"),
+				Command:    "kk notify --help",
+			})
+			return NewExitError(exitCodeInputValidation, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	failed := 0
+	for _, t := range targets {
+		spinner := ui.StartPtermSpinner(ui.MsgF("notify_sending", t.Name, t.Type))
+		if err := notifier.SendTo(ctx, t, notify.TestEvent()); err != nil {
+			spinner.Fail(ui.MsgF("notify_send_failed", t.Name, ui.SanitizeError(err)))
+			failed++
+			continue
+		}
+		spinner.Success(ui.MsgF("notify_sent", t.Name))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d notification targets failed", failed, len(targets))
+	}
+	return nil
+}
+
+// loadNotifier returns the notifier for the configured targets, or nil when
+// there are none.
+func loadNotifier() (*notify.Notifier, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Notifications == nil || len(cfg.Notifications.Targets) == 0 {
+		return nil, nil
+	}
+	if err := cfg.Notifications.Validate(); err != nil {
+		return nil, err
+	}
+	return notify.New(*cfg.Notifications, notifyProjectName(cfg)), nil
+}
+
+// notifyProjectName names the stack in notifications: the named project, or
+// the project directory.
+func notifyProjectName(cfg *config.Config) string {
+	if project, err := cfg.ActiveProject(); err == nil && project != nil {
+		return project.Name
+	}
+	if name := config.SelectedProjectName(); name != "" {
+		return name
+	}
+	if cfg.ProjectDir != "" {
+		return filepath.Base(cfg.ProjectDir)
+	}
+	return ""
+}
+
+// sendNotification delivers ev to the subscribed targets. Nothing is sent
+// when no targets are configured.
+func sendNotification(ev notify.Event) error {
+	notifier, err := loadNotifier()
+	if err != nil || notifier == nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	return notifier.Send(ctx, ev)
+}
+
+// notifyOrWarn sends ev and prints a warning when delivery fails. A failed
+// notification never fails the command that triggered it.
+func notifyOrWarn(ev notify.Event) {
+	if err := sendNotification(ev); err != nil && !ui.IsStructuredOutput() {
+		ui.ShowWarning(ui.MsgF("notify_failed", ui.SanitizeError(err)))
+	}
+}
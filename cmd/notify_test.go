@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/notify"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+func TestLoadNotifier(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	notifier, err := loadNotifier()
+	if err != nil || notifier != nil {
+		t.Fatalf("loadNotifier() without targets = %v, %v", notifier, err)
+	}
+
+	cfg := &config.Config{Language: "en", Notifications: &notify.Settings{Targets: []notify.Target{{Name: "ops", Type: "email"}}}}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadNotifier(); err == nil {
+		t.Fatal("loadNotifier() accepted an unknown target type")
+	}
+}
+
+func TestNotifyUpdate(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	received := make(chan notify.Event, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ev notify.Event
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("decode notification: %v", err)
+		}
+		received <- ev
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{Language: "en", ProjectDir: "/srv/shop", Notifications: &notify.Settings{Targets: []notify.Target{
+		{Name: "hook", Type: notify.TypeWebhook, URL: srv.URL, Events: []string{notify.KindUpdate}},
+	}}}
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	updates := []updater.ImageUpdate{{Image: "redis:alpine", OldDigest: "sha256:a", NewDigest: "sha256:b"}}
+	notifyUpdate(updater.OutcomeCancelled, updates, nil)
+	notifyUpdate("", updates, errors.New("recreate failed"))
+	if err := sendNotification(notify.HealthEvent("db", "unhealthy", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 1 {
+		t.Fatalf("received %d notifications, want only the failed update", len(received))
+	}
+	ev := <-received
+	if ev.Kind != notify.KindUpdate || ev.Status != updater.OutcomeFailed || ev.Project != "shop" || len(ev.Images) != 1 {
+		t.Fatalf("event = %+v", ev)
+	}
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+var projectCmd = &cobra.Command{
+	Use:   "project",
+	Short: "Manage named kkengine stacks",
+	Long: `Register several kkengine stacks on one host and switch between them.
+
+Every lifecycle command runs against the current project, or the one given
+with --project or the KK_PROJECT environment variable.`,
+	Annotations: map[string]string{"group": "management"},
+}
+
+var projectAddCmd = &cobra.Command{
+	Use:   "add NAME [DIR]",
+	Short: "Register a project directory (default: current directory)",
+	Long: `Register an initialized project directory under NAME.
+
+Each project gets its own Docker Compose project name (kk-NAME unless
+--compose-project is given). Stop a running stack before registering it,
+then start it again with 'kk start --project NAME'.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: runProjectAdd,
+}
+
+var projectListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List registered projects",
+	Args:  cobra.NoArgs,
+	RunE:  runProjectList,
+}
+
+var projectUseCmd = &cobra.Command{
+	Use:   "use NAME",
+	Short: "Make NAME the current project",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProjectUse,
+}
+
+var projectRemoveCmd = &cobra.Command{
+	Use:   "remove NAME",
+	Short: "Unregister a project (files and containers are kept)",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runProjectRemove,
+}
+
+var (
+	projectComposeName string
+	projectUse         bool
+)
+
+func init() {
+	projectAddCmd.Flags().StringVar(&projectComposeName, "compose-project", "", "Docker Compose project name (default kk-NAME)")
+	projectAddCmd.Flags().BoolVar(&projectUse, "use", false, "Make the new project current")
+	projectCmd.AddCommand(projectAddCmd, projectListCmd, projectUseCmd, projectRemoveCmd)
+	rootCmd.AddCommand(projectCmd)
+}
+
+func runProjectAdd(cmd *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) == 2 {
+		dir = args[1]
+	}
+	if _, err := os.Stat(filepath.Join(dir, "docker-compose.yml")); err != nil {
+		return showProjectError(err, ui.Msg("project_add_not_initialized"), "kk init --project "+args[0])
+	}
+
+	return updateProjects(func(cfg *config.Config) error {
+		if err := cfg.AddProject(config.Project{Name: args[0], Dir: dir, ComposeProject: projectComposeName}); err != nil {
+			return err
+		}
+		if projectUse {
+			return cfg.UseProject(args[0])
+		}
+		return nil
+	}, ui.MsgF("project_added", args[0]))
+}
+
+func runProjectList(cmd *cobra.Command, args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return showProjectError(err, ui.Msg("run_init_to_configure"), "")
+	}
+	if ui.IsStructuredOutput() {
+		projects := cfg.Projects
+		if projects == nil {
+			projects = []config.Project{}
+		}
+		return ui.WriteStructured(os.Stdout, projects)
+	}
+	if len(cfg.Projects) == 0 && cfg.ProjectDir == "" {
+		ui.ShowInfo(ui.Msg("project_list_empty"))
+		return nil
+	}
+	ui.PrintProjects(cfg)
+	return nil
+}
+
+func runProjectUse(cmd *cobra.Command, args []string) error {
+	return updateProjects(func(cfg *config.Config) error {
+		return cfg.UseProject(args[0])
+	}, ui.MsgF("project_switched", args[0]))
+}
+
+func runProjectRemove(cmd *cobra.Command, args []string) error {
+	return updateProjects(func(cfg *config.Config) error {
+		return cfg.RemoveProject(args[0])
+	}, ui.MsgF("project_removed", args[0]))
+}
+
+// updateProjects loads the config, applies change and saves it.
+func updateProjects(change func(*config.Config) error, success string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return showProjectError(err, ui.Msg("run_init_to_configure"), "")
+	}
+	if err := change(cfg); err != nil {
+		return showProjectError(NewExitError(exitCodeInputValidation, err), ui.Msg("project_list_hint"), "kk project list")
+	}
+	if err := cfg.Save(); err != nil {
+		return showProjectError(err, "", "")
+	}
+	ui.ShowSuccess(success)
+	return nil
+}
+
+func showProjectError(err error, suggestion, command string) error {
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("project_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: suggestion,
+		Command:    command,
+	})
+	return err
+}
@@ -74,7 +74,7 @@ func runRemove(cmd *cobra.Command, args []string) error {
 		spinner.Fail(ui.Msg("remove_failed"))
 
 		suggestion := ui.Msg("err_check_docker_logs")
-		command := ui.Msg("docker_compose_logs_command")
+		command := ui.Msg("kk_logs_command")
 		if ui.IsDockerPermissionError(execErr) {
 			suggestion, command = ui.DockerPermissionSuggestion()
 		}
@@ -94,16 +94,7 @@ func runRestart(cmd *cobra.Command, args []string) error {
 
 			healthSpinner := ui.StartPtermSpinner(ui.Msg("health_checking"))
 
-			var containers []monitor.ContainerInfo
-			for name := range composeFile.Services {
-				containers = append(containers, monitor.ContainerInfo{
-					ServiceName:    name,
-					ContainerName:  fmt.Sprintf("kkengine_%s", name),
-					HasHealthCheck: composeFile.HasHealthCheck(name),
-				})
-			}
-
-			healthMonitor.MonitorAll(timeoutCtx, containers, func(status monitor.HealthStatus) {
+			healthMonitor.MonitorAll(timeoutCtx, monitor.ComposeContainers(composeFile), func(status monitor.HealthStatus) {
 				ui.ShowServiceProgress(status.ServiceName, status.Status)
 			})
 			healthSpinner.Success(ui.Msg("health_checking"))
@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/backup"
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <archive>",
+	Short: "Restore the stack from a kk backup archive",
+	Long: `Verify a kk backup archive, stop the stack, restore the config files and
+volumes, re-import the MariaDB dump, and start the stack again.
+
+Restores into the configured project directory, or the current directory when
+no project is configured (e.g. on a fresh VPS). Use --dir to pick another one.`,
+	Annotations: map[string]string{"group": "management"},
+	Args:        cobra.ExactArgs(1),
+	RunE:        runRestore,
+}
+
+var (
+	restoreForce bool
+	restoreDir   string
+)
+
+// restoreDBWait bounds how long restore waits for MariaDB before importing.
+const restoreDBWait = 3 * time.Minute
+
+func init() {
+	restoreCmd.Flags().BoolVarP(&restoreForce, "force", "f", false, "Stop a running stack and restore over it")
+	restoreCmd.Flags().StringVar(&restoreDir, "dir", "", "Project directory to restore into")
+	rootCmd.AddCommand(restoreCmd)
+}
+
+func runRestore(cmd *cobra.Command, args []string) error {
+	archivePath, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+	targetDir, err := resolveRestoreDir()
+	if err != nil {
+		return showRestoreError(err, "")
+	}
+	if cfg, cfgErr := config.Load(); cfgErr == nil {
+		if err := cfg.ExportComposeProject(); err != nil {
+			return showRestoreError(err, "")
+		}
+	}
+
+	ui.ShowCommandBanner(ui.Msg("cmd_restore_title"), ui.Msg("restore_desc"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigChan
+		fmt.Println("\n\n" + ui.Msg("stopping"))
+		cancel()
+	}()
+
+	// Step 1: Verify archive
+	ui.ShowStepHeader(1, 5, ui.Msg("step_restore_verify"))
+	workDir, err := os.MkdirTemp("", "kk-restore-*")
+	if err != nil {
+		return showRestoreError(err, "")
+	}
+	defer func() {
+		warnOnError(os.RemoveAll(workDir))
+	}()
+
+	spinner := ui.StartPtermSpinner(ui.Msg("restore_verifying"))
+	manifest, err := backup.Open(archivePath, workDir)
+	if err != nil {
+		spinner.Fail(ui.Msg("restore_failed"))
+		return showRestoreError(err, ui.Msg("restore_invalid_archive_suggestion"))
+	}
+	spinner.Success(ui.Msg("restore_verified"))
+	ui.ShowInfo(ui.MsgF("restore_backup_info", manifest.CreatedAt.Local().Format(time.RFC1123), manifest.KKVersion, strings.Join(manifest.Services, ", ")))
+
+	// Step 2: Refuse to clobber a running stack unless --force
+	ui.ShowStepHeader(2, 5, ui.Msg("step_restore_stop"))
+	executor := compose.NewExecutor(targetDir)
+	previousRootPassword := config.ReadEnvValue(targetDir, "DB_ROOT_PASSWORD")
+	if err := stopStackForRestore(ctx, executor, targetDir); err != nil {
+		return err
+	}
+
+	// Step 3: Config files, then volume contents
+	ui.ShowStepHeader(3, 5, ui.Msg("step_restore_files"))
+	if err := backupExistingConfigs(targetDir); err != nil {
+		ui.ShowWarning(ui.MsgF("config_backup_failed", err))
+	}
+	spinner = ui.StartPtermSpinner(ui.Msg("restore_writing_configs"))
+	restored, err := backup.RestoreConfigs(manifest, workDir, targetDir)
+	if err != nil {
+		spinner.Fail(ui.Msg("restore_failed"))
+		return showRestoreError(err, "")
+	}
+	spinner.Success(ui.MsgF("restore_configs_written", strings.Join(restored, ", ")))
+
+	dataCtx, dataCancel := context.WithTimeout(ctx, backupTimeout)
+	defer dataCancel()
+	for _, entry := range manifest.EntriesOfKind(backup.KindVolume) {
+		name := strings.TrimSuffix(filepath.Base(entry.Path), ".tar")
+		spinner = ui.StartPtermSpinner(ui.MsgF("restore_restoring_volume", name))
+		if err := backup.RestoreVolume(dataCtx, executor, workDir, entry); err != nil {
+			spinner.Fail(ui.Msg("restore_failed"))
+			return showRestoreError(err, ui.Msg("err_check_docker_logs"))
+		}
+		spinner.Success(ui.MsgF("restore_volume_restored", name))
+	}
+
+	composeFile, err := compose.ParseComposeFile(targetDir)
+	if err != nil {
+		return showRestoreError(err, ui.Msg("err_compose_file_missing"))
+	}
+	warnRestoredImages(dataCtx, manifest, composeFile, updater.NewDockerImageInspector())
+
+	// Step 4: Database
+	ui.ShowStepHeader(4, 5, ui.Msg("step_restore_database"))
+	if dumps := manifest.EntriesOfKind(backup.KindDatabase); len(dumps) == 0 {
+		ui.ShowInfo(ui.Msg("restore_no_database"))
+	} else if err := importRestoredDatabase(dataCtx, executor, composeFile, workDir, dumps[0], config.ReadEnvValue(targetDir, "DB_ROOT_PASSWORD"), previousRootPassword); err != nil {
+		return err
+	}
+
+	// Step 5: Bring the whole stack back and wait for health
+	ui.ShowStepHeader(5, 5, ui.Msg("step_restore_start"))
+	startCtx, startCancel := context.WithTimeout(ctx, compose.DefaultTimeout)
+	defer startCancel()
+	spinner = ui.StartPtermSpinner(ui.Msg("starting_services"))
+	if err := executor.Up(startCtx); err != nil {
+		spinner.Fail(ui.Msg("start_failed"))
+		suggestion := ui.Msg("err_check_docker_logs")
+		if ui.IsContainerConflictError(err) {
+			suggestion, _ = ui.ContainerConflictSuggestion(composeFile.GetContainerNames(), composeFile.GetNetworkName(compose.StackNetworkKey))
+		}
+		return showRestoreError(err, suggestion)
+	}
+	spinner.Success(ui.Msg("services_started"))
+	for _, r := range monitorComposeHealth(startCtx, composeFile) {
+		if !r.Healthy {
+			ui.ShowWarning(ui.Msg("some_not_ready"))
+			break
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		cfg = &config.Config{Language: string(ui.GetLanguage())}
+	}
+	if err := cfg.SetInitializedDir(targetDir); err != nil {
+		ui.ShowWarning(fmt.Sprintf("Cannot save config: %v", err))
+	} else if saveErr := cfg.Save(); saveErr != nil {
+		ui.ShowWarning(fmt.Sprintf("Cannot save config: %v", saveErr))
+	}
+
+	statuses, err := monitor.GetStatusWithServices(startCtx, executor, composeFile.GetServiceNames())
+	if err == nil {
+		ui.PrintCommandResult(statuses, ui.Msg("cmd_restore_title"), "restore_summary_success", "restore_summary_partial")
+	}
+	fmt.Println()
+	ui.ShowCompletionBanner(true, ui.IconComplete+" "+ui.Msg("restore_complete"), ui.MsgF("restore_complete_box", targetDir))
+	return nil
+}
+
+// resolveRestoreDir picks --dir, then the configured project, then the current directory.
+func resolveRestoreDir() (string, error) {
+	dir := restoreDir
+	if dir == "" {
+		if cfg, err := config.Load(); err == nil && cfg.ActiveProjectDir() != "" {
+			if info, statErr := os.Stat(cfg.ActiveProjectDir()); statErr == nil && info.IsDir() {
+				dir = cfg.ActiveProjectDir()
+			}
+		}
+	}
+	if dir == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return "", err
+		}
+		dir = cwd
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := os.Chdir(dir); err != nil {
+		return "", fmt.Errorf("failed to change to project directory %s: %w", dir, err)
+	}
+	return dir, nil
+}
+
+func stopStackForRestore(ctx context.Context, executor *compose.Executor, targetDir string) error {
+	if _, err := os.Stat(executor.ComposeFile); os.IsNotExist(err) {
+		ui.ShowInfo(ui.Msg("restore_no_existing_stack"))
+		return nil
+	}
+
+	statusCtx, statusCancel := context.WithTimeout(ctx, 30*time.Second)
+	defer statusCancel()
+	statuses, err := monitor.GetStatus(statusCtx, executor)
+	if err != nil {
+		return showRestoreError(err, ui.Msg("err_check_docker_running"))
+	}
+	running := false
+	for _, s := range statuses {
+		if s.Running {
+			running = true
+			break
+		}
+	}
+	if running && !restoreForce {
+		err := errors.New(ui.MsgF("restore_stack_running", targetDir))
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("restore_failed"),
+			Message:    err.Error(),
+			Suggestion: ui.Msg("restore_stack_running_suggestion"),
+			Command:    "kk restore --force <archive>",
+		})
+		return err
+	}
+
+	downCtx, downCancel := context.WithTimeout(ctx, compose.DefaultTimeout)
+	defer downCancel()
+	spinner := ui.StartPtermSpinner(ui.Msg("restore_stopping"))
+	if err := executor.Down(downCtx); err != nil {
+		spinner.Fail(ui.Msg("stop_failed"))
+		return showRestoreError(err, ui.Msg("err_check_docker_logs"))
+	}
+	spinner.Success(ui.Msg("restore_stopped"))
+	return nil
+}
+
+func importRestoredDatabase(ctx context.Context, executor *compose.Executor, composeFile *compose.ComposeFile, workDir string, dump backup.Entry, passwords ...string) error {
+	spinner := ui.StartPtermSpinner(ui.Msg("restore_starting_db"))
+	if err := executor.UpServices(ctx, backup.DatabaseService); err != nil {
+		spinner.Fail(ui.Msg("start_failed"))
+		return showRestoreError(err, ui.Msg("err_check_docker_logs"))
+	}
+
+	healthMonitor, err := monitor.NewHealthMonitor()
+	if err != nil {
+		spinner.Fail(ui.Msg("restore_db_not_ready"))
+		return showRestoreError(err, ui.Msg("err_check_docker_running"))
+	}
+	defer healthMonitor.Close()
+
+	waitCtx, waitCancel := context.WithTimeout(ctx, restoreDBWait)
+	defer waitCancel()
+	container := composeFile.GetServiceContainerName(backup.DatabaseService)
+	hasHealthCheck := composeFile.HasHealthCheck(backup.DatabaseService)
+	status := healthMonitor.WaitForHealthy(waitCtx, container, hasHealthCheck)
+	for !status.Healthy && waitCtx.Err() == nil {
+		status = healthMonitor.WaitForHealthy(waitCtx, container, hasHealthCheck)
+	}
+	if !status.Healthy {
+		spinner.Fail(ui.Msg("restore_db_not_ready"))
+		return showRestoreError(errors.New(ui.Msg("restore_db_not_ready")+": "+status.Status), ui.Msg("err_check_docker_logs"))
+	}
+	spinner.Success(ui.Msg("restore_db_ready"))
+
+	spinner = ui.StartPtermSpinner(ui.Msg("restore_importing_db"))
+	if err := backup.ImportDatabase(ctx, executor, workDir, dump, passwords...); err != nil {
+		spinner.Fail(ui.Msg("restore_failed"))
+		return showRestoreError(err, ui.Msg("err_check_docker_logs"))
+	}
+	spinner.Success(ui.Msg("restore_db_imported"))
+	return nil
+}
+
+// warnRestoredImages warns about images whose identity on this host differs
+// from the one the backup recorded, since the stack then runs other code
+// against the restored data.
+func warnRestoredImages(ctx context.Context, manifest *backup.Manifest, composeFile *compose.ComposeFile, inspector updater.ImageInspector) {
+	current, err := updater.SnapshotImages(ctx, composeFile.GetServiceImages(), inspector)
+	if err != nil {
+		ui.ShowWarning(ui.MsgF("restore_images_check_failed", err))
+		return
+	}
+	mismatches := manifest.ImageMismatches(current)
+	for _, m := range mismatches {
+		ui.ShowWarning(ui.MsgF("restore_image_mismatch", m.Image, m.Recorded, m.Current))
+	}
+	if len(mismatches) > 0 {
+		ui.ShowNote(ui.Msg("restore_images_mismatch_hint"))
+	}
+}
+
+func showRestoreError(err error, suggestion string) error {
+	command := ""
+	if ui.IsDockerPermissionError(err) {
+		suggestion, command = ui.DockerPermissionSuggestion()
+	}
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("restore_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: suggestion,
+		Command:    command,
+	})
+	return err
+}
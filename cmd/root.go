@@ -6,31 +6,106 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/engine"
 	"github.com/kkauto-net/kk-install/pkg/ui"
 )
 
 var Version = "0.1.0"
 
+var (
+	outputFormat  string
+	projectName   string
+	dockerHost    string
+	dockerContext string
+
+	// configuredRuntime is the container runtime saved by kk init.
+	configuredRuntime string
+)
+
 var rootCmd = &cobra.Command{
-	Use:           "kk",
-	Short:         "🚀 Manage your kkengine Docker stack effortlessly",
-	Long:          `🚀 Manage your kkengine Docker stack effortlessly.`,
-	SilenceErrors: true, // We handle errors with ShowBoxedError
-	SilenceUsage:  true, // Don't show usage on errors
+	Use:               "kk",
+	Short:             "🚀 Manage your kkengine Docker stack effortlessly",
+	Long:              `🚀 Manage your kkengine Docker stack effortlessly.`,
+	SilenceErrors:     true, // We handle errors with ShowBoxedError
+	SilenceUsage:      true, // Don't show usage on errors
+	PersistentPreRunE: setupOutput,
+}
+
+// setupOutput applies the global --output, --project, --docker-host,
+// --context and --events flags before any command runs.
+func setupOutput(cmd *cobra.Command, args []string) error {
+	format, err := ui.ParseOutputFormat(outputFormat)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("invalid_output_format"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("invalid_output_format_suggestion"),
+		})
+		return NewExitError(exitCodeInputValidation, err)
+	}
+	ui.SetOutputFormat(format)
+
+	config.SelectProject(projectName)
+	if name := config.SelectedProjectName(); name != "" {
+		if err := config.ValidateProjectName(name); err != nil {
+			ui.ShowBoxedError(ui.ErrorSuggestion{
+				Title:      ui.Msg("invalid_project_name"),
+				Message:    ui.SanitizeError(err),
+				Suggestion: ui.Msg("project_list_hint"),
+				Command:    "kk project list",
+			})
+			return NewExitError(exitCodeInputValidation, err)
+		}
+	}
+	if err := engine.SetTarget(engine.Target{Host: dockerHost, Context: dockerContext}); err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("invalid_docker_target"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("invalid_docker_target_suggestion"),
+			Command:    "docker context ls",
+		})
+		return NewExitError(exitCodeInputValidation, err)
+	}
+	// KK_RUNTIME, set for docker group re-execs and systemd units, wins over
+	// the runtime saved by kk init.
+	containerRuntime := engine.Runtime(configuredRuntime)
+	if fromEnv, envErr := engine.ParseRuntime(os.Getenv(engine.RuntimeEnv)); envErr == nil && fromEnv != "" {
+		containerRuntime = fromEnv
+	}
+	if err := engine.SetRuntime(containerRuntime); err != nil {
+		return err
+	}
+	return setupEvents(cmd)
 }
 
 func Execute() {
 	// Apply custom help templates (after all subcommands are registered)
 	ui.ApplyTemplates(rootCmd)
 
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	finishEvents(err)
+	if err != nil {
 		// Error already displayed via ShowBoxedError in command handlers
 		os.Exit(ExitCode(err))
 	}
 }
 
+// warnOnError shows err as a warning. It is for cleanup steps whose failure
+// should not change the result of the command.
+func warnOnError(err error) {
+	if err != nil {
+		ui.ShowWarning(ui.MsgF("warn_cleanup_failed", ui.SanitizeError(err)))
+	}
+}
+
 func init() {
 	rootCmd.Version = Version
+	rootCmd.PersistentFlags().StringVar(&outputFormat, "output", string(ui.OutputTable), "Output format: table, json or yaml")
+	rootCmd.PersistentFlags().StringVar(&projectName, "project", "", "Named project to operate on (default: $KK_PROJECT, then the current project)")
+	rootCmd.PersistentFlags().StringVar(&dockerHost, "docker-host", "", "Docker engine to manage, e.g. ssh://user@host (default: $DOCKER_HOST)")
+	rootCmd.PersistentFlags().StringVar(&dockerContext, "context", "", "Docker context to use (default: $DOCKER_CONTEXT, then the current docker context)")
+	rootCmd.PersistentFlags().StringVar(&eventsFormat, "events", "", "Emit lifecycle events as newline-delimited JSON (ndjson)")
+	rootCmd.PersistentFlags().IntVar(&eventsFD, "events-fd", 1, "File descriptor for --events (default stdout)")
 
 	ui.InitTerminalColors()
 
@@ -38,6 +113,7 @@ func init() {
 	cfg, err := config.Load()
 	if err == nil && cfg != nil {
 		ui.SetLanguage(ui.Language(cfg.Language))
+		configuredRuntime = cfg.Runtime
 	}
 	// If load fails, ui package already defaults to English
 }
@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/backup"
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/secrets"
+	"github.com/kkauto-net/kk-install/pkg/spec"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/validator"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:         "secrets",
+	Short:       "Manage stack secrets",
+	Long:        `Manage the credentials kk init generated for the stack.`,
+	Annotations: map[string]string{"group": "core"},
+}
+
+var secretsRotateCmd = &cobra.Command{
+	Use:   "rotate",
+	Short: "Generate new secrets and apply them to the running stack",
+	Long: `Generate new values for the selected secrets and apply them inside the
+running services: ALTER USER in MariaDB, CONFIG SET requirepass in Redis and,
+if SeaweedFS has S3 identities, the identity holding the old key. Then .env
+is rewritten atomically and only the containers that use the changed values
+are recreated. If any step fails, the services and .env are rolled back to
+the old values.`,
+	Example: `  kk secrets rotate --redis
+  kk secrets rotate --db --s3
+  kk secrets rotate --all --yes`,
+	Args: cobra.NoArgs,
+	RunE: runSecretsRotate,
+}
+
+type secretsRotateOptions struct {
+	DB, Redis, JWT, S3, All bool
+	Yes                     bool
+}
+
+var secretsRotateOpts secretsRotateOptions
+
+// rotateGroups are the kk.yaml secrets each rotate flag covers.
+var rotateGroups = []struct {
+	flag    string
+	secrets []string
+}{
+	{"db", []string{"db_password", "db_root_password"}},
+	{"redis", []string{"redis_password"}},
+	{"jwt", []string{"jwt_secret"}},
+	{"s3", []string{"s3_access_key", "s3_secret_key"}},
+}
+
+// secretsExecutor is the part of compose.Executor kk secrets rotate uses.
+type secretsExecutor interface {
+	secrets.ServiceExecutor
+	HasContainers(ctx context.Context) (bool, error)
+	UpServices(ctx context.Context, services ...string) error
+}
+
+// newSecretsExecutor is swapped in tests.
+var newSecretsExecutor = func(dir string) secretsExecutor {
+	return compose.NewExecutor(dir)
+}
+
+func init() {
+	f := secretsRotateCmd.Flags()
+	f.BoolVar(&secretsRotateOpts.DB, "db", false, "Rotate DB_PASSWORD and DB_ROOT_PASSWORD")
+	f.BoolVar(&secretsRotateOpts.Redis, "redis", false, "Rotate REDIS_PASSWORD")
+	f.BoolVar(&secretsRotateOpts.JWT, "jwt", false, "Rotate JWT_SECRET")
+	f.BoolVar(&secretsRotateOpts.S3, "s3", false, "Rotate S3_ACCESS_KEY and S3_SECRET_KEY")
+	f.BoolVar(&secretsRotateOpts.All, "all", false, "Rotate every secret")
+	f.BoolVarP(&secretsRotateOpts.Yes, "yes", "y", false, "Rotate without asking")
+	secretsCmd.AddCommand(secretsRotateCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
+
+func runSecretsRotate(cmd *cobra.Command, args []string) error {
+	dir, err := config.EnsureProjectDir()
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("project_not_configured"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("run_init_to_configure"),
+			Command:    "kk init",
+		})
+		return err
+	}
+	ui.ShowCommandBanner("kk secrets rotate", ui.Msg("secrets_rotate_desc"))
+
+	opts := secretsRotateOpts
+	names := selectedSecrets(opts)
+	if len(names) == 0 {
+		err = NewExitError(exitCodeInputValidation, errors.New(ui.Msg("secrets_none_selected")))
+		return showSecretsError(err, ui.Msg("secrets_select_hint"), "kk secrets rotate --all")
+	}
+	if err = checkExternalSecrets(dir, names); err != nil {
+		return showSecretsError(NewExitError(exitCodeInputValidation, err), ui.Msg("secrets_external_hint"), "")
+	}
+
+	current := loadExistingEnv(dir)
+	from := make(map[string]string, len(names))
+	to := make(map[string]string, len(names))
+	var keys []string
+	for _, name := range names {
+		key := spec.SecretKeys[name]
+		if current[key] == "" {
+			err = NewExitError(exitCodeInputValidation, errors.New(ui.MsgF("secrets_missing", key)))
+			return showSecretsError(err, ui.Msg("run_init_to_configure"), "kk init")
+		}
+		value, genErr := generateSecret(name)
+		if genErr != nil {
+			return showSecretsError(fmt.Errorf("generate %s: %w", key, genErr), "", "")
+		}
+		from[key], to[key] = current[key], value
+		keys = append(keys, key)
+	}
+
+	composeFile, err := compose.ParseComposeFile(dir)
+	if err != nil {
+		return showSecretsError(err, ui.Msg("err_compose_file_missing"), "kk init")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	executor := newSecretsExecutor(dir)
+	steps := rotateSteps(executor, composeFile, current["DB_USERNAME"], from, to)
+	running, err := executor.HasContainers(ctx)
+	if err != nil && len(steps) > 0 {
+		return showSecretsError(err, ui.Msg("err_check_docker_running"), "")
+	}
+	// The services hold the current credentials in their data; changing only
+	// .env would lock the stack out.
+	if len(steps) > 0 && !running {
+		return showSecretsError(errors.New(ui.Msg("secrets_stack_not_running")), ui.Msg("secrets_start_hint"), "kk start")
+	}
+	var recreate []string
+	if running {
+		if recreate, err = secretsDependents(dir, to); err != nil {
+			return showSecretsError(err, "", "")
+		}
+	}
+
+	if !opts.Yes && validator.IsInteractiveTTY() {
+		prompt := ui.MsgF("secrets_confirm", strings.Join(keys, ", "))
+		if len(recreate) > 0 {
+			prompt += " " + ui.MsgF("secrets_confirm_recreate", strings.Join(recreate, ", "))
+		}
+		confirmed, confirmErr := confirmConfig(prompt)
+		if confirmErr != nil {
+			return confirmErr
+		}
+		if !confirmed {
+			ui.ShowInfo(ui.Msg("config_cancelled"))
+			return nil
+		}
+	}
+
+	if backupErr := backupExistingConfigs(dir); backupErr != nil {
+		ui.ShowWarning(ui.MsgF("config_backup_failed", backupErr))
+	}
+
+	if len(steps) > 0 {
+		spinner := ui.StartPtermSpinner(ui.Msg("secrets_applying"))
+		if err = secrets.Rotate(ctx, steps); err != nil {
+			spinner.Fail(ui.Msg("secrets_rotate_failed"))
+			return showSecretsError(err, ui.Msg("secrets_rolled_back"), "")
+		}
+		spinner.Success(ui.Msg("secrets_applied"))
+	}
+
+	if err = writeSecretsEnv(dir, to); err != nil {
+		undoCtx, undoCancel := secrets.UndoContext(ctx)
+		defer undoCancel()
+		err = errors.Join(err, writeSecretsEnv(dir, from), secrets.Undo(undoCtx, steps))
+		return showSecretsError(err, ui.Msg("secrets_rolled_back"), "")
+	}
+
+	if len(recreate) > 0 {
+		services := strings.Join(recreate, ", ")
+		spinner := ui.StartPtermSpinner(ui.MsgF("config_restarting", services))
+		if err = executor.UpServices(ctx, recreate...); err != nil {
+			spinner.Fail(ui.Msg("secrets_rotate_failed"))
+			undoCtx, undoCancel := secrets.UndoContext(ctx)
+			defer undoCancel()
+			err = errors.Join(err, writeSecretsEnv(dir, from), secrets.Undo(undoCtx, steps), executor.UpServices(undoCtx, recreate...))
+			return showSecretsError(err, ui.Msg("secrets_rolled_back"), "kk status")
+		}
+		spinner.Success(ui.MsgF("config_restarted", services))
+	}
+
+	ui.ShowSuccess(ui.IconCheck + " " + ui.MsgF("secrets_rotated", strings.Join(keys, ", ")))
+	if !running {
+		ui.ShowNote(ui.Msg("secrets_apply_on_start"))
+	}
+	return nil
+}
+
+// selectedSecrets returns the kk.yaml names of the secrets the flags select.
+func selectedSecrets(opts secretsRotateOptions) []string {
+	flags := map[string]bool{"db": opts.DB, "redis": opts.Redis, "jwt": opts.JWT, "s3": opts.S3}
+	var names []string
+	for _, group := range rotateGroups {
+		if opts.All || flags[group.flag] {
+			names = append(names, group.secrets...)
+		}
+	}
+	return names
+}
+
+// checkExternalSecrets rejects secrets kk.yaml reads from the environment or
+// a file: kk apply would put the old value back.
+func checkExternalSecrets(dir string, names []string) error {
+	path := filepath.Join(dir, spec.FileName)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+	s, err := spec.Load(path)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if src := s.Secrets[name]; src.External() {
+			return errors.New(ui.MsgF("secrets_external", name, src.String()))
+		}
+	}
+	return nil
+}
+
+func generateSecret(name string) (string, error) {
+	for _, secret := range applySecrets {
+		if secret.name == name {
+			return secret.generate()
+		}
+	}
+	return "", fmt.Errorf("unknown secret %s", name)
+}
+
+// rotateSteps returns the in-service changes for the rotated keys, skipping
+// services the stack does not run.
+func rotateSteps(exec secrets.ServiceExecutor, composeFile *compose.ComposeFile, dbUser string, from, to map[string]string) []secrets.Step {
+	has := func(service string) bool {
+		_, ok := composeFile.Services[service]
+		return ok
+	}
+	var steps []secrets.Step
+	if _, ok := to["DB_PASSWORD"]; ok && has(backup.DatabaseService) {
+		steps = append(steps, secrets.MariaDB(exec, dbUser,
+			secrets.DBCredentials{RootPassword: from["DB_ROOT_PASSWORD"], Password: from["DB_PASSWORD"]},
+			secrets.DBCredentials{RootPassword: to["DB_ROOT_PASSWORD"], Password: to["DB_PASSWORD"]}))
+	}
+	if _, ok := to["REDIS_PASSWORD"]; ok && has(backup.RedisService) {
+		steps = append(steps, secrets.Redis(exec, from["REDIS_PASSWORD"], to["REDIS_PASSWORD"]))
+	}
+	if _, ok := to["S3_ACCESS_KEY"]; ok && has(secrets.SeaweedFSService) {
+		steps = append(steps, secrets.SeaweedFSS3(exec,
+			secrets.S3Credentials{AccessKey: from["S3_ACCESS_KEY"], SecretKey: from["S3_SECRET_KEY"]},
+			secrets.S3Credentials{AccessKey: to["S3_ACCESS_KEY"], SecretKey: to["S3_SECRET_KEY"]}))
+	}
+	return steps
+}
+
+// secretsDependents returns the services that read any of the changed
+// values, from a copy of .env with them set.
+func secretsDependents(dir string, values map[string]string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ".env"))
+	if err != nil {
+		return nil, err
+	}
+	newDir, err := os.MkdirTemp("", "kk-secrets-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		warnOnError(os.RemoveAll(newDir))
+	}()
+	if err = os.WriteFile(filepath.Join(newDir, ".env"), secrets.SetEnvValues(data, values), 0600); err != nil {
+		return nil, err
+	}
+	services, err := compose.ChangedServices(dir, newDir, []string{".env"})
+	if err != nil {
+		return nil, err
+	}
+	return services.Recreate, nil
+}
+
+// writeSecretsEnv sets values in .env and in its base copy, so the next
+// kk apply does not take the change for a local edit.
+func writeSecretsEnv(dir string, values map[string]string) error {
+	if err := secrets.WriteEnvFile(filepath.Join(dir, ".env"), values); err != nil {
+		return err
+	}
+	return spec.RewriteBase(dir, func(baseDir string) error {
+		path := filepath.Join(baseDir, ".env")
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return nil
+		}
+		return secrets.WriteEnvFile(path, values)
+	})
+}
+
+func showSecretsError(err error, suggestion, command string) error {
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("secrets_rotate_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: suggestion,
+		Command:    command,
+	})
+	return err
+}
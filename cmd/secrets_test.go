@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+const secretsCompose = `services:
+  kkengine:
+    image: kkengine:1
+    env_file:
+      - .env
+  redis:
+    image: redis:alpine
+    command: redis-server --requirepass ${REDIS_PASSWORD}
+`
+
+// secretsFakeExecutor accepts every command and fails up -d when upErr is set.
+type secretsFakeExecutor struct {
+	execs []string
+	ups   [][]string
+	upErr error
+}
+
+func (f *secretsFakeExecutor) Exec(_ context.Context, service string, stdin io.Reader, stdout io.Writer, _ ...string) error {
+	if _, err := io.ReadAll(stdin); err != nil {
+		return err
+	}
+	f.execs = append(f.execs, service)
+	_, err := io.WriteString(stdout, "OK\n")
+	return err
+}
+
+func (f *secretsFakeExecutor) HasContainers(context.Context) (bool, error) { return true, nil }
+
+func (f *secretsFakeExecutor) UpServices(_ context.Context, services ...string) error {
+	f.ups = append(f.ups, services)
+	return f.upErr
+}
+
+func setupSecretsProject(t *testing.T) (string, *secretsFakeExecutor) {
+	t.Helper()
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if chdirErr := os.Chdir(cwd); chdirErr != nil {
+			t.Logf("restore working directory: %v", chdirErr)
+		}
+	})
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"docker-compose.yml": secretsCompose,
+		".env":               "JWT_SECRET=oldjwt\nREDIS_PASSWORD=oldredis\n",
+	} {
+		if err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = (&config.Config{Language: "en", ProjectDir: dir}).Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	exec := &secretsFakeExecutor{}
+	oldExecutor, oldOpts := newSecretsExecutor, secretsRotateOpts
+	t.Cleanup(func() { newSecretsExecutor, secretsRotateOpts = oldExecutor, oldOpts })
+	newSecretsExecutor = func(string) secretsExecutor { return exec }
+	return dir, exec
+}
+
+func TestRunSecretsRotate(t *testing.T) {
+	dir, exec := setupSecretsProject(t)
+	secretsRotateOpts = secretsRotateOptions{Redis: true, Yes: true}
+
+	if err := runSecretsRotate(&cobra.Command{}, nil); err != nil {
+		t.Fatalf("runSecretsRotate() error = %v", err)
+	}
+	env := loadExistingEnv(dir)
+	if env["REDIS_PASSWORD"] == "oldredis" || len(env["REDIS_PASSWORD"]) < 16 {
+		t.Errorf("REDIS_PASSWORD = %q, want a new value", env["REDIS_PASSWORD"])
+	}
+	if env["JWT_SECRET"] != "oldjwt" {
+		t.Errorf("JWT_SECRET = %q, want it untouched", env["JWT_SECRET"])
+	}
+	if !reflect.DeepEqual(exec.execs, []string{"redis"}) {
+		t.Errorf("exec calls = %v, want one in redis", exec.execs)
+	}
+	if !reflect.DeepEqual(exec.ups, [][]string{{"kkengine", "redis"}}) {
+		t.Errorf("recreated %v, want kkengine and redis", exec.ups)
+	}
+}
+
+func TestRunSecretsRotateRollsBack(t *testing.T) {
+	dir, exec := setupSecretsProject(t)
+	exec.upErr = errors.New("up failed")
+	secretsRotateOpts = secretsRotateOptions{Redis: true, JWT: true, Yes: true}
+
+	err := runSecretsRotate(&cobra.Command{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "up failed") {
+		t.Fatalf("runSecretsRotate() error = %v, want the up failure", err)
+	}
+	data, readErr := os.ReadFile(filepath.Join(dir, ".env"))
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	if string(data) != "JWT_SECRET=oldjwt\nREDIS_PASSWORD=oldredis\n" {
+		t.Errorf(".env after rollback = %q", data)
+	}
+	// The redis password was set and then put back.
+	if !reflect.DeepEqual(exec.execs, []string{"redis", "redis"}) {
+		t.Errorf("exec calls = %v", exec.execs)
+	}
+}
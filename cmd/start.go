@@ -2,9 +2,11 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/spf13/cobra"
@@ -12,7 +14,9 @@ import (
 	"github.com/kkauto-net/kk-install/pkg/compose"
 	"github.com/kkauto-net/kk-install/pkg/config"
 	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/notify"
 	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/updater"
 	"github.com/kkauto-net/kk-install/pkg/validator"
 )
 
@@ -24,7 +28,10 @@ var startCmd = &cobra.Command{
 	RunE:        runStart,
 }
 
+var startAllowDrift bool
+
 func init() {
+	startCmd.Flags().BoolVar(&startAllowDrift, "allow-drift", false, "Start even if images do not match the digests pinned in kk.lock")
 	rootCmd.AddCommand(startCmd)
 }
 
@@ -65,6 +72,7 @@ func runStart(cmd *cobra.Command, args []string) error {
 
 	ui.ShowStepHeader(1, 4, ui.Msg("step_preflight"))
 	results, err := validator.RunPreflight(cwd, includeCaddy)
+	validator.EmitPreflightResults(results)
 	validator.PrintPreflightResults(results)
 
 	if err != nil {
@@ -76,6 +84,12 @@ func runStart(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if composeFile != nil {
+		if err := checkImageLock(cwd, composeFile); err != nil {
+			return err
+		}
+	}
+
 	ui.ShowStepHeader(2, 4, ui.Msg("step_start_services"))
 	executor := compose.NewExecutor(cwd)
 
@@ -88,12 +102,12 @@ func runStart(cmd *cobra.Command, args []string) error {
 		spinner.Fail(ui.Msg("start_failed"))
 
 		suggestion := ui.Msg("err_check_docker_logs")
-		command := ui.Msg("docker_compose_logs_command")
+		command := ui.Msg("kk_logs_command")
 
 		if ui.IsDockerPermissionError(err) {
 			suggestion, command = ui.DockerPermissionSuggestion()
-		} else if ui.IsContainerConflictError(err) {
-			suggestion, command = ui.ContainerConflictSuggestion()
+		} else if ui.IsContainerConflictError(err) && composeFile != nil {
+			suggestion, command = ui.ContainerConflictSuggestion(composeFile.GetContainerNames(), composeFile.GetNetworkName(compose.StackNetworkKey))
 		}
 
 		ui.ShowBoxedError(ui.ErrorSuggestion{
@@ -114,16 +128,7 @@ func runStart(cmd *cobra.Command, args []string) error {
 	} else {
 		defer healthMonitor.Close()
 
-		var containers []monitor.ContainerInfo
-		for name := range composeFile.Services {
-			containers = append(containers, monitor.ContainerInfo{
-				ServiceName:    name,
-				ContainerName:  fmt.Sprintf("kkengine_%s", name),
-				HasHealthCheck: composeFile.HasHealthCheck(name),
-			})
-		}
-
-		healthResults := healthMonitor.MonitorAll(timeoutCtx, containers, func(status monitor.HealthStatus) {
+		healthResults := healthMonitor.MonitorAll(timeoutCtx, monitor.ComposeContainers(composeFile), func(status monitor.HealthStatus) {
 			ui.ShowServiceProgress(status.ServiceName, status.Status)
 		})
 
@@ -131,7 +136,7 @@ func runStart(cmd *cobra.Command, args []string) error {
 		for _, r := range healthResults {
 			if !r.Healthy {
 				allHealthy = false
-				break
+				notifyOrWarn(notify.HealthEvent(r.ServiceName, r.Status, r.Message))
 			}
 		}
 
@@ -151,3 +156,43 @@ func runStart(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// checkImageLock refuses to start images that drift from the digests pinned in
+// kk.lock, unless --allow-drift is set. Projects without a lockfile are not checked.
+func checkImageLock(cwd string, composeFile *compose.ComposeFile) error {
+	lock, err := updater.LoadLock(cwd)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("lock_read_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("lock_drift_suggestion"),
+			Command:    "kk update",
+		})
+		return err
+	}
+	if lock == nil {
+		return nil
+	}
+
+	drift := lock.Drift(composeFile.GetServiceImages())
+	if len(drift) == 0 {
+		return nil
+	}
+	if startAllowDrift {
+		ui.ShowWarning(ui.Msg("lock_drift_allowed"))
+		return nil
+	}
+
+	lines := make([]string, len(drift))
+	for i, d := range drift {
+		lines[i] = "  " + ui.MsgF("lock_drift_locked", d.Image, d.Locked)
+	}
+	err = errors.New(ui.MsgF("lock_drift_message", strings.Join(lines, "\n")))
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("lock_drift_title"),
+		Message:    err.Error(),
+		Suggestion: ui.Msg("lock_drift_suggestion"),
+		Command:    "kk update",
+	})
+	return err
+}
@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"io"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/pterm/pterm"
 	"github.com/spf13/cobra"
 
 	"github.com/kkauto-net/kk-install/pkg/compose"
@@ -21,11 +25,22 @@ var statusCmd = &cobra.Command{
 	RunE:        runStatus,
 }
 
+var (
+	statusWatch  bool
+	statusFailOn string
+)
+
 func init() {
+	statusCmd.Flags().BoolVarP(&statusWatch, "watch", "w", false, "Keep a live table updated from Docker events until interrupted")
+	statusCmd.Flags().StringVar(&statusFailOn, "fail-on", "", `Exit non-zero when a service becomes unhealthy ("unhealthy")`)
 	rootCmd.AddCommand(statusCmd)
 }
 
 func runStatus(cmd *cobra.Command, args []string) error {
+	if err := validateFailOn(statusFailOn); err != nil {
+		return err
+	}
+
 	cwd, err := config.EnsureProjectDir()
 	if err != nil {
 		ui.ShowBoxedError(ui.ErrorSuggestion{
@@ -37,7 +52,10 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	ui.ShowCommandBanner(ui.Msg("cmd_status_title"), ui.Msg("status_desc"))
+	structured := ui.IsStructuredOutput()
+	if !structured {
+		ui.ShowCommandBanner(ui.Msg("cmd_status_title"), ui.Msg("status_desc"))
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -55,16 +73,28 @@ func runStatus(cmd *cobra.Command, args []string) error {
 
 	definedServices := composeFile.GetServiceNames()
 	if len(definedServices) == 0 {
+		if structured {
+			return writeStatuses(ctx, nil)
+		}
 		ui.ShowWarning(ui.Msg("no_services_defined"))
 		ui.ShowNote(ui.Msg("run_init"))
 		return nil
 	}
 
+	if statusWatch {
+		return runStatusWatch(composeFile)
+	}
+
 	executor := compose.NewExecutor(cwd)
-	spinner := ui.StartPtermSpinner(ui.Msg("get_status_failed"))
+	var spinner *pterm.SpinnerPrinter
+	if !structured {
+		spinner = ui.StartPtermSpinner(ui.Msg("get_status_failed"))
+	}
 	statuses, err := monitor.GetStatusWithServices(ctx, executor, definedServices)
 	if err != nil {
-		spinner.Fail(ui.Msg("get_status_failed"))
+		if spinner != nil {
+			spinner.Fail(ui.Msg("get_status_failed"))
+		}
 
 		suggestion := ui.Msg("err_check_docker_running")
 		command := ui.Msg("docker_start_command")
@@ -81,6 +111,9 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		})
 		return err
 	}
+	if structured {
+		return writeStatuses(ctx, statuses)
+	}
 	spinner.Success(ui.Msg("status_desc"))
 
 	ui.PrintStatusTable(statuses)
@@ -96,6 +129,13 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		ui.ShowNote(ui.MsgF("unhealthy_services_logs", unhealthyServices[0]))
 	}
 
+	for _, s := range statuses {
+		if s.Name == "db" && s.PublishesPublicly(3306) {
+			ui.ShowWarning(ui.Msg("db_published_publicly"))
+			ui.ShowNote(ui.Msg("db_published_publicly_hint"))
+		}
+	}
+
 	for _, s := range statuses {
 		if s.Running {
 			domain := config.ReadEnvValue(cwd, "SYSTEM_DOMAIN")
@@ -104,5 +144,32 @@ func runStatus(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	if statusFailOn == failOnUnhealthy && !monitor.IsAllHealthy(statuses) {
+		return NewExitError(exitCodeUnhealthy, errors.New("not all services are healthy"))
+	}
+	return nil
+}
+
+// writeStatuses prints statuses in the structured output format, enriched
+// with image digests and uptime. It returns an exit error when any service is
+// not healthy so scripts can rely on the exit code alone.
+func writeStatuses(ctx context.Context, statuses []monitor.ServiceStatus) error {
+	if statuses == nil {
+		statuses = []monitor.ServiceStatus{}
+	}
+	if cli, err := monitor.NewDockerClient(); err == nil {
+		monitor.EnrichStatuses(ctx, cli, statuses, time.Now())
+		warnOnError(cli.Close())
+	}
+	return encodeStatuses(os.Stdout, statuses)
+}
+
+func encodeStatuses(w io.Writer, statuses []monitor.ServiceStatus) error {
+	if err := ui.WriteStructured(w, statuses); err != nil {
+		return err
+	}
+	if !monitor.IsAllHealthy(statuses) {
+		return NewExitError(exitCodeUnhealthy, errors.New("not all services are healthy"))
+	}
 	return nil
 }
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+func TestEncodeStatuses(t *testing.T) {
+	ui.SetOutputFormat(ui.OutputJSON)
+	defer ui.SetOutputFormat(ui.OutputTable)
+
+	healthy := []monitor.ServiceStatus{{Name: "db", ContainerName: "kkengine_db", Running: true, Health: "healthy"}}
+	var buf bytes.Buffer
+	if err := encodeStatuses(&buf, healthy); err != nil {
+		t.Fatalf("encodeStatuses() error = %v", err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, buf.String())
+	}
+	if len(decoded) != 1 || decoded[0]["container_name"] != "kkengine_db" {
+		t.Fatalf("decoded = %v", decoded)
+	}
+
+	buf.Reset()
+	unhealthy := []monitor.ServiceStatus{{Name: "db", Running: true, Health: "unhealthy"}}
+	err := encodeStatuses(&buf, unhealthy)
+	if ExitCode(err) != exitCodeUnhealthy {
+		t.Fatalf("ExitCode() = %d, want %d", ExitCode(err), exitCodeUnhealthy)
+	}
+	if buf.Len() == 0 {
+		t.Fatal("unhealthy statuses should still be printed")
+	}
+}
+
+func TestValidateFailOn(t *testing.T) {
+	for _, v := range []string{"", "unhealthy"} {
+		if err := validateFailOn(v); err != nil {
+			t.Fatalf("validateFailOn(%q) error = %v", v, err)
+		}
+	}
+	if err := validateFailOn("degraded"); ExitCode(err) != exitCodeInputValidation {
+		t.Fatalf("validateFailOn(degraded) exit code = %d, want %d", ExitCode(err), exitCodeInputValidation)
+	}
+}
+
+func TestFirstUnhealthy(t *testing.T) {
+	tests := []struct {
+		states []monitor.WatchState
+		want   string
+	}{
+		{[]monitor.WatchState{{Service: "db", State: "running", Health: "healthy"}, {Service: "redis", State: "running"}}, ""},
+		{[]monitor.WatchState{{Service: "db", State: "running", Health: "starting"}, {Service: "redis", State: "missing"}}, "redis"},
+		{[]monitor.WatchState{{Service: "db", State: "running", Health: "unhealthy"}}, "db"},
+	}
+	for _, tt := range tests {
+		if got := firstUnhealthy(tt.states); got != tt.want {
+			t.Errorf("firstUnhealthy(%v) = %q, want %q", tt.states, got, tt.want)
+		}
+	}
+}
+
+func TestRenderWatch(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	states := []monitor.WatchState{
+		{Service: "db", State: "running", Health: "healthy", Changed: now.Add(-time.Minute)},
+		{Service: "kkengine", State: "running", Health: "unhealthy", RestartCount: 3, OOMKills: 1, Changed: now.Add(-2 * time.Second)},
+	}
+	recent := []monitor.WatchEvent{{Time: now, Service: "kkengine", Kind: monitor.WatchOOM, Message: "killed: out of memory"}}
+
+	out := pterm.RemoveColorFromString(renderWatch(states, recent, now))
+	for _, want := range []string{"● kkengine", "unhealthy", "3", "kkengine  oom (killed: out of memory)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("renderWatch() missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "● db") {
+		t.Errorf("renderWatch() highlighted a row that changed a minute ago:\n%s", out)
+	}
+}
+
+func TestWatchNotification(t *testing.T) {
+	tests := []struct {
+		ev     monitor.WatchEvent
+		status string
+		ok     bool
+	}{
+		{monitor.WatchEvent{Service: "db", Kind: monitor.WatchHealth, From: "healthy", To: "unhealthy"}, "unhealthy", true},
+		{monitor.WatchEvent{Service: "db", Kind: monitor.WatchHealth, From: "unhealthy", To: "healthy"}, "healthy", true},
+		{monitor.WatchEvent{Service: "db", Kind: monitor.WatchHealth, From: "starting", To: "healthy"}, "", false},
+		{monitor.WatchEvent{Service: "db", Kind: monitor.WatchOOM, Message: "killed: out of memory"}, "oom-killed", true},
+		{monitor.WatchEvent{Service: "db", Kind: monitor.WatchDie, From: "running", To: "exited"}, "exited", true},
+		{monitor.WatchEvent{Service: "db", Kind: monitor.WatchStart, To: "running"}, "", false},
+	}
+	for _, tt := range tests {
+		ev, ok := watchNotification(tt.ev)
+		if ok != tt.ok || ev.Status != tt.status {
+			t.Errorf("watchNotification(%+v) = %q, %v; want %q, %v", tt.ev, ev.Status, ok, tt.status, tt.ok)
+		}
+	}
+}
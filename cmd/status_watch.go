@@ -0,0 +1,257 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pterm/pterm"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/notify"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+// failOnUnhealthy is the only supported --fail-on value.
+const failOnUnhealthy = "unhealthy"
+
+const (
+	// watchRecentEvents is how many transitions are listed under the table.
+	watchRecentEvents = 8
+	// watchHighlight is how long a changed row stays highlighted.
+	watchHighlight = 10 * time.Second
+)
+
+func validateFailOn(s string) error {
+	if s != "" && s != failOnUnhealthy {
+		return NewExitError(exitCodeInputValidation, fmt.Errorf("invalid --fail-on value %q (use unhealthy)", s))
+	}
+	return nil
+}
+
+// runStatusWatch follows the stack's containers through Docker events and
+// redraws the status table on every transition until interrupted. With
+// --fail-on unhealthy it returns an exit error on the first unhealthy service.
+func runStatusWatch(composeFile *compose.ComposeFile) error {
+	cli, err := monitor.NewDockerClient()
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("get_status_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("err_check_docker_running"),
+			Command:    ui.Msg("docker_start_command"),
+		})
+		return err
+	}
+	defer func() {
+		warnOnError(cli.Close())
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigChan)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
+
+	containers := make(map[string]string)
+	for _, service := range composeFile.GetServiceNames() {
+		containers[composeFile.GetServiceContainerName(service)] = service
+	}
+	watcher := monitor.NewWatcher(cli, containers)
+	watcher.Init(ctx, time.Now())
+
+	structured := ui.IsStructuredOutput()
+	var area *pterm.AreaPrinter
+	var recent []monitor.WatchEvent
+	var notifyWarning string
+	render := func() {
+		if area != nil {
+			out := renderWatch(watcher.States(), recent, time.Now())
+			if notifyWarning != "" {
+				out += pterm.Yellow(notifyWarning) + "\n"
+			}
+			area.Update(out)
+		}
+	}
+	if structured {
+		for _, st := range watcher.States() {
+			if err := ui.WriteStructuredRecord(os.Stdout, st); err != nil {
+				return err
+			}
+		}
+	} else {
+		ui.ShowInfo(ui.Msg("status_watch_hint"))
+		if area, err = pterm.DefaultArea.Start(); err != nil {
+			return err
+		}
+		defer func() { warnOnError(area.Stop()) }()
+		render()
+	}
+
+	if statusFailOn == failOnUnhealthy {
+		if service := firstUnhealthy(watcher.States()); service != "" {
+			return NewExitError(exitCodeUnhealthy, errors.New(ui.MsgF("status_watch_unhealthy", service)))
+		}
+	}
+
+	// Deliver notifications in the background so retries never stall the
+	// table; wait for pending ones before exiting.
+	var notifications sync.WaitGroup
+	defer notifications.Wait()
+	notifyErrs := make(chan error, 1)
+
+	evCh := make(chan monitor.WatchEvent)
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- watcher.Run(ctx, func(ev monitor.WatchEvent) {
+			select {
+			case evCh <- ev:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev := <-evCh:
+			if structured {
+				if err := ui.WriteStructuredRecord(os.Stdout, ev); err != nil {
+					return err
+				}
+			} else {
+				recent = append(recent, ev)
+				if len(recent) > watchRecentEvents {
+					recent = recent[len(recent)-watchRecentEvents:]
+				}
+				render()
+			}
+			if nev, ok := watchNotification(ev); ok {
+				notifications.Add(1)
+				go func() {
+					defer notifications.Done()
+					if err := sendNotification(nev); err != nil {
+						select {
+						case notifyErrs <- err:
+						default:
+						}
+					}
+				}()
+			}
+			if statusFailOn == failOnUnhealthy && ev.Unhealthy() {
+				return NewExitError(exitCodeUnhealthy, errors.New(ui.MsgF("status_watch_unhealthy", ev.Service)))
+			}
+		case err := <-notifyErrs:
+			notifyWarning = ui.MsgF("notify_failed", ui.SanitizeError(err))
+			render()
+		case <-ticker.C:
+			render()
+		case err := <-runErr:
+			if err != nil {
+				ui.ShowBoxedError(ui.ErrorSuggestion{
+					Title:      ui.Msg("get_status_failed"),
+					Message:    ui.SanitizeError(err),
+					Suggestion: ui.Msg("err_check_docker_running"),
+					Command:    ui.Msg("docker_start_command"),
+				})
+			}
+			return err
+		}
+	}
+}
+
+// watchNotification maps a transition to a health notification: services
+// that turn unhealthy, die or are OOM killed, and services that recover.
+func watchNotification(ev monitor.WatchEvent) (notify.Event, bool) {
+	switch {
+	case ev.Unhealthy():
+		status := ev.To
+		if ev.Kind == monitor.WatchOOM {
+			status = "oom-killed"
+		}
+		return notify.HealthEvent(ev.Service, status, ev.Message), true
+	case ev.Kind == monitor.WatchHealth && ev.From == "unhealthy" && ev.To == "healthy":
+		return notify.HealthEvent(ev.Service, ev.To, ""), true
+	}
+	return notify.Event{}, false
+}
+
+// firstUnhealthy returns the first service that is not running or reports
+// an unhealthy healthcheck, or "".
+func firstUnhealthy(states []monitor.WatchState) string {
+	for _, st := range states {
+		if st.State != "running" || st.Health == "unhealthy" {
+			return st.Service
+		}
+	}
+	return ""
+}
+
+// renderWatch draws the live table followed by the latest transitions.
+// Rows that changed within watchHighlight are highlighted.
+func renderWatch(states []monitor.WatchState, recent []monitor.WatchEvent, now time.Time) string {
+	tableData := pterm.TableData{{
+		ui.Msg("col_service"), ui.Msg("col_status"), ui.Msg("col_health"),
+		ui.Msg("col_restarts"), ui.Msg("col_oom_kills"), ui.Msg("col_changed"),
+	}}
+	for _, st := range states {
+		health := st.Health
+		if health == "" {
+			health = "-"
+		}
+		changed := "-"
+		if !st.Changed.IsZero() {
+			changed = st.Changed.Local().Format("15:04:05")
+		}
+		row := []string{st.Service, st.State, health, strconv.Itoa(st.RestartCount), strconv.Itoa(st.OOMKills), changed}
+		switch {
+		case st.State != "running" || st.Health == "unhealthy" || st.OOMKills > 0:
+			row[1], row[2] = pterm.Red(row[1]), pterm.Red(row[2])
+		case st.Health == "starting":
+			row[2] = pterm.Yellow(row[2])
+		default:
+			row[1], row[2] = pterm.Green(row[1]), pterm.Green(row[2])
+		}
+		if !st.Changed.IsZero() && now.Sub(st.Changed) < watchHighlight {
+			row[0] = pterm.Bold.Sprint(pterm.Yellow("● " + st.Service))
+		}
+		tableData = append(tableData, row)
+	}
+	table, err := pterm.DefaultTable.WithHasHeader(true).WithBoxed(true).WithData(tableData).Srender()
+	if err != nil {
+		table = err.Error()
+	}
+
+	out := table + "\n"
+	for _, ev := range recent {
+		out += "  " + formatWatchEvent(ev) + "\n"
+	}
+	return out
+}
+
+func formatWatchEvent(ev monitor.WatchEvent) string {
+	line := ev.Time.Local().Format("15:04:05") + "  " + ev.Service + "  " + ev.Kind
+	if ev.From != "" || ev.To != "" {
+		line += ": " + ev.From + " → " + ev.To
+	}
+	if ev.Message != "" {
+		line += " (" + ev.Message + ")"
+	}
+	if ev.Unhealthy() {
+		return pterm.Red(line)
+	}
+	return line
+}
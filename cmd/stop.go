@@ -61,7 +61,7 @@ func runStop(cmd *cobra.Command, args []string) error {
 		spinner.Fail(ui.Msg("stop_failed"))
 
 		suggestion := ui.Msg("err_check_docker_logs")
-		command := ui.Msg("docker_compose_logs_command")
+		command := ui.Msg("kk_logs_command")
 		if ui.IsDockerPermissionError(err) {
 			suggestion, command = ui.DockerPermissionSuggestion()
 		}
@@ -2,10 +2,15 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/charmbracelet/huh"
 	"github.com/spf13/cobra"
@@ -13,21 +18,40 @@ import (
 	"github.com/kkauto-net/kk-install/pkg/compose"
 	"github.com/kkauto-net/kk-install/pkg/config"
 	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/notify"
 	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/updater"
 )
 
 var updateCmd = &cobra.Command{
-	Use:         "update",
-	Short:       "Pull latest images and restart services",
-	Long:        `Pull new images when available and optionally restart services to run the new version.`,
+	Use:   "update",
+	Short: "Pull latest images and restart services",
+	Long: `Pull new images when available and optionally restart services to run the new version.
+
+The images running before the update are recorded. If services do not become
+healthy after the restart, kk update rolls back to them automatically; use
+--rollback to return to them manually.
+
+Runs never overlap: a second kk update exits while one is in progress. Every run
+is recorded under ~/.kk/update-history; see kk update history and
+kk update schedule for unattended runs.`,
 	Annotations: map[string]string{"group": "management"},
 	RunE:        runUpdate,
 }
 
-var forceUpdate bool
+var (
+	forceUpdate     bool
+	rollbackUpdate  bool
+	scheduledUpdate bool
+	updateMaxDelay  time.Duration
+)
 
 func init() {
 	updateCmd.Flags().BoolVarP(&forceUpdate, "force", "f", false, "Skip confirmation prompts")
+	updateCmd.Flags().BoolVar(&rollbackUpdate, "rollback", false, "Return to the images that were running before the last update")
+	updateCmd.Flags().DurationVar(&updateMaxDelay, "max-delay", 0, "Wait a random time up to this duration before updating (spreads scheduled runs)")
+	updateCmd.Flags().BoolVar(&scheduledUpdate, "scheduled", false, "Mark the run as scheduled in the update history")
+	updateCmd.Flags().Lookup("scheduled").Hidden = true
 	rootCmd.AddCommand(updateCmd)
 }
 
@@ -43,7 +67,11 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	ui.ShowCommandBanner(ui.Msg("cmd_update_title"), ui.Msg("update_desc"))
+	if rollbackUpdate {
+		ui.ShowCommandBanner(ui.Msg("cmd_rollback_title"), ui.Msg("rollback_desc"))
+	} else {
+		ui.ShowCommandBanner(ui.Msg("cmd_update_title"), ui.Msg("update_desc"))
+	}
 
 	// Setup graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -57,13 +85,68 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		cancel()
 	}()
 
+	if err := waitUpdateDelay(ctx); err != nil {
+		return err
+	}
+
+	record := &updater.RunRecord{StartedAt: time.Now(), ProjectDir: cwd, Trigger: updater.TriggerManual}
+	if scheduledUpdate {
+		record.Trigger = updater.TriggerScheduled
+	}
+
+	runLock, err := updater.AcquireRunLock(updateLockPath())
+	if err != nil {
+		if errors.Is(err, updater.ErrUpdateRunning) {
+			record.Outcome = updater.OutcomeSkipped
+		}
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("update_locked"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("update_locked_suggestion"),
+			Command:    "kk update history",
+		})
+		recordUpdateRun(record, err)
+		return err
+	}
+	defer func() {
+		warnOnError(runLock.Release())
+	}()
+
 	executor := compose.NewExecutor(cwd)
+	if rollbackUpdate {
+		err = runUpdateRollback(ctx, cwd, executor, record)
+	} else {
+		err = applyUpdate(ctx, cwd, executor, record)
+	}
+	recordUpdateRun(record, err)
+	return err
+}
+
+// applyUpdate pulls, shows and applies new images, filling in record as it goes.
+func applyUpdate(ctx context.Context, cwd string, executor *compose.Executor, record *updater.RunRecord) (err error) {
 	imageState, err := prepareUpdateImageState(ctx, cwd)
 	if err != nil {
 		showUpdatePreparationError(err)
 		return err
 	}
 
+	// Record the running images before pulling; kept only if containers get replaced
+	undoRollbackPoint, err := saveRollbackPoint(imageState)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("rollback_save_failed"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("lock_write_failed_suggestion"),
+		})
+		return err
+	}
+	replaced := false
+	defer func() {
+		if !replaced {
+			undoRollbackPoint()
+		}
+	}()
+
 	// Step 1: Pull new images
 	ui.ShowStepHeader(1, 4, ui.Msg("step_pull_images"))
 	spinner := ui.StartPtermSpinner(ui.Msg("pulling_images"))
@@ -71,7 +154,7 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	pullCtx, pullCancel := context.WithTimeout(ctx, compose.DefaultTimeout)
 	defer pullCancel()
 
-	_, err = executor.Pull(pullCtx)
+	err = pullUpdateImages(pullCtx, executor, imageState)
 	if err != nil {
 		spinner.Fail(ui.Msg("pull_failed"))
 
@@ -101,8 +184,25 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	record.ImagesChanged = updater.ChangedImages(updates)
+	if len(updates) > 0 {
+		defer func() {
+			notifyUpdate(record.Outcome, updates, err)
+		}()
+	}
+
 	if len(updates) == 0 {
+		record.Outcome = updater.OutcomeUpToDate
 		ui.ShowOK(ui.Msg("images_up_to_date"))
+		// Record the current digests the first time, or after a manual edit
+		changed, lockErr := writeImageLock(imageState)
+		if lockErr != nil {
+			showLockWriteError(lockErr)
+			return lockErr
+		}
+		if changed {
+			ui.ShowOK(ui.Msg("lock_written"))
+		}
 		return nil
 	}
 
@@ -124,12 +224,21 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 		return confirmErr
 	}
 	if !confirmed {
+		record.Outcome = updater.OutcomeCancelled
 		return nil
 	}
 
 	// Step 3: Restart services with new images
 	ui.ShowStepHeader(3, 4, ui.Msg("step_recreate"))
 
+	// Pin the new digests before recreating so compose starts exactly what was pulled
+	replaced = true
+	if _, lockErr := writeImageLock(imageState); lockErr != nil {
+		showLockWriteError(lockErr)
+		return lockErr
+	}
+	ui.ShowOK(ui.Msg("lock_written"))
+
 	recreateCtx, recreateCancel := context.WithTimeout(ctx, compose.DefaultTimeout)
 	defer recreateCancel()
 
@@ -141,14 +250,35 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 			Title:      ui.Msg("restart_failed"),
 			Message:    ui.SanitizeError(err),
 			Suggestion: ui.Msg("err_check_docker_logs"),
-			Command:    ui.Msg("docker_compose_logs_command"),
+			Command:    ui.Msg("kk_logs_command"),
 		})
 		return fmt.Errorf("%s: %w", ui.Msg("restart_failed"), err)
 	}
 	restartSpinner.Success(ui.Msg("restart_complete"))
 
 	definedServices := imageState.composeFile.GetServiceNames()
-	monitorUpdateHealth(recreateCtx, imageState.composeFile)
+	healthResults := monitorComposeHealth(recreateCtx, imageState.composeFile)
+	if healthResults != nil {
+		record.Health = updater.HealthHealthy
+	}
+	if unhealthy := unhealthyServices(healthResults); len(unhealthy) > 0 {
+		record.Health = updater.HealthUnhealthy
+		record.Unhealthy = unhealthy
+		ui.ShowWarning(ui.MsgF("rollback_auto", strings.Join(unhealthy, ", ")))
+		point, loadErr := updater.LoadRollbackPoint(cwd)
+		if loadErr != nil {
+			return showRollbackError(loadErr, "")
+		}
+		// Recreate and health waiting may have used up recreateCtx.
+		rollbackCtx, rollbackCancel := context.WithTimeout(ctx, compose.DefaultTimeout)
+		defer rollbackCancel()
+		if err := rollbackImages(rollbackCtx, cwd, executor, imageState.composeFile, point); err != nil {
+			return err
+		}
+		record.Outcome = updater.OutcomeRolledBack
+		return rolledBackError(unhealthy)
+	}
+	record.Outcome = updater.OutcomeUpdated
 
 	// Step 4: Show status
 	ui.ShowStepHeader(4, 4, ui.Msg("step_status"))
@@ -165,6 +295,50 @@ func runUpdate(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// notifyUpdate reports a run that found new images. Cancelled runs changed
+// nothing and are not reported.
+func notifyUpdate(outcome string, updates []updater.ImageUpdate, err error) {
+	if outcome == updater.OutcomeCancelled {
+		return
+	}
+	if outcome == "" {
+		outcome = updater.OutcomeFailed
+	}
+	notifyOrWarn(notify.UpdateEvent(outcome, updates, err))
+}
+
+// waitUpdateDelay sleeps a random time below --max-delay so scheduled runs
+// across many servers do not hit the registry at the same moment.
+func waitUpdateDelay(ctx context.Context) error {
+	if updateMaxDelay <= 0 {
+		return nil
+	}
+	delay := rand.N(updateMaxDelay).Round(time.Second)
+	ui.ShowInfo(ui.MsgF("update_delay", delay))
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func updateLockPath() string {
+	return filepath.Join(config.StateDir(), "update.lock")
+}
+
+func updateHistoryDir() string {
+	return filepath.Join(config.StateDir(), "update-history")
+}
+
+// recordUpdateRun finalises record and stores it in the update history.
+func recordUpdateRun(record *updater.RunRecord, err error) {
+	record.Finish(time.Now(), err)
+	if writeErr := updater.WriteRecord(updateHistoryDir(), record); writeErr != nil {
+		ui.ShowWarning(fmt.Sprintf("Cannot write update history: %v", writeErr))
+	}
+}
+
 func confirmUpdateRestart() (bool, error) {
 	if forceUpdate {
 		return true, nil
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+var updateHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List past kk update runs",
+	Long:  `List recorded kk update runs, newest first. Records are JSON files in ~/.kk/update-history.`,
+	Args:  cobra.NoArgs,
+	RunE:  runUpdateHistory,
+}
+
+var historyLimit int
+
+func init() {
+	updateHistoryCmd.Flags().IntVarP(&historyLimit, "limit", "n", 20, "Number of runs to show (0 for all)")
+	updateCmd.AddCommand(updateHistoryCmd)
+}
+
+func runUpdateHistory(cmd *cobra.Command, args []string) error {
+	ui.ShowCommandBanner(ui.Msg("cmd_update_history_title"), ui.Msg("update_history_desc"))
+
+	records, err := updater.ReadRecords(updateHistoryDir(), historyLimit)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:   ui.Msg("update_history_failed"),
+			Message: ui.SanitizeError(err),
+		})
+		return err
+	}
+	if len(records) == 0 {
+		ui.ShowInfo(ui.Msg("update_history_empty"))
+		return nil
+	}
+
+	ui.PrintUpdateHistory(historyEntries(records))
+	return nil
+}
+
+func historyEntries(records []updater.RunRecord) []ui.UpdateHistoryEntry {
+	entries := make([]ui.UpdateHistoryEntry, len(records))
+	for i, r := range records {
+		health := r.Health
+		if health == updater.HealthUnknown {
+			health = ""
+		}
+		entries[i] = ui.UpdateHistoryEntry{
+			StartedAt: r.StartedAt.Local().Format("2006-01-02 15:04:05"),
+			Trigger:   r.Trigger,
+			Outcome:   r.Outcome,
+			Images:    len(r.ImagesChanged),
+			Health:    health,
+			Duration:  (time.Duration(r.DurationSeconds * float64(time.Second))).Round(time.Second).String(),
+			Error:     r.Error,
+		}
+	}
+	return entries
+}
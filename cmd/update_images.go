@@ -3,19 +3,26 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/kkauto-net/kk-install/pkg/compose"
 	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/spec"
 	"github.com/kkauto-net/kk-install/pkg/ui"
 	"github.com/kkauto-net/kk-install/pkg/updater"
 )
 
 type updateImageState struct {
+	cwd         string
 	composeFile *compose.ComposeFile
-	images      []string
+	images      []string // Floating references, digest pins stripped
+	pinned      bool     // docker-compose.yml references image digests
 	inspector   updater.ImageInspector
 	containers  updater.ContainerInspector
+	puller      updater.ImagePuller
+	lock        *updater.Lockfile
 	before      map[string]updater.ImageIdentity
+	after       map[string]updater.ImageIdentity
 }
 
 func prepareUpdateImageState(ctx context.Context, cwd string) (*updateImageState, error) {
@@ -24,11 +31,29 @@ func prepareUpdateImageState(ctx context.Context, cwd string) (*updateImageState
 		return nil, err
 	}
 
-	images := composeFile.GetServiceImages()
-	if len(images) == 0 {
+	refs := composeFile.GetServiceImages()
+	if len(refs) == 0 {
 		return nil, fmt.Errorf("no service images defined in docker-compose.yml")
 	}
 
+	// Updates are resolved against the floating tags; pins only record the result
+	pinned := false
+	seen := make(map[string]bool, len(refs))
+	var images []string
+	for _, ref := range refs {
+		pinned = pinned || updater.IsPinned(ref)
+		base := updater.BaseImage(ref)
+		if !seen[base] {
+			seen[base] = true
+			images = append(images, base)
+		}
+	}
+
+	lock, err := updater.LoadLock(cwd)
+	if err != nil {
+		return nil, err
+	}
+
 	inspector := updater.NewDockerImageInspector()
 	inspectCtx, cancel := context.WithTimeout(ctx, compose.DefaultTimeout)
 	defer cancel()
@@ -39,14 +64,28 @@ func prepareUpdateImageState(ctx context.Context, cwd string) (*updateImageState
 	}
 
 	return &updateImageState{
+		cwd:         cwd,
 		composeFile: composeFile,
 		images:      images,
+		pinned:      pinned,
 		inspector:   inspector,
 		containers:  inspector,
+		puller:      inspector,
+		lock:        lock,
 		before:      before,
 	}, nil
 }
 
+// pullUpdateImages pulls new images. A pinned compose file would only re-pull the
+// locked digests, so the floating tags are pulled directly instead.
+func pullUpdateImages(ctx context.Context, executor *compose.Executor, state *updateImageState) error {
+	if state.pinned {
+		return updater.PullImages(ctx, state.images, state.puller)
+	}
+	_, err := executor.Pull(ctx)
+	return err
+}
+
 func detectUpdatesAfterPull(ctx context.Context, state *updateImageState) ([]updater.ImageUpdate, error) {
 	inspectCtx, cancel := context.WithTimeout(ctx, compose.DefaultTimeout)
 	defer cancel()
@@ -55,6 +94,7 @@ func detectUpdatesAfterPull(ctx context.Context, state *updateImageState) ([]upd
 	if err != nil {
 		return nil, err
 	}
+	state.after = after
 
 	imageUpdates, err := updater.CompareSnapshots(state.before, after)
 	if err != nil {
@@ -77,13 +117,55 @@ func serviceContainerTargets(composeFile *compose.ComposeFile) []updater.Contain
 			continue
 		}
 		targets = append(targets, updater.ContainerTarget{
-			Image:     image,
+			Image:     updater.BaseImage(image),
 			Container: composeFile.GetServiceContainerName(name),
 		})
 	}
 	return targets
 }
 
+// writeImageLock records the digests resolved by the last pull in kk.lock and pins
+// docker-compose.yml to them. Returns whether anything changed on disk.
+func writeImageLock(state *updateImageState) (bool, error) {
+	lock := updater.NewLockfile(state.after, time.Now())
+	changed := false
+	if !lock.Equal(state.lock) {
+		if err := lock.Save(state.cwd); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	rewritten, err := pinComposeImages(state.cwd, lock.Ref)
+	if err != nil {
+		return changed, err
+	}
+	return changed || rewritten, nil
+}
+
+// pinComposeImages rewrites the images in docker-compose.yml and in kk's
+// record of what it last wrote there, so the pins are not taken for local
+// edits. Edits made by hand are kept: only image values change.
+func pinComposeImages(cwd string, refFor func(image string) string) (bool, error) {
+	rewritten, err := compose.RewriteImages(cwd, refFor)
+	if err != nil {
+		return false, err
+	}
+	err = spec.RewriteBase(cwd, func(dir string) error {
+		_, rewriteErr := compose.RewriteImages(dir, refFor)
+		return rewriteErr
+	})
+	return rewritten, err
+}
+
+func showLockWriteError(err error) {
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("lock_write_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: ui.Msg("lock_write_failed_suggestion"),
+	})
+}
+
 func showUpdatePreparationError(err error) {
 	ui.ShowBoxedError(ui.ErrorSuggestion{
 		Title:      ui.Msg("pull_failed"),
@@ -93,23 +175,16 @@ func showUpdatePreparationError(err error) {
 	})
 }
 
-func monitorUpdateHealth(ctx context.Context, composeFile *compose.ComposeFile) {
+// monitorComposeHealth waits for every compose service to report healthy and
+// prints progress as it goes.
+func monitorComposeHealth(ctx context.Context, composeFile *compose.ComposeFile) []monitor.HealthStatus {
 	healthMonitor, err := monitor.NewHealthMonitor()
 	if err != nil {
-		return
+		return nil
 	}
 	defer healthMonitor.Close()
 
-	var containers []monitor.ContainerInfo
-	for _, name := range composeFile.GetServiceNames() {
-		containers = append(containers, monitor.ContainerInfo{
-			ServiceName:    name,
-			ContainerName:  composeFile.GetServiceContainerName(name),
-			HasHealthCheck: composeFile.HasHealthCheck(name),
-		})
-	}
-
-	healthMonitor.MonitorAll(ctx, containers, func(status monitor.HealthStatus) {
+	return healthMonitor.MonitorAll(ctx, monitor.ComposeContainers(composeFile), func(status monitor.HealthStatus) {
 		ui.ShowServiceProgress(status.ServiceName, status.Status)
 	})
 }
@@ -3,6 +3,8 @@ package cmd
 import (
 	"context"
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/kkauto-net/kk-install/pkg/compose"
@@ -59,3 +61,55 @@ func TestDetectUpdatesAfterPullIncludesStaleRunningContainer(t *testing.T) {
 		t.Fatalf("update = %#v", updates[0])
 	}
 }
+
+func TestServiceContainerTargetsStripDigestPins(t *testing.T) {
+	composeFile := &compose.ComposeFile{
+		Services: map[string]compose.Service{
+			"redis": {Image: "redis:alpine@sha256:old"},
+		},
+	}
+
+	targets := serviceContainerTargets(composeFile)
+	if len(targets) != 1 || targets[0].Image != "redis:alpine" || targets[0].Container != "kkengine_redis" {
+		t.Fatalf("targets = %#v", targets)
+	}
+}
+
+func TestWriteImageLockPinsComposeFile(t *testing.T) {
+	dir := t.TempDir()
+	composePath := filepath.Join(dir, "docker-compose.yml")
+	if err := os.WriteFile(composePath, []byte("services:\n  redis:\n    image: redis:alpine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &updateImageState{
+		cwd: dir,
+		after: map[string]updater.ImageIdentity{
+			"redis:alpine": {Image: "redis:alpine", Value: "sha256:new", Present: true, Source: updater.IdentitySourceRepoDigest},
+		},
+	}
+	changed, err := writeImageLock(state)
+	if err != nil {
+		t.Fatalf("writeImageLock() error = %v", err)
+	}
+	if !changed {
+		t.Fatal("writeImageLock() changed = false, want true")
+	}
+
+	composeFile, err := compose.ParseComposeFile(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := composeFile.Services["redis"].Image; got != "redis:alpine@sha256:new" {
+		t.Fatalf("compose image = %q", got)
+	}
+	lock, err := updater.LoadLock(dir)
+	if err != nil || lock == nil {
+		t.Fatalf("LoadLock() = %v, %v", lock, err)
+	}
+
+	state.lock = lock
+	if changed, err := writeImageLock(state); err != nil || changed {
+		t.Fatalf("second writeImageLock() = %v, %v; want no change", changed, err)
+	}
+}
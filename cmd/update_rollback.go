@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+// saveRollbackPoint records the pre-update images so a bad update can be undone.
+// The returned undo restores the previous rollback file and must be called when
+// the update ends without touching the running containers.
+func saveRollbackPoint(state *updateImageState) (func(), error) {
+	path := filepath.Join(state.cwd, updater.RollbackFileName)
+	previous, readErr := os.ReadFile(path)
+
+	point := updater.NewRollbackPoint(state.composeFile.GetServiceImages(), state.before, state.lock, time.Now())
+	if err := point.Save(state.cwd); err != nil {
+		return func() {}, err
+	}
+
+	return func() {
+		if readErr == nil {
+			warnOnError(os.WriteFile(path, previous, 0644))
+			return
+		}
+		warnOnError(os.Remove(path))
+	}, nil
+}
+
+// runUpdateRollback handles kk update --rollback.
+func runUpdateRollback(ctx context.Context, cwd string, executor *compose.Executor, record *updater.RunRecord) error {
+	point, err := updater.LoadRollbackPoint(cwd)
+	if err != nil {
+		suggestion := ""
+		if errors.Is(err, updater.ErrNoRollbackPoint) {
+			suggestion = ui.Msg("rollback_none_suggestion")
+		}
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("rollback_none"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: suggestion,
+		})
+		return err
+	}
+
+	// Step 1: Show what will be restored
+	ui.ShowStepHeader(1, 3, ui.Msg("step_rollback_review"))
+	ui.ShowInfo(ui.MsgF("rollback_recorded_at", point.CreatedAt.Local().Format(time.RFC1123)))
+	rows := make([]ui.ImageUpdate, 0, len(point.Images))
+	for _, img := range point.Images {
+		if img.ID == "" {
+			continue
+		}
+		rows = append(rows, ui.ImageUpdate{Image: img.Image, OldDigest: currentImageIdentity(ctx, img.Image), NewDigest: img.Digest})
+	}
+	ui.PrintUpdatesTable(rows)
+	fmt.Println()
+
+	if !forceUpdate {
+		var confirm bool
+		form := huh.NewForm(huh.NewGroup(huh.NewConfirm().Title(ui.Msg("rollback_confirm")).Value(&confirm)))
+		if err := form.Run(); err != nil {
+			return err
+		}
+		if !confirm {
+			ui.ShowInfo(ui.Msg("rollback_cancelled"))
+			record.Outcome = updater.OutcomeCancelled
+			return nil
+		}
+	}
+
+	composeFile, err := compose.ParseComposeFile(cwd)
+	if err != nil {
+		showUpdatePreparationError(err)
+		return err
+	}
+
+	// Step 2: Retag, restore pins and recreate
+	ui.ShowStepHeader(2, 3, ui.Msg("step_rollback_restore"))
+	recreateCtx, recreateCancel := context.WithTimeout(ctx, compose.DefaultTimeout)
+	defer recreateCancel()
+	if err := rollbackImages(recreateCtx, cwd, executor, composeFile, point); err != nil {
+		return err
+	}
+	record.Outcome = updater.OutcomeRolledBack
+
+	// Step 3: Show status
+	ui.ShowStepHeader(3, 3, ui.Msg("step_status"))
+	statuses, err := monitor.GetStatusWithServices(recreateCtx, executor, composeFile.GetServiceNames())
+	if err == nil {
+		ui.PrintCommandResult(statuses, ui.Msg("cmd_rollback_title"), "rollback_summary_success", "rollback_summary_partial")
+	}
+	return nil
+}
+
+// rollbackImages points the floating tags back at the recorded image IDs, restores
+// kk.lock and the compose pins, and recreates the containers.
+func rollbackImages(ctx context.Context, cwd string, executor *compose.Executor, composeFile *compose.ComposeFile, point *updater.RollbackPoint) error {
+	spinner := ui.StartPtermSpinner(ui.Msg("rollback_retagging"))
+	if err := point.Retag(ctx, updater.NewDockerImageInspector()); err != nil {
+		spinner.Fail(ui.Msg("rollback_failed"))
+		return showRollbackError(err, ui.Msg("rollback_failed_suggestion"))
+	}
+	if err := point.RestoreLock(cwd); err != nil {
+		spinner.Fail(ui.Msg("rollback_failed"))
+		return showRollbackError(err, ui.Msg("lock_write_failed_suggestion"))
+	}
+	refs := point.Refs()
+	if _, err := pinComposeImages(cwd, func(image string) string {
+		if ref, ok := refs[updater.BaseImage(image)]; ok {
+			return ref
+		}
+		return image
+	}); err != nil {
+		spinner.Fail(ui.Msg("rollback_failed"))
+		return showRollbackError(err, ui.Msg("lock_write_failed_suggestion"))
+	}
+	spinner.Success(ui.Msg("rollback_retagged"))
+
+	spinner = ui.StartPtermSpinner(ui.Msg("restarting"))
+	if err := executor.ForceRecreate(ctx); err != nil {
+		spinner.Fail(ui.Msg("restart_failed"))
+		return showRollbackError(err, ui.Msg("err_check_docker_logs"))
+	}
+	spinner.Success(ui.Msg("restart_complete"))
+	monitorComposeHealth(ctx, composeFile)
+
+	// The previous set is live again; a second rollback would be a no-op
+	warnOnError(os.Remove(filepath.Join(cwd, updater.RollbackFileName)))
+	ui.ShowOK(ui.Msg("rollback_complete"))
+	return nil
+}
+
+// unhealthyServices lists services that did not become healthy.
+func unhealthyServices(results []monitor.HealthStatus) []string {
+	var names []string
+	for _, r := range results {
+		if !r.Healthy {
+			names = append(names, r.ServiceName)
+		}
+	}
+	return names
+}
+
+func currentImageIdentity(ctx context.Context, image string) string {
+	identity, err := updater.NewDockerImageInspector().Inspect(ctx, image)
+	if err != nil || !identity.Present {
+		return updater.IdentityNotPresent
+	}
+	return identity.Value
+}
+
+func showRollbackError(err error, suggestion string) error {
+	command := ""
+	if ui.IsDockerPermissionError(err) {
+		suggestion, command = ui.DockerPermissionSuggestion()
+	}
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("rollback_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: suggestion,
+		Command:    command,
+	})
+	return fmt.Errorf("%s: %w", ui.Msg("rollback_failed"), err)
+}
+
+func rolledBackError(services []string) error {
+	return errors.New(ui.MsgF("rollback_auto_done", strings.Join(services, ", ")))
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+func TestSaveRollbackPointUndoKeepsPreviousPoint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, updater.RollbackFileName)
+	if err := os.WriteFile(path, []byte("previous\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := &updateImageState{
+		cwd: dir,
+		composeFile: &compose.ComposeFile{
+			Services: map[string]compose.Service{"redis": {Image: "redis:alpine"}},
+		},
+		before: map[string]updater.ImageIdentity{
+			"redis:alpine": {Value: "sha256:digest", ID: "sha256:id", Present: true},
+		},
+	}
+	undo, err := saveRollbackPoint(state)
+	if err != nil {
+		t.Fatalf("saveRollbackPoint() error = %v", err)
+	}
+	point, err := updater.LoadRollbackPoint(dir)
+	if err != nil || len(point.Images) != 1 || point.Images[0].ID != "sha256:id" {
+		t.Fatalf("LoadRollbackPoint() = %#v, %v", point, err)
+	}
+
+	undo()
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "previous\n" {
+		t.Fatalf("rollback file after undo = %q, %v", data, err)
+	}
+}
+
+func TestUnhealthyServices(t *testing.T) {
+	got := unhealthyServices([]monitor.HealthStatus{
+		{ServiceName: "db", Healthy: true},
+		{ServiceName: "kkengine", Healthy: false},
+	})
+	if !reflect.DeepEqual(got, []string{"kkengine"}) {
+		t.Fatalf("unhealthyServices() = %v", got)
+	}
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/engine"
+	"github.com/kkauto-net/kk-install/pkg/scheduler"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+var updateScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "Run kk update unattended in a daily maintenance window",
+	Long: `Install a systemd timer (as root) or a cron entry that runs
+'kk update --force' once a day at a random time inside the maintenance window.
+
+The window uses the server's local time and may wrap past midnight.`,
+	Example: `  kk update schedule --window 02:00-04:00
+  kk update schedule --backend cron
+  kk update schedule --remove`,
+	Args: cobra.NoArgs,
+	RunE: runUpdateSchedule,
+}
+
+var (
+	scheduleWindow  string
+	scheduleBackend string
+	scheduleRemove  bool
+)
+
+func init() {
+	updateScheduleCmd.Flags().StringVar(&scheduleWindow, "window", "02:00-04:00", "Daily maintenance window (HH:MM-HH:MM, local time)")
+	updateScheduleCmd.Flags().StringVar(&scheduleBackend, "backend", "auto", "Scheduler to use: auto, systemd or cron")
+	updateScheduleCmd.Flags().BoolVar(&scheduleRemove, "remove", false, "Remove the scheduled updates")
+	updateCmd.AddCommand(updateScheduleCmd)
+}
+
+func runUpdateSchedule(cmd *cobra.Command, args []string) error {
+	if !scheduleRemove {
+		if _, err := config.EnsureProjectDir(); err != nil {
+			ui.ShowBoxedError(ui.ErrorSuggestion{
+				Title:      ui.Msg("project_not_configured"),
+				Message:    ui.SanitizeError(err),
+				Suggestion: ui.Msg("run_init_to_configure"),
+				Command:    "kk init",
+			})
+			return err
+		}
+	}
+
+	ui.ShowCommandBanner(ui.Msg("cmd_update_schedule_title"), ui.Msg("update_schedule_desc"))
+
+	manager := scheduler.NewManager()
+	backend, err := resolveScheduleBackend(manager)
+	if err != nil {
+		return showScheduleError(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if scheduleRemove {
+		spinner := ui.StartPtermSpinner(ui.MsgF("update_schedule_removing", backend))
+		if err := manager.Remove(ctx, backend, config.ProjectName()); err != nil {
+			spinner.Fail(ui.Msg("update_schedule_failed"))
+			return showScheduleError(err)
+		}
+		spinner.Success(ui.MsgF("update_schedule_removed", backend))
+		return nil
+	}
+
+	window, err := scheduler.ParseWindow(scheduleWindow)
+	if err != nil {
+		ui.ShowBoxedError(ui.ErrorSuggestion{
+			Title:      ui.Msg("update_schedule_invalid_window"),
+			Message:    ui.SanitizeError(err),
+			Suggestion: ui.Msg("update_schedule_window_suggestion"),
+		})
+		return NewExitError(exitCodeInputValidation, err)
+	}
+
+	schedule, err := buildUpdateSchedule(backend, window)
+	if err != nil {
+		return showScheduleError(err)
+	}
+
+	spinner := ui.StartPtermSpinner(ui.MsgF("update_schedule_installing", backend))
+	if err := manager.Install(ctx, backend, schedule); err != nil {
+		spinner.Fail(ui.Msg("update_schedule_failed"))
+		return showScheduleError(err)
+	}
+	spinner.Success(ui.MsgF("update_schedule_installed", window, backend))
+	ui.ShowInfo(ui.Msg("update_schedule_history_hint"))
+	return nil
+}
+
+func resolveScheduleBackend(manager *scheduler.Manager) (scheduler.Backend, error) {
+	switch scheduleBackend {
+	case "auto", "":
+		return manager.Detect()
+	case string(scheduler.BackendSystemd), string(scheduler.BackendCron):
+		return scheduler.Backend(scheduleBackend), nil
+	default:
+		return "", NewExitError(exitCodeInputValidation, fmt.Errorf("unknown backend %q (use auto, systemd or cron)", scheduleBackend))
+	}
+}
+
+// buildUpdateSchedule assembles the unattended command. systemd spreads the start
+// with RandomizedDelaySec; cron relies on kk update --max-delay instead.
+func buildUpdateSchedule(backend scheduler.Backend, window scheduler.Window) (scheduler.Schedule, error) {
+	exe, err := kkExecutable()
+	if err != nil {
+		return scheduler.Schedule{}, err
+	}
+
+	command := []string{exe, "update", "--force", "--scheduled"}
+	if backend == scheduler.BackendCron {
+		command = append(command, "--max-delay", window.Length.String())
+	}
+	project := config.ProjectName()
+	if project != "" {
+		command = append(command, "--project", project)
+	}
+
+	return scheduler.Schedule{Project: project, Window: window, Command: command, Env: unattendedEnv()}, nil
+}
+
+// kkExecutable returns the resolved path of the running kk binary.
+func kkExecutable() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	if resolved, evalErr := filepath.EvalSymlinks(exe); evalErr == nil {
+		exe = resolved
+	}
+	return exe, nil
+}
+
+// unattendedEnv is the environment for kk runs started by systemd or cron.
+// They start with an empty environment; keep the same config and docker access.
+func unattendedEnv() map[string]string {
+	env := map[string]string{}
+	if home, err := os.UserHomeDir(); err == nil {
+		env["HOME"] = home
+	}
+	if os.Getenv("KK_DOCKER_SUDO") == "1" {
+		env["KK_DOCKER_SUDO"] = "1"
+	}
+	// Keep talking to the engine selected with --docker-host/--context and
+	// the runtime selected by kk init.
+	for _, name := range []string{"DOCKER_HOST", "DOCKER_CONTEXT", "DOCKER_CONFIG", engine.RuntimeEnv} {
+		if value := os.Getenv(name); value != "" {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+func showScheduleError(err error) error {
+	ui.ShowBoxedError(ui.ErrorSuggestion{
+		Title:      ui.Msg("update_schedule_failed"),
+		Message:    ui.SanitizeError(err),
+		Suggestion: ui.Msg("update_schedule_failed_suggestion"),
+	})
+	return err
+}
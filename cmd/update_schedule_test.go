@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/config"
+	"github.com/kkauto-net/kk-install/pkg/scheduler"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+func TestBuildUpdateSchedule(t *testing.T) {
+	t.Setenv("KK_DOCKER_SUDO", "1")
+	t.Setenv("DOCKER_HOST", "ssh://deploy@prod")
+	window := scheduler.Window{Start: 2 * time.Hour, Length: 90 * time.Minute}
+
+	cron, err := buildUpdateSchedule(scheduler.BackendCron, window)
+	if err != nil {
+		t.Fatalf("buildUpdateSchedule() error = %v", err)
+	}
+	if !slices.Equal(cron.Command[1:], []string{"update", "--force", "--scheduled", "--max-delay", "1h30m0s"}) {
+		t.Fatalf("cron command = %v", cron.Command)
+	}
+	if cron.Env["KK_DOCKER_SUDO"] != "1" || cron.Env["HOME"] == "" || cron.Env["DOCKER_HOST"] != "ssh://deploy@prod" {
+		t.Fatalf("cron env = %v", cron.Env)
+	}
+
+	systemd, err := buildUpdateSchedule(scheduler.BackendSystemd, window)
+	if err != nil {
+		t.Fatalf("buildUpdateSchedule() error = %v", err)
+	}
+	if slices.Contains(systemd.Command, "--max-delay") {
+		t.Fatalf("systemd command should rely on RandomizedDelaySec: %v", systemd.Command)
+	}
+}
+
+// Units follow the project chosen with 'kk project use' when no --project
+// or KK_PROJECT is given.
+func TestUnitsUseCurrentProject(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(config.ProjectEnv, "")
+	config.SelectProject("")
+	if err := (&config.Config{CurrentProject: "prod"}).Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	schedule, err := buildUpdateSchedule(scheduler.BackendCron, scheduler.Window{Start: time.Hour, Length: time.Hour})
+	if err != nil {
+		t.Fatalf("buildUpdateSchedule() error = %v", err)
+	}
+	if schedule.Project != "prod" || !slices.Equal(schedule.Command[len(schedule.Command)-2:], []string{"--project", "prod"}) {
+		t.Fatalf("schedule = %+v", schedule)
+	}
+
+	guardSvc, err := buildGuardService()
+	if err != nil {
+		t.Fatalf("buildGuardService() error = %v", err)
+	}
+	exporterSvc, err := buildExporterService()
+	if err != nil {
+		t.Fatalf("buildExporterService() error = %v", err)
+	}
+	for _, svc := range []scheduler.Service{guardSvc, exporterSvc} {
+		if !strings.HasSuffix(svc.Name, "@prod") || !slices.Equal(svc.Command[len(svc.Command)-2:], []string{"--project", "prod"}) {
+			t.Fatalf("service = %+v", svc)
+		}
+	}
+}
+
+func TestHistoryEntries(t *testing.T) {
+	entries := historyEntries([]updater.RunRecord{{
+		StartedAt:       time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC),
+		DurationSeconds: 83.4,
+		Trigger:         updater.TriggerScheduled,
+		Outcome:         updater.OutcomeUpdated,
+		ImagesChanged:   []updater.ChangedImage{{Image: "redis:alpine"}},
+		Health:          updater.HealthUnknown,
+	}})
+
+	if len(entries) != 1 {
+		t.Fatalf("len(entries) = %d", len(entries))
+	}
+	e := entries[0]
+	if e.Images != 1 || e.Duration != "1m23s" || e.Health != "" || e.Trigger != "scheduled" {
+		t.Fatalf("entry = %+v", e)
+	}
+}
@@ -0,0 +1,227 @@
+// Package archive writes and reads the gzip-compressed tarballs kk uses for
+// backups and support bundles, plus sha256sum-compatible checksum files.
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChecksumSuffix is appended to an archive path to name its checksum file.
+const ChecksumSuffix = ".sha256"
+
+// Create packs every regular file below srcDir into a tar.gz at dest.
+// Entries are written in lexical order so identical inputs give identical archives.
+func Create(srcDir, dest string) error {
+	var paths []string
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+	writeErr := writeEntries(tw, srcDir, paths)
+	if err := tw.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if err := gz.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if err := out.Close(); writeErr == nil {
+		writeErr = err
+	}
+	if writeErr != nil {
+		return errors.Join(writeErr, os.Remove(dest))
+	}
+	return nil
+}
+
+func writeEntries(tw *tar.Writer, srcDir string, paths []string) error {
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if err := copyFileTo(tw, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFileTo(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	_, copyErr := io.Copy(w, f)
+	closeErr := f.Close()
+	if copyErr != nil {
+		return copyErr
+	}
+	return closeErr
+}
+
+// Extract unpacks a tar.gz created by Create into destDir.
+// Entries that would escape destDir are rejected.
+func Extract(src, destDir string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return errors.Join(fmt.Errorf("read %s: %w", filepath.Base(src), err), in.Close())
+	}
+	extractErr := extractEntries(tar.NewReader(gz), filepath.Base(src), destDir)
+	closeErr := errors.Join(gz.Close(), in.Close())
+	if extractErr != nil {
+		return extractErr
+	}
+	return closeErr
+}
+
+func extractEntries(tr *tar.Reader, name, destDir string) error {
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read %s: %w", name, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		target, err := safeJoin(destDir, header.Name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		if err := writeFile(target, tr, os.FileMode(header.Mode).Perm()); err != nil {
+			return err
+		}
+	}
+}
+
+func safeJoin(root, name string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("archive entry %q escapes destination", name)
+	}
+	return filepath.Join(root, cleaned), nil
+}
+
+func writeFile(path string, r io.Reader, mode os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	_, copyErr := io.Copy(f, r)
+	closeErr := f.Close()
+	if copyErr != nil {
+		return copyErr
+	}
+	return closeErr
+}
+
+// SHA256File returns the hex-encoded sha256 of the file at path.
+func SHA256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	_, copyErr := io.Copy(h, f)
+	closeErr := f.Close()
+	if copyErr != nil {
+		return "", copyErr
+	}
+	if closeErr != nil {
+		return "", closeErr
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// WriteChecksumFile writes "<sha256>  <basename>" next to path, in the format
+// accepted by `sha256sum -c`. Returns the checksum.
+func WriteChecksumFile(path string) (string, error) {
+	sum, err := SHA256File(path)
+	if err != nil {
+		return "", err
+	}
+	line := fmt.Sprintf("%s  %s\n", sum, filepath.Base(path))
+	if err := os.WriteFile(path+ChecksumSuffix, []byte(line), 0644); err != nil {
+		return "", err
+	}
+	return sum, nil
+}
+
+// VerifyChecksumFile compares path against its checksum file, if one exists.
+// Returns found=false when there is no checksum file to compare against.
+func VerifyChecksumFile(path string) (found bool, err error) {
+	data, err := os.ReadFile(path + ChecksumSuffix)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	line, _, _ := strings.Cut(string(data), "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true, fmt.Errorf("checksum file %s is empty", filepath.Base(path)+ChecksumSuffix)
+	}
+
+	sum, err := SHA256File(path)
+	if err != nil {
+		return true, err
+	}
+	if !strings.EqualFold(fields[0], sum) {
+		return true, fmt.Errorf("checksum mismatch for %s", filepath.Base(path))
+	}
+	return true, nil
+}
@@ -0,0 +1,124 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCreateExtractRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	mustWrite(t, filepath.Join(src, "manifest.json"), "{}", 0644)
+	mustWrite(t, filepath.Join(src, "config", ".env"), "DB_PASSWORD=secret", 0600)
+
+	dest := filepath.Join(t.TempDir(), "out", "backup.tar.gz")
+	if err := Create(src, dest); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("stat archive: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("archive mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	out := t.TempDir()
+	if err := Extract(dest, out); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, "config", ".env"))
+	if err != nil {
+		t.Fatalf("read extracted .env: %v", err)
+	}
+	if string(data) != "DB_PASSWORD=secret" {
+		t.Fatalf(".env = %q", data)
+	}
+	envInfo, err := os.Stat(filepath.Join(out, "config", ".env"))
+	if err != nil {
+		t.Fatalf("stat extracted .env: %v", err)
+	}
+	if envInfo.Mode().Perm() != 0600 {
+		t.Fatalf(".env mode = %v, want 0600", envInfo.Mode().Perm())
+	}
+}
+
+func TestExtractRejectsPathTraversal(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "evil.tar.gz")
+	f, err := os.Create(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	body := "pwned"
+	if err := tw.WriteHeader(&tar.Header{Name: "../escape.txt", Mode: 0644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write([]byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	err = Extract(dest, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "escapes destination") {
+		t.Fatalf("Extract() error = %v, want escape error", err)
+	}
+}
+
+func TestChecksumFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	mustWrite(t, path, "archive-bytes", 0600)
+
+	found, err := VerifyChecksumFile(path)
+	if err != nil || found {
+		t.Fatalf("VerifyChecksumFile() without sidecar = %v, %v", found, err)
+	}
+
+	sum, err := WriteChecksumFile(path)
+	if err != nil {
+		t.Fatalf("WriteChecksumFile() error = %v", err)
+	}
+	line, err := os.ReadFile(path + ChecksumSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(line) != sum+"  backup.tar.gz\n" {
+		t.Fatalf("checksum line = %q", line)
+	}
+
+	if found, err = VerifyChecksumFile(path); err != nil || !found {
+		t.Fatalf("VerifyChecksumFile() = %v, %v", found, err)
+	}
+
+	mustWrite(t, path, "tampered", 0600)
+	if _, err = VerifyChecksumFile(path); err == nil {
+		t.Fatal("VerifyChecksumFile() expected mismatch error")
+	}
+}
+
+func mustWrite(t *testing.T, path, content string, mode os.FileMode) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,243 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kkauto-net/kk-install/pkg/archive"
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/templates"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+// ConfigFiles are the rendered project files captured by a backup.
+var ConfigFiles = []string{
+	"docker-compose.yml",
+	compose.OverrideFileName,
+	".env",
+	"Caddyfile",
+	"kkfiler.toml",
+	"kkphp.conf",
+	updater.LockFileName,
+}
+
+// Volume is a data directory captured from inside a service container.
+type Volume struct {
+	Name    string // Archive name, e.g. redis_data
+	Service string // Compose service that mounts the data
+	Path    string // Mount path inside the container
+}
+
+// Volumes lists the data directories captured by kk backup.
+var Volumes = []Volume{
+	{Name: "filestore", Service: "seaweedfs", Path: "/data"}, // SYSTEM_FILESTORE bind mount
+	{Name: "redis_data", Service: "redis", Path: "/data"},
+	{Name: "caddy_data", Service: "caddy", Path: "/data"},
+}
+
+const (
+	// DatabaseService is the compose service running MariaDB.
+	DatabaseService = "db"
+	// RedisService is the compose service running Redis.
+	RedisService = "redis"
+
+	configDir = "config"
+	dumpPath  = "database/dump.sql"
+	volumeDir = "volumes"
+)
+
+// dumpScript runs inside the db container so the root password never leaves
+// it, and passes it in MYSQL_PWD so it does not show up in a process list.
+const dumpScript = `export MYSQL_PWD="$MYSQL_ROOT_PASSWORD"; exec mariadb-dump -uroot --all-databases --single-transaction --routines --events --triggers`
+
+// redisSaveScript reads the password from stdin so it never shows up in a process list.
+const redisSaveScript = `read -r REDISCLI_AUTH; export REDISCLI_AUTH; redis-cli SAVE`
+
+// ServiceExecutor runs commands inside compose service containers.
+type ServiceExecutor interface {
+	Exec(ctx context.Context, service string, stdin io.Reader, stdout io.Writer, args ...string) error
+}
+
+// Snapshot accumulates backup artifacts in a staging directory.
+type Snapshot struct {
+	dir      string
+	exec     ServiceExecutor
+	manifest *Manifest
+}
+
+// NewSnapshot starts a snapshot in stagingDir. The manifest is filled in as artifacts are added.
+func NewSnapshot(stagingDir string, exec ServiceExecutor, manifest *Manifest) *Snapshot {
+	if manifest.FormatVersion == 0 {
+		manifest.FormatVersion = FormatVersion
+	}
+	return &Snapshot{dir: stagingDir, exec: exec, manifest: manifest}
+}
+
+// Manifest returns the manifest built so far.
+func (s *Snapshot) Manifest() *Manifest {
+	return s.manifest
+}
+
+// AddConfigs copies the rendered config files that exist in projectDir.
+func (s *Snapshot) AddConfigs(projectDir string) error {
+	for _, name := range ConfigFiles {
+		data, err := os.ReadFile(filepath.Join(projectDir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		rel := path.Join(configDir, name)
+		if err := s.writeFile(rel, data, templates.FileMode(name)); err != nil {
+			return err
+		}
+		if err := s.addEntry(rel, KindConfig, "", ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddDatabase stores a logical dump of every database taken inside the db container.
+func (s *Snapshot) AddDatabase(ctx context.Context) error {
+	err := s.streamToFile(dumpPath, func(w io.Writer) error {
+		return s.exec.Exec(ctx, DatabaseService, nil, w, "sh", "-c", dumpScript)
+	})
+	if err != nil {
+		return fmt.Errorf("dump database: %w", err)
+	}
+	return s.addEntry(dumpPath, KindDatabase, DatabaseService, "")
+}
+
+// SaveRedis forces Redis to flush its dataset to disk before the volume is archived.
+func (s *Snapshot) SaveRedis(ctx context.Context, password string) error {
+	if err := s.exec.Exec(ctx, RedisService, strings.NewReader(password+"\n"), io.Discard, "sh", "-c", redisSaveScript); err != nil {
+		return fmt.Errorf("redis SAVE: %w", err)
+	}
+	return nil
+}
+
+// AddVolume archives the content of a volume from inside its service container.
+func (s *Snapshot) AddVolume(ctx context.Context, v Volume) error {
+	rel := path.Join(volumeDir, v.Name+".tar")
+	err := s.streamToFile(rel, func(w io.Writer) error {
+		return s.exec.Exec(ctx, v.Service, nil, w, "tar", "-C", v.Path, "-cf", "-", ".")
+	})
+	if err != nil {
+		return fmt.Errorf("archive volume %s: %w", v.Name, err)
+	}
+	return s.addEntry(rel, KindVolume, v.Service, v.Path)
+}
+
+// Finish writes the manifest and packs the staging directory into dest.
+// A sha256sum-compatible checksum file is written next to the archive; its sum is returned.
+func (s *Snapshot) Finish(dest string) (string, error) {
+	if err := WriteManifest(s.dir, s.manifest); err != nil {
+		return "", err
+	}
+	if err := archive.Create(s.dir, dest); err != nil {
+		return "", err
+	}
+	return archive.WriteChecksumFile(dest)
+}
+
+// ImageRecords converts an image snapshot into manifest records, sorted by image.
+func ImageRecords(snapshot map[string]updater.ImageIdentity) []ImageRecord {
+	records := make([]ImageRecord, 0, len(snapshot))
+	for image, identity := range snapshot {
+		records = append(records, ImageRecord{
+			Image:  image,
+			Digest: identity.Value,
+			ID:     identity.ID,
+			Source: identity.Source,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Image < records[j].Image
+	})
+	return records
+}
+
+// ImageMismatch is a recorded image whose identity on this host differs from
+// the one in the backup.
+type ImageMismatch struct {
+	Image    string
+	Recorded string
+	Current  string // updater.IdentityNotPresent when the image is not pulled
+}
+
+// ImageMismatches compares the images recorded in m with current, the
+// identities of the same images now. Images the backup did not find, or that
+// are not in current, are skipped.
+func (m *Manifest) ImageMismatches(current map[string]updater.ImageIdentity) []ImageMismatch {
+	var mismatches []ImageMismatch
+	for _, record := range m.Images {
+		identity, ok := current[record.Image]
+		if !ok || record.Digest == "" || record.Digest == updater.IdentityNotPresent {
+			continue
+		}
+		if identity.Value != record.Digest {
+			mismatches = append(mismatches, ImageMismatch{Image: record.Image, Recorded: record.Digest, Current: identity.Value})
+		}
+	}
+	return mismatches
+}
+
+// VolumesFor returns the volumes whose service is part of the stack.
+func VolumesFor(services []string) []Volume {
+	defined := make(map[string]bool, len(services))
+	for _, name := range services {
+		defined[name] = true
+	}
+	var volumes []Volume
+	for _, v := range Volumes {
+		if defined[v.Service] {
+			volumes = append(volumes, v)
+		}
+	}
+	return volumes
+}
+
+func (s *Snapshot) addEntry(rel, kind, service, target string) error {
+	entry, err := newEntry(s.dir, rel, kind)
+	if err != nil {
+		return err
+	}
+	entry.Service = service
+	entry.Target = target
+	s.manifest.Entries = append(s.manifest.Entries, entry)
+	return nil
+}
+
+func (s *Snapshot) writeFile(rel string, data []byte, mode os.FileMode) error {
+	target := filepath.Join(s.dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, mode)
+}
+
+func (s *Snapshot) streamToFile(rel string, produce func(io.Writer) error) error {
+	target := filepath.Join(s.dir, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	produceErr := produce(f)
+	closeErr := f.Close()
+	if produceErr != nil {
+		return errors.Join(produceErr, os.Remove(target))
+	}
+	return closeErr
+}
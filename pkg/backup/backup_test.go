@@ -0,0 +1,175 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/archive"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+type fakeExec struct {
+	calls  []string
+	stdin  map[string]string
+	output map[string]string
+	fail   map[string]error
+}
+
+func newFakeExec() *fakeExec {
+	return &fakeExec{stdin: map[string]string{}, output: map[string]string{}, fail: map[string]error{}}
+}
+
+func (f *fakeExec) Exec(_ context.Context, service string, stdin io.Reader, stdout io.Writer, args ...string) error {
+	f.calls = append(f.calls, service+" "+strings.Join(args, " "))
+	if stdin != nil {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		f.stdin[service] = string(data)
+	}
+	if err := f.fail[service]; err != nil {
+		return err
+	}
+	_, err := io.WriteString(stdout, f.output[service])
+	return err
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	project := t.TempDir()
+	writeTestFile(t, filepath.Join(project, "docker-compose.yml"), "services: {}\n")
+	writeTestFile(t, filepath.Join(project, ".env"), "DB_PASSWORD=secret\n")
+
+	exec := newFakeExec()
+	exec.output["db"] = "CREATE DATABASE kkengine_db;\n"
+	exec.output["redis"] = "redis-tar"
+
+	staging := t.TempDir()
+	snapshot := NewSnapshot(staging, exec, &Manifest{KKVersion: "test"})
+	if err := snapshot.AddConfigs(project); err != nil {
+		t.Fatalf("AddConfigs() error = %v", err)
+	}
+	if err := snapshot.AddDatabase(context.Background()); err != nil {
+		t.Fatalf("AddDatabase() error = %v", err)
+	}
+	if err := snapshot.SaveRedis(context.Background(), "redis-pass"); err != nil {
+		t.Fatalf("SaveRedis() error = %v", err)
+	}
+	if err := snapshot.AddVolume(context.Background(), Volume{Name: "redis_data", Service: "redis", Path: "/data"}); err != nil {
+		t.Fatalf("AddVolume() error = %v", err)
+	}
+
+	if exec.stdin["redis"] != "redis-pass\n" {
+		t.Fatalf("redis password should be passed on stdin, got %q", exec.stdin["redis"])
+	}
+	for _, call := range exec.calls {
+		if strings.Contains(call, "redis-pass") {
+			t.Fatalf("password leaked into command args: %q", call)
+		}
+		// mariadb-dump would show -p<password> in the container's process list.
+		if strings.Contains(call, "mariadb-dump") && strings.Contains(call, " -p") {
+			t.Fatalf("database password passed as an argument: %q", call)
+		}
+	}
+
+	dest := filepath.Join(t.TempDir(), "kk-backup.tar.gz")
+	sum, err := snapshot.Finish(dest)
+	if err != nil {
+		t.Fatalf("Finish() error = %v", err)
+	}
+	if sum == "" {
+		t.Fatal("Finish() returned empty checksum")
+	}
+	if found, err := archive.VerifyChecksumFile(dest); err != nil || !found {
+		t.Fatalf("VerifyChecksumFile() = %v, %v", found, err)
+	}
+
+	extracted := t.TempDir()
+	if err := archive.Extract(dest, extracted); err != nil {
+		t.Fatalf("Extract() error = %v", err)
+	}
+	manifest, err := ReadManifest(extracted)
+	if err != nil {
+		t.Fatalf("ReadManifest() error = %v", err)
+	}
+	if err := manifest.Verify(extracted); err != nil {
+		t.Fatalf("Verify() error = %v", err)
+	}
+	if got := len(manifest.EntriesOfKind(KindConfig)); got != 2 {
+		t.Fatalf("config entries = %d, want 2", got)
+	}
+	if got := manifest.EntriesOfKind(KindVolume); len(got) != 1 || got[0].Target != "/data" {
+		t.Fatalf("volume entries = %#v", got)
+	}
+
+	writeTestFile(t, filepath.Join(extracted, "database", "dump.sql"), "tampered")
+	if err := manifest.Verify(extracted); err == nil {
+		t.Fatal("Verify() expected checksum mismatch")
+	}
+}
+
+func TestAddDatabaseFailureLeavesNoPartialDump(t *testing.T) {
+	exec := newFakeExec()
+	exec.fail["db"] = errors.New("container not running")
+	staging := t.TempDir()
+	snapshot := NewSnapshot(staging, exec, &Manifest{})
+
+	if err := snapshot.AddDatabase(context.Background()); err == nil {
+		t.Fatal("AddDatabase() expected error")
+	}
+	if _, err := os.Stat(filepath.Join(staging, "database", "dump.sql")); !os.IsNotExist(err) {
+		t.Fatalf("partial dump should be removed, stat err = %v", err)
+	}
+	if len(snapshot.Manifest().Entries) != 0 {
+		t.Fatalf("entries = %#v, want none", snapshot.Manifest().Entries)
+	}
+}
+
+func TestManifestVerifyRejectsNewerFormat(t *testing.T) {
+	m := &Manifest{FormatVersion: FormatVersion + 1}
+	if err := m.Verify(t.TempDir()); err == nil {
+		t.Fatal("Verify() expected unsupported version error")
+	}
+}
+
+func TestVolumesForAndImageRecords(t *testing.T) {
+	volumes := VolumesFor([]string{"db", "redis", "kkengine"})
+	if len(volumes) != 1 || volumes[0].Name != "redis_data" {
+		t.Fatalf("VolumesFor() = %#v", volumes)
+	}
+
+	records := ImageRecords(map[string]updater.ImageIdentity{
+		"redis:alpine": {Value: "sha256:bbb", ID: "sha256:id2", Source: updater.IdentitySourceRepoDigest},
+		"mariadb:10.6": {Value: "sha256:aaa", ID: "sha256:id1", Source: updater.IdentitySourceRepoDigest},
+	})
+	if len(records) != 2 || records[0].Image != "mariadb:10.6" || records[1].Digest != "sha256:bbb" {
+		t.Fatalf("ImageRecords() = %#v", records)
+	}
+
+	manifest := &Manifest{Images: append(records, ImageRecord{Image: "caddy:alpine", Digest: updater.IdentityNotPresent})}
+	mismatches := manifest.ImageMismatches(map[string]updater.ImageIdentity{
+		"mariadb:10.6": {Value: "sha256:aaa"},
+		"redis:alpine": {Value: updater.IdentityNotPresent},
+		"caddy:alpine": {Value: "sha256:ccc"},
+	})
+	want := []ImageMismatch{{Image: "redis:alpine", Recorded: "sha256:bbb", Current: updater.IdentityNotPresent}}
+	if !reflect.DeepEqual(mismatches, want) {
+		t.Fatalf("ImageMismatches() = %#v, want %#v", mismatches, want)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,124 @@
+// Package backup captures and restores full snapshots of a kkengine stack:
+// config files, a logical MariaDB dump and the contents of the data volumes.
+package backup
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/archive"
+)
+
+const (
+	// FormatVersion is bumped whenever the archive layout changes incompatibly.
+	FormatVersion = 1
+
+	// ManifestName is the manifest file at the root of every backup archive.
+	ManifestName = "manifest.json"
+)
+
+// Entry kinds recorded in the manifest
+const (
+	KindConfig   = "config"
+	KindDatabase = "database"
+	KindVolume   = "volume"
+)
+
+// Manifest describes the content of a backup archive.
+type Manifest struct {
+	FormatVersion int           `json:"format_version"`
+	CreatedAt     time.Time     `json:"created_at"`
+	KKVersion     string        `json:"kk_version"`
+	ProjectDir    string        `json:"project_dir"`
+	Services      []string      `json:"services"`
+	Images        []ImageRecord `json:"images"`
+	Entries       []Entry       `json:"entries"`
+}
+
+// ImageRecord pins the image a service was running when the backup was taken.
+type ImageRecord struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest"`
+	ID     string `json:"id,omitempty"`
+	Source string `json:"source"`
+}
+
+// Entry is a single file inside the archive.
+type Entry struct {
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Service string `json:"service,omitempty"`
+	Target  string `json:"target,omitempty"` // Container path for volume entries
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+}
+
+// WriteManifest writes manifest.json into dir.
+func WriteManifest(dir string, m *Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ManifestName), append(data, '\n'), 0644)
+}
+
+// ReadManifest loads manifest.json from an extracted backup directory.
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s not found: not a kk backup archive", ManifestName)
+		}
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", ManifestName, err)
+	}
+	return &m, nil
+}
+
+// Verify checks the format version and every entry checksum against the files in dir.
+func (m *Manifest) Verify(dir string) error {
+	if m.FormatVersion == 0 || m.FormatVersion > FormatVersion {
+		return fmt.Errorf("unsupported backup format version %d (this kk supports up to %d)", m.FormatVersion, FormatVersion)
+	}
+	for _, entry := range m.Entries {
+		path := filepath.Join(dir, filepath.FromSlash(entry.Path))
+		sum, err := archive.SHA256File(path)
+		if err != nil {
+			return fmt.Errorf("backup entry %s: %w", entry.Path, err)
+		}
+		if sum != entry.SHA256 {
+			return fmt.Errorf("backup entry %s: checksum mismatch", entry.Path)
+		}
+	}
+	return nil
+}
+
+// EntriesOfKind returns the manifest entries with the given kind, in manifest order.
+func (m *Manifest) EntriesOfKind(kind string) []Entry {
+	var entries []Entry
+	for _, entry := range m.Entries {
+		if entry.Kind == kind {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func newEntry(dir, rel, kind string) (Entry, error) {
+	path := filepath.Join(dir, filepath.FromSlash(rel))
+	info, err := os.Stat(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	sum, err := archive.SHA256File(path)
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Path: rel, Kind: kind, Size: info.Size(), SHA256: sum}, nil
+}
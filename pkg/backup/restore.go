@@ -0,0 +1,130 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/kkauto-net/kk-install/pkg/archive"
+	"github.com/kkauto-net/kk-install/pkg/templates"
+)
+
+// ServiceRunner runs commands in throwaway service containers while the stack is stopped.
+type ServiceRunner interface {
+	RunOnce(ctx context.Context, service string, stdin io.Reader, stdout io.Writer, entrypoint string, args ...string) error
+}
+
+// volumeRestoreScript empties the mount point before unpacking so no stale files survive.
+const volumeRestoreScript = `cd "$1" && find . -mindepth 1 -delete && tar -xf -`
+
+// loginProbeScript and importScript read the root password from the first stdin line.
+const (
+	loginProbeScript = `read -r MYSQL_PWD; export MYSQL_PWD; exec mariadb -uroot -e "SELECT 1"`
+	importScript     = `read -r MYSQL_PWD; export MYSQL_PWD; exec mariadb -uroot`
+)
+
+// ErrNoDatabaseLogin is returned when none of the candidate root passwords work.
+var ErrNoDatabaseLogin = errors.New("cannot log in to MariaDB as root with the restored or previous DB_ROOT_PASSWORD")
+
+// Open verifies the archive checksum file (when present), extracts the archive
+// into workDir and verifies the manifest and every entry checksum.
+func Open(archivePath, workDir string) (*Manifest, error) {
+	if _, err := archive.VerifyChecksumFile(archivePath); err != nil {
+		return nil, err
+	}
+	if err := archive.Extract(archivePath, workDir); err != nil {
+		return nil, err
+	}
+	manifest, err := ReadManifest(workDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := manifest.Verify(workDir); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// RestoreConfigs writes the backed-up config files into projectDir, using the
+// same permissions templates.RenderAll applies. Returns the restored file names.
+func RestoreConfigs(m *Manifest, extractedDir, projectDir string) ([]string, error) {
+	var restored []string
+	for _, entry := range m.EntriesOfKind(KindConfig) {
+		name := path.Base(entry.Path)
+		data, err := os.ReadFile(filepath.Join(extractedDir, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			return restored, err
+		}
+		target := filepath.Join(projectDir, name)
+		mode := templates.FileMode(name)
+		if err := os.WriteFile(target, data, mode); err != nil {
+			return restored, err
+		}
+		// WriteFile keeps the mode of an existing file; enforce it explicitly
+		if err := os.Chmod(target, mode); err != nil {
+			return restored, err
+		}
+		restored = append(restored, name)
+	}
+	return restored, nil
+}
+
+// RestoreVolume replaces the content of a volume with the archived tarball,
+// using a throwaway container of the owning service.
+func RestoreVolume(ctx context.Context, runner ServiceRunner, extractedDir string, entry Entry) error {
+	f, err := os.Open(filepath.Join(extractedDir, filepath.FromSlash(entry.Path)))
+	if err != nil {
+		return err
+	}
+	runErr := runner.RunOnce(ctx, entry.Service, f, io.Discard, "sh", "-c", volumeRestoreScript, "sh", entry.Target)
+	closeErr := f.Close()
+	if runErr != nil {
+		return fmt.Errorf("restore volume %s: %w", path.Base(entry.Path), runErr)
+	}
+	return closeErr
+}
+
+// ImportDatabase replays the MariaDB dump inside the running db container.
+// Candidate root passwords are tried in order; the first one that can log in is used.
+func ImportDatabase(ctx context.Context, exec ServiceExecutor, extractedDir string, entry Entry, passwords ...string) error {
+	password, err := findRootPassword(ctx, exec, passwords)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(filepath.Join(extractedDir, filepath.FromSlash(entry.Path)))
+	if err != nil {
+		return err
+	}
+	stdin := io.MultiReader(strings.NewReader(password+"\n"), f)
+	importErr := exec.Exec(ctx, DatabaseService, stdin, io.Discard, "sh", "-c", importScript)
+	closeErr := f.Close()
+	if importErr != nil {
+		return fmt.Errorf("import database: %w", importErr)
+	}
+	return closeErr
+}
+
+func findRootPassword(ctx context.Context, exec ServiceExecutor, passwords []string) (string, error) {
+	tried := map[string]bool{}
+	var lastErr error
+	for _, password := range passwords {
+		if password == "" || tried[password] {
+			continue
+		}
+		tried[password] = true
+		lastErr = exec.Exec(ctx, DatabaseService, strings.NewReader(password+"\n"), io.Discard, "sh", "-c", loginProbeScript)
+		if lastErr == nil {
+			return password, nil
+		}
+	}
+	if lastErr != nil {
+		return "", fmt.Errorf("%w: %v", ErrNoDatabaseLogin, lastErr)
+	}
+	return "", ErrNoDatabaseLogin
+}
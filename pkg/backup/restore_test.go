@@ -0,0 +1,145 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type fakeRunner struct {
+	calls []string
+	stdin string
+}
+
+func (f *fakeRunner) RunOnce(_ context.Context, service string, stdin io.Reader, _ io.Writer, entrypoint string, args ...string) error {
+	f.calls = append(f.calls, service+" "+entrypoint+" "+strings.Join(args, " "))
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	f.stdin = string(data)
+	return nil
+}
+
+type loginExec struct {
+	validPassword string
+	imported      string
+	probes        int
+}
+
+func (l *loginExec) Exec(_ context.Context, _ string, stdin io.Reader, _ io.Writer, args ...string) error {
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	password, rest, _ := strings.Cut(string(data), "\n")
+	if password != l.validPassword {
+		l.probes++
+		return errors.New("Access denied for user 'root'")
+	}
+	if strings.Contains(args[len(args)-1], "SELECT 1") {
+		l.probes++
+		return nil
+	}
+	l.imported = rest
+	return nil
+}
+
+func TestOpenAndRestore(t *testing.T) {
+	project := t.TempDir()
+	writeTestFile(t, filepath.Join(project, "docker-compose.yml"), "services: {}\n")
+	writeTestFile(t, filepath.Join(project, ".env"), "DB_ROOT_PASSWORD=restored\n")
+
+	exec := newFakeExec()
+	exec.output["db"] = "CREATE DATABASE kkengine_db;\n"
+	exec.output["redis"] = "redis-tar"
+	staging := t.TempDir()
+	snapshot := NewSnapshot(staging, exec, &Manifest{})
+	if err := snapshot.AddConfigs(project); err != nil {
+		t.Fatal(err)
+	}
+	if err := snapshot.AddDatabase(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := snapshot.AddVolume(context.Background(), Volume{Name: "redis_data", Service: "redis", Path: "/data"}); err != nil {
+		t.Fatal(err)
+	}
+	archivePath := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := snapshot.Finish(archivePath); err != nil {
+		t.Fatal(err)
+	}
+
+	extracted := t.TempDir()
+	manifest, err := Open(archivePath, extracted)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	target := t.TempDir()
+	// Pre-existing .env with loose permissions must end up 0600
+	writeTestFile(t, filepath.Join(target, ".env"), "OLD=1\n")
+	restored, err := RestoreConfigs(manifest, extracted, target)
+	if err != nil {
+		t.Fatalf("RestoreConfigs() error = %v", err)
+	}
+	if len(restored) != 2 {
+		t.Fatalf("restored = %v", restored)
+	}
+	info, err := os.Stat(filepath.Join(target, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf(".env mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	runner := &fakeRunner{}
+	volume := manifest.EntriesOfKind(KindVolume)[0]
+	if err := RestoreVolume(context.Background(), runner, extracted, volume); err != nil {
+		t.Fatalf("RestoreVolume() error = %v", err)
+	}
+	if runner.stdin != "redis-tar" || !strings.HasSuffix(runner.calls[0], "sh /data") {
+		t.Fatalf("RestoreVolume() calls = %v stdin = %q", runner.calls, runner.stdin)
+	}
+
+	db := &loginExec{validPassword: "previous"}
+	dump := manifest.EntriesOfKind(KindDatabase)[0]
+	if err := ImportDatabase(context.Background(), db, extracted, dump, "restored", "previous"); err != nil {
+		t.Fatalf("ImportDatabase() error = %v", err)
+	}
+	if db.imported != "CREATE DATABASE kkengine_db;\n" {
+		t.Fatalf("imported = %q", db.imported)
+	}
+	if db.probes != 2 {
+		t.Fatalf("probes = %d, want 2", db.probes)
+	}
+}
+
+func TestImportDatabaseNoValidPassword(t *testing.T) {
+	db := &loginExec{validPassword: "other"}
+	err := ImportDatabase(context.Background(), db, t.TempDir(), Entry{Path: dumpPath}, "a", "a", "")
+	if !errors.Is(err, ErrNoDatabaseLogin) {
+		t.Fatalf("ImportDatabase() error = %v, want ErrNoDatabaseLogin", err)
+	}
+	if db.probes != 1 {
+		t.Fatalf("duplicate passwords should be probed once, got %d", db.probes)
+	}
+}
+
+func TestOpenRejectsTamperedArchive(t *testing.T) {
+	staging := t.TempDir()
+	snapshot := NewSnapshot(staging, newFakeExec(), &Manifest{})
+	archivePath := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if _, err := snapshot.Finish(archivePath); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, archivePath, "garbage")
+
+	if _, err := Open(archivePath, t.TempDir()); err == nil {
+		t.Fatal("Open() expected checksum error")
+	}
+}
@@ -0,0 +1,224 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ServiceChanges are the services affected by rewriting generated files.
+type ServiceChanges struct {
+	// Recreate lists services whose definition or environment changed;
+	// up -d recreates them.
+	Recreate []string
+	// Restart lists services that only bind-mount a changed file; up -d
+	// would leave them running with the old content.
+	Restart []string
+	// Removed lists services no longer in the compose file.
+	Removed []string
+}
+
+// Empty reports whether no service is affected.
+func (c ServiceChanges) Empty() bool {
+	return len(c.Recreate) == 0 && len(c.Restart) == 0 && len(c.Removed) == 0
+}
+
+// All returns every affected service, sorted.
+func (c ServiceChanges) All() []string {
+	all := slices.Concat(c.Recreate, c.Restart, c.Removed)
+	sort.Strings(all)
+	return all
+}
+
+// ChangedServices compares the project in oldDir with the files named in
+// changed, whose new versions are in newDir, and returns the services that
+// must be recreated or restarted to pick them up. Both sides are merged with
+// the overlay in oldDir, which is not generated.
+func ChangedServices(oldDir, newDir string, changed []string) (ServiceChanges, error) {
+	oldServices, err := readServiceDefs(oldDir, oldDir)
+	if err != nil {
+		return ServiceChanges{}, err
+	}
+	newServices := oldServices
+	if slices.Contains(changed, "docker-compose.yml") {
+		if newServices, err = readServiceDefs(newDir, oldDir); err != nil {
+			return ServiceChanges{}, err
+		}
+	}
+	var envKeys []string
+	if slices.Contains(changed, ".env") {
+		envKeys = changedEnvKeys(filepath.Join(oldDir, ".env"), filepath.Join(newDir, ".env"))
+	}
+
+	var result ServiceChanges
+	for _, name := range sortedKeys(newServices) {
+		def := newServices[name]
+		old, existed := oldServices[name]
+		switch {
+		case !existed || !reflect.DeepEqual(old, def):
+			result.Recreate = append(result.Recreate, name)
+		case usesEnv(def, envKeys):
+			result.Recreate = append(result.Recreate, name)
+		case mountsAny(def, changed):
+			result.Restart = append(result.Restart, name)
+		}
+	}
+	for _, name := range sortedKeys(oldServices) {
+		if _, ok := newServices[name]; !ok {
+			result.Removed = append(result.Removed, name)
+		}
+	}
+	return result, nil
+}
+
+// readServiceDefs reads the services of docker-compose.yml in dir, merged
+// with the overlay in overlayDir the way ParseComposeFile merges it.
+func readServiceDefs(dir, overlayDir string) (map[string]map[string]any, error) {
+	services, err := readServiceLayer(filepath.Join(dir, "docker-compose.yml"))
+	if err != nil {
+		return nil, err
+	}
+	overlayPath, ok := overlayFile(overlayDir)
+	if !ok {
+		return services, nil
+	}
+	overlay, err := readServiceLayer(overlayPath)
+	if err != nil {
+		return nil, err
+	}
+	if services == nil {
+		services = make(map[string]map[string]any, len(overlay))
+	}
+	for name, def := range overlay {
+		services[name] = mergeServiceDef(services[name], def)
+	}
+	return services, nil
+}
+
+// mergeServiceDef returns base with the keys of overlay applied: mappings
+// are merged, lists combined and other values replaced.
+func mergeServiceDef(base, overlay map[string]any) map[string]any {
+	merged := make(map[string]any, len(base)+len(overlay))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range overlay {
+		switch v := value.(type) {
+		case map[string]any:
+			if prev, ok := merged[key].(map[string]any); ok {
+				value = mergeServiceDef(prev, v)
+			}
+		case []any:
+			if prev, ok := merged[key].([]any); ok {
+				value = slices.Concat(prev, v)
+			}
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+func readServiceLayer(path string) (map[string]map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var file struct {
+		Services map[string]map[string]any `yaml:"services"`
+	}
+	if err = yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return file.Services, nil
+}
+
+// changedEnvKeys returns the keys whose value differs between two .env files.
+func changedEnvKeys(oldPath, newPath string) []string {
+	oldEnv, newEnv := readEnvFile(oldPath), readEnvFile(newPath)
+	var keys []string
+	for key, value := range newEnv {
+		if prev, ok := oldEnv[key]; !ok || prev != value {
+			keys = append(keys, key)
+		}
+	}
+	for key := range oldEnv {
+		if _, ok := newEnv[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func readEnvFile(path string) map[string]string {
+	env := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return env
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			env[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return env
+}
+
+// usesEnv reports whether a service loads the .env file or interpolates one
+// of keys.
+func usesEnv(def map[string]any, keys []string) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	if _, ok := def["env_file"]; ok {
+		return true
+	}
+	data, err := yaml.Marshal(def)
+	if err != nil {
+		return true
+	}
+	text := string(data)
+	for _, key := range keys {
+		if strings.Contains(text, "${"+key+"}") || strings.Contains(text, "${"+key+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// mountsAny reports whether a service bind-mounts one of the project files.
+func mountsAny(def map[string]any, files []string) bool {
+	volumes, _ := def["volumes"].([]any)
+	for _, v := range volumes {
+		spec, ok := v.(string)
+		if !ok {
+			continue
+		}
+		for _, file := range files {
+			if strings.HasPrefix(spec, "./"+file+":") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
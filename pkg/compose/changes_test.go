@@ -0,0 +1,132 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const changesCompose = `services:
+  kkengine:
+    image: kkengine:1
+    env_file:
+      - .env
+  db:
+    image: mariadb:11
+    environment:
+      MARIADB_PASSWORD: ${DB_PASSWORD}
+  caddy:
+    image: caddy:alpine
+    volumes:
+      - ./Caddyfile:/etc/caddy/Caddyfile
+`
+
+func writeProject(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestChangedServices(t *testing.T) {
+	oldDir := writeProject(t, map[string]string{
+		"docker-compose.yml": changesCompose,
+		".env":               "DB_PASSWORD=old\nTZ=UTC\n",
+	})
+
+	tests := []struct {
+		name    string
+		newDir  map[string]string
+		changed []string
+		want    ServiceChanges
+	}{
+		{
+			name:    "mounted file",
+			newDir:  map[string]string{"Caddyfile": "new"},
+			changed: []string{"Caddyfile"},
+			want:    ServiceChanges{Restart: []string{"caddy"}},
+		},
+		{
+			name:    "env value used by env_file only",
+			newDir:  map[string]string{".env": "DB_PASSWORD=old\nTZ=Asia/Ho_Chi_Minh\n"},
+			changed: []string{".env"},
+			want:    ServiceChanges{Recreate: []string{"kkengine"}},
+		},
+		{
+			name:    "interpolated env value",
+			newDir:  map[string]string{".env": "DB_PASSWORD=new\nTZ=UTC\n"},
+			changed: []string{".env"},
+			want:    ServiceChanges{Recreate: []string{"db", "kkengine"}},
+		},
+		{
+			name: "service removed and changed",
+			newDir: map[string]string{"docker-compose.yml": `services:
+  kkengine:
+    image: kkengine:2
+    env_file:
+      - .env
+  db:
+    image: mariadb:11
+    environment:
+      MARIADB_PASSWORD: ${DB_PASSWORD}
+`},
+			changed: []string{"docker-compose.yml"},
+			want:    ServiceChanges{Recreate: []string{"kkengine"}, Removed: []string{"caddy"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ChangedServices(oldDir, writeProject(t, tt.newDir), tt.changed)
+			if err != nil {
+				t.Fatalf("ChangedServices() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ChangedServices() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangedServicesMergesOverlay(t *testing.T) {
+	oldDir := writeProject(t, map[string]string{
+		"docker-compose.yml": changesCompose,
+		OverrideFileName: `services:
+  caddy:
+    environment:
+      ACME_EMAIL: ${ACME_EMAIL}
+  worker:
+    image: worker:1
+    environment:
+      TZ: ${TZ}
+`,
+		".env": "DB_PASSWORD=old\nTZ=UTC\nACME_EMAIL=a@example.com\n",
+	})
+
+	got, err := ChangedServices(oldDir, writeProject(t, map[string]string{
+		".env": "DB_PASSWORD=old\nTZ=Asia/Ho_Chi_Minh\nACME_EMAIL=b@example.com\n",
+	}), []string{".env"})
+	if err != nil {
+		t.Fatalf("ChangedServices() error = %v", err)
+	}
+	want := ServiceChanges{Recreate: []string{"caddy", "kkengine", "worker"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangedServices() = %+v, want %+v", got, want)
+	}
+
+	// A new docker-compose.yml keeps the overlay: its services are not
+	// reported as removed.
+	got, err = ChangedServices(oldDir, writeProject(t, map[string]string{
+		"docker-compose.yml": changesCompose,
+	}), []string{"docker-compose.yml"})
+	if err != nil {
+		t.Fatalf("ChangedServices() error = %v", err)
+	}
+	if !got.Empty() {
+		t.Errorf("ChangedServices() = %+v, want no changes", got)
+	}
+}
@@ -3,21 +3,41 @@ package compose
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/kkauto-net/kk-install/pkg/engine"
 )
 
 // Variables for dependency injection in tests
 var (
 	execCommand  = exec.CommandContext
 	execLookPath = exec.LookPath
+
+	// composeBinary is detected once per process.
+	composeBinary = sync.OnceValue(detectComposeBinary)
+
+	// engineClient returns the Engine API client used to list containers.
+	engineClient = func() (containerLister, error) { return engine.Shared() }
 )
 
+// containerLister is the Engine API call Ps needs.
+type containerLister interface {
+	ContainerList(ctx context.Context, options container.ListOptions) ([]container.Summary, error)
+}
+
 // Executor wraps docker-compose commands
 type Executor struct {
 	WorkDir     string
@@ -31,11 +51,30 @@ func NewExecutor(workDir string) *Executor {
 	}
 }
 
+// Files returns the compose files passed with -f: docker-compose.yml, then
+// the overlay next to it when there is one. Naming files with -f turns off
+// docker compose's own lookup of the overlay.
+func (e *Executor) Files() []string {
+	return ComposeFiles(filepath.Dir(e.ComposeFile))
+}
+
 // Up runs docker-compose up -d
 func (e *Executor) Up(ctx context.Context) error {
 	return e.runWithStderrCapture(ctx, "up", "-d")
 }
 
+// UpServices runs docker-compose up -d for the given services only
+func (e *Executor) UpServices(ctx context.Context, services ...string) error {
+	return e.runWithStderrCapture(ctx, append([]string{"up", "-d"}, services...)...)
+}
+
+// UpRemoveOrphans runs docker-compose up -d --remove-orphans for the given
+// services (all when none), removing containers of services that are no
+// longer in the compose file.
+func (e *Executor) UpRemoveOrphans(ctx context.Context, services ...string) error {
+	return e.runWithStderrCapture(ctx, append([]string{"up", "-d", "--remove-orphans"}, services...)...)
+}
+
 // Down runs docker-compose down
 func (e *Executor) Down(ctx context.Context) error {
 	return e.run(ctx, "down")
@@ -48,7 +87,7 @@ func (e *Executor) DownWithVolumes(ctx context.Context) error {
 
 // Restart runs docker-compose restart, or up -d when the stack was stopped.
 func (e *Executor) Restart(ctx context.Context) error {
-	hasContainers, err := e.hasComposeContainers(ctx)
+	hasContainers, err := e.HasContainers(ctx)
 	if err != nil {
 		return err
 	}
@@ -58,7 +97,16 @@ func (e *Executor) Restart(ctx context.Context) error {
 	return e.run(ctx, "restart")
 }
 
-func (e *Executor) hasComposeContainers(ctx context.Context) (bool, error) {
+// RestartServices runs docker-compose restart for the given services only
+func (e *Executor) RestartServices(ctx context.Context, services ...string) error {
+	return e.runWithStderrCapture(ctx, append([]string{"restart"}, services...)...)
+}
+
+// HasContainers reports whether any of the project's containers is running.
+func (e *Executor) HasContainers(ctx context.Context) (bool, error) {
+	if running, err := e.listRunning(ctx); err == nil {
+		return len(running) > 0, nil
+	}
 	out, err := e.runWithOutput(ctx, "ps", "-q")
 	if err != nil {
 		return false, err
@@ -71,9 +119,135 @@ func (e *Executor) Pull(ctx context.Context) (string, error) {
 	return e.runWithOutput(ctx, "pull")
 }
 
-// Ps runs docker-compose ps
+// Ps lists the running containers of the stack as JSON lines, in the format
+// of docker-compose ps --format json. It asks the Engine API and only runs
+// docker-compose ps when the API cannot be reached.
 func (e *Executor) Ps(ctx context.Context) (string, error) {
-	return e.runWithOutput(ctx, "ps", "--format", "json")
+	running, err := e.listRunning(ctx)
+	if err != nil {
+		return e.runWithOutput(ctx, "ps", "--format", "json")
+	}
+
+	var out strings.Builder
+	enc := json.NewEncoder(&out)
+	enc.SetEscapeHTML(false)
+	for _, c := range running {
+		if encodeErr := enc.Encode(psEntry(c)); encodeErr != nil {
+			return "", encodeErr
+		}
+	}
+	return out.String(), nil
+}
+
+// listRunning returns the running containers of the services in the compose
+// file, found by their container names.
+func (e *Executor) listRunning(ctx context.Context) ([]container.Summary, error) {
+	composeFile, err := ParseComposeFile(filepath.Dir(e.ComposeFile))
+	if err != nil {
+		return nil, err
+	}
+	cli, err := engineClient()
+	if err != nil {
+		return nil, err
+	}
+
+	names := composeFile.GetContainerNames()
+	if len(names) == 0 {
+		return nil, nil
+	}
+	args := filters.NewArgs()
+	for _, name := range names {
+		args.Add("name", "^/?"+regexp.QuoteMeta(name)+"$")
+	}
+	containers, err := cli.ContainerList(ctx, container.ListOptions{Filters: args})
+	if err != nil {
+		return nil, err
+	}
+
+	// Give containers without compose labels their service from the compose file.
+	services := make(map[string]string, len(names))
+	for _, service := range composeFile.GetServiceNames() {
+		services[composeFile.GetServiceContainerName(service)] = service
+	}
+	for i := range containers {
+		c := &containers[i]
+		if c.Labels == nil {
+			c.Labels = map[string]string{}
+		}
+		if c.Labels[engine.LabelService] == "" {
+			c.Labels[engine.LabelService] = services[containerName(*c)]
+		}
+	}
+	sort.Slice(containers, func(i, j int) bool { return containerName(containers[i]) < containerName(containers[j]) })
+	return containers, nil
+}
+
+// psJSON is one line of docker-compose ps --format json.
+type psJSON struct {
+	Name    string `json:"Name"`
+	State   string `json:"State"`
+	Health  string `json:"Health"`
+	Ports   string `json:"Ports"`
+	Service string `json:"Service"`
+	Image   string `json:"Image"`
+}
+
+func psEntry(c container.Summary) psJSON {
+	return psJSON{
+		Name:    containerName(c),
+		State:   string(c.State),
+		Health:  healthFromStatus(c.Status),
+		Ports:   formatPorts(c.Ports),
+		Service: c.Labels[engine.LabelService],
+		Image:   c.Image,
+	}
+}
+
+func containerName(c container.Summary) string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// healthFromStatus reads the healthcheck state from a status such as
+// "Up 5 minutes (healthy)".
+func healthFromStatus(status string) string {
+	switch {
+	case strings.HasSuffix(status, "(healthy)"):
+		return "healthy"
+	case strings.HasSuffix(status, "(unhealthy)"):
+		return "unhealthy"
+	case strings.HasSuffix(status, "(health: starting)"):
+		return "starting"
+	}
+	return ""
+}
+
+// formatPorts renders ports the way docker-compose ps does, e.g.
+// "0.0.0.0:8019->8019/tcp, [::]:8019->8019/tcp, 6379/tcp".
+func formatPorts(ports []container.Port) string {
+	sorted := append([]container.Port(nil), ports...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].PrivatePort != sorted[j].PrivatePort {
+			return sorted[i].PrivatePort < sorted[j].PrivatePort
+		}
+		return sorted[i].IP < sorted[j].IP
+	})
+
+	parts := make([]string, 0, len(sorted))
+	for _, p := range sorted {
+		if p.PublicPort == 0 {
+			parts = append(parts, fmt.Sprintf("%d/%s", p.PrivatePort, p.Type))
+			continue
+		}
+		host := p.IP
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		parts = append(parts, fmt.Sprintf("%s:%d->%d/%s", host, p.PublicPort, p.PrivatePort, p.Type))
+	}
+	return strings.Join(parts, ", ")
 }
 
 // ForceRecreate runs docker-compose up -d --force-recreate
@@ -81,6 +255,55 @@ func (e *Executor) ForceRecreate(ctx context.Context) error {
 	return e.run(ctx, "up", "-d", "--force-recreate")
 }
 
+// Exec runs a command inside a running service container (docker-compose exec -T),
+// streaming stdin/stdout. Stderr is captured and returned as part of the error.
+func (e *Executor) Exec(ctx context.Context, service string, stdin io.Reader, stdout io.Writer, args ...string) error {
+	return e.runStreaming(ctx, stdin, stdout, append([]string{"exec", "-T", service}, args...)...)
+}
+
+// RunOnce runs a command in a throwaway container of the given service
+// (docker-compose run --rm --no-deps -T), with the service volumes mounted.
+// Works while the stack is stopped.
+func (e *Executor) RunOnce(ctx context.Context, service string, stdin io.Reader, stdout io.Writer, entrypoint string, args ...string) error {
+	runArgs := []string{"run", "--rm", "--no-deps", "-T", "--entrypoint", entrypoint, service}
+	return e.runStreaming(ctx, stdin, stdout, append(runArgs, args...)...)
+}
+
+// LogOptions selects which log lines docker-compose logs prints.
+type LogOptions struct {
+	Follow     bool
+	Tail       string // number of lines per service, or "all"
+	Since      string // timestamp or relative duration, e.g. 42m
+	Until      string
+	Timestamps bool
+	Services   []string // empty means all services
+}
+
+func (o LogOptions) args() []string {
+	args := []string{"logs", "--no-color"}
+	if o.Follow {
+		args = append(args, "--follow")
+	}
+	if o.Tail != "" {
+		args = append(args, "--tail", o.Tail)
+	}
+	if o.Since != "" {
+		args = append(args, "--since", o.Since)
+	}
+	if o.Until != "" {
+		args = append(args, "--until", o.Until)
+	}
+	if o.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	return append(args, o.Services...)
+}
+
+// Logs runs docker-compose logs, streaming the prefixed log lines to stdout.
+func (e *Executor) Logs(ctx context.Context, stdout io.Writer, opts LogOptions) error {
+	return e.runStreaming(ctx, nil, stdout, opts.args()...)
+}
+
 func (e *Executor) run(ctx context.Context, args ...string) error {
 	cmd := e.buildCmd(ctx, args...)
 	cmd.Stdout = os.Stdout
@@ -107,6 +330,23 @@ func (e *Executor) runWithStderrCapture(ctx context.Context, args ...string) err
 	return nil
 }
 
+// runStreaming wires stdin/stdout to the caller and captures stderr for error details
+func (e *Executor) runStreaming(ctx context.Context, stdin io.Reader, stdout io.Writer, args ...string) error {
+	cmd := e.buildCmd(ctx, args...)
+	var stderr bytes.Buffer
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
 func (e *Executor) runWithOutput(ctx context.Context, args ...string) (string, error) {
 	cmd := e.buildCmd(ctx, args...)
 	var stdout, stderr bytes.Buffer
@@ -120,22 +360,45 @@ func (e *Executor) runWithOutput(ctx context.Context, args ...string) (string, e
 	return stdout.String(), nil
 }
 
-func (e *Executor) buildCmd(ctx context.Context, args ...string) *exec.Cmd {
-	// Try docker compose (v2) first, fallback to docker-compose (v1)
-	cmdName := "docker"
-	cmdArgs := append([]string{"compose", "-f", e.ComposeFile}, args...)
-
-	// Check if docker compose v2 is available
+// detectComposeBinary picks docker compose (v2) when available, and the
+// standalone docker-compose (v1) otherwise. With Podman it prefers podman
+// compose, then podman-compose; docker-compose also works against Podman's
+// API socket.
+func detectComposeBinary() []string {
+	if engine.CurrentRuntime() == engine.RuntimePodman {
+		if _, err := execLookPath("podman"); err == nil {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if execCommand(ctx, "podman", "compose", "version").Run() == nil {
+				return []string{"podman", "compose"}
+			}
+		}
+		if _, err := execLookPath("podman-compose"); err == nil {
+			return []string{"podman-compose"}
+		}
+	}
 	if _, err := execLookPath("docker"); err != nil {
-		cmdName = "docker-compose"
-		cmdArgs = append([]string{"-f", e.ComposeFile}, args...)
-	} else if execCommand(ctx, "docker", "compose", "version").Run() != nil {
-		// Fallback to docker-compose v1
-		cmdName = "docker-compose"
-		cmdArgs = append([]string{"-f", e.ComposeFile}, args...)
+		return []string{"docker-compose"}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if execCommand(ctx, "docker", "compose", "version").Run() != nil {
+		return []string{"docker-compose"}
+	}
+	return []string{"docker", "compose"}
+}
+
+func (e *Executor) buildCmd(ctx context.Context, args ...string) *exec.Cmd {
+	binary := composeBinary()
+	cmdName := binary[0]
+	cmdArgs := append([]string{}, binary[1:]...)
+	for _, file := range e.Files() {
+		cmdArgs = append(cmdArgs, "-f", file)
 	}
+	cmdArgs = append(cmdArgs, args...)
 
-	if os.Getenv("KK_DOCKER_SUDO") == "1" {
+	// sudo would drop DOCKER_HOST/DOCKER_CONTEXT, which select a remote engine.
+	if os.Getenv("KK_DOCKER_SUDO") == "1" && !engine.CurrentTarget().Remote() {
 		cmd := execCommand(ctx, "sudo", append([]string{cmdName}, cmdArgs...)...)
 		cmd.Dir = e.WorkDir
 		return cmd
@@ -3,13 +3,19 @@ package compose
 import (
 	"context"
 	"errors"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/kkauto-net/kk-install/pkg/engine"
 )
 
 // All tests in this file require Docker to be running
@@ -47,13 +53,24 @@ func TestExecutorCommandConstruction(t *testing.T) {
 		want     []string
 	}{
 		{name: "up", run: func(ctx context.Context, e *Executor) error { return e.Up(ctx) }, want: []string{"docker compose -f COMPOSE up -d"}},
+		{name: "up services", run: func(ctx context.Context, e *Executor) error { return e.UpServices(ctx, "db") }, want: []string{"docker compose -f COMPOSE up -d db"}},
 		{name: "down", run: func(ctx context.Context, e *Executor) error { return e.Down(ctx) }, want: []string{"docker compose -f COMPOSE down"}},
 		{name: "down with volumes", run: func(ctx context.Context, e *Executor) error { return e.DownWithVolumes(ctx) }, want: []string{"docker compose -f COMPOSE down -v"}},
 		{name: "restart when running", psOutput: "abc123\n", run: func(ctx context.Context, e *Executor) error { return e.Restart(ctx) }, want: []string{"docker compose -f COMPOSE ps -q", "docker compose -f COMPOSE restart"}},
 		{name: "restart when stopped", psOutput: "", run: func(ctx context.Context, e *Executor) error { return e.Restart(ctx) }, want: []string{"docker compose -f COMPOSE ps -q", "docker compose -f COMPOSE up -d"}},
+		{name: "restart services", run: func(ctx context.Context, e *Executor) error { return e.RestartServices(ctx, "kkengine") }, want: []string{"docker compose -f COMPOSE restart kkengine"}},
 		{name: "force recreate", run: func(ctx context.Context, e *Executor) error { return e.ForceRecreate(ctx) }, want: []string{"docker compose -f COMPOSE up -d --force-recreate"}},
 		{name: "pull", run: func(ctx context.Context, e *Executor) error { _, err := e.Pull(ctx); return err }, want: []string{"docker compose -f COMPOSE pull"}},
 		{name: "ps", run: func(ctx context.Context, e *Executor) error { _, err := e.Ps(ctx); return err }, want: []string{"docker compose -f COMPOSE ps --format json"}},
+		{name: "exec", run: func(ctx context.Context, e *Executor) error {
+			return e.Exec(ctx, "db", nil, io.Discard, "mariadb-dump")
+		}, want: []string{"docker compose -f COMPOSE exec -T db mariadb-dump"}},
+		{name: "run once", run: func(ctx context.Context, e *Executor) error {
+			return e.RunOnce(ctx, "redis", nil, io.Discard, "tar", "-C", "/data", "-xf", "-")
+		}, want: []string{"docker compose -f COMPOSE run --rm --no-deps -T --entrypoint tar redis -C /data -xf -"}},
+		{name: "logs", run: func(ctx context.Context, e *Executor) error {
+			return e.Logs(ctx, io.Discard, LogOptions{Follow: true, Tail: "50", Since: "1h", Timestamps: true, Services: []string{"kkengine", "db"}})
+		}, want: []string{"docker compose -f COMPOSE logs --no-color --follow --tail 50 --since 1h --timestamps kkengine db"}},
 	}
 
 	for _, tt := range tests {
@@ -74,6 +91,26 @@ func TestExecutorCommandConstruction(t *testing.T) {
 	}
 }
 
+func TestExecutorPassesOverlay(t *testing.T) {
+	calls := withFakeComposeCommands(t, false, 0, "", "ok\n")
+	dir := t.TempDir()
+	overlay := filepath.Join(dir, OverrideFileName)
+	if err := os.WriteFile(overlay, []byte("services: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	executor := NewExecutor(dir)
+
+	if err := executor.Up(context.Background()); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	got := normalizeComposeCalls(*calls, executor.ComposeFile)
+	want := []string{"docker compose -f COMPOSE -f " + filepath.ToSlash(overlay) + " up -d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("commands = %#v, want %#v", got, want)
+	}
+}
+
 func TestExecutorFallbackToDockerComposeV1(t *testing.T) {
 	calls := withFakeComposeCommands(t, true, 0, "", "ok\n")
 	dir := t.TempDir()
@@ -120,10 +157,97 @@ func TestExecutorPropagatesCommandErrors(t *testing.T) {
 	}
 }
 
+func TestExecutorDetectsComposeOnce(t *testing.T) {
+	withFakeComposeCommands(t, false, 0, "", "ok\n")
+	versionChecks := 0
+	fakeCommand := execCommand
+	execCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		if reflect.DeepEqual(args, []string{"compose", "version"}) {
+			versionChecks++
+		}
+		return fakeCommand(ctx, name, args...)
+	}
+
+	executor := NewExecutor(t.TempDir())
+	for i := 0; i < 3; i++ {
+		if err := executor.Down(context.Background()); err != nil {
+			t.Fatalf("Down() error = %v", err)
+		}
+	}
+	if versionChecks != 1 {
+		t.Fatalf("docker compose version ran %d times, want 1", versionChecks)
+	}
+}
+
+func TestExecutorUsesPodmanCompose(t *testing.T) {
+	t.Setenv(engine.RuntimeEnv, string(engine.RuntimePodman))
+	calls := withFakeComposeCommands(t, false, 0, "", "")
+	execLookPath = func(file string) (string, error) {
+		if file == "podman-compose" {
+			return "/usr/bin/podman-compose", nil
+		}
+		return "", errors.New("not found")
+	}
+	executor := NewExecutor(t.TempDir())
+
+	if err := executor.Down(context.Background()); err != nil {
+		t.Fatalf("Down() error = %v", err)
+	}
+	got := normalizeComposeCalls(*calls, executor.ComposeFile)
+	if !reflect.DeepEqual(got, []string{"podman-compose -f COMPOSE down"}) {
+		t.Fatalf("commands = %#v, want podman-compose", got)
+	}
+}
+
+type fakeLister []container.Summary
+
+func (f fakeLister) ContainerList(_ context.Context, _ container.ListOptions) ([]container.Summary, error) {
+	return f, nil
+}
+
+func TestExecutorPsUsesEngine(t *testing.T) {
+	calls := withFakeComposeCommands(t, false, 0, "", "ok\n")
+	dir := t.TempDir()
+	compose := "services:\n  db:\n    container_name: shop_db\n  kkengine: {}\n"
+	if err := os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte(compose), 0644); err != nil {
+		t.Fatal(err)
+	}
+	engineClient = func() (containerLister, error) {
+		return fakeLister{
+			{Names: []string{"/shop_db"}, Image: "mariadb:11", State: "running", Status: "Up 2 minutes (healthy)",
+				Ports: []container.Port{{IP: "::", PublicPort: 3306, PrivatePort: 3306, Type: "tcp"}, {IP: "0.0.0.0", PublicPort: 3306, PrivatePort: 3306, Type: "tcp"}}},
+			{Names: []string{"/kkengine_kkengine"}, Image: "kkengine:latest", State: "running", Status: "Up 1 second (health: starting)",
+				Labels: map[string]string{"com.docker.compose.service": "kkengine"}, Ports: []container.Port{{PrivatePort: 8019, Type: "tcp"}}},
+		}, nil
+	}
+	executor := NewExecutor(dir)
+
+	out, err := executor.Ps(context.Background())
+	if err != nil {
+		t.Fatalf("Ps() error = %v", err)
+	}
+	want := `{"Name":"kkengine_kkengine","State":"running","Health":"starting","Ports":"8019/tcp","Service":"kkengine","Image":"kkengine:latest"}` + "\n" +
+		`{"Name":"shop_db","State":"running","Health":"healthy","Ports":"0.0.0.0:3306->3306/tcp, [::]:3306->3306/tcp","Service":"db","Image":"mariadb:11"}` + "\n"
+	if out != want {
+		t.Fatalf("Ps() =\n%s\nwant\n%s", out, want)
+	}
+
+	if err := executor.Restart(context.Background()); err != nil {
+		t.Fatalf("Restart() error = %v", err)
+	}
+	got := normalizeComposeCalls(*calls, executor.ComposeFile)
+	if !reflect.DeepEqual(got, []string{"docker compose -f COMPOSE restart"}) {
+		t.Fatalf("commands = %#v, want only the restart", got)
+	}
+}
+
 func withFakeComposeCommands(t *testing.T, dockerV2Unavailable bool, exitCode int, psOutput string, defaultOutput string) *[]string {
 	t.Helper()
 	oldExecCommand := execCommand
 	oldExecLookPath := execLookPath
+	oldEngineClient := engineClient
+	composeBinary = sync.OnceValue(detectComposeBinary)
+	engineClient = func() (containerLister, error) { return nil, errors.New("engine unavailable") }
 	calls := []string{}
 	execLookPath = func(file string) (string, error) {
 		if file == "docker" {
@@ -148,6 +272,8 @@ func withFakeComposeCommands(t *testing.T, dockerV2Unavailable bool, exitCode in
 	t.Cleanup(func() {
 		execCommand = oldExecCommand
 		execLookPath = oldExecLookPath
+		engineClient = oldEngineClient
+		composeBinary = sync.OnceValue(detectComposeBinary)
 	})
 	return &calls
 }
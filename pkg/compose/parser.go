@@ -4,13 +4,31 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// StackNetworkKey is the networks: key of the generated compose file.
+const StackNetworkKey = "kkengine_net"
+
 type ComposeFile struct {
 	Services map[string]Service `yaml:"services"`
+	Networks map[string]Network `yaml:"networks"`
+}
+
+type Network struct {
+	Name string `yaml:"name"`
+}
+
+// PublishedPort is a host port mapping from a service's ports list.
+type PublishedPort struct {
+	HostIP        string
+	HostPort      int
+	ContainerPort int
 }
 
 type Service struct {
@@ -19,31 +37,132 @@ type Service struct {
 	Ports         []string     `yaml:"ports"`
 	HealthCheck   *HealthCheck `yaml:"healthcheck"`
 	DependsOn     interface{}  `yaml:"depends_on"`
+	CPUs          string       `yaml:"cpus"`
+	MemLimit      string       `yaml:"mem_limit"`
 }
 
 type HealthCheck struct {
-	Test     []string `yaml:"test"`
-	Interval string   `yaml:"interval"`
-	Timeout  string   `yaml:"timeout"`
-	Retries  int      `yaml:"retries"`
+	Test        []string `yaml:"test"`
+	Interval    string   `yaml:"interval"`
+	Timeout     string   `yaml:"timeout"`
+	Retries     int      `yaml:"retries"`
+	StartPeriod string   `yaml:"start_period"`
 }
 
-// ParseComposeFile reads and parses docker-compose.yml
-func ParseComposeFile(dir string) (*ComposeFile, error) {
-	composePath := filepath.Join(dir, "docker-compose.yml")
-	content, err := os.ReadFile(composePath)
-	if err != nil {
-		return nil, err
+// Dependencies returns the services s depends on, sorted, from either the
+// list or the map form of depends_on.
+func (s Service) Dependencies() []string {
+	var deps []string
+	switch v := s.DependsOn.(type) {
+	case []interface{}:
+		for _, d := range v {
+			if name, ok := d.(string); ok {
+				deps = append(deps, name)
+			}
+		}
+	case map[string]interface{}:
+		for name := range v {
+			deps = append(deps, name)
+		}
 	}
+	sort.Strings(deps)
+	return deps
+}
 
-	var compose ComposeFile
-	if err := yaml.Unmarshal(content, &compose); err != nil {
-		return nil, err
+// OverrideFileName is the user's compose overlay next to the generated
+// docker-compose.yml, for extra services, volumes or settings that survive
+// re-rendering.
+const OverrideFileName = "docker-compose.override.yml"
+
+// ComposeFiles returns the compose files of the stack in dir, in the order
+// docker compose must merge them: the generated file, then the overlay when
+// there is one.
+func ComposeFiles(dir string) []string {
+	files := []string{filepath.Join(dir, "docker-compose.yml")}
+	if overlay, ok := overlayFile(dir); ok {
+		files = append(files, overlay)
 	}
+	return files
+}
 
+func overlayFile(dir string) (string, bool) {
+	path := filepath.Join(dir, OverrideFileName)
+	_, err := os.Stat(path)
+	return path, err == nil
+}
+
+// ParseComposeFile reads and parses docker-compose.yml, merged with the
+// overlay the way docker compose merges it.
+func ParseComposeFile(dir string) (*ComposeFile, error) {
+	var compose ComposeFile
+	for _, path := range ComposeFiles(dir) {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var layer ComposeFile
+		if err := yaml.Unmarshal(content, &layer); err != nil {
+			return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		compose.merge(layer)
+	}
 	return &compose, nil
 }
 
+// merge adds the services and networks of an overlay. Services present in
+// both keep their settings unless the overlay sets them; ports and
+// dependencies are combined.
+func (c *ComposeFile) merge(overlay ComposeFile) {
+	if c.Services == nil {
+		c.Services = make(map[string]Service, len(overlay.Services))
+	}
+	for name, svc := range overlay.Services {
+		if base, ok := c.Services[name]; ok {
+			svc = base.merge(svc)
+		}
+		c.Services[name] = svc
+	}
+	if c.Networks == nil {
+		c.Networks = make(map[string]Network, len(overlay.Networks))
+	}
+	for key, network := range overlay.Networks {
+		c.Networks[key] = network
+	}
+}
+
+func (s Service) merge(overlay Service) Service {
+	if overlay.Image != "" {
+		s.Image = overlay.Image
+	}
+	if overlay.ContainerName != "" {
+		s.ContainerName = overlay.ContainerName
+	}
+	if overlay.CPUs != "" {
+		s.CPUs = overlay.CPUs
+	}
+	if overlay.MemLimit != "" {
+		s.MemLimit = overlay.MemLimit
+	}
+	for _, port := range overlay.Ports {
+		if !slices.Contains(s.Ports, port) {
+			s.Ports = append(s.Ports, port)
+		}
+	}
+	if overlay.HealthCheck != nil {
+		s.HealthCheck = overlay.HealthCheck
+	}
+	if overlay.DependsOn != nil {
+		names := append(s.Dependencies(), overlay.Dependencies()...)
+		sort.Strings(names)
+		var deps []interface{}
+		for _, name := range slices.Compact(names) {
+			deps = append(deps, name)
+		}
+		s.DependsOn = deps
+	}
+	return s
+}
+
 // GetServiceNames returns list of service names
 func (c *ComposeFile) GetServiceNames() []string {
 	var names []string
@@ -79,6 +198,71 @@ func (c *ComposeFile) GetServiceContainerName(serviceName string) string {
 	return fmt.Sprintf("kkengine_%s", serviceName)
 }
 
+// GetContainerNames returns the container names of all services, in service-name order.
+func (c *ComposeFile) GetContainerNames() []string {
+	var names []string
+	for _, name := range c.GetServiceNames() {
+		names = append(names, c.GetServiceContainerName(name))
+	}
+	return names
+}
+
+// GetNetworkName returns the Docker name of the network declared under key:
+// its explicit name, or the key itself.
+func (c *ComposeFile) GetNetworkName(key string) string {
+	if n, ok := c.Networks[key]; ok && n.Name != "" {
+		return n.Name
+	}
+	return key
+}
+
+// GetPublishedPorts returns the host ports a service publishes. Mappings
+// without a host port, port ranges and variables are skipped.
+func (c *ComposeFile) GetPublishedPorts(serviceName string) []PublishedPort {
+	var published []PublishedPort
+	for _, mapping := range c.GetServicePorts(serviceName) {
+		if p, ok := ParsePortMapping(mapping); ok {
+			published = append(published, p)
+		}
+	}
+	return published
+}
+
+// ParsePortMapping parses a short-syntax port mapping such as "8019:8019",
+// "127.0.0.1:3306:3306/tcp" or "[::1]:3306:3306". It reports false when the
+// mapping does not publish a single fixed host port.
+func ParsePortMapping(mapping string) (PublishedPort, bool) {
+	mapping, _, _ = strings.Cut(strings.TrimSpace(mapping), "/")
+
+	var p PublishedPort
+	if strings.HasPrefix(mapping, "[") {
+		ip, rest, ok := strings.Cut(mapping[1:], "]:")
+		if !ok {
+			return p, false
+		}
+		p.HostIP = ip
+		mapping = rest
+	}
+
+	parts := strings.Split(mapping, ":")
+	switch {
+	case len(parts) == 3 && p.HostIP == "":
+		p.HostIP = parts[0]
+		parts = parts[1:]
+	case len(parts) != 2:
+		return p, false
+	}
+
+	host, errHost := strconv.Atoi(parts[0])
+	container, errContainer := strconv.Atoi(parts[1])
+	if errHost != nil || errContainer != nil || host <= 0 {
+		return p, false
+	}
+	p.HostPort = host
+	p.ContainerPort = container
+	return p, true
+}
+
 // HasHealthCheck returns true if service has healthcheck defined
 func (c *ComposeFile) HasHealthCheck(serviceName string) bool {
 	if svc, ok := c.Services[serviceName]; ok {
@@ -152,3 +152,122 @@ func TestComposeFile_GetServicePorts(t *testing.T) {
 	ports = composeFile.GetServicePorts("nonexistent")
 	assert.Empty(t, ports)
 }
+
+func TestParsePortMapping(t *testing.T) {
+	tests := []struct {
+		mapping string
+		want    PublishedPort
+		ok      bool
+	}{
+		{"8019:8019", PublishedPort{HostPort: 8019, ContainerPort: 8019}, true},
+		{"13306:3306/tcp", PublishedPort{HostPort: 13306, ContainerPort: 3306}, true},
+		{"127.0.0.1:3306:3306", PublishedPort{HostIP: "127.0.0.1", HostPort: 3306, ContainerPort: 3306}, true},
+		{"[::1]:3306:3306", PublishedPort{HostIP: "::1", HostPort: 3306, ContainerPort: 3306}, true},
+		{"3306", PublishedPort{}, false},
+		{"8000-8010:8000-8010", PublishedPort{}, false},
+		{"${DB_PORT}:3306", PublishedPort{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := ParsePortMapping(tt.mapping)
+		assert.Equal(t, tt.ok, ok, tt.mapping)
+		if tt.ok {
+			assert.Equal(t, tt.want, got, tt.mapping)
+		}
+	}
+}
+
+func TestComposeFile_NamesAndPublishedPorts(t *testing.T) {
+	composeFile := &ComposeFile{
+		Services: map[string]Service{
+			"kkengine": {ContainerName: "staging_app", Ports: []string{"9019:8019"}},
+			"redis":    {ContainerName: "staging_redis"},
+		},
+		Networks: map[string]Network{"kkengine_net": {Name: "staging_net"}},
+	}
+	assert.Equal(t, []string{"staging_app", "staging_redis"}, composeFile.GetContainerNames())
+	assert.Equal(t, "staging_net", composeFile.GetNetworkName("kkengine_net"))
+	assert.Equal(t, "other", composeFile.GetNetworkName("other"))
+	assert.Equal(t, []PublishedPort{{HostPort: 9019, ContainerPort: 8019}}, composeFile.GetPublishedPorts("kkengine"))
+	assert.Empty(t, composeFile.GetPublishedPorts("redis"))
+}
+
+func TestService_Dependencies(t *testing.T) {
+	tempDir := t.TempDir()
+	composeContent := `
+services:
+  kkengine:
+    depends_on:
+      seaweedfs:
+        condition: service_healthy
+      db:
+        condition: service_healthy
+  caddy:
+    depends_on:
+      - kkengine
+  db:
+    healthcheck:
+      test: ["CMD", "true"]
+      start_period: 30s
+`
+	err := os.WriteFile(filepath.Join(tempDir, "docker-compose.yml"), []byte(composeContent), 0644)
+	assert.NoError(t, err)
+
+	composeFile, err := ParseComposeFile(tempDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db", "seaweedfs"}, composeFile.Services["kkengine"].Dependencies())
+	assert.Equal(t, []string{"kkengine"}, composeFile.Services["caddy"].Dependencies())
+	assert.Empty(t, composeFile.Services["db"].Dependencies())
+	assert.Equal(t, "30s", composeFile.Services["db"].HealthCheck.StartPeriod)
+}
+
+func TestParseComposeFileMergesOverlay(t *testing.T) {
+	dir := t.TempDir()
+	base := `services:
+  kkengine:
+    image: kkengine:latest
+    container_name: kkengine_app
+    ports:
+      - "8019:8019"
+    depends_on:
+      db:
+        condition: service_healthy
+  db:
+    image: mariadb:11
+networks:
+  kkengine_net:
+    name: kkengine_net
+`
+	overlay := `services:
+  kkengine:
+    ports:
+      - "8019:8019"
+      - "9100:9100"
+    depends_on:
+      - mailrelay
+  mailrelay:
+    image: boky/postfix:latest
+    container_name: kkengine_mailrelay
+    healthcheck:
+      test: ["CMD", "sh", "-c", "postfix status"]
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte(base), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, OverrideFileName), []byte(overlay), 0644))
+
+	assert.Equal(t, []string{filepath.Join(dir, "docker-compose.yml"), filepath.Join(dir, OverrideFileName)}, ComposeFiles(dir))
+
+	composeFile, err := ParseComposeFile(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"db", "kkengine", "mailrelay"}, composeFile.GetServiceNames())
+	assert.True(t, composeFile.HasHealthCheck("mailrelay"))
+	assert.Equal(t, "kkengine_mailrelay", composeFile.GetServiceContainerName("mailrelay"))
+
+	app := composeFile.Services["kkengine"]
+	assert.Equal(t, "kkengine:latest", app.Image)
+	assert.Equal(t, []string{"8019:8019", "9100:9100"}, app.Ports)
+	assert.Equal(t, []string{"db", "mailrelay"}, app.Dependencies())
+	assert.Equal(t, "kkengine_net", composeFile.GetNetworkName("kkengine_net"))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, OverrideFileName), []byte("services: [\n"), 0644))
+	_, err = ParseComposeFile(dir)
+	assert.ErrorContains(t, err, OverrideFileName)
+}
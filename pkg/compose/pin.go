@@ -0,0 +1,116 @@
+package compose
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RewriteImages replaces the image of every service in the compose files of
+// dir, the overlay included, with refFor(image). Only the image values are
+// touched; comments, ordering and formatting of the rest of the files are
+// preserved. Returns whether a file changed.
+func RewriteImages(dir string, refFor func(image string) string) (bool, error) {
+	changed := false
+	for _, path := range ComposeFiles(dir) {
+		rewritten, err := rewriteImagesIn(path, refFor)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || rewritten
+	}
+	return changed, nil
+}
+
+func rewriteImagesIn(composePath string, refFor func(image string) string) (bool, error) {
+	info, err := os.Stat(composePath)
+	if err != nil {
+		return false, err
+	}
+	content, err := os.ReadFile(composePath)
+	if err != nil {
+		return false, err
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(content, &root); err != nil {
+		return false, err
+	}
+
+	lines := strings.Split(string(content), "\n")
+	changed := false
+	for _, node := range serviceImageNodes(&root) {
+		ref := refFor(node.Value)
+		if ref == node.Value {
+			continue
+		}
+		if err := replaceScalar(lines, node, ref); err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if err := os.WriteFile(composePath, []byte(strings.Join(lines, "\n")), info.Mode().Perm()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// serviceImageNodes returns the scalar value node of services.*.image.
+func serviceImageNodes(root *yaml.Node) []*yaml.Node {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil
+	}
+	services := mappingValue(root.Content[0], "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	var nodes []*yaml.Node
+	for i := 1; i < len(services.Content); i += 2 {
+		image := mappingValue(services.Content[i], "image")
+		if image != nil && image.Kind == yaml.ScalarNode {
+			nodes = append(nodes, image)
+		}
+	}
+	return nodes
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// replaceScalar swaps the scalar at node's position for value, keeping its quote style.
+func replaceScalar(lines []string, node *yaml.Node, value string) error {
+	old := node.Value
+	switch node.Style {
+	case yaml.DoubleQuotedStyle:
+		old, value = `"`+old+`"`, `"`+value+`"`
+	case yaml.SingleQuotedStyle:
+		old, value = "'"+old+"'", "'"+value+"'"
+	}
+
+	if node.Line < 1 || node.Line > len(lines) {
+		return fmt.Errorf("image %q: line %d out of range", node.Value, node.Line)
+	}
+	line := lines[node.Line-1]
+	start := node.Column - 1
+	if start < 0 || !strings.HasPrefix(line[min(start, len(line)):], old) {
+		return fmt.Errorf("image %q on line %d uses a format kk cannot rewrite", node.Value, node.Line)
+	}
+	lines[node.Line-1] = line[:start] + value + line[start+len(old):]
+	return nil
+}
@@ -0,0 +1,69 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRewriteImages(t *testing.T) {
+	tempDir := t.TempDir()
+	content := `services:
+  app:
+    image: kkauto/kkengine:latest # main app
+    ports:
+      - "8019:8019"
+  redis:
+    image: "redis:alpine@sha256:old"
+  db:
+    image: mariadb:10.6
+`
+	composePath := filepath.Join(tempDir, "docker-compose.yml")
+	require.NoError(t, os.WriteFile(composePath, []byte(content), 0640))
+
+	pins := map[string]string{
+		"kkauto/kkengine:latest":  "kkauto/kkengine:latest@sha256:app",
+		"redis:alpine@sha256:old": "redis:alpine@sha256:new",
+	}
+	changed, err := RewriteImages(tempDir, func(image string) string {
+		if ref, ok := pins[image]; ok {
+			return ref
+		}
+		return image
+	})
+	require.NoError(t, err)
+	assert.True(t, changed)
+
+	got, err := os.ReadFile(composePath)
+	require.NoError(t, err)
+	assert.Equal(t, `services:
+  app:
+    image: kkauto/kkengine:latest@sha256:app # main app
+    ports:
+      - "8019:8019"
+  redis:
+    image: "redis:alpine@sha256:new"
+  db:
+    image: mariadb:10.6
+`, string(got))
+
+	info, err := os.Stat(composePath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	composeFile, err := ParseComposeFile(tempDir)
+	require.NoError(t, err)
+	assert.Equal(t, "redis:alpine@sha256:new", composeFile.Services["redis"].Image)
+}
+
+func TestRewriteImagesUnchanged(t *testing.T) {
+	tempDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(tempDir, "docker-compose.yml"), []byte("services:\n  db:\n    image: mariadb:10.6\n"), 0644))
+
+	changed, err := RewriteImages(tempDir, func(image string) string { return image })
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/kkauto-net/kk-install/pkg/notify"
 )
 
 const (
@@ -17,8 +19,13 @@ const (
 
 // Config represents user configuration
 type Config struct {
-	Language   string `yaml:"language"`    // "en" or "vi"
-	ProjectDir string `yaml:"project_dir"` // Path to project with docker-compose.yml
+	Language       string    `yaml:"language"`                  // "en" or "vi"
+	ProjectDir     string    `yaml:"project_dir"`               // Path to project with docker-compose.yml
+	CurrentProject string    `yaml:"current_project,omitempty"` // Named project selected by 'kk project use'
+	Projects       []Project `yaml:"projects,omitempty"`        // Named projects registered with 'kk project add'
+	Runtime        string    `yaml:"runtime,omitempty"`         // "docker" or "podman", chosen by 'kk init'; empty detects
+
+	Notifications *notify.Settings `yaml:"notifications,omitempty"` // Webhook targets for health and update events
 }
 
 // ConfigDir returns the config directory path (~/.kk)
@@ -58,6 +65,10 @@ func Load() (*Config, error) {
 	if cfg.Language != "en" && cfg.Language != "vi" {
 		cfg.Language = "en"
 	}
+	// Detect the runtime again rather than guess from an invalid value
+	if cfg.Runtime != "docker" && cfg.Runtime != "podman" {
+		cfg.Runtime = ""
+	}
 
 	return cfg, nil
 }
@@ -74,18 +85,32 @@ func (c *Config) Save() error {
 		return err
 	}
 
-	return os.WriteFile(ConfigPath(), data, 0644)
+	// Notification targets may hold webhook URLs and bot tokens; tighten
+	// configs written by older versions too
+	if err := os.WriteFile(ConfigPath(), data, 0600); err != nil {
+		return err
+	}
+	return os.Chmod(ConfigPath(), 0600)
 }
 
-// EnsureProjectDir validates and changes to the configured project directory.
+// EnsureProjectDir validates and changes to the active project directory.
 // Returns the project directory path or error if not configured/invalid.
-// If the project directory is invalid, it will be cleared from config.
+// If the unnamed project directory is invalid, it will be cleared from config;
+// named projects stay registered until 'kk project remove'.
 func EnsureProjectDir() (string, error) {
 	cfg, err := Load()
 	if err != nil {
 		return "", fmt.Errorf("failed to load config: %w", err)
 	}
 
+	project, err := cfg.ActiveProject()
+	if err != nil {
+		return "", err
+	}
+	if project != nil {
+		return enterProject(project)
+	}
+
 	if cfg.ProjectDir == "" {
 		return "", errors.New("no project configured, run 'kk init' first")
 	}
@@ -151,6 +176,9 @@ func IsProjectNotConfiguredError(err error) bool {
 		return false
 	}
 	msg := err.Error()
+	if errors.Is(err, ErrUnknownProject) {
+		return true
+	}
 	return msg == "no project configured, run 'kk init' first" ||
 		strings.Contains(msg, "project directory no longer exists") ||
 		strings.Contains(msg, "docker-compose.yml not found in")
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -62,6 +63,35 @@ func TestSaveAndLoad(t *testing.T) {
 	assert.Equal(t, "vi", loaded.Language)
 }
 
+func TestLoad_Notifications(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	require.NoError(t, os.MkdirAll(ConfigDir(), 0755))
+	data := `language: en
+notifications:
+  attempts: 5
+  timeout: 3s
+  targets:
+    - name: ops
+      type: slack
+      url: https://hooks.slack.com/services/T/B/X
+      events: [health]
+`
+	require.NoError(t, os.WriteFile(ConfigPath(), []byte(data), 0644))
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.NotNil(t, cfg.Notifications)
+	assert.Equal(t, 5, cfg.Notifications.Attempts)
+	assert.Equal(t, 3*time.Second, cfg.Notifications.Timeout)
+	require.Len(t, cfg.Notifications.Targets, 1)
+	assert.Equal(t, []string{"health"}, cfg.Notifications.Targets[0].Events)
+
+	require.NoError(t, cfg.Save())
+	info, err := os.Stat(ConfigPath())
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "config with tokens must not be world readable")
+}
+
 func TestLoad_InvalidLanguageDefaultsToEnglish(t *testing.T) {
 	// Use temp dir
 	origHome := os.Getenv("HOME")
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+)
+
+// ProjectEnv selects a named project when --project is not given.
+const ProjectEnv = "KK_PROJECT"
+
+// composeProjectEnv is read by every docker compose invocation.
+const composeProjectEnv = "COMPOSE_PROJECT_NAME"
+
+// composeProjectPrefix namespaces the Docker Compose project of named projects.
+const composeProjectPrefix = "kk-"
+
+// ErrUnknownProject is returned when the selected project is not registered.
+var ErrUnknownProject = errors.New("unknown project")
+
+var projectNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Project is a named kkengine stack on this host.
+type Project struct {
+	Name           string `yaml:"name" json:"name"`
+	Dir            string `yaml:"dir" json:"dir"`
+	ComposeProject string `yaml:"compose_project" json:"compose_project"` // COMPOSE_PROJECT_NAME for the stack
+}
+
+// selectedProject is set from the global --project flag.
+var selectedProject string
+
+// SelectProject overrides the project for this process. An empty name falls
+// back to KK_PROJECT and then to the current project in config.
+func SelectProject(name string) {
+	selectedProject = name
+}
+
+// SelectedProjectName returns the project requested by --project or
+// KK_PROJECT, or "" when neither is set.
+func SelectedProjectName() string {
+	if selectedProject != "" {
+		return selectedProject
+	}
+	return os.Getenv(ProjectEnv)
+}
+
+// ProjectName returns the project selected with --project or KK_PROJECT,
+// else the one chosen with 'kk project use', or "" for the unnamed default
+// project. Units and state kept per project are named after it.
+func ProjectName() string {
+	if name := SelectedProjectName(); name != "" {
+		return name
+	}
+	if cfg, err := Load(); err == nil {
+		return cfg.CurrentProject
+	}
+	return ""
+}
+
+// ValidateProjectName checks that name is usable as a project and Compose
+// project name.
+func ValidateProjectName(name string) error {
+	if !projectNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid project name %q: use lowercase letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// StateDir returns the directory for state kept per stack, such as the
+// update lock, update history and guard audit log: ~/.kk/projects/<name>
+// for the selected or current named project, ~/.kk for the unnamed default
+// project.
+func StateDir() string {
+	name := ProjectName()
+	// Unknown and invalid names fail project lookup before any state is kept.
+	if name == "" || ValidateProjectName(name) != nil {
+		return ConfigDir()
+	}
+	return filepath.Join(ConfigDir(), "projects", name)
+}
+
+// DefaultComposeProject returns the Compose project name for a project.
+func DefaultComposeProject(name string) string {
+	return composeProjectPrefix + name
+}
+
+// FindProject returns the registered project called name, or nil.
+func (c *Config) FindProject(name string) *Project {
+	for i := range c.Projects {
+		if c.Projects[i].Name == name {
+			return &c.Projects[i]
+		}
+	}
+	return nil
+}
+
+// ActiveProject returns the named project selected by --project, KK_PROJECT
+// or 'kk project use'. It returns nil when no named project is selected and
+// the legacy ProjectDir applies.
+func (c *Config) ActiveProject() (*Project, error) {
+	name := SelectedProjectName()
+	if name == "" {
+		name = c.CurrentProject
+	}
+	if name == "" {
+		return nil, nil
+	}
+	p := c.FindProject(name)
+	if p == nil {
+		return nil, fmt.Errorf("%w %q, see 'kk project list'", ErrUnknownProject, name)
+	}
+	return p, nil
+}
+
+// ActiveProjectDir returns the directory of the active project, or "" when
+// none is configured.
+func (c *Config) ActiveProjectDir() string {
+	p, err := c.ActiveProject()
+	switch {
+	case err != nil:
+		return ""
+	case p != nil:
+		return p.Dir
+	default:
+		return c.ProjectDir
+	}
+}
+
+// ComposeProjectName returns the Compose project of the selected named
+// project, including one that 'kk init' or 'kk restore' is about to register.
+// It returns "" for the unnamed default project.
+func (c *Config) ComposeProjectName() string {
+	name := SelectedProjectName()
+	if name == "" {
+		name = c.CurrentProject
+	}
+	if name == "" {
+		return ""
+	}
+	if p := c.FindProject(name); p != nil && p.ComposeProject != "" {
+		return p.ComposeProject
+	}
+	return DefaultComposeProject(name)
+}
+
+// ExportComposeProject sets COMPOSE_PROJECT_NAME for the selected named
+// project so docker compose calls made by this process use it.
+func (c *Config) ExportComposeProject() error {
+	name := c.ComposeProjectName()
+	if name == "" {
+		return nil
+	}
+	return os.Setenv(composeProjectEnv, name)
+}
+
+// AddProject registers a project. The directory is made absolute.
+func (c *Config) AddProject(p Project) error {
+	if err := ValidateProjectName(p.Name); err != nil {
+		return err
+	}
+	if c.FindProject(p.Name) != nil {
+		return fmt.Errorf("project %q already exists", p.Name)
+	}
+	dir, err := filepath.Abs(p.Dir)
+	if err != nil {
+		return err
+	}
+	p.Dir = dir
+	if p.ComposeProject == "" {
+		p.ComposeProject = DefaultComposeProject(p.Name)
+	}
+	c.Projects = append(c.Projects, p)
+	return nil
+}
+
+// UseProject makes name the current project.
+func (c *Config) UseProject(name string) error {
+	p := c.FindProject(name)
+	if p == nil {
+		return fmt.Errorf("%w %q, see 'kk project list'", ErrUnknownProject, name)
+	}
+	c.CurrentProject = p.Name
+	c.ProjectDir = p.Dir
+	return nil
+}
+
+// RemoveProject unregisters name. Files and containers are left untouched.
+// Removing the current project clears the selection.
+func (c *Config) RemoveProject(name string) error {
+	i := slices.IndexFunc(c.Projects, func(p Project) bool { return p.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w %q, see 'kk project list'", ErrUnknownProject, name)
+	}
+	c.Projects = slices.Delete(c.Projects, i, i+1)
+	if c.CurrentProject == name {
+		c.CurrentProject = ""
+		c.ProjectDir = ""
+	}
+	return nil
+}
+
+// SetInitializedDir records dir as the project created by 'kk init'. A named
+// project selected with --project or KK_PROJECT is registered (or moved) to
+// dir; otherwise dir becomes the unnamed default project.
+func (c *Config) SetInitializedDir(dir string) error {
+	name := SelectedProjectName()
+	if name == "" {
+		c.CurrentProject = ""
+		c.ProjectDir = dir
+		return nil
+	}
+	if p := c.FindProject(name); p != nil {
+		p.Dir = dir
+		if c.CurrentProject == name {
+			c.ProjectDir = dir
+		}
+		return nil
+	}
+	return c.AddProject(Project{Name: name, Dir: dir})
+}
+
+// enterProject validates a named project, exports its Compose project name
+// and changes into its directory.
+func enterProject(p *Project) (string, error) {
+	if info, err := os.Stat(p.Dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("project directory no longer exists: %s (project %q)", p.Dir, p.Name)
+	}
+	if _, err := os.Stat(filepath.Join(p.Dir, "docker-compose.yml")); os.IsNotExist(err) {
+		return "", fmt.Errorf("docker-compose.yml not found in: %s (project %q)", p.Dir, p.Name)
+	}
+
+	composeProject := p.ComposeProject
+	if composeProject == "" {
+		composeProject = DefaultComposeProject(p.Name)
+	}
+	// Every docker compose call inherits this, so stacks do not share a
+	// Compose project even when their directories have the same base name.
+	if err := os.Setenv(composeProjectEnv, composeProject); err != nil {
+		return "", err
+	}
+
+	if err := os.Chdir(p.Dir); err != nil {
+		return "", fmt.Errorf("failed to change to project directory %s: %w", p.Dir, err)
+	}
+	return p.Dir, nil
+}
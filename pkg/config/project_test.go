@@ -0,0 +1,160 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newProjectDir(t *testing.T, root, name string) string {
+	t.Helper()
+	dir := filepath.Join(root, name)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte("services: {}\n"), 0644))
+	return dir
+}
+
+func TestProjectAddUseRemove(t *testing.T) {
+	cfg := &Config{ProjectDir: "/srv/legacy"}
+
+	require.NoError(t, cfg.AddProject(Project{Name: "staging", Dir: "/srv/staging"}))
+	require.NoError(t, cfg.AddProject(Project{Name: "prod", Dir: "/srv/prod", ComposeProject: "kkengine"}))
+	assert.Error(t, cfg.AddProject(Project{Name: "prod", Dir: "/srv/other"}))
+	assert.Error(t, cfg.AddProject(Project{Name: "Bad Name", Dir: "/srv/other"}))
+	assert.Equal(t, "kk-staging", cfg.FindProject("staging").ComposeProject)
+
+	require.NoError(t, cfg.UseProject("prod"))
+	assert.Equal(t, "prod", cfg.CurrentProject)
+	assert.Equal(t, "/srv/prod", cfg.ProjectDir)
+	assert.Equal(t, "kkengine", cfg.ComposeProjectName())
+
+	assert.ErrorIs(t, cfg.UseProject("missing"), ErrUnknownProject)
+
+	require.NoError(t, cfg.RemoveProject("prod"))
+	assert.Empty(t, cfg.CurrentProject)
+	assert.Empty(t, cfg.ProjectDir)
+	assert.Len(t, cfg.Projects, 1)
+}
+
+func TestActiveProjectSelection(t *testing.T) {
+	t.Setenv(ProjectEnv, "")
+	defer SelectProject("")
+
+	cfg := &Config{
+		ProjectDir:     "/srv/prod",
+		CurrentProject: "prod",
+		Projects: []Project{
+			{Name: "prod", Dir: "/srv/prod", ComposeProject: "kk-prod"},
+			{Name: "staging", Dir: "/srv/staging", ComposeProject: "kk-staging"},
+		},
+	}
+
+	p, err := cfg.ActiveProject()
+	require.NoError(t, err)
+	assert.Equal(t, "prod", p.Name)
+
+	t.Setenv(ProjectEnv, "staging")
+	assert.Equal(t, "/srv/staging", cfg.ActiveProjectDir())
+
+	SelectProject("prod")
+	assert.Equal(t, "/srv/prod", cfg.ActiveProjectDir())
+
+	SelectProject("nope")
+	_, err = cfg.ActiveProject()
+	assert.True(t, errors.Is(err, ErrUnknownProject))
+	assert.True(t, IsProjectNotConfiguredError(err))
+	assert.Empty(t, cfg.ActiveProjectDir())
+
+	SelectProject("")
+	t.Setenv(ProjectEnv, "")
+	legacy := &Config{ProjectDir: "/srv/legacy"}
+	p, err = legacy.ActiveProject()
+	require.NoError(t, err)
+	assert.Nil(t, p)
+	assert.Equal(t, "/srv/legacy", legacy.ActiveProjectDir())
+	assert.Empty(t, legacy.ComposeProjectName())
+}
+
+func TestStateDir(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(ProjectEnv, "")
+	defer SelectProject("")
+
+	assert.Equal(t, ConfigDir(), StateDir())
+
+	require.NoError(t, (&Config{CurrentProject: "prod"}).Save())
+	assert.Equal(t, filepath.Join(ConfigDir(), "projects", "prod"), StateDir())
+
+	SelectProject("staging")
+	assert.Equal(t, filepath.Join(ConfigDir(), "projects", "staging"), StateDir())
+
+	SelectProject("../escape")
+	assert.Equal(t, ConfigDir(), StateDir())
+}
+
+func TestProjectName(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(ProjectEnv, "")
+	defer SelectProject("")
+
+	assert.Empty(t, ProjectName())
+
+	require.NoError(t, (&Config{CurrentProject: "prod"}).Save())
+	assert.Equal(t, "prod", ProjectName())
+
+	t.Setenv(ProjectEnv, "staging")
+	assert.Equal(t, "staging", ProjectName())
+
+	SelectProject("shop")
+	assert.Equal(t, "shop", ProjectName())
+}
+
+func TestSetInitializedDir(t *testing.T) {
+	t.Setenv(ProjectEnv, "")
+	defer SelectProject("")
+
+	cfg := &Config{CurrentProject: "prod", Projects: []Project{{Name: "prod", Dir: "/srv/prod"}}}
+	require.NoError(t, cfg.SetInitializedDir("/srv/new"))
+	assert.Empty(t, cfg.CurrentProject)
+	assert.Equal(t, "/srv/new", cfg.ProjectDir)
+
+	SelectProject("staging")
+	require.NoError(t, cfg.SetInitializedDir("/srv/staging"))
+	require.NotNil(t, cfg.FindProject("staging"))
+	assert.Equal(t, "kk-staging", cfg.FindProject("staging").ComposeProject)
+	assert.Equal(t, "/srv/new", cfg.ProjectDir, "registering a project must not switch the default")
+}
+
+func TestEnsureProjectDir_NamedProject(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("COMPOSE_PROJECT_NAME", "")
+	defer SelectProject("")
+
+	prod := newProjectDir(t, tmpDir, "prod")
+	staging := newProjectDir(t, tmpDir, "staging")
+
+	cfg := &Config{Language: "en"}
+	require.NoError(t, cfg.AddProject(Project{Name: "prod", Dir: prod}))
+	require.NoError(t, cfg.AddProject(Project{Name: "staging", Dir: staging}))
+	require.NoError(t, cfg.UseProject("prod"))
+	require.NoError(t, cfg.Save())
+
+	SelectProject("staging")
+	dir, err := EnsureProjectDir()
+	require.NoError(t, err)
+	assert.Equal(t, staging, dir)
+	assert.Equal(t, "kk-staging", os.Getenv("COMPOSE_PROJECT_NAME"))
+
+	require.NoError(t, os.RemoveAll(staging))
+	_, err = EnsureProjectDir()
+	assert.Error(t, err)
+
+	loaded, err := Load()
+	require.NoError(t, err)
+	assert.NotNil(t, loaded.FindProject("staging"), "named projects stay registered")
+}
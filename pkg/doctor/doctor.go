@@ -0,0 +1,178 @@
+// Package doctor assembles the diagnostic bundle written by kk doctor. Every
+// file is redacted before it is staged, so the bundle can be attached to a
+// support ticket as is.
+package doctor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/archive"
+	"github.com/kkauto-net/kk-install/pkg/logs"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+// SummaryFile is the human-readable report at the root of the bundle.
+const SummaryFile = "summary.txt"
+
+// ErrorsFile lists what could not be collected into the bundle.
+const ErrorsFile = "errors.txt"
+
+// Status is the outcome of one diagnostic check.
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusInfo Status = "info"
+)
+
+// Check is one line of the summary.
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Bundle accumulates redacted diagnostic files in a staging directory.
+type Bundle struct {
+	dir      string
+	redactor *logs.Redactor
+	header   []string
+	checks   []Check
+	errors   []string
+}
+
+// NewBundle starts a bundle in stagingDir. secrets are masked in every file
+// in addition to the ui.RedactSecrets rules.
+func NewBundle(stagingDir string, secrets []string) *Bundle {
+	return &Bundle{dir: stagingDir, redactor: logs.NewRedactor(secrets)}
+}
+
+// Redact masks the bundle's secrets and anything ui.RedactSecrets recognises.
+func (b *Bundle) Redact(s string) string {
+	return ui.RedactSecrets(b.redactor.Redact(s))
+}
+
+// SetHeader sets the "key: value" lines printed above the checks.
+func (b *Bundle) SetHeader(lines ...string) {
+	b.header = lines
+}
+
+// AddCheck records a check result for the summary. The detail is redacted.
+func (b *Bundle) AddCheck(name string, status Status, detail string) {
+	b.checks = append(b.checks, Check{Name: name, Status: status, Detail: b.Redact(detail)})
+}
+
+// Checks returns the checks recorded so far.
+func (b *Bundle) Checks() []Check {
+	return b.checks
+}
+
+// AddError records a failure to collect part of the bundle. Write stages the
+// failures as ErrorsFile. A nil err is ignored.
+func (b *Bundle) AddError(err error) {
+	if err != nil {
+		b.errors = append(b.errors, err.Error())
+	}
+}
+
+// AddFile stages data under name after redacting it.
+func (b *Bundle) AddFile(name string, data []byte) error {
+	path := filepath.Join(b.dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.Redact(string(data))), 0600)
+}
+
+// AddJSON stages v as indented JSON under name.
+func (b *Bundle) AddJSON(name string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode %s: %w", name, err)
+	}
+	return b.AddFile(name, append(data, '\n'))
+}
+
+// AddMaskedEnv stages the keys of the .env in projectDir with every value
+// masked. It does nothing when there is no .env.
+func (b *Bundle) AddMaskedEnv(projectDir string) error {
+	data, err := os.ReadFile(filepath.Join(projectDir, ".env"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return b.AddFile("env.masked", []byte(MaskEnv(string(data))))
+}
+
+// Summary renders the human-readable report.
+func (b *Bundle) Summary() string {
+	var sb strings.Builder
+	sb.WriteString("kk doctor report\n")
+	for _, line := range b.header {
+		sb.WriteString(b.Redact(line) + "\n")
+	}
+	sb.WriteString("\n")
+
+	width := 0
+	for _, c := range b.checks {
+		width = max(width, len(c.Name))
+	}
+	for _, c := range b.checks {
+		line := fmt.Sprintf("[%-4s] %-*s", strings.ToUpper(string(c.Status)), width, c.Name)
+		if c.Detail != "" {
+			line += "  " + strings.ReplaceAll(c.Detail, "\n", "\n"+strings.Repeat(" ", width+9))
+		}
+		sb.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	return sb.String()
+}
+
+// Write adds the summary and the collection errors, and packs the staging
+// directory into a tar.gz at dest.
+func (b *Bundle) Write(dest string) error {
+	if err := b.AddFile(SummaryFile, []byte(b.Summary())); err != nil {
+		return err
+	}
+	if len(b.errors) > 0 {
+		if err := b.AddFile(ErrorsFile, []byte(strings.Join(b.errors, "\n")+"\n")); err != nil {
+			return err
+		}
+	}
+	return archive.Create(b.dir, dest)
+}
+
+// MaskEnv returns the KEY= lines of an env file with non-empty values masked.
+// Comments and blank lines are dropped.
+func MaskEnv(env string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(env, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		sb.WriteString(strings.TrimSpace(key) + "=")
+		if strings.TrimSpace(value) != "" {
+			sb.WriteString(ui.RedactedSecret)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// DefaultFileName names a bundle created at t.
+func DefaultFileName(t time.Time) string {
+	return "kk-doctor-" + t.UTC().Format("20060102-150405") + ".tar.gz"
+}
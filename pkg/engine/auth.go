@@ -0,0 +1,150 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types/registry"
+)
+
+// dockerHubServer is the key the docker CLI stores Docker Hub logins under.
+const dockerHubServer = "https://index.docker.io/v1/"
+
+var execCommand = exec.CommandContext
+
+// dockerConfig is the part of the docker CLI config.json holding logins.
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// RegistryAuth returns the encoded credentials `docker login` saved for the
+// registry of ref, for image.PullOptions.RegistryAuth. It returns "" when
+// there are none, or they can not be read, so the pull goes anonymous.
+func RegistryAuth(ctx context.Context, ref string) string {
+	server := registryServer(ref)
+	if server == "" {
+		return ""
+	}
+	cfg, ok := loadDockerConfig()
+	if !ok {
+		return ""
+	}
+	auth, ok := cfg.credentials(ctx, server)
+	if !ok {
+		return ""
+	}
+	encoded, err := registry.EncodeAuthConfig(auth)
+	if err != nil {
+		return ""
+	}
+	return encoded
+}
+
+// registryServer returns the config key of the registry serving ref.
+func registryServer(ref string) string {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return ""
+	}
+	if domain := reference.Domain(named); domain != "docker.io" {
+		return domain
+	}
+	return dockerHubServer
+}
+
+// loadDockerConfig reads config.json from the docker CLI config directory.
+func loadDockerConfig() (dockerConfig, bool) {
+	var cfg dockerConfig
+	configDir, err := dockerConfigDir()
+	if err != nil {
+		return cfg, false
+	}
+	data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		return cfg, false
+	}
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return cfg, false
+	}
+	return cfg, true
+}
+
+// credentials looks server up like the docker CLI: in its credential
+// helper, else the default credential store, else the auths entry.
+func (c dockerConfig) credentials(ctx context.Context, server string) (registry.AuthConfig, bool) {
+	helper := c.CredHelpers[server]
+	if helper == "" {
+		helper = c.CredsStore
+	}
+	if helper != "" {
+		if auth, ok := helperCredentials(ctx, helper, server); ok {
+			return auth, true
+		}
+	}
+
+	entry, ok := c.Auths[server]
+	if !ok {
+		return registry.AuthConfig{}, false
+	}
+	auth := registry.AuthConfig{
+		Username:      entry.Username,
+		Password:      entry.Password,
+		IdentityToken: entry.IdentityToken,
+		ServerAddress: server,
+	}
+	if entry.Auth != "" {
+		decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+		if err != nil {
+			return registry.AuthConfig{}, false
+		}
+		user, password, found := strings.Cut(string(decoded), ":")
+		if !found {
+			return registry.AuthConfig{}, false
+		}
+		auth.Username, auth.Password = user, password
+	}
+	if auth.Username == "" && auth.IdentityToken == "" {
+		return registry.AuthConfig{}, false
+	}
+	return auth, true
+}
+
+// helperCredentials asks docker-credential-<helper> for the login of server.
+func helperCredentials(ctx context.Context, helper, server string) (registry.AuthConfig, bool) {
+	cmd := execCommand(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return registry.AuthConfig{}, false
+	}
+	var creds struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil || creds.Secret == "" {
+		return registry.AuthConfig{}, false
+	}
+	auth := registry.AuthConfig{ServerAddress: server}
+	// Helpers return identity tokens under this username.
+	if creds.Username == "<token>" {
+		auth.IdentityToken = creds.Secret
+	} else {
+		auth.Username, auth.Password = creds.Username, creds.Secret
+	}
+	return auth, true
+}
@@ -0,0 +1,78 @@
+package engine
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+func writeDockerConfig(t *testing.T, content string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CONFIG", dir)
+}
+
+func decodeAuth(t *testing.T, encoded string) registry.AuthConfig {
+	t.Helper()
+	auth, err := registry.DecodeAuthConfig(encoded)
+	if err != nil {
+		t.Fatalf("DecodeAuthConfig() error = %v", err)
+	}
+	return *auth
+}
+
+func TestRegistryAuthFromAuths(t *testing.T) {
+	hub := base64.StdEncoding.EncodeToString([]byte("hubuser:hubpass"))
+	private := base64.StdEncoding.EncodeToString([]byte("ci:secret"))
+	writeDockerConfig(t, `{"auths":{
+		"https://index.docker.io/v1/":{"auth":"`+hub+`"},
+		"registry.example.com:5000":{"auth":"`+private+`"}
+	}}`)
+	ctx := context.Background()
+
+	auth := decodeAuth(t, RegistryAuth(ctx, "redis:alpine"))
+	if auth.Username != "hubuser" || auth.Password != "hubpass" || auth.ServerAddress != dockerHubServer {
+		t.Fatalf("Docker Hub auth = %+v", auth)
+	}
+	auth = decodeAuth(t, RegistryAuth(ctx, "registry.example.com:5000/team/app:1.2"))
+	if auth.Username != "ci" || auth.Password != "secret" {
+		t.Fatalf("private registry auth = %+v", auth)
+	}
+	if got := RegistryAuth(ctx, "ghcr.io/other/app"); got != "" {
+		t.Fatalf("RegistryAuth() for an unknown registry = %q, want anonymous", got)
+	}
+}
+
+func TestRegistryAuthNoConfig(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	if got := RegistryAuth(context.Background(), "redis:alpine"); got != "" {
+		t.Fatalf("RegistryAuth() = %q, want anonymous", got)
+	}
+}
+
+func TestRegistryAuthFromCredentialHelper(t *testing.T) {
+	writeDockerConfig(t, `{"credHelpers":{"ghcr.io":"fake"}}`)
+	var gotName string
+	old := execCommand
+	execCommand = func(ctx context.Context, name string, _ ...string) *exec.Cmd {
+		gotName = name
+		return exec.CommandContext(ctx, "sh", "-c", `read -r server; printf '{"ServerURL":"%s","Username":"bot","Secret":"token"}' "$server"`)
+	}
+	t.Cleanup(func() { execCommand = old })
+
+	auth := decodeAuth(t, RegistryAuth(context.Background(), "ghcr.io/team/app:latest"))
+	if gotName != "docker-credential-fake" {
+		t.Fatalf("helper = %q", gotName)
+	}
+	if auth.Username != "bot" || auth.Password != "token" || auth.ServerAddress != "ghcr.io" {
+		t.Fatalf("helper auth = %+v", auth)
+	}
+}
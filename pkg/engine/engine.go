@@ -0,0 +1,120 @@
+// Package engine is the Docker Engine API client shared by every kk command.
+// Inspecting containers and images, listing containers, following events and
+// pulling or tagging images go through the API; only compose operations
+// shell out to the compose CLI.
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+// Compose labels set on every container docker compose creates.
+const (
+	LabelProject    = "com.docker.compose.project"
+	LabelService    = "com.docker.compose.service"
+	LabelWorkingDir = "com.docker.compose.project.working_dir"
+)
+
+var shared = sync.OnceValues(New)
+
+// New returns a new client for the current target (see SetTarget),
+// configured from the environment (DOCKER_HOST, DOCKER_API_VERSION, ...) and
+// negotiating the API version with the daemon.
+func New() (*client.Client, error) {
+	ep, err := CurrentTarget().endpoint()
+	if err != nil {
+		return nil, err
+	}
+	targetOpts, err := ep.options()
+	if err != nil {
+		return nil, err
+	}
+	opts := append([]client.Opt{client.FromEnv}, targetOpts...)
+	cli, err := client.NewClientWithOpts(append(opts, client.WithAPIVersionNegotiation())...)
+	if err != nil {
+		return nil, fmt.Errorf("create Docker client: %w", err)
+	}
+	return cli, nil
+}
+
+// Shared returns the process-wide client, created on first use. Closing it
+// only drops idle connections, so callers may Close it when they are done.
+func Shared() (*client.Client, error) {
+	return shared()
+}
+
+// IsNotFound reports whether err means the container, image or network does
+// not exist.
+func IsNotFound(err error) bool {
+	return cerrdefs.IsNotFound(err)
+}
+
+// RepoDigest returns the digest part of the first of an image's RepoDigests
+// in sorted order, or "" when it has none (a local build).
+func RepoDigest(repoDigests []string) string {
+	sorted := slices.Clone(repoDigests)
+	slices.Sort(sorted)
+	for _, rd := range sorted {
+		if _, digest, ok := strings.Cut(rd, "@"); ok && digest != "" {
+			return digest
+		}
+	}
+	return ""
+}
+
+// ImagePuller is the API subset PullImage needs.
+type ImagePuller interface {
+	ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error)
+}
+
+// PullImage pulls ref and waits for the pull to finish, with the credentials
+// `docker login` saved for its registry (see RegistryAuth). Errors the daemon
+// reports in the progress stream are returned as errors.
+func PullImage(ctx context.Context, cli ImagePuller, ref string) error {
+	stream, err := cli.ImagePull(ctx, ref, image.PullOptions{RegistryAuth: RegistryAuth(ctx, ref)})
+	if err != nil {
+		return err
+	}
+	pullErr := readPullProgress(stream)
+	if closeErr := stream.Close(); pullErr == nil {
+		pullErr = closeErr
+	}
+	return pullErr
+}
+
+// readPullProgress reads an image pull progress stream to its end and
+// returns the first error it reports.
+func readPullProgress(stream io.Reader) error {
+	dec := json.NewDecoder(stream)
+	for {
+		var msg struct {
+			Error       string `json:"error"`
+			ErrorDetail struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if decodeErr := dec.Decode(&msg); decodeErr != nil {
+			if errors.Is(decodeErr, io.EOF) {
+				return nil
+			}
+			return decodeErr
+		}
+		if msg.ErrorDetail.Message != "" {
+			return errors.New(msg.ErrorDetail.Message)
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+	}
+}
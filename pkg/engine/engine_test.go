@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/image"
+)
+
+type fakePuller string
+
+func (f fakePuller) ImagePull(_ context.Context, _ string, _ image.PullOptions) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(string(f))), nil
+}
+
+func TestPullImage(t *testing.T) {
+	ok := fakePuller(`{"status":"Pulling from library/redis"}` + "\n" + `{"status":"Digest: sha256:abc"}` + "\n")
+	if err := PullImage(context.Background(), ok, "redis:alpine"); err != nil {
+		t.Fatalf("PullImage() error = %v", err)
+	}
+
+	denied := fakePuller(`{"status":"Pulling"}` + "\n" + `{"errorDetail":{"message":"pull access denied"},"error":"pull access denied"}` + "\n")
+	err := PullImage(context.Background(), denied, "private/app")
+	if err == nil || err.Error() != "pull access denied" {
+		t.Fatalf("PullImage() error = %v, want the stream error", err)
+	}
+}
+
+func TestShared(t *testing.T) {
+	a, errA := Shared()
+	b, errB := Shared()
+	if errA != nil || errB != nil {
+		t.Fatalf("Shared() errors = %v, %v", errA, errB)
+	}
+	if a != b {
+		t.Fatal("Shared() returned different clients")
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	if !IsNotFound(fmt.Errorf("No such container: db: %w", cerrdefs.ErrNotFound)) {
+		t.Error("wrapped ErrNotFound should be not found")
+	}
+	if IsNotFound(fmt.Errorf("permission denied")) {
+		t.Error("other errors are not not-found")
+	}
+}
+
+func TestRepoDigest(t *testing.T) {
+	got := RepoDigest([]string{"registry.example.com/redis@sha256:bbb", "redis@sha256:aaa"})
+	if got != "sha256:aaa" {
+		t.Fatalf("RepoDigest() = %q, want the digest of the first sorted entry", got)
+	}
+	if got = RepoDigest(nil); got != "" {
+		t.Fatalf("RepoDigest(nil) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ProbeImage is the image probes run in. It is pulled on first use.
+const ProbeImage = "busybox:stable"
+
+// Probe is a throwaway container running one command on the engine's host.
+// It lets checks that read the host, such as listening ports or free disk
+// space, work against a remote engine.
+type Probe struct {
+	Cmd         []string
+	Binds       []string // host:container[:ro]
+	HostNetwork bool     // Share the host's network namespace
+}
+
+// ProbeClient is the API subset RunProbe needs.
+type ProbeClient interface {
+	ImagePuller
+	ImageInspect(ctx context.Context, ref string, opts ...client.ImageInspectOption) (image.InspectResponse, error)
+	ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error)
+	ContainerStart(ctx context.Context, containerID string, options container.StartOptions) error
+	ContainerWait(ctx context.Context, containerID string, condition container.WaitCondition) (<-chan container.WaitResponse, <-chan error)
+	ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error)
+	ContainerRemove(ctx context.Context, containerID string, options container.RemoveOptions) error
+}
+
+// RunProbe runs p to completion, removes its container and returns its
+// standard output. A non-zero exit is an error carrying standard error.
+func RunProbe(ctx context.Context, cli ProbeClient, p Probe) (output string, err error) {
+	if _, err = cli.ImageInspect(ctx, ProbeImage); err != nil {
+		if !IsNotFound(err) {
+			return "", err
+		}
+		if pullErr := PullImage(ctx, cli, ProbeImage); pullErr != nil {
+			return "", fmt.Errorf("pull %s: %w", ProbeImage, pullErr)
+		}
+	}
+
+	hostConfig := &container.HostConfig{Binds: p.Binds}
+	if p.HostNetwork {
+		hostConfig.NetworkMode = network.NetworkHost
+	}
+	created, err := cli.ContainerCreate(ctx, &container.Config{Image: ProbeImage, Cmd: p.Cmd}, hostConfig, nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if removeErr := cli.ContainerRemove(context.WithoutCancel(ctx), created.ID, container.RemoveOptions{Force: true}); removeErr != nil {
+			err = errors.Join(err, fmt.Errorf("remove probe container: %w", removeErr))
+		}
+	}()
+
+	if err = cli.ContainerStart(ctx, created.ID, container.StartOptions{}); err != nil {
+		return "", err
+	}
+	var exitCode int64
+	statusCh, errCh := cli.ContainerWait(ctx, created.ID, container.WaitConditionNotRunning)
+	select {
+	case status := <-statusCh:
+		exitCode = status.StatusCode
+	case err = <-errCh:
+		return "", err
+	}
+
+	logs, err := cli.ContainerLogs(ctx, created.ID, container.LogsOptions{ShowStdout: true, ShowStderr: true})
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	_, err = stdcopy.StdCopy(&stdout, &stderr, logs)
+	if closeErr := logs.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("%s exited with code %d: %s", strings.Join(p.Cmd, " "), exitCode, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
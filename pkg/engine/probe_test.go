@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type fakeProbeEngine struct {
+	fakePuller
+	pulled     bool
+	hostConfig *container.HostConfig
+	cmd        []string
+	exitCode   int64
+	stdout     string
+	stderr     string
+	removed    bool
+}
+
+func (f *fakeProbeEngine) ImageInspect(_ context.Context, ref string, _ ...client.ImageInspectOption) (image.InspectResponse, error) {
+	return image.InspectResponse{}, fmt.Errorf("No such image: %s: %w", ref, cerrdefs.ErrNotFound)
+}
+
+func (f *fakeProbeEngine) ImagePull(ctx context.Context, ref string, opts image.PullOptions) (io.ReadCloser, error) {
+	f.pulled = true
+	return f.fakePuller.ImagePull(ctx, ref, opts)
+}
+
+func (f *fakeProbeEngine) ContainerCreate(_ context.Context, config *container.Config, hostConfig *container.HostConfig, _ *network.NetworkingConfig, _ *ocispec.Platform, _ string) (container.CreateResponse, error) {
+	f.cmd, f.hostConfig = config.Cmd, hostConfig
+	return container.CreateResponse{ID: "probe1"}, nil
+}
+
+func (f *fakeProbeEngine) ContainerStart(context.Context, string, container.StartOptions) error {
+	return nil
+}
+
+func (f *fakeProbeEngine) ContainerWait(context.Context, string, container.WaitCondition) (<-chan container.WaitResponse, <-chan error) {
+	statusCh := make(chan container.WaitResponse, 1)
+	statusCh <- container.WaitResponse{StatusCode: f.exitCode}
+	return statusCh, make(chan error)
+}
+
+func (f *fakeProbeEngine) ContainerLogs(context.Context, string, container.LogsOptions) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	if _, err := stdcopy.NewStdWriter(&buf, stdcopy.Stdout).Write([]byte(f.stdout)); err != nil {
+		return nil, err
+	}
+	if _, err := stdcopy.NewStdWriter(&buf, stdcopy.Stderr).Write([]byte(f.stderr)); err != nil {
+		return nil, err
+	}
+	return io.NopCloser(&buf), nil
+}
+
+func (f *fakeProbeEngine) ContainerRemove(context.Context, string, container.RemoveOptions) error {
+	f.removed = true
+	return nil
+}
+
+func TestRunProbe(t *testing.T) {
+	cli := &fakeProbeEngine{fakePuller: `{"status":"Downloaded"}`, stdout: "listening\n", stderr: "noise\n"}
+	out, err := RunProbe(context.Background(), cli, Probe{Cmd: []string{"cat", "/proc/net/tcp"}, HostNetwork: true})
+	if err != nil || out != "listening\n" {
+		t.Fatalf("RunProbe() = %q, %v", out, err)
+	}
+	if !cli.pulled || !cli.removed {
+		t.Fatalf("pulled = %t, removed = %t, want both", cli.pulled, cli.removed)
+	}
+	if !cli.hostConfig.NetworkMode.IsHost() || !slices.Equal(cli.cmd, []string{"cat", "/proc/net/tcp"}) {
+		t.Fatalf("created %v with %+v", cli.cmd, cli.hostConfig)
+	}
+
+	failing := &fakeProbeEngine{exitCode: 1, stderr: "df: /data: No such file\n"}
+	_, err = RunProbe(context.Background(), failing, Probe{Cmd: []string{"df", "-Pk", "/data"}})
+	if err == nil || !strings.Contains(err.Error(), "No such file") || !failing.removed {
+		t.Fatalf("RunProbe(failing) error = %v, removed = %t", err, failing.removed)
+	}
+}
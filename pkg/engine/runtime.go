@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Runtime is the container runtime kk drives. Podman is used through its
+// Docker-compatible API socket, so the API client works unchanged; the CLI,
+// the compose command and how the service is installed and started differ.
+type Runtime string
+
+const (
+	RuntimeDocker Runtime = "docker"
+	RuntimePodman Runtime = "podman"
+)
+
+// RuntimeEnv carries the runtime to the processes kk starts: docker group
+// re-execs and the systemd units of kk update schedule, exporter and guard.
+const RuntimeEnv = "KK_RUNTIME"
+
+var (
+	lookPath           = exec.LookPath
+	execCommandContext = exec.CommandContext
+)
+
+// ParseRuntime parses docker, podman or auto; auto and "" return "".
+func ParseRuntime(s string) (Runtime, error) {
+	switch r := Runtime(strings.ToLower(strings.TrimSpace(s))); r {
+	case "", "auto":
+		return "", nil
+	case RuntimeDocker, RuntimePodman:
+		return r, nil
+	}
+	return "", fmt.Errorf("unknown container runtime %q (want docker, podman or auto)", s)
+}
+
+// DetectRuntime returns Docker when the docker CLI is installed and is not
+// Podman's docker alias (podman-docker), Podman when Podman is installed,
+// and Docker otherwise so kk offers to install it.
+func DetectRuntime() Runtime {
+	if path, err := lookPath("docker"); err == nil && !isPodmanAlias(path) {
+		return RuntimeDocker
+	}
+	if _, err := lookPath("podman"); err == nil {
+		return RuntimePodman
+	}
+	return RuntimeDocker
+}
+
+// isPodmanAlias reports whether the docker at path is podman-docker's
+// wrapper script rather than the Docker CLI.
+func isPodmanAlias(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	head := make([]byte, 512)
+	n, err := f.Read(head)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false
+	}
+	return bytes.HasPrefix(head[:n], []byte("#!")) && bytes.Contains(head[:n], []byte("podman"))
+}
+
+// podmanHost is the DOCKER_HOST SetRuntime exported for Podman, if any.
+var podmanHost string
+
+// SetRuntime selects the runtime for the rest of the process ("" detects it)
+// and exports KK_RUNTIME for the processes kk starts. For Podman it points
+// DOCKER_HOST at Podman's API socket unless --docker-host or --context chose
+// an engine. Call it after SetTarget and before the first Shared.
+func SetRuntime(r Runtime) error {
+	if r == "" {
+		r = DetectRuntime()
+	}
+	if err := os.Setenv(RuntimeEnv, string(r)); err != nil {
+		return err
+	}
+	if podmanHost != "" && os.Getenv("DOCKER_HOST") == podmanHost {
+		if err := os.Unsetenv("DOCKER_HOST"); err != nil {
+			return err
+		}
+		podmanHost = ""
+	}
+	if r == RuntimePodman && os.Getenv("DOCKER_HOST") == "" && os.Getenv("DOCKER_CONTEXT") == "" {
+		podmanHost = "unix://" + PodmanSocket()
+		return os.Setenv("DOCKER_HOST", podmanHost)
+	}
+	return nil
+}
+
+// CurrentRuntime returns the runtime chosen with SetRuntime or KK_RUNTIME,
+// detecting it otherwise.
+func CurrentRuntime() Runtime {
+	if r, err := ParseRuntime(os.Getenv(RuntimeEnv)); err == nil && r != "" {
+		return r
+	}
+	return DetectRuntime()
+}
+
+// Rootless reports whether Podman runs rootless, that is as kk's own user.
+func Rootless() bool {
+	return os.Geteuid() != 0
+}
+
+// PodmanSocket returns the path of Podman's Docker-compatible API socket,
+// provided by the podman.socket systemd unit (the user unit when rootless).
+func PodmanSocket() string {
+	if !Rootless() {
+		return "/run/podman/podman.sock"
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if dir == "" {
+		dir = fmt.Sprintf("/run/user/%d", os.Getuid())
+	}
+	return filepath.Join(dir, "podman", "podman.sock")
+}
+
+// RunHealthcheck runs the healthcheck of container once. Podman schedules
+// healthchecks with systemd timers, which hosts without systemd or rootless
+// users without lingering do not get, leaving containers "starting" forever;
+// kk runs them itself whenever it checks health. It does nothing for Docker,
+// whose daemon runs healthchecks, and for remote engines.
+func RunHealthcheck(ctx context.Context, container string) error {
+	if CurrentRuntime() != RuntimePodman || CurrentTarget().Remote() {
+		return nil
+	}
+	// Exits non-zero for unhealthy containers and those without a
+	// healthcheck; the inspect that follows reports both.
+	err := execCommandContext(ctx, "podman", "healthcheck", "run", container).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRuntime(t *testing.T) {
+	for input, want := range map[string]Runtime{"": "", "auto": "", "Docker": RuntimeDocker, " podman ": RuntimePodman} {
+		if got, err := ParseRuntime(input); err != nil || got != want {
+			t.Errorf("ParseRuntime(%q) = %q, %v, want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseRuntime("containerd"); err == nil {
+		t.Error("ParseRuntime(containerd) expected an error")
+	}
+}
+
+func TestDetectRuntime(t *testing.T) {
+	dir := t.TempDir()
+	alias := filepath.Join(dir, "docker")
+	if err := os.WriteFile(alias, []byte("#!/bin/sh\nexec /usr/bin/podman \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	original := lookPath
+	t.Cleanup(func() { lookPath = original })
+
+	tests := []struct {
+		name      string
+		installed map[string]string
+		want      Runtime
+	}{
+		{"docker", map[string]string{"docker": "/usr/bin/docker", "podman": "/usr/bin/podman"}, RuntimeDocker},
+		{"podman", map[string]string{"podman": "/usr/bin/podman"}, RuntimePodman},
+		{"podman-docker alias", map[string]string{"docker": alias, "podman": "/usr/bin/podman"}, RuntimePodman},
+		{"neither", nil, RuntimeDocker},
+	}
+	for _, tt := range tests {
+		lookPath = func(file string) (string, error) {
+			if path, ok := tt.installed[file]; ok {
+				return path, nil
+			}
+			return "", errors.New("not found")
+		}
+		if got := DetectRuntime(); got != tt.want {
+			t.Errorf("%s: DetectRuntime() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetRuntimePodman(t *testing.T) {
+	t.Setenv(RuntimeEnv, "")
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+
+	if err := SetRuntime(RuntimePodman); err != nil {
+		t.Fatalf("SetRuntime() error = %v", err)
+	}
+	if CurrentRuntime() != RuntimePodman {
+		t.Fatalf("CurrentRuntime() = %q", CurrentRuntime())
+	}
+	if got, want := os.Getenv("DOCKER_HOST"), "unix://"+PodmanSocket(); got != want {
+		t.Fatalf("DOCKER_HOST = %q, want %q", got, want)
+	}
+	if Rootless() && PodmanSocket() != "/run/user/1000/podman/podman.sock" {
+		t.Fatalf("PodmanSocket() = %q", PodmanSocket())
+	}
+
+	// Switching back (kk init picking Docker) drops the Podman socket.
+	if err := SetRuntime(RuntimeDocker); err != nil || CurrentRuntime() != RuntimeDocker {
+		t.Fatalf("SetRuntime(docker) = %v, runtime %q", err, CurrentRuntime())
+	}
+	if got := os.Getenv("DOCKER_HOST"); got != "" {
+		t.Fatalf("DOCKER_HOST = %q, want it unset for Docker", got)
+	}
+}
+
+func TestSetRuntimeKeepsExplicitHost(t *testing.T) {
+	t.Setenv(RuntimeEnv, "")
+	t.Setenv("DOCKER_HOST", "ssh://deploy@prod")
+	if err := SetRuntime(RuntimePodman); err != nil {
+		t.Fatalf("SetRuntime() error = %v", err)
+	}
+	if got := os.Getenv("DOCKER_HOST"); got != "ssh://deploy@prod" {
+		t.Fatalf("DOCKER_HOST = %q, want the --docker-host value", got)
+	}
+}
@@ -0,0 +1,282 @@
+package engine
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// Target selects the Docker engine kk talks to. The zero value leaves the
+// choice to the environment: DOCKER_HOST, DOCKER_CONTEXT or the Docker CLI's
+// current context, usually the local daemon.
+type Target struct {
+	Host    string // e.g. ssh://deploy@vps or tcp://10.0.0.5:2376
+	Context string // A Docker CLI context name
+}
+
+// SetTarget selects the engine for the rest of the process. It exports
+// DOCKER_HOST or DOCKER_CONTEXT so the docker and compose CLIs kk runs talk
+// to the same engine as the API client. Call it before the first Shared.
+func SetTarget(t Target) error {
+	switch {
+	case t.Host != "" && t.Context != "":
+		return errors.New("--docker-host and --context cannot be used together")
+	case t.Host != "":
+		if _, err := client.ParseHostURL(t.Host); err != nil {
+			return fmt.Errorf("invalid Docker host %q: %w", t.Host, err)
+		}
+		return os.Setenv("DOCKER_HOST", t.Host)
+	case t.Context != "":
+		if _, err := t.endpoint(); err != nil {
+			return err
+		}
+		// DOCKER_HOST would win over DOCKER_CONTEXT in the docker CLI.
+		if err := os.Unsetenv("DOCKER_HOST"); err != nil {
+			return err
+		}
+		return os.Setenv("DOCKER_CONTEXT", t.Context)
+	}
+	return nil
+}
+
+// CurrentTarget returns the engine selected by the flags or the environment.
+func CurrentTarget() Target {
+	return Target{Host: os.Getenv("DOCKER_HOST"), Context: os.Getenv("DOCKER_CONTEXT")}
+}
+
+// Remote reports whether the engine runs on another machine. Checks that
+// read the local host (free ports, disk space) must then go through the
+// engine instead.
+func (t Target) Remote() bool {
+	ep, err := t.endpoint()
+	if err != nil || ep.Host == "" {
+		return false
+	}
+	u, err := url.Parse(ep.Host)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "unix", "npipe":
+		return false
+	case "tcp", "http", "https":
+		host := u.Hostname()
+		return host != "localhost" && !net.ParseIP(host).IsLoopback()
+	}
+	return true
+}
+
+// String describes the target for messages: the host, or the context and
+// the host it points at. It is empty for the local default engine.
+func (t Target) String() string {
+	ep, err := t.endpoint()
+	if err != nil || ep.Host == "" {
+		return ""
+	}
+	if ep.Context != "" {
+		return fmt.Sprintf("%s (context %s)", ep.Host, ep.Context)
+	}
+	return ep.Host
+}
+
+// endpoint is a resolved engine address.
+type endpoint struct {
+	Host    string
+	Context string // Set when Host came from a Docker context
+	TLSDir  string // ca.pem, cert.pem and key.pem of the context, if any
+}
+
+type contextMeta struct {
+	Endpoints struct {
+		Docker struct {
+			Host string `json:"Host"`
+		} `json:"docker"`
+	} `json:"Endpoints"`
+}
+
+// endpoint resolves t the way the docker CLI does: an explicit host, then a
+// context (explicit, or the CLI's currentContext), then the default engine.
+func (t Target) endpoint() (endpoint, error) {
+	if t.Host != "" {
+		return endpoint{Host: t.Host}, nil
+	}
+	name := t.Context
+	if name == "" {
+		name = currentContext()
+	}
+	if name == "" || name == "default" {
+		return endpoint{}, nil
+	}
+
+	configDir, err := dockerConfigDir()
+	if err != nil {
+		return endpoint{}, fmt.Errorf("read docker context %q: %w", name, err)
+	}
+	id := sha256.Sum256([]byte(name))
+	dir := hex.EncodeToString(id[:])
+	data, err := os.ReadFile(filepath.Join(configDir, "contexts", "meta", dir, "meta.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return endpoint{}, fmt.Errorf("docker context %q not found (see docker context ls)", name)
+		}
+		return endpoint{}, fmt.Errorf("read docker context %q: %w", name, err)
+	}
+	var meta contextMeta
+	if err = json.Unmarshal(data, &meta); err != nil {
+		return endpoint{}, fmt.Errorf("parse docker context %q: %w", name, err)
+	}
+	ep := endpoint{Host: meta.Endpoints.Docker.Host, Context: name}
+	tlsDir := filepath.Join(configDir, "contexts", "tls", dir, "docker")
+	if _, err = os.Stat(filepath.Join(tlsDir, "ca.pem")); err == nil {
+		ep.TLSDir = tlsDir
+	}
+	return ep, nil
+}
+
+// options returns the client options that point a client at ep.
+func (ep endpoint) options() ([]client.Opt, error) {
+	if ep.Host == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(ep.Host, "ssh://") {
+		args, err := sshArgs(ep.Host)
+		if err != nil {
+			return nil, err
+		}
+		// The host only names the connection; every dial runs ssh.
+		return []client.Opt{
+			client.WithHost("http://docker.example.com"),
+			client.WithDialContext(func(context.Context, string, string) (net.Conn, error) {
+				return dialCommand("ssh", args...)
+			}),
+		}, nil
+	}
+	opts := []client.Opt{client.WithHost(ep.Host)}
+	if ep.TLSDir != "" {
+		opts = append(opts, client.WithTLSClientConfig(
+			filepath.Join(ep.TLSDir, "ca.pem"),
+			filepath.Join(ep.TLSDir, "cert.pem"),
+			filepath.Join(ep.TLSDir, "key.pem"),
+		))
+	}
+	return opts, nil
+}
+
+// dockerConfigDir returns $DOCKER_CONFIG, or ~/.docker.
+func dockerConfigDir() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker"), nil
+}
+
+// currentContext returns the context selected with docker context use.
+func currentContext() string {
+	configDir, err := dockerConfigDir()
+	if err != nil {
+		return ""
+	}
+	data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+	if err != nil {
+		return ""
+	}
+	var cfg struct {
+		CurrentContext string `json:"currentContext"`
+	}
+	if json.Unmarshal(data, &cfg) != nil {
+		return ""
+	}
+	return cfg.CurrentContext
+}
+
+// sshArgs returns the ssh arguments that reach the engine behind an
+// ssh://[user@]host[:port] URL, as the docker CLI does.
+func sshArgs(host string) ([]string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Docker host %q: %w", host, err)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid Docker host %q: no hostname", host)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("invalid Docker host %q: ssh hosts cannot have a path", host)
+	}
+	var args []string
+	if user := u.User.Username(); user != "" {
+		args = append(args, "-l", user)
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	return append(args, "--", u.Hostname(), "docker", "system", "dial-stdio"), nil
+}
+
+// dialCommand starts name and returns a connection over its stdin and stdout.
+func dialCommand(name string, args ...string) (net.Conn, error) {
+	cmd := exec.Command(name, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", name, err)
+	}
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+// commandConn is a net.Conn over a command's stdin and stdout.
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+}
+
+func (c *commandConn) Read(p []byte) (int, error)  { return c.stdout.Read(p) }
+func (c *commandConn) Write(p []byte) (int, error) { return c.stdin.Write(p) }
+
+// Close hangs up by killing the command, so the exit status it then reports
+// is not an error.
+func (c *commandConn) Close() error {
+	closeErr := c.stdin.Close()
+	if err := c.cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return errors.Join(closeErr, err)
+	}
+	var exitErr *exec.ExitError
+	if err := c.cmd.Wait(); err != nil && !errors.As(err, &exitErr) {
+		return errors.Join(closeErr, err)
+	}
+	return closeErr
+}
+
+func (c *commandConn) LocalAddr() net.Addr              { return commandAddr{} }
+func (c *commandConn) RemoteAddr() net.Addr             { return commandAddr{} }
+func (c *commandConn) SetDeadline(time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(time.Time) error { return nil }
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string { return "command" }
+func (commandAddr) String() string  { return "command" }
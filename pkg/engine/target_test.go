@@ -0,0 +1,110 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// writeContext creates a docker context the way docker context create does.
+func writeContext(t *testing.T, configDir, name, host string) {
+	t.Helper()
+	id := sha256.Sum256([]byte(name))
+	dir := filepath.Join(configDir, "contexts", "meta", hex.EncodeToString(id[:]))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	meta := `{"Name":"` + name + `","Metadata":{},"Endpoints":{"docker":{"Host":"` + host + `","SkipTLSVerify":false}}}`
+	if err := os.WriteFile(filepath.Join(dir, "meta.json"), []byte(meta), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTargetEndpoint(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	writeContext(t, configDir, "prod", "ssh://deploy@prod.example.com")
+
+	ep, err := Target{Context: "prod"}.endpoint()
+	if err != nil || ep.Host != "ssh://deploy@prod.example.com" || ep.Context != "prod" {
+		t.Fatalf("endpoint(prod) = %+v, %v", ep, err)
+	}
+	if _, err = (Target{Context: "missing"}).endpoint(); err == nil {
+		t.Fatal("endpoint(missing) expected an error")
+	}
+	if ep, err = (Target{}).endpoint(); err != nil || ep.Host != "" {
+		t.Fatalf("endpoint() = %+v, %v, want the default engine", ep, err)
+	}
+
+	// docker context use prod
+	if err = os.WriteFile(filepath.Join(configDir, "config.json"), []byte(`{"currentContext":"prod"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := (Target{}).String(); got != "ssh://deploy@prod.example.com (context prod)" {
+		t.Fatalf("String() = %q", got)
+	}
+	if got := (Target{Host: "tcp://10.0.0.5:2376"}).String(); got != "tcp://10.0.0.5:2376" {
+		t.Fatalf("String() = %q, want the explicit host to win", got)
+	}
+}
+
+func TestTargetRemote(t *testing.T) {
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	tests := map[string]bool{
+		"":                            false,
+		"unix:///var/run/docker.sock": false,
+		"tcp://127.0.0.1:2375":        false,
+		"tcp://localhost:2375":        false,
+		"tcp://10.0.0.5:2376":         true,
+		"ssh://deploy@prod":           true,
+	}
+	for host, want := range tests {
+		if got := (Target{Host: host}).Remote(); got != want {
+			t.Errorf("Target{Host: %q}.Remote() = %t, want %t", host, got, want)
+		}
+	}
+}
+
+func TestSetTarget(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+	writeContext(t, configDir, "prod", "ssh://prod")
+
+	if err := SetTarget(Target{Host: "ssh://a", Context: "prod"}); err == nil {
+		t.Fatal("SetTarget() accepted both a host and a context")
+	}
+	if err := SetTarget(Target{Host: "not a url"}); err == nil {
+		t.Fatal("SetTarget() accepted an invalid host")
+	}
+	if err := SetTarget(Target{Host: "tcp://10.0.0.5:2376"}); err != nil {
+		t.Fatalf("SetTarget(host) error = %v", err)
+	}
+	if got := CurrentTarget(); got.Host != "tcp://10.0.0.5:2376" {
+		t.Fatalf("CurrentTarget() = %+v", got)
+	}
+	if err := SetTarget(Target{Context: "prod"}); err != nil {
+		t.Fatalf("SetTarget(context) error = %v", err)
+	}
+	if got := CurrentTarget(); got.Host != "" || got.Context != "prod" {
+		t.Fatalf("CurrentTarget() = %+v, want only the context", got)
+	}
+}
+
+func TestSSHArgs(t *testing.T) {
+	args, err := sshArgs("ssh://deploy@prod.example.com:2222")
+	want := []string{"-l", "deploy", "-p", "2222", "--", "prod.example.com", "docker", "system", "dial-stdio"}
+	if err != nil || !slices.Equal(args, want) {
+		t.Fatalf("sshArgs() = %v, %v, want %v", args, err, want)
+	}
+	if args, err = sshArgs("ssh://prod"); err != nil || !slices.Equal(args, []string{"--", "prod", "docker", "system", "dial-stdio"}) {
+		t.Fatalf("sshArgs(no user) = %v, %v", args, err)
+	}
+	if _, err = sshArgs("ssh://prod/var/run/docker.sock"); err == nil {
+		t.Fatal("sshArgs() accepted a path")
+	}
+}
@@ -0,0 +1,243 @@
+// Package events emits newline-delimited JSON lifecycle events so that
+// provisioning tools can follow a kk command without parsing terminal output.
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/redact"
+)
+
+// FormatNDJSON is the only supported --events value.
+const FormatNDJSON = "ndjson"
+
+// Type identifies an event.
+type Type string
+
+const (
+	TypeCommandStarted  Type = "command.started"
+	TypeStepStarted     Type = "step.started"
+	TypeStepFinished    Type = "step.finished"
+	TypePreflightResult Type = "preflight.result"
+	TypeHealthChanged   Type = "health.changed"
+	TypeCommandFinished Type = "command.finished"
+)
+
+// Step statuses reported with step.finished.
+const (
+	StepOK     = "ok"
+	StepFailed = "failed"
+)
+
+// Event is one line of the stream. Only the payload matching Type is set.
+type Event struct {
+	Time      time.Time  `json:"time"`
+	Type      Type       `json:"type"`
+	Command   string     `json:"command"`
+	Step      *Step      `json:"step,omitempty"`
+	Preflight *Preflight `json:"preflight,omitempty"`
+	Health    *Health    `json:"health,omitempty"`
+	ExitCode  *int       `json:"exit_code,omitempty"`
+	Error     *Error     `json:"error,omitempty"`
+}
+
+// Step describes a numbered command step.
+type Step struct {
+	Number int    `json:"number"`
+	Total  int    `json:"total"`
+	Title  string `json:"title"`
+	Status string `json:"status,omitempty"`
+}
+
+// Preflight is the outcome of one preflight check.
+type Preflight struct {
+	Check      string `json:"check"`
+	Passed     bool   `json:"passed"`
+	Warning    string `json:"warning,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Fix        string `json:"fix,omitempty"`
+	FixCommand string `json:"fix_command,omitempty"`
+}
+
+// Health is a service health transition.
+type Health struct {
+	Service   string `json:"service"`
+	Container string `json:"container"`
+	Status    string `json:"status"`
+	Healthy   bool   `json:"healthy"`
+	Message   string `json:"message,omitempty"`
+}
+
+// Error is the final error of a command.
+type Error struct {
+	Message string `json:"message"`
+	Key     string `json:"key,omitempty"`
+}
+
+// ParseFormat validates an --events value. Empty disables events.
+func ParseFormat(s string) (string, error) {
+	switch s {
+	case "", FormatNDJSON:
+		return s, nil
+	default:
+		return "", fmt.Errorf("invalid events format %q (use ndjson)", s)
+	}
+}
+
+// Emitter writes events to a stream. It is safe for concurrent use.
+type Emitter struct {
+	mu      sync.Mutex
+	enc     *json.Encoder
+	command string
+	step    *Step
+	now     func() time.Time
+	err     error // first error writing the stream
+}
+
+// NewEmitter returns an emitter writing to w on behalf of command.
+func NewEmitter(w io.Writer, command string) *Emitter {
+	return &Emitter{enc: json.NewEncoder(w), command: command, now: time.Now}
+}
+
+func (e *Emitter) emit(ev Event) {
+	ev.Time = e.now().UTC()
+	ev.Command = e.command
+	// A broken event pipe must not abort the command itself; Err reports it.
+	if err := e.enc.Encode(ev); err != nil && e.err == nil {
+		e.err = err
+	}
+}
+
+// Err returns the first error writing the stream, if any.
+func (e *Emitter) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.err
+}
+
+// Start emits command.started.
+func (e *Emitter) Start() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(Event{Type: TypeCommandStarted})
+}
+
+// StepStarted finishes the open step, if any, and starts a new one.
+func (e *Emitter) StepStarted(number, total int, title string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.finishStep(StepOK)
+	e.step = &Step{Number: number, Total: total, Title: title}
+	e.emit(Event{Type: TypeStepStarted, Step: e.step})
+}
+
+func (e *Emitter) finishStep(status string) {
+	if e.step == nil {
+		return
+	}
+	step := *e.step
+	step.Status = status
+	e.step = nil
+	e.emit(Event{Type: TypeStepFinished, Step: &step})
+}
+
+// Preflight emits preflight.result.
+func (e *Emitter) Preflight(p Preflight) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(Event{Type: TypePreflightResult, Preflight: &p})
+}
+
+// Health emits health.changed.
+func (e *Emitter) Health(h Health) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.emit(Event{Type: TypeHealthChanged, Health: &h})
+}
+
+// Finish closes the open step and emits command.finished. A nil err means
+// the command succeeded; key is the UserError key, if any.
+func (e *Emitter) Finish(exitCode int, err error, key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ev := Event{Type: TypeCommandFinished, ExitCode: &exitCode}
+	if err != nil {
+		e.finishStep(StepFailed)
+		ev.Error = &Error{Message: redact.Error(err), Key: key}
+	} else {
+		e.finishStep(StepOK)
+	}
+	e.emit(ev)
+}
+
+var (
+	defaultMu sync.RWMutex
+	current   *Emitter
+)
+
+// Enable routes the package-level helpers to w and emits command.started.
+func Enable(w io.Writer, command string) {
+	e := NewEmitter(w, command)
+	defaultMu.Lock()
+	current = e
+	defaultMu.Unlock()
+	e.Start()
+}
+
+// Disable stops emitting events.
+func Disable() {
+	defaultMu.Lock()
+	current = nil
+	defaultMu.Unlock()
+}
+
+// Enabled reports whether an event stream is active.
+func Enabled() bool {
+	return active() != nil
+}
+
+func active() *Emitter {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return current
+}
+
+// StepStarted emits step.started on the active stream, if any.
+func StepStarted(number, total int, title string) {
+	if e := active(); e != nil {
+		e.StepStarted(number, total, title)
+	}
+}
+
+// PreflightResult emits preflight.result on the active stream, if any.
+func PreflightResult(p Preflight) {
+	if e := active(); e != nil {
+		e.Preflight(p)
+	}
+}
+
+// HealthChanged emits health.changed on the active stream, if any.
+func HealthChanged(h Health) {
+	if e := active(); e != nil {
+		e.Health(h)
+	}
+}
+
+// Finish emits command.finished on the active stream, if any.
+func Finish(exitCode int, err error, key string) {
+	if e := active(); e != nil {
+		e.Finish(exitCode, err, key)
+	}
+}
+
+// Err returns the first error writing the active stream, if any.
+func Err() error {
+	if e := active(); e != nil {
+		return e.Err()
+	}
+	return nil
+}
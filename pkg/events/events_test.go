@@ -0,0 +1,116 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) []Event {
+	t.Helper()
+	var out []Event
+	sc := bufio.NewScanner(buf)
+	for sc.Scan() {
+		var ev Event
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &ev), sc.Text())
+		out = append(out, ev)
+	}
+	return out
+}
+
+func TestEmitterStepLifecycle(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEmitter(&buf, "kk start")
+	e.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	e.Start()
+	e.StepStarted(1, 2, "Preflight")
+	e.Preflight(Preflight{Check: "Docker", Passed: true})
+	e.StepStarted(2, 2, "Start services")
+	e.Health(Health{Service: "db", Container: "kkengine_db", Status: "unhealthy"})
+	e.Finish(4, errors.New("db unhealthy"), "")
+
+	evs := decode(t, &buf)
+	types := make([]Type, len(evs))
+	for i, ev := range evs {
+		types[i] = ev.Type
+		assert.Equal(t, "kk start", ev.Command)
+	}
+	assert.Equal(t, []Type{
+		TypeCommandStarted,
+		TypeStepStarted, TypePreflightResult, TypeStepFinished,
+		TypeStepStarted, TypeHealthChanged, TypeStepFinished,
+		TypeCommandFinished,
+	}, types)
+
+	assert.Equal(t, StepOK, evs[3].Step.Status)
+	assert.Equal(t, 1, evs[3].Step.Number)
+	assert.Equal(t, StepFailed, evs[6].Step.Status)
+	require.NotNil(t, evs[7].ExitCode)
+	assert.Equal(t, 4, *evs[7].ExitCode)
+	assert.Equal(t, "db unhealthy", evs[7].Error.Message)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestEmitterKeepsWriteError(t *testing.T) {
+	e := NewEmitter(failingWriter{}, "kk start")
+	e.Start()
+	e.Finish(0, nil, "")
+	assert.EqualError(t, e.Err(), "broken pipe")
+}
+
+func TestFinishSuccess(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEmitter(&buf, "kk update")
+	e.Finish(0, nil, "")
+
+	evs := decode(t, &buf)
+	require.Len(t, evs, 1)
+	assert.Equal(t, 0, *evs[0].ExitCode)
+	assert.Nil(t, evs[0].Error)
+}
+
+func TestFinishRedactsError(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEmitter(&buf, "kk init")
+	e.Finish(1, errors.New("write .env: DB_PASSWORD=hunter2"), "")
+
+	evs := decode(t, &buf)
+	require.Len(t, evs, 1)
+	assert.Equal(t, "write .env: DB_PASSWORD=[REDACTED]", evs[0].Error.Message)
+}
+
+func TestPackageHelpersNoopWhenDisabled(t *testing.T) {
+	Disable()
+	assert.False(t, Enabled())
+	StepStarted(1, 1, "noop")
+	Finish(0, nil, "")
+
+	var buf bytes.Buffer
+	Enable(&buf, "kk init")
+	defer Disable()
+	assert.True(t, Enabled())
+	StepStarted(1, 1, "Docker")
+	Finish(0, nil, "")
+	assert.Len(t, decode(t, &buf), 4)
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"", "ndjson"} {
+		_, err := ParseFormat(s)
+		assert.NoError(t, err)
+	}
+	_, err := ParseFormat("json")
+	assert.Error(t, err)
+}
@@ -0,0 +1,244 @@
+// Package exporter serves the health of a kk stack as Prometheus metrics in
+// the text exposition format.
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+)
+
+// ContentType is the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// ErrNoTLS is returned by Collector.Cert when the stack serves no HTTPS; the
+// certificate metrics are then left out rather than reported as failed.
+var ErrNoTLS = errors.New("stack does not serve HTTPS")
+
+// Cert is the TLS certificate served for the stack domain.
+type Cert struct {
+	Domain   string
+	NotAfter time.Time
+}
+
+// Collector gathers the stack state on every scrape. Statuses is required;
+// the other sources are optional and their metrics are omitted when nil.
+type Collector struct {
+	// Statuses returns the compose services, enriched with restart counts
+	// and image creation times.
+	Statuses func(ctx context.Context) ([]monitor.ServiceStatus, error)
+	// Health checks a running container once.
+	Health func(ctx context.Context, containerName string) monitor.HealthStatus
+	// LastUpdate returns when the last successful kk update finished, and
+	// false when there was none.
+	LastUpdate func() (time.Time, bool, error)
+	// Cert fetches the TLS certificate served by the stack.
+	Cert func(ctx context.Context) (Cert, error)
+	// Now defaults to time.Now.
+	Now func() time.Time
+	// OnWriteError receives the errors of writing a response, usually a
+	// scraper that went away.
+	OnWriteError func(error)
+
+	mu sync.Mutex // one scrape at a time
+}
+
+// Collect writes one scrape to w.
+func (c *Collector) Collect(ctx context.Context, w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	start := now()
+
+	up := newFamily("kk_up", "gauge", "Whether the stack status could be read (1) or not (0).")
+	serviceUp := newFamily("kk_service_up", "gauge", "Whether the service container is running.")
+	serviceHealthy := newFamily("kk_service_healthy", "gauge", "Whether the service is healthy, or running when it has no healthcheck.")
+	restarts := newFamily("kk_service_restart_count", "gauge", "Number of times Docker restarted the service container.")
+	imageAge := newFamily("kk_service_image_age_seconds", "gauge", "Age of the image the service container runs, from the image creation time.")
+	lastUpdate := newFamily("kk_last_successful_update_timestamp_seconds", "gauge", "Unix time the last successful kk update finished.")
+	certDays := newFamily("kk_tls_certificate_expiry_days", "gauge", "Days until the TLS certificate served for the stack domain expires.")
+	collectorOK := newFamily("kk_collector_success", "gauge", "Whether each collector succeeded during this scrape.")
+	duration := newFamily("kk_scrape_duration_seconds", "gauge", "Time taken to collect the metrics.")
+
+	statuses, err := c.Statuses(ctx)
+	up.add(boolValue(err == nil))
+	collectorOK.add(boolValue(err == nil), "collector", "status")
+	for _, s := range statuses {
+		running := s.Running && s.ContainerName != ""
+		serviceUp.add(boolValue(running), "service", s.Name)
+
+		healthy := running && (s.Health == "" || s.Health == "healthy")
+		if running && c.Health != nil {
+			healthy = c.Health(ctx, s.ContainerName).Healthy
+		}
+		serviceHealthy.add(boolValue(healthy), "service", s.Name)
+
+		if s.ContainerName == "" {
+			continue
+		}
+		restarts.add(float64(s.RestartCount), "service", s.Name)
+		if s.ImageCreated != nil {
+			imageAge.add(now().Sub(*s.ImageCreated).Seconds(), "service", s.Name, "image", s.Image)
+		}
+	}
+
+	if c.LastUpdate != nil {
+		at, ok, updateErr := c.LastUpdate()
+		collectorOK.add(boolValue(updateErr == nil), "collector", "update")
+		if updateErr == nil && ok {
+			lastUpdate.add(float64(at.Unix()))
+		}
+	}
+
+	if c.Cert != nil {
+		cert, certErr := c.Cert(ctx)
+		if !errors.Is(certErr, ErrNoTLS) {
+			collectorOK.add(boolValue(certErr == nil), "collector", "tls")
+		}
+		if certErr == nil {
+			certDays.add(cert.NotAfter.Sub(now()).Hours()/24, "domain", cert.Domain)
+		}
+	}
+
+	duration.add(now().Sub(start).Seconds())
+
+	var buf bytes.Buffer
+	for _, f := range []*family{up, serviceUp, serviceHealthy, restarts, imageAge, lastUpdate, certDays, collectorOK, duration} {
+		f.write(&buf)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// Handler serves /metrics, collecting with the given per-scrape timeout.
+func (c *Collector) Handler(timeout time.Duration) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		var buf bytes.Buffer
+		if err := c.Collect(ctx, &buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		c.writeResponse(w, buf.Bytes())
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		c.writeResponse(w, []byte(`<html><head><title>kk exporter</title></head><body><h1>kk exporter</h1><p><a href="/metrics">Metrics</a></p></body></html>`))
+	})
+	return mux
+}
+
+// writeResponse writes body to w and passes a failed write to OnWriteError.
+func (c *Collector) writeResponse(w io.Writer, body []byte) {
+	if _, err := w.Write(body); err != nil && c.OnWriteError != nil {
+		c.OnWriteError(err)
+	}
+}
+
+// FetchCert connects to addr with TLS using domain as server name and returns
+// the leaf certificate. The certificate is not verified, so that an expired or
+// self-signed certificate is still reported.
+func FetchCert(ctx context.Context, addr, domain string) (Cert, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{},
+		Config:    &tls.Config{ServerName: domain, InsecureSkipVerify: true}, // only the expiry date is read
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return Cert{}, fmt.Errorf("connect to %s: %w", addr, err)
+	}
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return Cert{}, errors.Join(errors.New("not a TLS connection"), conn.Close())
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if err = conn.Close(); err != nil {
+		return Cert{}, err
+	}
+	if len(certs) == 0 {
+		return Cert{}, errors.New("no certificate presented")
+	}
+	return Cert{Domain: domain, NotAfter: certs[0].NotAfter}, nil
+}
+
+// family is one metric with its samples.
+type family struct {
+	name, typ, help string
+	samples         []sample
+}
+
+type sample struct {
+	labels []string // name, value pairs
+	value  float64
+}
+
+func newFamily(name, typ, help string) *family {
+	return &family{name: name, typ: typ, help: help}
+}
+
+func (f *family) add(value float64, labels ...string) {
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// write renders the family; families without samples are left out.
+func (f *family) write(w *bytes.Buffer) {
+	if len(f.samples) == 0 {
+		return
+	}
+	sort.SliceStable(f.samples, func(i, j int) bool {
+		return strings.Join(f.samples[i].labels, "\x00") < strings.Join(f.samples[j].labels, "\x00")
+	})
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+	for _, s := range f.samples {
+		w.WriteString(f.name)
+		if len(s.labels) > 0 {
+			w.WriteByte('{')
+			for i := 0; i+1 < len(s.labels); i += 2 {
+				if i > 0 {
+					w.WriteByte(',')
+				}
+				fmt.Fprintf(w, "%s=\"%s\"", s.labels[i], escapeLabel(s.labels[i+1]))
+			}
+			w.WriteByte('}')
+		}
+		w.WriteByte(' ')
+		w.WriteString(strconv.FormatFloat(s.value, 'g', -1, 64))
+		w.WriteByte('\n')
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
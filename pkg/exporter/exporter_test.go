@@ -0,0 +1,144 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+)
+
+func testCollector() *Collector {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	built := now.Add(-48 * time.Hour)
+	return &Collector{
+		Statuses: func(context.Context) ([]monitor.ServiceStatus, error) {
+			return []monitor.ServiceStatus{
+				{Name: "kkengine", ContainerName: "kkengine_app", Image: "kkauto/kkengine:latest", Running: true, Health: "healthy", RestartCount: 2, ImageCreated: &built},
+				{Name: "db", ContainerName: "kkengine_db", Running: true, Health: "healthy"},
+				{Name: "redis", Status: "not created"},
+			}, nil
+		},
+		Health: func(_ context.Context, name string) monitor.HealthStatus {
+			return monitor.HealthStatus{Container: name, Healthy: name != "kkengine_db", Status: "unhealthy"}
+		},
+		LastUpdate: func() (time.Time, bool, error) {
+			return time.Unix(1767225600, 0), true, nil
+		},
+		Cert: func(context.Context) (Cert, error) {
+			return Cert{Domain: "example.com", NotAfter: now.Add(36 * time.Hour)}, nil
+		},
+		Now: func() time.Time { return now },
+	}
+}
+
+func TestCollect(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, testCollector().Collect(context.Background(), &buf))
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE kk_up gauge\nkk_up 1\n",
+		`kk_service_up{service="db"} 1`,
+		`kk_service_up{service="redis"} 0`,
+		`kk_service_healthy{service="db"} 0`,
+		`kk_service_healthy{service="kkengine"} 1`,
+		`kk_service_healthy{service="redis"} 0`,
+		`kk_service_restart_count{service="kkengine"} 2`,
+		`kk_service_image_age_seconds{service="kkengine",image="kkauto/kkengine:latest"} 172800`,
+		"kk_last_successful_update_timestamp_seconds 1.7672256e+09\n",
+		`kk_tls_certificate_expiry_days{domain="example.com"} 1.5`,
+		`kk_collector_success{collector="tls"} 1`,
+	} {
+		assert.Contains(t, out, want)
+	}
+	assert.NotContains(t, out, `kk_service_restart_count{service="redis"}`)
+}
+
+func TestCollectFailures(t *testing.T) {
+	c := testCollector()
+	c.Statuses = func(context.Context) ([]monitor.ServiceStatus, error) { return nil, errors.New("docker not running") }
+	c.LastUpdate = func() (time.Time, bool, error) { return time.Time{}, false, nil }
+	c.Cert = func(context.Context) (Cert, error) { return Cert{}, errors.New("connection refused") }
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Collect(context.Background(), &buf))
+	out := buf.String()
+
+	assert.Contains(t, out, "kk_up 0\n")
+	assert.Contains(t, out, `kk_collector_success{collector="status"} 0`)
+	assert.Contains(t, out, `kk_collector_success{collector="tls"} 0`)
+	assert.Contains(t, out, `kk_collector_success{collector="update"} 1`)
+	assert.NotContains(t, out, "kk_service_up")
+	assert.NotContains(t, out, "kk_last_successful_update_timestamp_seconds")
+	assert.NotContains(t, out, "kk_tls_certificate_expiry_days")
+}
+
+func TestCollectWithoutTLS(t *testing.T) {
+	c := testCollector()
+	c.Cert = func(context.Context) (Cert, error) { return Cert{}, ErrNoTLS }
+
+	var buf bytes.Buffer
+	require.NoError(t, c.Collect(context.Background(), &buf))
+	assert.NotContains(t, buf.String(), `collector="tls"`)
+	assert.NotContains(t, buf.String(), "kk_tls_certificate_expiry_days")
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewServer(testCollector().Handler(time.Second))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, ContentType, resp.Header.Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(string(body), "# HELP kk_up "))
+
+	resp, err = http.Get(srv.URL + "/other")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+type failingResponseWriter struct {
+	httptest.ResponseRecorder
+}
+
+func (*failingResponseWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestHandlerReportsWriteError(t *testing.T) {
+	c := testCollector()
+	var got error
+	c.OnWriteError = func(err error) { got = err }
+
+	w := &failingResponseWriter{ResponseRecorder: *httptest.NewRecorder()}
+	c.Handler(time.Second).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.EqualError(t, got, "connection reset")
+}
+
+func TestFetchCert(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	cert, err := FetchCert(context.Background(), srv.Listener.Addr().String(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", cert.Domain)
+	assert.Equal(t, srv.Certificate().NotAfter, cert.NotAfter)
+}
+
+func TestEscapeLabel(t *testing.T) {
+	assert.Equal(t, `a\"b\\c\nd`, escapeLabel("a\"b\\c\nd"))
+}
@@ -0,0 +1,249 @@
+// Package guard restarts compose services whose healthcheck keeps failing,
+// which Docker's restart policies do not do on their own.
+package guard
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+	"github.com/kkauto-net/kk-install/pkg/redact"
+)
+
+// Audit actions.
+const (
+	ActionStarted   = "guard_started"
+	ActionStopped   = "guard_stopped"
+	ActionUnhealthy = "unhealthy"
+	ActionRestart   = "restart"
+	ActionFailed    = "restart_failed"
+	ActionExhausted = "budget_exhausted"
+	ActionRecovered = "recovered"
+)
+
+// Policy controls when and how often services are restarted.
+type Policy struct {
+	InitialDelay time.Duration // Wait after the first restart before the next one
+	MaxDelay     time.Duration // Upper bound of the doubling wait
+	Budget       int           // Restarts allowed per service within Window
+	Window       time.Duration
+}
+
+// DefaultPolicy backs off like the health monitor and allows five restarts an hour.
+func DefaultPolicy() Policy {
+	return Policy{
+		InitialDelay: monitor.InitialDelay,
+		MaxDelay:     monitor.MaxDelay,
+		Budget:       5,
+		Window:       time.Hour,
+	}
+}
+
+// Service is a compose service under guard.
+type Service struct {
+	Name      string
+	Container string
+}
+
+// Entry is one line of the audit log.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Action    string    `json:"action"`
+	Service   string    `json:"service,omitempty"`
+	Container string    `json:"container,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	Attempt   int       `json:"attempt,omitempty"` // Restarts within the budget window, this one included
+	Message   string    `json:"message,omitempty"`
+}
+
+// Guard checks services and restarts the unhealthy ones.
+type Guard struct {
+	Policy   Policy
+	Services []Service
+	// Check returns the current health of a container.
+	Check func(ctx context.Context, container string) monitor.HealthStatus
+	// Restart restarts one compose service.
+	Restart func(ctx context.Context, service string) error
+	// Record receives every action; it writes the audit log.
+	Record func(Entry)
+
+	mu     sync.Mutex
+	states map[string]*serviceState
+}
+
+type serviceState struct {
+	unhealthy bool
+	restarts  []time.Time // Within the budget window
+	delay     time.Duration
+	next      time.Time // No restart before this
+	exhausted bool
+}
+
+func (g *Guard) state(service string) *serviceState {
+	if g.states == nil {
+		g.states = make(map[string]*serviceState)
+	}
+	st, ok := g.states[service]
+	if !ok {
+		st = &serviceState{delay: g.Policy.InitialDelay}
+		g.states[service] = st
+	}
+	return st
+}
+
+// Tick checks every service once and restarts those that are unhealthy and
+// due. Only an "unhealthy" healthcheck triggers a restart: stopped containers
+// were stopped on purpose or are Docker's restart policy's job.
+func (g *Guard) Tick(ctx context.Context, now time.Time) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, svc := range g.Services {
+		health := g.Check(ctx, svc.Container)
+		st := g.state(svc.Name)
+
+		if health.Status != "unhealthy" {
+			if st.unhealthy && health.Healthy {
+				g.record(Entry{Time: now, Action: ActionRecovered, Service: svc.Name, Container: svc.Container, Status: health.Status})
+				st.unhealthy = false
+				st.exhausted = false
+				st.delay = g.Policy.InitialDelay
+				st.next = time.Time{}
+			}
+			continue
+		}
+
+		if !st.unhealthy {
+			st.unhealthy = true
+			g.record(Entry{Time: now, Action: ActionUnhealthy, Service: svc.Name, Container: svc.Container, Status: health.Status, Message: health.Message})
+		}
+		if st.exhausted || now.Before(st.next) {
+			continue
+		}
+
+		st.restarts = pruneBefore(st.restarts, now.Add(-g.Policy.Window))
+		if len(st.restarts) >= g.Policy.Budget {
+			st.exhausted = true
+			g.record(Entry{
+				Time: now, Action: ActionExhausted, Service: svc.Name, Container: svc.Container, Status: health.Status,
+				Message: fmt.Sprintf("%d restarts within %s; not restarting again until the service recovers", len(st.restarts), g.Policy.Window),
+			})
+			continue
+		}
+
+		st.restarts = append(st.restarts, now)
+		entry := Entry{Time: now, Action: ActionRestart, Service: svc.Name, Container: svc.Container, Status: health.Status, Attempt: len(st.restarts)}
+		if err := g.Restart(ctx, svc.Name); err != nil {
+			entry.Action = ActionFailed
+			entry.Message = redact.Error(err)
+		}
+		g.record(entry)
+
+		st.next = now.Add(st.delay)
+		st.delay = min(st.delay*2, g.Policy.MaxDelay)
+	}
+}
+
+// Run ticks every interval until ctx is done.
+func (g *Guard) Run(ctx context.Context, interval time.Duration) {
+	g.record(Entry{Time: time.Now(), Action: ActionStarted, Message: fmt.Sprintf("%d services, budget %d per %s", len(g.Services), g.Policy.Budget, g.Policy.Window)})
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		g.Tick(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			g.record(Entry{Time: time.Now(), Action: ActionStopped})
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (g *Guard) record(e Entry) {
+	if g.Record != nil {
+		g.Record(e)
+	}
+}
+
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	kept := times[:0]
+	for _, t := range times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+// AuditLog appends entries as JSON lines to a file.
+type AuditLog struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewAuditLog writes to path, creating its directory when needed.
+func NewAuditLog(path string) (*AuditLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return &AuditLog{path: path}, nil
+}
+
+// Path returns the log file path.
+func (l *AuditLog) Path() string {
+	return l.path
+}
+
+// Append writes e as one line.
+func (l *AuditLog) Append(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		return errors.Join(err, f.Close())
+	}
+	return f.Close()
+}
+
+// ReadAudit returns the last limit entries of the log at path, oldest first
+// (limit <= 0 means all). Malformed lines are skipped.
+func ReadAudit(path string, limit int) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []Entry
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var e Entry
+		if json.Unmarshal(line, &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	return entries, nil
+}
@@ -0,0 +1,121 @@
+package guard
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/kkauto-net/kk-install/pkg/monitor"
+)
+
+type fakeStack struct {
+	health     map[string]string
+	restarted  []string
+	restartErr error
+	entries    []Entry
+}
+
+func (f *fakeStack) guard(policy Policy) *Guard {
+	return &Guard{
+		Policy:   policy,
+		Services: []Service{{Name: "kkengine", Container: "kkengine_app"}, {Name: "db", Container: "kkengine_db"}},
+		Check: func(_ context.Context, container string) monitor.HealthStatus {
+			status := f.health[container]
+			return monitor.HealthStatus{Container: container, Status: status, Healthy: status == "healthy"}
+		},
+		Restart: func(_ context.Context, service string) error {
+			f.restarted = append(f.restarted, service)
+			return f.restartErr
+		},
+		Record: func(e Entry) { f.entries = append(f.entries, e) },
+	}
+}
+
+func (f *fakeStack) actions() []string {
+	var actions []string
+	for _, e := range f.entries {
+		actions = append(actions, e.Action+" "+e.Service)
+	}
+	return actions
+}
+
+func TestTickBacksOff(t *testing.T) {
+	stack := &fakeStack{health: map[string]string{"kkengine_app": "unhealthy", "kkengine_db": "healthy"}}
+	g := stack.guard(Policy{InitialDelay: 10 * time.Second, MaxDelay: 30 * time.Second, Budget: 10, Window: time.Hour})
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// Restarts at 0s, then after 10s, 20s and 30s (capped) of backoff.
+	for _, sec := range []int{0, 5, 10, 15, 30, 45, 60, 89, 90} {
+		g.Tick(context.Background(), start.Add(time.Duration(sec)*time.Second))
+	}
+
+	var restartTimes []time.Duration
+	for _, e := range stack.entries {
+		if e.Action == ActionRestart {
+			restartTimes = append(restartTimes, e.Time.Sub(start))
+		}
+	}
+	assert.Equal(t, []time.Duration{0, 10 * time.Second, 30 * time.Second, 60 * time.Second, 90 * time.Second}, restartTimes)
+	assert.Equal(t, []string{"kkengine", "kkengine", "kkengine", "kkengine", "kkengine"}, stack.restarted, "healthy db is never restarted")
+	assert.Equal(t, ActionUnhealthy, stack.entries[0].Action)
+	assert.Equal(t, 5, stack.entries[len(stack.entries)-1].Attempt)
+}
+
+func TestTickBudgetAndRecovery(t *testing.T) {
+	stack := &fakeStack{health: map[string]string{"kkengine_app": "healthy", "kkengine_db": "unhealthy"}, restartErr: errors.New("exit status 1: DB_PASSWORD=hunter2")}
+	g := stack.guard(Policy{InitialDelay: time.Second, MaxDelay: time.Second, Budget: 2, Window: time.Hour})
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 5; i++ {
+		g.Tick(context.Background(), now.Add(time.Duration(i)*time.Minute))
+	}
+	assert.Equal(t, []string{"unhealthy db", "restart_failed db", "restart_failed db", "budget_exhausted db"}, stack.actions())
+	assert.Equal(t, "exit status 1: DB_PASSWORD=[REDACTED]", stack.entries[1].Message)
+
+	// Recovery resets the budget lock and the backoff.
+	stack.health["kkengine_db"] = "healthy"
+	g.Tick(context.Background(), now.Add(10*time.Minute))
+	stack.health["kkengine_db"] = "unhealthy"
+	g.Tick(context.Background(), now.Add(2*time.Hour))
+	assert.Equal(t, []string{"recovered db", "unhealthy db", "restart_failed db"}, stack.actions()[4:])
+}
+
+func TestTickIgnoresStoppedServices(t *testing.T) {
+	stack := &fakeStack{health: map[string]string{"kkengine_app": "stopped", "kkengine_db": "starting"}}
+	g := stack.guard(DefaultPolicy())
+	g.Tick(context.Background(), time.Now())
+	assert.Empty(t, stack.entries)
+	assert.Empty(t, stack.restarted)
+}
+
+func TestAuditLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "guard-audit.log")
+	log, err := NewAuditLog(path)
+	require.NoError(t, err)
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, action := range []string{ActionStarted, ActionRestart, ActionRecovered} {
+		require.NoError(t, log.Append(Entry{Time: start.Add(time.Duration(i) * time.Second), Action: action, Service: "db"}))
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString("not json\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	entries, err := ReadAudit(path, 2)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, ActionRestart, entries[0].Action)
+	assert.Equal(t, ActionRecovered, entries[1].Action)
+
+	entries, err = ReadAudit(filepath.Join(t.TempDir(), "missing.log"), 0)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
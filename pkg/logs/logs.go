@@ -0,0 +1,184 @@
+// Package logs post-processes docker compose log output: it masks secrets
+// from the project's .env, filters lines and optionally re-encodes every line
+// as a JSON record.
+package logs
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/config"
+)
+
+// Redacted replaces every secret value in the output.
+const Redacted = "[REDACTED]"
+
+// SecretKeys are the .env keys whose values never leave kk logs unmasked.
+var SecretKeys = []string{
+	"DB_PASSWORD",
+	"DB_ROOT_PASSWORD",
+	"REDIS_PASSWORD",
+	"JWT_SECRET",
+	"S3_ACCESS_KEY",
+	"S3_SECRET_KEY",
+	"LICENSE_KEY",
+}
+
+// minSecretLen skips values so short they would mask ordinary log text.
+const minSecretLen = 6
+
+// EnvSecrets returns the values of SecretKeys from the .env in projectDir.
+func EnvSecrets(projectDir string) []string {
+	var secrets []string
+	for _, key := range SecretKeys {
+		if v := config.ReadEnvValue(projectDir, key); v != "" {
+			secrets = append(secrets, v)
+		}
+	}
+	return secrets
+}
+
+// Redactor masks a fixed set of secret values.
+type Redactor struct {
+	replacer *strings.Replacer
+}
+
+// NewRedactor returns a Redactor for secrets. Longer values are matched
+// first so a secret containing another one is masked as a whole.
+func NewRedactor(secrets []string) *Redactor {
+	values := make([]string, 0, len(secrets))
+	for _, s := range secrets {
+		if len(s) >= minSecretLen {
+			values = append(values, s)
+		}
+	}
+	if len(values) == 0 {
+		return &Redactor{}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, Redacted)
+	}
+	return &Redactor{replacer: strings.NewReplacer(pairs...)}
+}
+
+// Redact returns s with every secret replaced by Redacted.
+func (r *Redactor) Redact(s string) string {
+	if r == nil || r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// Record is one log line in --json mode.
+type Record struct {
+	Time      string `json:"time,omitempty"`
+	Service   string `json:"service"`
+	Container string `json:"container"`
+	Message   string `json:"message"`
+}
+
+// ParseLine splits a docker compose log line ("container  | message") into a
+// Record. containers maps container names to compose services; unknown
+// containers keep their name as the service. When timestamps is set, a
+// leading RFC 3339 timestamp is moved from the message into Time.
+func ParseLine(line string, containers map[string]string, timestamps bool) Record {
+	name, msg, ok := strings.Cut(line, "|")
+	if !ok {
+		return Record{Message: line}
+	}
+	rec := Record{Container: strings.TrimSpace(name)}
+	rec.Service = rec.Container
+	if service, ok := containers[rec.Container]; ok {
+		rec.Service = service
+	}
+	msg = strings.TrimPrefix(msg, " ")
+
+	if timestamps {
+		if ts, rest, ok := strings.Cut(msg, " "); ok {
+			if _, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				rec.Time, msg = ts, rest
+			}
+		}
+	}
+	rec.Message = msg
+	return rec
+}
+
+// Options configures a Writer.
+type Options struct {
+	Redactor *Redactor
+	// Grep keeps only lines (messages in JSON mode) that match.
+	Grep *regexp.Regexp
+	// JSON writes one Record per line instead of the raw text.
+	JSON bool
+	// Containers maps container names to compose services for JSON records.
+	Containers map[string]string
+	// Timestamps tells the parser that lines carry docker timestamps.
+	Timestamps bool
+}
+
+// Writer is an io.Writer that processes docker compose log output line by
+// line before passing it on. Call Flush once the producer is done.
+type Writer struct {
+	out  io.Writer
+	opts Options
+	enc  *json.Encoder
+	buf  []byte
+}
+
+// NewWriter returns a Writer that writes processed lines to out.
+func NewWriter(out io.Writer, opts Options) *Writer {
+	return &Writer{out: out, opts: opts, enc: json.NewEncoder(out)}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(w.buf[:i])
+		w.buf = w.buf[i+1:]
+		if err := w.writeLine(line); err != nil {
+			return len(p), err
+		}
+	}
+}
+
+// Flush processes a trailing line that did not end in a newline.
+func (w *Writer) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	line := string(w.buf)
+	w.buf = nil
+	return w.writeLine(line)
+}
+
+func (w *Writer) writeLine(line string) error {
+	line = strings.TrimSuffix(line, "\r")
+	if !w.opts.JSON {
+		line = w.opts.Redactor.Redact(line)
+		if w.opts.Grep != nil && !w.opts.Grep.MatchString(line) {
+			return nil
+		}
+		_, err := io.WriteString(w.out, line+"\n")
+		return err
+	}
+
+	rec := ParseLine(line, w.opts.Containers, w.opts.Timestamps)
+	rec.Message = w.opts.Redactor.Redact(rec.Message)
+	if w.opts.Grep != nil && !w.opts.Grep.MatchString(rec.Message) {
+		return nil
+	}
+	return w.enc.Encode(rec)
+}
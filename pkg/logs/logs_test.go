@@ -0,0 +1,73 @@
+package logs
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvSecrets(t *testing.T) {
+	dir := t.TempDir()
+	env := "DB_PASSWORD=dbsecret123\nREDIS_PASSWORD=\nSYSTEM_DOMAIN=example.com\nJWT_SECRET=jwtsecret456\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".env"), []byte(env), 0600))
+
+	assert.Equal(t, []string{"dbsecret123", "jwtsecret456"}, EnvSecrets(dir))
+}
+
+func TestRedactor(t *testing.T) {
+	r := NewRedactor([]string{"hunter2-long", "hunter2-longer-secret", "abc"})
+
+	assert.Equal(t, "pw=[REDACTED] other=[REDACTED]", r.Redact("pw=hunter2-longer-secret other=hunter2-long"))
+	assert.Equal(t, "abc stays", r.Redact("abc stays"), "short values are not masked")
+	assert.Equal(t, "plain", (*Redactor)(nil).Redact("plain"))
+}
+
+func TestParseLine(t *testing.T) {
+	containers := map[string]string{"kkengine_app": "kkengine"}
+
+	rec := ParseLine("kkengine_app  | 2026-01-02T03:04:05.123456789Z listening on :8019", containers, true)
+	assert.Equal(t, Record{Time: "2026-01-02T03:04:05.123456789Z", Service: "kkengine", Container: "kkengine_app", Message: "listening on :8019"}, rec)
+
+	rec = ParseLine("other-db-1  | ready | accepting", containers, false)
+	assert.Equal(t, Record{Service: "other-db-1", Container: "other-db-1", Message: "ready | accepting"}, rec)
+
+	assert.Equal(t, Record{Message: "no prefix"}, ParseLine("no prefix", containers, false))
+}
+
+func TestWriter(t *testing.T) {
+	input := "kkengine_db  | connect with dbsecret123\nkkengine_app  | GET /health 200\nkkengine_app  | error: timeout"
+
+	t.Run("plain", func(t *testing.T) {
+		var out bytes.Buffer
+		w := NewWriter(&out, Options{Redactor: NewRedactor([]string{"dbsecret123"})})
+		_, err := w.Write([]byte(input[:20]))
+		require.NoError(t, err)
+		_, err = w.Write([]byte(input[20:]))
+		require.NoError(t, err)
+		require.NoError(t, w.Flush())
+
+		assert.Equal(t, "kkengine_db  | connect with [REDACTED]\nkkengine_app  | GET /health 200\nkkengine_app  | error: timeout\n", out.String())
+	})
+
+	t.Run("json with grep", func(t *testing.T) {
+		var out bytes.Buffer
+		w := NewWriter(&out, Options{
+			Redactor:   NewRedactor([]string{"dbsecret123"}),
+			Grep:       regexp.MustCompile(`connect|error`),
+			JSON:       true,
+			Containers: map[string]string{"kkengine_db": "db", "kkengine_app": "kkengine"},
+		})
+		_, err := w.Write([]byte(input))
+		require.NoError(t, err)
+		require.NoError(t, w.Flush())
+
+		want := `{"service":"db","container":"kkengine_db","message":"connect with [REDACTED]"}` + "\n" +
+			`{"service":"kkengine","container":"kkengine_app","message":"error: timeout"}` + "\n"
+		assert.Equal(t, want, out.String())
+	})
+}
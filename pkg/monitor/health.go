@@ -4,17 +4,24 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/engine"
+	"github.com/kkauto-net/kk-install/pkg/events"
 )
 
 const (
-	MaxRetries    = 3
 	InitialDelay  = 2 * time.Second
 	MaxDelay      = 30 * time.Second
 	CheckInterval = 3 * time.Second
+
+	// Docker's healthcheck defaults, used when a service does not set them.
+	DefaultHealthInterval = 30 * time.Second
+	DefaultHealthRetries  = 3
 )
 
 type HealthStatus struct {
@@ -34,14 +41,23 @@ type DockerClient interface {
 // HealthMonitor checks container health status
 type HealthMonitor struct {
 	client DockerClient
+	// refresh runs a container's healthcheck before it is inspected, for
+	// runtimes that may not run healthchecks on their own (Podman).
+	refresh func(ctx context.Context, containerName string) error
 }
 
+// NewHealthMonitor returns a HealthMonitor using the shared Engine API client.
 func NewHealthMonitor() (*HealthMonitor, error) {
-	cli, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	cli, err := engine.Shared()
 	if err != nil {
 		return nil, fmt.Errorf("tao Docker client that bai: %w", err)
 	}
-	return &HealthMonitor{client: cli}, nil
+	return &HealthMonitor{client: cli, refresh: engine.RunHealthcheck}, nil
+}
+
+// NewHealthMonitorWithClient returns a HealthMonitor using an existing client.
+func NewHealthMonitorWithClient(cli DockerClient) *HealthMonitor {
+	return &HealthMonitor{client: cli}
 }
 
 func (m *HealthMonitor) Close() {
@@ -50,46 +66,76 @@ func (m *HealthMonitor) Close() {
 	}
 }
 
-// WaitForHealthy waits for container to become healthy with retry
+// WaitForHealthy waits for container to become healthy, assuming Docker's
+// default healthcheck timing.
 func (m *HealthMonitor) WaitForHealthy(ctx context.Context, containerName string, hasHealthCheck bool) HealthStatus {
+	return m.waitFor(ctx, ContainerInfo{ContainerName: containerName, HasHealthCheck: hasHealthCheck})
+}
+
+// waitFor polls c with exponential backoff until it is healthy, Docker marks
+// it unhealthy, or its HealthTimeout passes. Inspect errors are retried until
+// then too, and the last one is returned when time runs out.
+func (m *HealthMonitor) waitFor(ctx context.Context, c ContainerInfo) HealthStatus {
 	status := HealthStatus{
-		Container: containerName,
+		ServiceName: c.ServiceName,
+		Container:   c.ContainerName,
 	}
 
-	// Extract service name from container name (e.g., kkengine_db -> db)
-	parts := strings.Split(containerName, "_")
-	if len(parts) > 1 {
-		status.ServiceName = parts[len(parts)-1]
-	} else {
-		status.ServiceName = containerName
+	// Without a service, extract its name from the container name (e.g.,
+	// kkengine_db -> db)
+	if status.ServiceName == "" {
+		parts := strings.Split(c.ContainerName, "_")
+		if len(parts) > 1 {
+			status.ServiceName = parts[len(parts)-1]
+		} else {
+			status.ServiceName = c.ContainerName
+		}
 	}
 
 	// If no health check defined, just check if running
-	if !hasHealthCheck {
-		return m.checkRunning(ctx, containerName, status)
+	if !c.HasHealthCheck {
+		return m.checkRunning(ctx, c.ContainerName, status)
 	}
 
-	// Wait for health check with retries
+	timeout := c.HealthTimeout()
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
 	delay := InitialDelay
-	for retry := 0; retry < MaxRetries; retry++ {
-		result := m.checkHealth(ctx, containerName)
-		if result.Healthy {
+	for {
+		result := m.checkHealth(ctx, c.ContainerName)
+		result.ServiceName = status.ServiceName
+		// Only "starting" and "error" (a failed inspect, e.g. while the
+		// container is recreated) can still change: "unhealthy" is Docker's
+		// verdict after the service's own retries.
+		if result.Status != "starting" && result.Status != "error" {
 			return result
 		}
 
-		// Wait before retry
 		select {
 		case <-ctx.Done():
+			if result.Status == "error" {
+				return result
+			}
 			status.Status = "timeout"
 			status.Message = "Da het thoi gian cho"
 			return status
+		case <-deadline.C:
+			if result.Status == "error" {
+				return result
+			}
+			status.Status = "timeout"
+			status.Message = fmt.Sprintf("Chua healthy sau %s", timeout)
+			return status
 		case <-time.After(delay):
 			// Exponential backoff
 			delay = min(delay*2, MaxDelay)
 		}
 	}
+}
 
-	// Final check after all retries
+// Check returns the current health of a container without waiting or retrying.
+func (m *HealthMonitor) Check(ctx context.Context, containerName string) HealthStatus {
 	return m.checkHealth(ctx, containerName)
 }
 
@@ -104,6 +150,13 @@ func (m *HealthMonitor) checkHealth(ctx context.Context, containerName string) H
 		status.ServiceName = containerName
 	}
 
+	if m.refresh != nil {
+		if err := m.refresh(ctx, containerName); err != nil {
+			status.Status = "error"
+			status.Message = fmt.Sprintf("Khong chay duoc healthcheck: %v", err)
+			return status
+		}
+	}
 	info, err := m.client.ContainerInspect(ctx, containerName)
 	if err != nil {
 		status.Status = "error"
@@ -161,33 +214,178 @@ func (m *HealthMonitor) checkRunning(ctx context.Context, containerName string,
 	return status
 }
 
-// MonitorAll waits for all containers to be healthy
+// MonitorAll waits for all containers to be healthy. Each container is
+// checked once the containers it depends on have finished, so independent
+// services are checked concurrently. Results are returned in dependency
+// order; onProgress calls are serialized.
 func (m *HealthMonitor) MonitorAll(ctx context.Context, containers []ContainerInfo, onProgress func(HealthStatus)) []HealthStatus {
-	var results []HealthStatus
+	order := dependencyOrder(containers)
+	position := make([]int, len(containers))
+	for pos, i := range order {
+		position[i] = pos
+	}
+	index := make(map[string]int, len(containers))
+	for i, c := range containers {
+		index[c.ServiceName] = i
+	}
 
-	for _, c := range containers {
-		// Report starting
-		onProgress(HealthStatus{
-			ServiceName: c.ServiceName,
-			Container:   c.ContainerName,
-			Status:      "starting",
-			Message:     "Dang kiem tra...",
-		})
+	var mu sync.Mutex
+	report := func(s HealthStatus) {
+		mu.Lock()
+		defer mu.Unlock()
+		emitHealth(s)
+		onProgress(s)
+	}
+
+	done := make([]chan struct{}, len(containers))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	results := make([]HealthStatus, len(containers))
 
-		status := m.WaitForHealthy(ctx, c.ContainerName, c.HasHealthCheck)
-		results = append(results, status)
+	var wg sync.WaitGroup
+	for i, c := range containers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(done[i])
 
-		// Report result
-		onProgress(status)
+			// Waiting only on dependencies placed earlier keeps a cycle
+			// from deadlocking.
+			for _, dep := range c.DependsOn {
+				if j, ok := index[dep]; ok && position[j] < position[i] {
+					select {
+					case <-done[j]:
+					case <-ctx.Done():
+					}
+				}
+			}
+
+			report(HealthStatus{
+				ServiceName: c.ServiceName,
+				Container:   c.ContainerName,
+				Status:      "starting",
+				Message:     "Dang kiem tra...",
+			})
+			results[i] = m.waitFor(ctx, c)
+			report(results[i])
+		}()
+	}
+	wg.Wait()
+
+	ordered := make([]HealthStatus, len(order))
+	for pos, i := range order {
+		ordered[pos] = results[i]
+	}
+	return ordered
+}
+
+// dependencyOrder returns the indexes of containers sorted so that every
+// container comes after its dependencies, keeping the input order otherwise.
+// Dependencies outside containers are ignored; a cycle is broken at its
+// first container.
+func dependencyOrder(containers []ContainerInfo) []int {
+	index := make(map[string]int, len(containers))
+	for i, c := range containers {
+		index[c.ServiceName] = i
+	}
+
+	placed := make([]bool, len(containers))
+	ready := func(i int) bool {
+		for _, dep := range containers[i].DependsOn {
+			if j, ok := index[dep]; ok && j != i && !placed[j] {
+				return false
+			}
+		}
+		return true
+	}
+
+	order := make([]int, 0, len(containers))
+	for len(order) < len(containers) {
+		next := -1
+		for i := range containers {
+			if !placed[i] && ready(i) {
+				next = i
+				break
+			}
+		}
+		if next < 0 {
+			for i := range containers {
+				if !placed[i] {
+					next = i
+					break
+				}
+			}
+		}
+		placed[next] = true
+		order = append(order, next)
 	}
+	return order
+}
 
-	return results
+// emitHealth reports a health transition on the event stream, if enabled.
+func emitHealth(s HealthStatus) {
+	events.HealthChanged(events.Health{
+		Service:   s.ServiceName,
+		Container: s.Container,
+		Status:    s.Status,
+		Healthy:   s.Healthy,
+		Message:   s.Message,
+	})
 }
 
 type ContainerInfo struct {
 	ServiceName    string
 	ContainerName  string
 	HasHealthCheck bool
+	DependsOn      []string // Service names
+
+	// Healthcheck timing from the compose file; zero values mean Docker's defaults.
+	StartPeriod time.Duration
+	Interval    time.Duration
+	Retries     int
+}
+
+// HealthTimeout is how long to wait for c to turn healthy: its start period
+// plus one interval per retry, by when Docker itself would have marked it
+// unhealthy, and one more interval of slack.
+func (c ContainerInfo) HealthTimeout() time.Duration {
+	interval := c.Interval
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
+	retries := c.Retries
+	if retries <= 0 {
+		retries = DefaultHealthRetries
+	}
+	return c.StartPeriod + time.Duration(retries+1)*interval
+}
+
+// ComposeContainers describes every service of composeFile for MonitorAll,
+// in service-name order.
+func ComposeContainers(composeFile *compose.ComposeFile) []ContainerInfo {
+	var containers []ContainerInfo
+	for _, name := range composeFile.GetServiceNames() {
+		svc := composeFile.Services[name]
+		c := ContainerInfo{
+			ServiceName:    name,
+			ContainerName:  composeFile.GetServiceContainerName(name),
+			HasHealthCheck: svc.HealthCheck != nil,
+			DependsOn:      svc.Dependencies(),
+		}
+		if hc := svc.HealthCheck; hc != nil {
+			// Unset or invalid durations keep Docker's defaults.
+			if d, err := time.ParseDuration(hc.StartPeriod); err == nil {
+				c.StartPeriod = d
+			}
+			if d, err := time.ParseDuration(hc.Interval); err == nil {
+				c.Interval = d
+			}
+			c.Retries = hc.Retries
+		}
+		containers = append(containers, c)
+	}
+	return containers
 }
 
 func min(a, b time.Duration) time.Duration {
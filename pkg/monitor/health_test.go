@@ -9,6 +9,8 @@ import (
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
 )
 
 // MockDockerClient implements DockerClient interface
@@ -174,10 +176,30 @@ func TestHealthMonitor_WaitForHealthy_WithHealthCheck(t *testing.T) {
 			}
 			return container.InspectResponse{}, errors.New("temporary inspect error")
 		}
-		status := monitor.WaitForHealthy(ctx, "kkengine_inspect_error", true)
+		// A 3s deadline leaves time for one retry after InitialDelay.
+		status := monitor.waitFor(ctx, ContainerInfo{ContainerName: "kkengine_inspect_error", HasHealthCheck: true, Interval: 1500 * time.Millisecond, Retries: 1})
 		assert.False(t, status.Healthy)
 		assert.Equal(t, "error", status.Status)
 		assert.Contains(t, status.Message, "temporary inspect error")
+		assert.GreaterOrEqual(t, callCount, 2)
+	})
+
+	t.Run("inspect error then healthy", func(t *testing.T) {
+		callCount := 0
+		mockClient.mockContainerInspect = func(ctx context.Context, containerID string) (container.InspectResponse, error) {
+			callCount++
+			if callCount == 1 {
+				return container.InspectResponse{}, errors.New("No such container: kkengine_app")
+			}
+			return container.InspectResponse{
+				ContainerJSONBase: &container.ContainerJSONBase{
+					State: &container.State{Health: &container.Health{Status: "healthy"}},
+				},
+			}, nil
+		}
+		status := monitor.WaitForHealthy(ctx, "kkengine_app", true)
+		assert.True(t, status.Healthy)
+		assert.Equal(t, 2, callCount)
 	})
 }
 
@@ -243,6 +265,8 @@ func TestHealthMonitor_MonitorAll(t *testing.T) {
 	assert.Equal(t, "running", results[1].Status)
 	assert.False(t, results[2].Healthy)
 	assert.Equal(t, "unhealthy", results[2].Status)
+	// The compose service name wins over the container name suffix.
+	assert.Equal(t, "unhealthy_svc", results[2].ServiceName)
 
 	// Check progress reports
 	mu.Lock()
@@ -253,7 +277,7 @@ func TestHealthMonitor_MonitorAll(t *testing.T) {
 	assert.Contains(t, receivedProgress, HealthStatus{ServiceName: "db", Container: "kkengine_db", Status: "starting", Message: "Dang kiem tra..."})
 	assert.Contains(t, receivedProgress, HealthStatus{ServiceName: "db", Container: "kkengine_db", Status: "running", Healthy: true})
 	assert.Contains(t, receivedProgress, HealthStatus{ServiceName: "unhealthy_svc", Container: "kkengine_unhealthy_svc", Status: "starting", Message: "Dang kiem tra..."})
-	assert.Contains(t, receivedProgress, HealthStatus{ServiceName: "svc", Container: "kkengine_unhealthy_svc", Status: "unhealthy", Message: "failed check"})
+	assert.Contains(t, receivedProgress, HealthStatus{ServiceName: "unhealthy_svc", Container: "kkengine_unhealthy_svc", Status: "unhealthy", Message: "failed check"})
 }
 
 func TestMin(t *testing.T) {
@@ -274,3 +298,142 @@ func TestHealthMonitor_Close(t *testing.T) {
 	monitor.Close()
 	assert.True(t, mockCloseCalled)
 }
+
+func TestDependencyOrder(t *testing.T) {
+	containers := []ContainerInfo{
+		{ServiceName: "caddy", DependsOn: []string{"kkengine"}},
+		{ServiceName: "db"},
+		{ServiceName: "kkengine", DependsOn: []string{"db", "seaweedfs", "missing"}},
+		{ServiceName: "redis"},
+		{ServiceName: "seaweedfs", DependsOn: []string{"db"}},
+	}
+	assert.Equal(t, []int{1, 3, 4, 2, 0}, dependencyOrder(containers))
+
+	cycle := []ContainerInfo{
+		{ServiceName: "a", DependsOn: []string{"b"}},
+		{ServiceName: "b", DependsOn: []string{"a"}},
+		{ServiceName: "c", DependsOn: []string{"b"}},
+	}
+	assert.Equal(t, []int{0, 1, 2}, dependencyOrder(cycle))
+}
+
+func TestContainerInfo_HealthTimeout(t *testing.T) {
+	assert.Equal(t, 120*time.Second, ContainerInfo{}.HealthTimeout())
+	seaweedfs := ContainerInfo{StartPeriod: 50 * time.Second, Interval: 10 * time.Second, Retries: 12}
+	assert.Equal(t, 180*time.Second, seaweedfs.HealthTimeout())
+}
+
+func TestComposeContainers(t *testing.T) {
+	cf := &compose.ComposeFile{Services: map[string]compose.Service{
+		"kkengine": {DependsOn: map[string]interface{}{"db": map[string]interface{}{"condition": "service_healthy"}}},
+		"db": {
+			ContainerName: "my_db",
+			HealthCheck:   &compose.HealthCheck{Interval: "10s", Retries: 5, StartPeriod: "30s"},
+		},
+	}}
+	assert.Equal(t, []ContainerInfo{
+		{ServiceName: "db", ContainerName: "my_db", HasHealthCheck: true, StartPeriod: 30 * time.Second, Interval: 10 * time.Second, Retries: 5},
+		{ServiceName: "kkengine", ContainerName: "kkengine_kkengine", DependsOn: []string{"db"}},
+	}, ComposeContainers(cf))
+}
+
+func TestHealthMonitor_WaitForHealthy_OwnTimeout(t *testing.T) {
+	mockClient := &MockDockerClient{mockContainerInspect: func(ctx context.Context, containerID string) (container.InspectResponse, error) {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				State: &container.State{Health: &container.Health{Status: "starting"}},
+			},
+		}, nil
+	}}
+	monitor := &HealthMonitor{client: mockClient}
+
+	status := monitor.waitFor(context.Background(), ContainerInfo{ContainerName: "kkengine_slow", HasHealthCheck: true, Interval: 10 * time.Millisecond, Retries: 1})
+	assert.Equal(t, "timeout", status.Status)
+	assert.Contains(t, status.Message, "20ms")
+}
+
+func TestHealthMonitor_MonitorAll_Dependencies(t *testing.T) {
+	running := func() container.InspectResponse {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				State: &container.State{Running: true, Status: "running"},
+			},
+		}
+	}
+
+	// db and redis block until both are being inspected, which only
+	// happens when independent services are checked concurrently.
+	var mu sync.Mutex
+	var inspected []string
+	bothStarted := make(chan struct{})
+	var waiting sync.WaitGroup
+	waiting.Add(2)
+	go func() {
+		waiting.Wait()
+		close(bothStarted)
+	}()
+	mockClient := &MockDockerClient{mockContainerInspect: func(ctx context.Context, containerID string) (container.InspectResponse, error) {
+		mu.Lock()
+		inspected = append(inspected, containerID)
+		mu.Unlock()
+		if containerID == "kkengine_db" || containerID == "kkengine_redis" {
+			waiting.Done()
+			select {
+			case <-bothStarted:
+			case <-time.After(5 * time.Second):
+				return container.InspectResponse{}, errors.New("checked sequentially")
+			}
+		}
+		return running(), nil
+	}}
+	monitor := &HealthMonitor{client: mockClient}
+
+	containers := []ContainerInfo{
+		{ServiceName: "app", ContainerName: "kkengine_app", DependsOn: []string{"db"}},
+		{ServiceName: "db", ContainerName: "kkengine_db"},
+		{ServiceName: "redis", ContainerName: "kkengine_redis"},
+	}
+	var progress []string
+	results := monitor.MonitorAll(context.Background(), containers, func(s HealthStatus) {
+		progress = append(progress, s.Container+" "+s.Status)
+	})
+
+	assert.Len(t, results, 3)
+	assert.Equal(t, "kkengine_db", results[0].Container)
+	assert.Equal(t, "kkengine_app", results[1].Container)
+	assert.Equal(t, "kkengine_redis", results[2].Container)
+	for _, r := range results {
+		assert.True(t, r.Healthy, r.Container)
+	}
+	assert.Equal(t, "kkengine_app", inspected[2], "app is checked after db")
+	assert.Less(t, indexOf(progress, "kkengine_db running"), indexOf(progress, "kkengine_app starting"))
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestHealthMonitor_CheckRunsHealthcheckFirst(t *testing.T) {
+	mockClient := &MockDockerClient{}
+	health := "starting"
+	monitor := &HealthMonitor{client: mockClient, refresh: func(_ context.Context, _ string) error {
+		health = "healthy"
+		return nil
+	}}
+	mockClient.mockContainerInspect = func(ctx context.Context, containerID string) (container.InspectResponse, error) {
+		return container.InspectResponse{
+			ContainerJSONBase: &container.ContainerJSONBase{
+				State: &container.State{Running: true, Status: "running", Health: &container.Health{Status: health}},
+			},
+		}, nil
+	}
+
+	status := monitor.Check(context.Background(), "kkengine_db")
+	assert.Equal(t, "healthy", status.Status, "the refreshed healthcheck result is inspected")
+	assert.True(t, status.Healthy)
+}
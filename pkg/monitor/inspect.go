@@ -0,0 +1,62 @@
+package monitor
+
+import (
+	"context"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+
+	"github.com/kkauto-net/kk-install/pkg/engine"
+)
+
+// InspectClient is the Docker API subset used to enrich service statuses.
+type InspectClient interface {
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	ImageInspect(ctx context.Context, imageID string, opts ...client.ImageInspectOption) (image.InspectResponse, error)
+}
+
+// NewDockerClient returns the shared Docker Engine API client.
+func NewDockerClient() (*client.Client, error) {
+	return engine.Shared()
+}
+
+// EnrichStatuses fills image digest and creation time, restart count, start
+// time and uptime for services that have a container. Containers that cannot be inspected are left as they are.
+func EnrichStatuses(ctx context.Context, cli InspectClient, statuses []ServiceStatus, now time.Time) {
+	for i := range statuses {
+		s := &statuses[i]
+		if s.ContainerName == "" {
+			continue
+		}
+		info, err := cli.ContainerInspect(ctx, s.ContainerName)
+		if err != nil || info.ContainerJSONBase == nil {
+			continue
+		}
+		if s.Image == "" && info.Config != nil {
+			s.Image = info.Config.Image
+		}
+
+		s.RestartCount = info.RestartCount
+		s.ImageDigest = info.Image
+		if img, err := cli.ImageInspect(ctx, info.Image); err == nil {
+			if digest := engine.RepoDigest(img.RepoDigests); digest != "" {
+				s.ImageDigest = digest
+			}
+			if created, err := time.Parse(time.RFC3339Nano, img.Created); err == nil {
+				s.ImageCreated = &created
+			}
+		}
+
+		if info.State == nil || !info.State.Running {
+			continue
+		}
+		started, err := time.Parse(time.RFC3339Nano, info.State.StartedAt)
+		if err != nil || started.IsZero() {
+			continue
+		}
+		s.StartedAt = &started
+		s.UptimeSeconds = int64(now.Sub(started).Seconds())
+	}
+}
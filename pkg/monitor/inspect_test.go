@@ -0,0 +1,83 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockInspectClient struct {
+	containers map[string]container.InspectResponse
+	images     map[string]image.InspectResponse
+}
+
+func (m *mockInspectClient) ContainerInspect(_ context.Context, containerID string) (container.InspectResponse, error) {
+	info, ok := m.containers[containerID]
+	if !ok {
+		return container.InspectResponse{}, errors.New("no such container")
+	}
+	return info, nil
+}
+
+func (m *mockInspectClient) ImageInspect(_ context.Context, imageID string, _ ...client.ImageInspectOption) (image.InspectResponse, error) {
+	img, ok := m.images[imageID]
+	if !ok {
+		return image.InspectResponse{}, errors.New("no such image")
+	}
+	return img, nil
+}
+
+func TestEnrichStatuses(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cli := &mockInspectClient{
+		containers: map[string]container.InspectResponse{
+			"kkengine_redis": {
+				ContainerJSONBase: &container.ContainerJSONBase{
+					Image:        "sha256:redisid",
+					RestartCount: 4,
+					State:        &container.State{Running: true, StartedAt: "2026-03-01T11:00:00.5Z"},
+				},
+			},
+			"kkengine_db": {
+				ContainerJSONBase: &container.ContainerJSONBase{
+					Image: "sha256:dbid",
+					State: &container.State{Running: false, StartedAt: "2026-03-01T10:00:00Z"},
+				},
+				Config: &container.Config{Image: "mariadb:10.6"},
+			},
+		},
+		images: map[string]image.InspectResponse{
+			"sha256:redisid": {RepoDigests: []string{"redis@sha256:redisdigest"}, Created: "2026-02-01T00:00:00Z"},
+		},
+	}
+
+	statuses := []ServiceStatus{
+		{Name: "db", ContainerName: "kkengine_db"},
+		{Name: "redis", ContainerName: "kkengine_redis", Image: "redis:alpine", Running: true},
+		{Name: "caddy"},
+		{Name: "gone", ContainerName: "kkengine_gone"},
+	}
+	EnrichStatuses(context.Background(), cli, statuses, now)
+
+	assert.Equal(t, "mariadb:10.6", statuses[0].Image)
+	assert.Equal(t, "sha256:dbid", statuses[0].ImageDigest)
+	assert.Nil(t, statuses[0].StartedAt)
+
+	assert.Equal(t, "sha256:redisdigest", statuses[1].ImageDigest)
+	assert.NotNil(t, statuses[1].StartedAt)
+	assert.Equal(t, int64(3599), statuses[1].UptimeSeconds)
+	assert.Equal(t, 4, statuses[1].RestartCount)
+	if assert.NotNil(t, statuses[1].ImageCreated) {
+		assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), *statuses[1].ImageCreated)
+	}
+	assert.Nil(t, statuses[0].ImageCreated)
+
+	assert.Empty(t, statuses[2].ImageDigest)
+	assert.Empty(t, statuses[3].ImageDigest)
+}
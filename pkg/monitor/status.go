@@ -3,16 +3,25 @@ package monitor
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sort"
 	"strings"
+	"time"
 )
 
 type ServiceStatus struct {
-	Name    string
-	Status  string
-	Health  string
-	Ports   string
-	Running bool
+	Name          string     `json:"name" yaml:"name"`
+	ContainerName string     `json:"container_name" yaml:"container_name"`
+	Image         string     `json:"image" yaml:"image"`
+	ImageDigest   string     `json:"image_digest" yaml:"image_digest"`
+	Status        string     `json:"status" yaml:"status"`
+	Health        string     `json:"health" yaml:"health"`
+	Ports         string     `json:"ports" yaml:"ports"`
+	Running       bool       `json:"running" yaml:"running"`
+	StartedAt     *time.Time `json:"started_at,omitempty" yaml:"started_at,omitempty"`
+	UptimeSeconds int64      `json:"uptime_seconds" yaml:"uptime_seconds"`
+	RestartCount  int        `json:"restart_count" yaml:"restart_count"`
+	ImageCreated  *time.Time `json:"image_created,omitempty" yaml:"image_created,omitempty"`
 }
 
 // ComposeExecutor interface for testing
@@ -76,6 +85,7 @@ type composePsJSON struct {
 	Health  string `json:"Health"`
 	Ports   string `json:"Ports"`
 	Service string `json:"Service"`
+	Image   string `json:"Image"`
 }
 
 func parseComposePs(output string) ([]ServiceStatus, error) {
@@ -94,11 +104,13 @@ func parseComposePs(output string) ([]ServiceStatus, error) {
 		}
 
 		status := ServiceStatus{
-			Name:    ps.Service,
-			Status:  ps.State,
-			Health:  ps.Health,
-			Ports:   ps.Ports,
-			Running: strings.ToLower(ps.State) == "running",
+			Name:          ps.Service,
+			ContainerName: ps.Name,
+			Image:         ps.Image,
+			Status:        ps.State,
+			Health:        ps.Health,
+			Ports:         ps.Ports,
+			Running:       strings.ToLower(ps.State) == "running",
 		}
 
 		statuses = append(statuses, status)
@@ -107,6 +119,27 @@ func parseComposePs(output string) ([]ServiceStatus, error) {
 	return statuses, nil
 }
 
+// PublishesPublicly reports whether containerPort is published on all host
+// interfaces, as shown by docker ("0.0.0.0:3306->3306/tcp", ":::3306->...").
+func (s ServiceStatus) PublishesPublicly(containerPort int) bool {
+	suffix := fmt.Sprintf("->%d/", containerPort)
+	for _, mapping := range strings.Split(s.Ports, ",") {
+		host, _, ok := strings.Cut(strings.TrimSpace(mapping), suffix)
+		if !ok {
+			continue
+		}
+		i := strings.LastIndex(host, ":")
+		if i < 0 {
+			continue
+		}
+		switch host[:i] {
+		case "", "0.0.0.0", "::", "[::]":
+			return true
+		}
+	}
+	return false
+}
+
 // IsAllHealthy checks if all services are running/healthy
 func IsAllHealthy(statuses []ServiceStatus) bool {
 	for _, s := range statuses {
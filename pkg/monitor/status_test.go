@@ -30,7 +30,7 @@ func (m *MockComposeExecutor) ForceRecreate(ctx context.Context) error  { return
 func TestGetStatus(t *testing.T) {
 	t.Run("successful ps output", func(t *testing.T) {
 		mockPsOutput := `
-{"ID":"1a","Name":"test_web_1","Service":"web","Project":"test","State":"running","Health":"healthy","Ports":"0.0.0.0:80->80/tcp"}
+{"ID":"1a","Name":"test_web_1","Service":"web","Project":"test","Image":"nginx:alpine","State":"running","Health":"healthy","Ports":"0.0.0.0:80->80/tcp"}
 {"ID":"2b","Name":"test_db_1","Service":"db","Project":"test","State":"running","Health":"","Ports":"5432/tcp"}
 `
 		mockExecutor := &MockComposeExecutor{
@@ -44,6 +44,8 @@ func TestGetStatus(t *testing.T) {
 		assert.Len(t, statuses, 2)
 
 		assert.Equal(t, "web", statuses[0].Name)
+		assert.Equal(t, "test_web_1", statuses[0].ContainerName)
+		assert.Equal(t, "nginx:alpine", statuses[0].Image)
 		assert.Equal(t, "running", statuses[0].Status)
 		assert.Equal(t, "healthy", statuses[0].Health)
 		assert.Equal(t, "0.0.0.0:80->80/tcp", statuses[0].Ports)
@@ -213,3 +215,20 @@ func TestGetStatusWithServices(t *testing.T) {
 		assert.Nil(t, statuses)
 	})
 }
+
+func TestPublishesPublicly(t *testing.T) {
+	tests := []struct {
+		ports string
+		want  bool
+	}{
+		{"0.0.0.0:3306->3306/tcp, :::3306->3306/tcp", true},
+		{"[::]:13306->3306/tcp", true},
+		{"127.0.0.1:3306->3306/tcp", false},
+		{"3306/tcp", false},
+		{"0.0.0.0:8019->8019/tcp", false},
+	}
+	for _, tt := range tests {
+		s := ServiceStatus{Name: "db", Ports: tt.ports}
+		assert.Equal(t, tt.want, s.PublishesPublicly(3306), tt.ports)
+	}
+}
@@ -0,0 +1,248 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	dockerevents "github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+
+	"github.com/kkauto-net/kk-install/pkg/events"
+)
+
+// composeServiceLabel is set by docker compose on every service container.
+const composeServiceLabel = "com.docker.compose.service"
+
+// WatchClient is the Docker API subset used by Watcher.
+type WatchClient interface {
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
+	Events(ctx context.Context, options dockerevents.ListOptions) (<-chan dockerevents.Message, <-chan error)
+}
+
+// Watch event kinds.
+const (
+	WatchHealth  = "health"
+	WatchStart   = "start"
+	WatchStop    = "stop"
+	WatchDie     = "die"
+	WatchRestart = "restart"
+	WatchOOM     = "oom"
+)
+
+// WatchState is the live state of one watched service.
+type WatchState struct {
+	Service      string    `json:"service"`
+	Container    string    `json:"container"`
+	State        string    `json:"state"`  // running, exited, ...
+	Health       string    `json:"health"` // healthy, unhealthy, starting, or empty without a healthcheck
+	RestartCount int       `json:"restart_count"`
+	OOMKills     int       `json:"oom_kills"`
+	Changed      time.Time `json:"changed"`
+}
+
+// WatchEvent is a state transition of a watched service.
+type WatchEvent struct {
+	Time    time.Time `json:"time"`
+	Service string    `json:"service"`
+	Kind    string    `json:"kind"`
+	From    string    `json:"from,omitempty"`
+	To      string    `json:"to,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
+
+// Unhealthy reports whether the event leaves the service unhealthy or down.
+func (e WatchEvent) Unhealthy() bool {
+	switch e.Kind {
+	case WatchHealth:
+		return e.To == "unhealthy"
+	case WatchDie, WatchOOM:
+		return true
+	}
+	return false
+}
+
+// Watcher follows the containers of a stack through the Docker events API.
+type Watcher struct {
+	cli        WatchClient
+	containers map[string]string // container name -> service
+
+	mu     sync.Mutex
+	states map[string]*WatchState // by service
+}
+
+// NewWatcher watches the given containers, keyed by container name with the
+// compose service as value.
+func NewWatcher(cli WatchClient, containers map[string]string) *Watcher {
+	w := &Watcher{cli: cli, containers: containers, states: make(map[string]*WatchState)}
+	for name, service := range containers {
+		w.states[service] = &WatchState{Service: service, Container: name, State: "missing"}
+	}
+	return w
+}
+
+// Init loads the current state of every container. Containers that do not
+// exist stay "missing".
+func (w *Watcher) Init(ctx context.Context, now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, st := range w.states {
+		info, err := w.cli.ContainerInspect(ctx, st.Container)
+		if err != nil || info.ContainerJSONBase == nil || info.State == nil {
+			continue
+		}
+		st.State = info.State.Status
+		st.RestartCount = info.RestartCount
+		if info.State.Health != nil {
+			st.Health = info.State.Health.Status
+		}
+		if info.State.OOMKilled {
+			st.OOMKills = 1
+		}
+		st.Changed = now
+	}
+}
+
+// States returns a copy of the current states, sorted by service.
+func (w *Watcher) States() []WatchState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	states := make([]WatchState, 0, len(w.states))
+	for _, st := range w.states {
+		states = append(states, *st)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Service < states[j].Service })
+	return states
+}
+
+// Run subscribes to container events of the watched containers and calls
+// onEvent for every transition until ctx is done or the stream fails.
+func (w *Watcher) Run(ctx context.Context, onEvent func(WatchEvent)) error {
+	args := filters.NewArgs(filters.Arg("type", string(dockerevents.ContainerEventType)))
+	for name := range w.containers {
+		args.Add("container", name)
+	}
+	msgs, errs := w.cli.Events(ctx, dockerevents.ListOptions{Filters: args})
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if err == nil || errors.Is(err, context.Canceled) || ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("docker events: %w", err)
+		case msg := <-msgs:
+			if ev, ok := w.Apply(msg); ok {
+				emitWatchEvent(ev, w.States())
+				onEvent(ev)
+			}
+		}
+	}
+}
+
+// Apply updates the state from one Docker event and returns the transition,
+// if the event is one the watch reports.
+func (w *Watcher) Apply(msg dockerevents.Message) (WatchEvent, bool) {
+	if msg.Type != "" && msg.Type != dockerevents.ContainerEventType {
+		return WatchEvent{}, false
+	}
+	name := msg.Actor.Attributes["name"]
+	service, ok := w.containers[name]
+	if !ok {
+		if service, ok = msg.Actor.Attributes[composeServiceLabel]; !ok {
+			return WatchEvent{}, false
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	st, ok := w.states[service]
+	if !ok {
+		return WatchEvent{}, false
+	}
+
+	ev := WatchEvent{Time: eventTime(msg), Service: service}
+	action := string(msg.Action)
+	switch {
+	case strings.HasPrefix(action, string(dockerevents.ActionHealthStatus)):
+		health := strings.TrimSpace(strings.TrimPrefix(action, string(dockerevents.ActionHealthStatus)+":"))
+		if health == st.Health {
+			return WatchEvent{}, false
+		}
+		ev.Kind, ev.From, ev.To = WatchHealth, st.Health, health
+		st.Health = health
+	case msg.Action == dockerevents.ActionStart:
+		ev.Kind, ev.From, ev.To = WatchStart, st.State, "running"
+		if st.State == "exited" || st.State == "dead" {
+			// Started again after dying: a restart, by policy or by hand.
+			ev.Kind = WatchRestart
+			st.RestartCount++
+		}
+		st.State = "running"
+		if st.Health != "" {
+			st.Health = "starting"
+		}
+	case msg.Action == dockerevents.ActionRestart:
+		if st.State == "running" {
+			// docker restart sends die and start first; already counted.
+			return WatchEvent{}, false
+		}
+		ev.Kind, ev.From, ev.To = WatchRestart, st.State, "running"
+		st.RestartCount++
+		st.State = "running"
+	case msg.Action == dockerevents.ActionDie:
+		ev.Kind, ev.From, ev.To = WatchDie, st.State, "exited"
+		if code := msg.Actor.Attributes["exitCode"]; code != "" {
+			ev.Message = "exit code " + code
+		}
+		st.State = "exited"
+	case msg.Action == dockerevents.ActionStop:
+		ev.Kind, ev.From, ev.To = WatchStop, st.State, "exited"
+		st.State = "exited"
+	case msg.Action == dockerevents.ActionOOM:
+		ev.Kind = WatchOOM
+		ev.Message = "killed: out of memory"
+		st.OOMKills++
+	default:
+		return WatchEvent{}, false
+	}
+	st.Changed = ev.Time
+	return ev, true
+}
+
+func eventTime(msg dockerevents.Message) time.Time {
+	if msg.TimeNano != 0 {
+		return time.Unix(0, msg.TimeNano)
+	}
+	if msg.Time != 0 {
+		return time.Unix(msg.Time, 0)
+	}
+	return time.Now()
+}
+
+// emitWatchEvent reports a watch transition as health.changed on the event
+// stream, if enabled.
+func emitWatchEvent(ev WatchEvent, states []WatchState) {
+	for _, st := range states {
+		if st.Service != ev.Service {
+			continue
+		}
+		status := st.Health
+		if status == "" {
+			status = st.State
+		}
+		events.HealthChanged(events.Health{
+			Service:   st.Service,
+			Container: st.Container,
+			Status:    status,
+			Healthy:   st.State == "running" && (st.Health == "" || st.Health == "healthy"),
+			Message:   strings.TrimSpace(ev.Kind + " " + ev.Message),
+		})
+	}
+}
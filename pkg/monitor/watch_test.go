@@ -0,0 +1,132 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	dockerevents "github.com/docker/docker/api/types/events"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockWatchClient struct {
+	mockInspectClient
+	msgs    chan dockerevents.Message
+	errs    chan error
+	options dockerevents.ListOptions
+}
+
+func (m *mockWatchClient) Events(_ context.Context, options dockerevents.ListOptions) (<-chan dockerevents.Message, <-chan error) {
+	m.options = options
+	return m.msgs, m.errs
+}
+
+func containerEvent(name string, action dockerevents.Action, attrs ...string) dockerevents.Message {
+	attributes := map[string]string{"name": name}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		attributes[attrs[i]] = attrs[i+1]
+	}
+	return dockerevents.Message{
+		Type:     dockerevents.ContainerEventType,
+		Action:   action,
+		Actor:    dockerevents.Actor{ID: "abc", Attributes: attributes},
+		TimeNano: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC).UnixNano(),
+	}
+}
+
+func newTestWatcher() (*Watcher, *mockWatchClient) {
+	cli := &mockWatchClient{
+		mockInspectClient: mockInspectClient{containers: map[string]container.InspectResponse{
+			"kkengine_app": {ContainerJSONBase: &container.ContainerJSONBase{
+				RestartCount: 2,
+				State:        &container.State{Status: "running", Running: true, Health: &container.Health{Status: "healthy"}},
+			}},
+			"kkengine_db": {ContainerJSONBase: &container.ContainerJSONBase{
+				State: &container.State{Status: "exited", OOMKilled: true},
+			}},
+		}},
+		msgs: make(chan dockerevents.Message),
+		errs: make(chan error, 1),
+	}
+	w := NewWatcher(cli, map[string]string{"kkengine_app": "kkengine", "kkengine_db": "db", "kkengine_redis": "redis"})
+	w.Init(context.Background(), time.Now())
+	return w, cli
+}
+
+func TestWatcherInit(t *testing.T) {
+	w, _ := newTestWatcher()
+	states := w.States()
+	require.Len(t, states, 3)
+
+	assert.Equal(t, "db", states[0].Service)
+	assert.Equal(t, "exited", states[0].State)
+	assert.Equal(t, 1, states[0].OOMKills)
+	assert.Equal(t, "kkengine", states[1].Service)
+	assert.Equal(t, "healthy", states[1].Health)
+	assert.Equal(t, 2, states[1].RestartCount)
+	assert.Equal(t, "missing", states[2].State)
+}
+
+func TestWatcherApply(t *testing.T) {
+	w, _ := newTestWatcher()
+
+	ev, ok := w.Apply(containerEvent("kkengine_app", dockerevents.ActionHealthStatusUnhealthy))
+	require.True(t, ok)
+	assert.Equal(t, WatchEvent{Time: ev.Time, Service: "kkengine", Kind: WatchHealth, From: "healthy", To: "unhealthy"}, ev)
+	assert.True(t, ev.Unhealthy())
+
+	_, ok = w.Apply(containerEvent("kkengine_app", dockerevents.ActionHealthStatusUnhealthy))
+	assert.False(t, ok, "repeated health status is not a transition")
+
+	// docker restart: die, stop, start, restart counts once.
+	ev, ok = w.Apply(containerEvent("kkengine_app", dockerevents.ActionDie, "exitCode", "137"))
+	require.True(t, ok)
+	assert.Equal(t, "exit code 137", ev.Message)
+	_, ok = w.Apply(containerEvent("kkengine_app", dockerevents.ActionStop))
+	require.True(t, ok)
+	ev, ok = w.Apply(containerEvent("kkengine_app", dockerevents.ActionStart))
+	require.True(t, ok)
+	assert.Equal(t, WatchRestart, ev.Kind)
+	_, ok = w.Apply(containerEvent("kkengine_app", dockerevents.ActionRestart))
+	assert.False(t, ok)
+
+	ev, ok = w.Apply(containerEvent("kkengine_db", dockerevents.ActionOOM))
+	require.True(t, ok)
+	assert.True(t, ev.Unhealthy())
+
+	_, ok = w.Apply(containerEvent("kkengine_app", dockerevents.ActionAttach))
+	assert.False(t, ok)
+	_, ok = w.Apply(containerEvent("other", dockerevents.ActionDie))
+	assert.False(t, ok)
+
+	states := w.States()
+	assert.Equal(t, 3, states[1].RestartCount)
+	assert.Equal(t, "starting", states[1].Health)
+	assert.Equal(t, 2, states[0].OOMKills)
+}
+
+func TestWatcherRun(t *testing.T) {
+	w, cli := newTestWatcher()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var got []WatchEvent
+	done := make(chan error, 1)
+	go func() {
+		done <- w.Run(ctx, func(ev WatchEvent) { got = append(got, ev) })
+	}()
+
+	cli.msgs <- containerEvent("kkengine_db", dockerevents.ActionStart)
+	cli.errs <- errors.New("connection reset")
+	err := <-done
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection reset")
+	require.Len(t, got, 1)
+	assert.Equal(t, WatchRestart, got[0].Kind)
+
+	assert.Equal(t, []string{"container"}, cli.options.Filters.Get("type"))
+	assert.ElementsMatch(t, []string{"kkengine_app", "kkengine_db", "kkengine_redis"}, cli.options.Filters.Get("container"))
+}
@@ -29,7 +29,19 @@ type N8nConfig struct {
 	DBPassword      string // PostgreSQL password
 	EncryptionKey   string // N8N_ENCRYPTION_KEY (critical - never lose this!)
 	Timezone        string // Timezone (default: Asia/Ho_Chi_Minh)
-	ConnectKKEngine bool   // Whether to join kkengine_net network
+	ConnectKKEngine bool   // Whether to join the kkengine stack network
+	KKEngineNetwork string // Docker name of the kkengine network (default kkengine_net)
+}
+
+// DefaultKKEngineNetwork is the network name of a default kkengine install.
+const DefaultKKEngineNetwork = "kkengine_net"
+
+// KKEngineNetworkName returns the kkengine network to join.
+func (c N8nConfig) KKEngineNetworkName() string {
+	if c.KKEngineNetwork == "" {
+		return DefaultKKEngineNetwork
+	}
+	return c.KKEngineNetwork
 }
 
 // Validate checks that all required fields meet minimum security requirements.
@@ -52,11 +64,11 @@ func (c N8nConfig) Validate() error {
 }
 
 // N8nDir returns the n8n installation directory.
-// Uses ProjectDir from config, fallback to ~/.kk/n8n if ProjectDir not set.
+// Uses the active project directory from config, fallback to ~/.kk/n8n if ProjectDir not set.
 func N8nDir() string {
 	cfg, err := config.Load()
-	if err == nil && cfg.ProjectDir != "" {
-		return filepath.Join(cfg.ProjectDir, N8nSubDir)
+	if err == nil && cfg.ActiveProjectDir() != "" {
+		return filepath.Join(cfg.ActiveProjectDir(), N8nSubDir)
 	}
 	// Fallback to ~/.kk/n8n
 	home, err := os.UserHomeDir()
@@ -0,0 +1,361 @@
+// Package notify delivers health and update notifications to Slack,
+// Telegram and generic webhook targets configured in ~/.kk/config.yaml.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/redact"
+	"github.com/kkauto-net/kk-install/pkg/updater"
+)
+
+// Target types.
+const (
+	TypeSlack    = "slack"
+	TypeTelegram = "telegram"
+	TypeWebhook  = "webhook"
+)
+
+// Event kinds. Targets subscribe to kinds with Target.Events.
+const (
+	KindHealth = "health"
+	KindUpdate = "update"
+	KindTest   = "test"
+)
+
+const (
+	// DefaultAttempts is used when Settings.Attempts is not set.
+	DefaultAttempts = 3
+	// DefaultTimeout bounds one delivery attempt when Settings.Timeout is not set.
+	DefaultTimeout = 10 * time.Second
+
+	telegramAPI = "https://api.telegram.org"
+)
+
+// Settings is the notifications section of the config file.
+type Settings struct {
+	Attempts int           `yaml:"attempts,omitempty"` // Deliveries tried per target, including the first
+	Timeout  time.Duration `yaml:"timeout,omitempty"`  // Per attempt, e.g. 10s
+	Targets  []Target      `yaml:"targets"`
+}
+
+// Target is one destination for notifications.
+type Target struct {
+	Name     string            `yaml:"name"`
+	Type     string            `yaml:"type"`                // slack, telegram or webhook
+	URL      string            `yaml:"url,omitempty"`       // Slack incoming webhook, webhook endpoint, or Telegram API override
+	BotToken string            `yaml:"bot_token,omitempty"` // Telegram
+	ChatID   string            `yaml:"chat_id,omitempty"`   // Telegram
+	Events   []string          `yaml:"events,omitempty"`    // Kinds to send; empty means all
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	Template string            `yaml:"template,omitempty"` // text/template for the request body
+}
+
+// ImageChange is an image replaced by kk update.
+type ImageChange struct {
+	Image string `json:"image"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// Event is one notification.
+type Event struct {
+	Kind    string        `json:"kind"`
+	Time    time.Time     `json:"time"`
+	Host    string        `json:"host"`
+	Project string        `json:"project,omitempty"`
+	Service string        `json:"service,omitempty"`
+	Status  string        `json:"status"` // Health status, or the update outcome
+	Message string        `json:"message,omitempty"`
+	Images  []ImageChange `json:"images,omitempty"`
+	Text    string        `json:"text"` // One-line human readable summary
+}
+
+// HealthEvent reports a service health change.
+func HealthEvent(service, status, message string) Event {
+	ev := Event{Kind: KindHealth, Service: service, Status: status, Message: message}
+	ev.Text = fmt.Sprintf("%s is %s", service, status)
+	if message != "" {
+		ev.Text += ": " + message
+	}
+	return ev
+}
+
+// UpdateEvent reports the result of a kk update run that found new images.
+func UpdateEvent(outcome string, updates []updater.ImageUpdate, err error) Event {
+	ev := Event{Kind: KindUpdate, Status: outcome}
+	names := make([]string, len(updates))
+	for i, u := range updates {
+		ev.Images = append(ev.Images, ImageChange{Image: u.Image, From: u.OldDigest, To: u.NewDigest})
+		names[i] = u.Image
+	}
+	ev.Text = fmt.Sprintf("kk update %s: %d image(s)", outcome, len(updates))
+	if len(names) > 0 {
+		ev.Text += " (" + strings.Join(names, ", ") + ")"
+	}
+	if err != nil {
+		ev.Message = redact.Error(err)
+		ev.Text += ": " + ev.Message
+	}
+	return ev
+}
+
+// TestEvent is sent by kk notify test.
+func TestEvent() Event {
+	return Event{Kind: KindTest, Status: "ok", Text: "Test notification from kk"}
+}
+
+// Validate reports configuration mistakes in the settings and every target.
+func (s Settings) Validate() error {
+	var errs []error
+	seen := map[string]bool{}
+	for i, t := range s.Targets {
+		if err := t.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("target %d: %w", i+1, err))
+		}
+		if seen[t.Name] {
+			errs = append(errs, fmt.Errorf("target %q is defined twice", t.Name))
+		}
+		seen[t.Name] = true
+	}
+	if s.Attempts < 0 || s.Timeout < 0 {
+		errs = append(errs, errors.New("attempts and timeout must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate reports configuration mistakes in the target.
+func (t Target) Validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	switch t.Type {
+	case TypeSlack, TypeWebhook:
+		if u, err := url.Parse(t.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s: url must be an http(s) URL", t.Name)
+		}
+	case TypeTelegram:
+		if t.BotToken == "" || t.ChatID == "" {
+			return fmt.Errorf("%s: bot_token and chat_id are required", t.Name)
+		}
+	default:
+		return fmt.Errorf("%s: unknown type %q (use slack, telegram or webhook)", t.Name, t.Type)
+	}
+	for _, kind := range t.Events {
+		if kind != KindHealth && kind != KindUpdate {
+			return fmt.Errorf("%s: unknown event %q (use health or update)", t.Name, kind)
+		}
+	}
+	if t.Template != "" {
+		if _, err := parseTemplate(t.Template); err != nil {
+			return fmt.Errorf("%s: %w", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// Wants reports whether the target subscribes to kind. Test events go to every target.
+func (t Target) Wants(kind string) bool {
+	return kind == KindTest || len(t.Events) == 0 || slices.Contains(t.Events, kind)
+}
+
+// Notifier sends events to the configured targets.
+type Notifier struct {
+	settings Settings
+	client   *http.Client
+	host     string
+	project  string
+	backoff  time.Duration // Wait before the first retry, doubled after each
+}
+
+// New returns a Notifier for settings. Events are stamped with the host name
+// and project.
+func New(settings Settings, project string) *Notifier {
+	if settings.Attempts == 0 {
+		settings.Attempts = DefaultAttempts
+	}
+	if settings.Timeout == 0 {
+		settings.Timeout = DefaultTimeout
+	}
+	// Without a host name events go out unstamped.
+	host, err := os.Hostname()
+	if err != nil {
+		host = ""
+	}
+	return &Notifier{
+		settings: settings,
+		client:   &http.Client{Timeout: settings.Timeout},
+		host:     host,
+		project:  project,
+		backoff:  time.Second,
+	}
+}
+
+// Targets returns the configured targets.
+func (n *Notifier) Targets() []Target {
+	return n.settings.Targets
+}
+
+// Send delivers ev to every target subscribed to its kind.
+func (n *Notifier) Send(ctx context.Context, ev Event) error {
+	var errs []error
+	for _, t := range n.settings.Targets {
+		if !t.Wants(ev.Kind) {
+			continue
+		}
+		if err := n.SendTo(ctx, t, ev); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SendTo delivers ev to t, retrying rate limits, server errors and network
+// failures with exponential backoff.
+func (n *Notifier) SendTo(ctx context.Context, t Target, ev Event) error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	n.stamp(&ev)
+	body, err := Payload(t, ev)
+	if err != nil {
+		return fmt.Errorf("%s: %w", t.Name, err)
+	}
+
+	delay := n.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, t, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= n.settings.Attempts {
+			return fmt.Errorf("%s: %w", t.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s: %w", t.Name, ctx.Err())
+		case <-time.After(delay):
+			delay *= 2
+		}
+	}
+}
+
+func (n *Notifier) stamp(ev *Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	if ev.Host == "" {
+		ev.Host = n.host
+	}
+	if ev.Project == "" {
+		ev.Project = n.project
+	}
+	if ev.Host != "" && ev.Text != "" {
+		ev.Text = "[" + ev.Host + "] " + ev.Text
+	}
+}
+
+// post makes one delivery and reports whether a failure is worth retrying.
+func (n *Notifier) post(ctx context.Context, t Target, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint(t), bytes.NewReader(body))
+	if err != nil {
+		return false, errors.New("invalid target URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		// url.Error repeats the URL, which holds the Slack or Telegram token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return ctx.Err() == nil, err
+	}
+	retry, err := responseError(resp)
+	return retry, errors.Join(err, resp.Body.Close())
+}
+
+// responseError returns nil for a 2xx response, else the status and the
+// start of the body, and whether the failure is worth retrying.
+func responseError(resp *http.Response) (bool, error) {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	detail, err := io.ReadAll(io.LimitReader(resp.Body, 200))
+	if err != nil {
+		return retry, fmt.Errorf("HTTP %d: read response: %w", resp.StatusCode, err)
+	}
+	if msg := strings.TrimSpace(string(detail)); msg != "" {
+		return retry, fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg)
+	}
+	return retry, fmt.Errorf("HTTP %d", resp.StatusCode)
+}
+
+func endpoint(t Target) string {
+	if t.Type == TypeTelegram && t.URL == "" {
+		return telegramAPI + "/bot" + t.BotToken + "/sendMessage"
+	}
+	return t.URL
+}
+
+// templateData is what target templates see: the event fields plus the
+// Telegram chat.
+type templateData struct {
+	Event
+	ChatID string
+}
+
+// Payload renders the request body for t: the target template when set,
+// otherwise the default body for its type.
+func Payload(t Target, ev Event) ([]byte, error) {
+	if t.Template != "" {
+		tmpl, err := parseTemplate(t.Template)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, templateData{Event: ev, ChatID: t.ChatID}); err != nil {
+			return nil, fmt.Errorf("render template: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+	switch t.Type {
+	case TypeSlack:
+		return json.Marshal(map[string]string{"text": ev.Text})
+	case TypeTelegram:
+		return json.Marshal(map[string]string{"chat_id": t.ChatID, "text": ev.Text})
+	default:
+		return json.Marshal(ev)
+	}
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("payload").Funcs(template.FuncMap{
+		// json renders a value as JSON, e.g. "text": {{json .Text}}
+		"json": func(v any) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,36 @@
+// Package redact masks credentials in text that kk logs, records or sends.
+// It has no dependencies, so every package can use it; the UI exposes the
+// same rules as ui.RedactSecrets.
+package redact
+
+import "regexp"
+
+// Placeholder replaces every value masked by Secrets.
+const Placeholder = "[REDACTED]"
+
+// patterns mask credentials that commonly leak into command output:
+// KEY=value pairs whose key names a secret, passwords in URLs and license keys.
+var patterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)\b([A-Z0-9_]*(?:PASSWORD|PASSWD|SECRET|TOKEN|ACCESS_KEY|LICENSE_KEY|ENCRYPTION_KEY|PRIVATE_KEY)[A-Z0-9_]*)(\s*[=:]\s*)("[^"]*"|'[^']*'|[^\s"',;]+)`), "${1}${2}" + Placeholder},
+	{regexp.MustCompile(`(://[^/\s:@]+:)[^/\s@]+@`), "${1}" + Placeholder + "@"},
+	{regexp.MustCompile(`\bLICENSE-[A-Za-z0-9]{8,}\b`), "LICENSE-" + Placeholder},
+}
+
+// Secrets masks secret values in s.
+func Secrets(s string) string {
+	for _, p := range patterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+// Error returns the message of err with secrets masked, or "" for nil.
+func Error(err error) string {
+	if err == nil {
+		return ""
+	}
+	return Secrets(err.Error())
+}
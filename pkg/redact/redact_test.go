@@ -0,0 +1,30 @@
+package redact
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSecrets(t *testing.T) {
+	tests := map[string]string{
+		"DB_PASSWORD=hunter2 DB_HOSTNAME=db":              "DB_PASSWORD=[REDACTED] DB_HOSTNAME=db",
+		`redis: requirepass token: "abc def"`:             `redis: requirepass token: [REDACTED]`,
+		"dial mysql://kkauto:s3cr3t@db:3306/kkengine":     "dial mysql://kkauto:[REDACTED]@db:3306/kkengine",
+		"license LICENSE-ABCDEF0123456789 rejected":       "license LICENSE-[REDACTED] rejected",
+		"port 3306 already in use by mariadbd (pid 1234)": "port 3306 already in use by mariadbd (pid 1234)",
+	}
+	for in, want := range tests {
+		if got := Secrets(in); got != want {
+			t.Errorf("Secrets(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestError(t *testing.T) {
+	if got := Error(nil); got != "" {
+		t.Errorf("Error(nil) = %q, want empty", got)
+	}
+	if got := Error(errors.New("JWT_SECRET=abc123")); got != "JWT_SECRET=[REDACTED]" {
+		t.Errorf("Error() = %q, want redacted secret", got)
+	}
+}
@@ -0,0 +1,402 @@
+// Package scheduler installs the systemd timer or cron entry that runs
+// unattended kk updates inside a maintenance window, and the systemd units
+// of long-running kk services.
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backend is the mechanism that triggers scheduled runs.
+type Backend string
+
+const (
+	BackendSystemd Backend = "systemd"
+	BackendCron    Backend = "cron"
+)
+
+const (
+	// UnitName is the base name of the systemd service and timer units; see
+	// ProjectUnitName.
+	UnitName = "kk-update"
+	// SystemdUnitDir is where system-wide units are installed.
+	SystemdUnitDir = "/etc/systemd/system"
+	// cronMarker tags the crontab line managed by kk; named projects add
+	// @project like unit names.
+	cronMarker = "# kk-update-schedule"
+	// defaultPath is used by scheduled runs, which start with a minimal environment.
+	defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+)
+
+var (
+	// ErrNoBackend is returned when neither systemd nor cron is usable.
+	ErrNoBackend = errors.New("neither systemd (as root) nor crontab is available")
+	// ErrSystemdNeedsRoot is returned when installing system units without root.
+	ErrSystemdNeedsRoot = errors.New("installing a systemd timer requires root; run with sudo or use --backend cron")
+	// ErrServiceNeedsRoot is returned when installing a service unit without root.
+	ErrServiceNeedsRoot = errors.New("installing a systemd service requires root; run with sudo")
+)
+
+// Window is a daily maintenance window, e.g. 02:00-04:00. It may wrap past midnight.
+type Window struct {
+	Start  time.Duration // Offset from midnight
+	Length time.Duration
+}
+
+// ParseWindow parses "HH:MM-HH:MM".
+func ParseWindow(s string) (Window, error) {
+	from, to, ok := strings.Cut(strings.TrimSpace(s), "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", s)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return Window{}, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+	length := end - start
+	if length < 0 {
+		length += 24 * time.Hour
+	}
+	if length == 0 {
+		return Window{}, fmt.Errorf("invalid window %q: start and end are equal", s)
+	}
+	return Window{Start: start, Length: length}, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// String formats the window as HH:MM-HH:MM.
+func (w Window) String() string {
+	return formatClock(w.Start) + "-" + formatClock(w.Start+w.Length)
+}
+
+func formatClock(d time.Duration) string {
+	d %= 24 * time.Hour
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// ProjectUnitName returns base for the unnamed default project and
+// base@project for a named one, so each project's units do not overwrite
+// the others'.
+func ProjectUnitName(base, project string) string {
+	if project == "" {
+		return base
+	}
+	return base + "@" + project
+}
+
+// Schedule describes the unattended run.
+type Schedule struct {
+	Project string // Named project the run updates, "" for the default one
+	Window  Window
+	Command []string          // Executable and arguments
+	Env     map[string]string // Extra environment for the run
+}
+
+// Runner executes an external command with optional stdin and returns its stdout.
+type Runner func(ctx context.Context, stdin string, name string, args ...string) (string, error)
+
+// Manager installs and removes schedules.
+type Manager struct {
+	Run      Runner
+	LookPath func(string) (string, error)
+	IsRoot   bool
+	UnitDir  string
+}
+
+// NewManager returns a Manager using the real system.
+func NewManager() *Manager {
+	return &Manager{
+		Run:      runCommand,
+		LookPath: exec.LookPath,
+		IsRoot:   os.Geteuid() == 0,
+		UnitDir:  SystemdUnitDir,
+	}
+}
+
+// Detect picks systemd when running as root on a systemd host, cron otherwise.
+func (m *Manager) Detect() (Backend, error) {
+	if m.IsRoot {
+		if _, err := m.LookPath("systemctl"); err == nil {
+			if _, err := os.Stat("/run/systemd/system"); err == nil {
+				return BackendSystemd, nil
+			}
+		}
+	}
+	if _, err := m.LookPath("crontab"); err == nil {
+		return BackendCron, nil
+	}
+	return "", ErrNoBackend
+}
+
+// Install writes the schedule for backend, replacing any previous kk schedule.
+func (m *Manager) Install(ctx context.Context, backend Backend, s Schedule) error {
+	switch backend {
+	case BackendSystemd:
+		if !m.IsRoot {
+			return ErrSystemdNeedsRoot
+		}
+		unit := ProjectUnitName(UnitName, s.Project)
+		service, timer := SystemdUnits(s)
+		if err := os.WriteFile(filepath.Join(m.UnitDir, unit+".service"), []byte(service), 0644); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(m.UnitDir, unit+".timer"), []byte(timer), 0644); err != nil {
+			return err
+		}
+		if _, err := m.Run(ctx, "", "systemctl", "daemon-reload"); err != nil {
+			return err
+		}
+		_, err := m.Run(ctx, "", "systemctl", "enable", "--now", unit+".timer")
+		return err
+	case BackendCron:
+		return m.updateCrontab(ctx, s.Project, CronLine(s))
+	default:
+		return fmt.Errorf("unknown schedule backend %q", backend)
+	}
+}
+
+// Remove deletes the kk schedule of project from backend. Missing schedules
+// are not an error.
+func (m *Manager) Remove(ctx context.Context, backend Backend, project string) error {
+	switch backend {
+	case BackendSystemd:
+		if !m.IsRoot {
+			return ErrSystemdNeedsRoot
+		}
+		unit := ProjectUnitName(UnitName, project)
+		timerPath := filepath.Join(m.UnitDir, unit+".timer")
+		if _, err := os.Stat(timerPath); os.IsNotExist(err) {
+			return nil
+		}
+		if _, err := m.Run(ctx, "", "systemctl", "disable", "--now", unit+".timer"); err != nil {
+			return err
+		}
+		for _, name := range []string{timerPath, filepath.Join(m.UnitDir, unit+".service")} {
+			if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		_, err := m.Run(ctx, "", "systemctl", "daemon-reload")
+		return err
+	case BackendCron:
+		return m.updateCrontab(ctx, project, "")
+	default:
+		return fmt.Errorf("unknown schedule backend %q", backend)
+	}
+}
+
+// Service is a long-running kk process supervised by systemd.
+type Service struct {
+	Name        string // Unit name without the .service suffix
+	Description string
+	Command     []string          // Executable and arguments
+	Env         map[string]string // Extra environment for the process
+}
+
+// InstallService writes the unit for s, enables it at boot and (re)starts it.
+func (m *Manager) InstallService(ctx context.Context, s Service) error {
+	if !m.IsRoot {
+		return ErrServiceNeedsRoot
+	}
+	if err := os.WriteFile(filepath.Join(m.UnitDir, s.Name+".service"), []byte(ServiceUnit(s)), 0644); err != nil {
+		return err
+	}
+	if _, err := m.Run(ctx, "", "systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	if _, err := m.Run(ctx, "", "systemctl", "enable", s.Name+".service"); err != nil {
+		return err
+	}
+	// restart rather than start so a reinstall picks up the new unit
+	_, err := m.Run(ctx, "", "systemctl", "restart", s.Name+".service")
+	return err
+}
+
+// RemoveService stops, disables and deletes the named unit. A missing unit is not an error.
+func (m *Manager) RemoveService(ctx context.Context, name string) error {
+	if !m.IsRoot {
+		return ErrServiceNeedsRoot
+	}
+	unitPath := filepath.Join(m.UnitDir, name+".service")
+	if _, err := os.Stat(unitPath); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := m.Run(ctx, "", "systemctl", "disable", "--now", name+".service"); err != nil {
+		return err
+	}
+	if err := os.Remove(unitPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	_, err := m.Run(ctx, "", "systemctl", "daemon-reload")
+	return err
+}
+
+// ServiceUnit renders a unit that starts after Docker and is restarted when it exits.
+func ServiceUnit(s Service) string {
+	var unit strings.Builder
+	fmt.Fprintf(&unit, "[Unit]\nDescription=%s\nWants=network-online.target\nAfter=network-online.target docker.service\n\n", s.Description)
+	unit.WriteString("[Service]\nType=simple\n")
+	for _, kv := range environment(s.Env) {
+		fmt.Fprintf(&unit, "Environment=%s\n", systemdQuote(kv))
+	}
+	quoted := make([]string, len(s.Command))
+	for i, arg := range s.Command {
+		quoted[i] = systemdQuote(arg)
+	}
+	fmt.Fprintf(&unit, "ExecStart=%s\n", strings.Join(quoted, " "))
+	unit.WriteString("Restart=on-failure\nRestartSec=5\n\n[Install]\nWantedBy=multi-user.target\n")
+	return unit.String()
+}
+
+func (m *Manager) updateCrontab(ctx context.Context, project, line string) error {
+	existing, err := m.Run(ctx, "", "crontab", "-l")
+	if err != nil {
+		// An empty crontab is reported as an error by most cron implementations
+		if !strings.Contains(strings.ToLower(err.Error()), "no crontab") {
+			return err
+		}
+		existing = ""
+	}
+	_, err = m.Run(ctx, MergeCrontab(existing, project, line), "crontab", "-")
+	return err
+}
+
+// SystemdUnits renders the oneshot service and the timer that fires inside the window.
+func SystemdUnits(s Schedule) (service, timer string) {
+	var svc strings.Builder
+	svc.WriteString("[Unit]\nDescription=kk unattended update\nWants=network-online.target\nAfter=network-online.target docker.service\n\n")
+	svc.WriteString("[Service]\nType=oneshot\n")
+	for _, kv := range environment(s.Env) {
+		fmt.Fprintf(&svc, "Environment=%s\n", systemdQuote(kv))
+	}
+	quoted := make([]string, len(s.Command))
+	for i, arg := range s.Command {
+		quoted[i] = systemdQuote(arg)
+	}
+	fmt.Fprintf(&svc, "ExecStart=%s\n", strings.Join(quoted, " "))
+
+	timer = fmt.Sprintf(`[Unit]
+Description=Run kk update in the %s maintenance window
+
+[Timer]
+OnCalendar=*-*-* %s:00
+RandomizedDelaySec=%d
+AccuracySec=1min
+Persistent=false
+
+[Install]
+WantedBy=timers.target
+`, s.Window, formatClock(s.Window.Start), int(s.Window.Length.Seconds()))
+
+	return svc.String(), timer
+}
+
+// CronLine renders the crontab entry. Cron has no jitter of its own, so the
+// command is expected to spread the start over the window itself. Cron turns
+// a bare % into a newline, so every % is escaped.
+func CronLine(s Schedule) string {
+	start := s.Window.Start
+	fields := []string{
+		fmt.Sprintf("%d %d * * *", int(start.Minutes())%60, int(start.Hours())),
+	}
+	for _, kv := range environment(s.Env) {
+		key, value, _ := strings.Cut(kv, "=")
+		fields = append(fields, key+"="+shellQuote(value))
+	}
+	for _, arg := range s.Command {
+		fields = append(fields, shellQuote(arg))
+	}
+	fields = append(fields, ">/dev/null 2>&1", ProjectUnitName(cronMarker, s.Project))
+	return strings.ReplaceAll(strings.Join(fields, " "), "%", `\%`)
+}
+
+// MergeCrontab replaces the kk-managed line of project in crontab with line
+// (removes it when empty). Every other line, blank ones included, is kept as is.
+func MergeCrontab(crontab, project, line string) string {
+	marker := ProjectUnitName(cronMarker, project)
+	var lines []string
+	if crontab != "" {
+		for _, l := range strings.Split(strings.TrimSuffix(crontab, "\n"), "\n") {
+			if strings.HasSuffix(strings.TrimSpace(l), marker) {
+				continue
+			}
+			lines = append(lines, l)
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// environment returns KEY=value pairs, PATH first, then the rest in sorted order.
+func environment(env map[string]string) []string {
+	pairs := []string{"PATH=" + defaultPath}
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		if k != "PATH" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		pairs = append(pairs, k+"="+env[k])
+	}
+	return pairs
+}
+
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./=:@%+,", r))
+	}) == -1 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func systemdQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func runCommand(ctx context.Context, stdin string, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("%s %s: %s", name, strings.Join(args, " "), msg)
+		}
+		return stdout.String(), fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return stdout.String(), nil
+}
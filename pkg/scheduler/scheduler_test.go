@@ -0,0 +1,242 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		in      string
+		start   time.Duration
+		length  time.Duration
+		wantErr bool
+	}{
+		{in: "02:00-04:00", start: 2 * time.Hour, length: 2 * time.Hour},
+		{in: "23:30-01:00", start: 23*time.Hour + 30*time.Minute, length: 90 * time.Minute},
+		{in: "03:00-03:00", wantErr: true},
+		{in: "3am-5am", wantErr: true},
+		{in: "02:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			w, err := ParseWindow(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseWindow(%q) expected error", tt.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseWindow(%q) error = %v", tt.in, err)
+			}
+			if w.Start != tt.start || w.Length != tt.length {
+				t.Fatalf("ParseWindow(%q) = %+v", tt.in, w)
+			}
+			if w.String() != tt.in {
+				t.Fatalf("String() = %q, want %q", w.String(), tt.in)
+			}
+		})
+	}
+}
+
+func testSchedule() Schedule {
+	return Schedule{
+		Window:  Window{Start: 2*time.Hour + 30*time.Minute, Length: 2 * time.Hour},
+		Command: []string{"/usr/local/bin/kk", "update", "--force", "--scheduled"},
+		Env:     map[string]string{"KK_DOCKER_SUDO": "1"},
+	}
+}
+
+func TestSystemdUnits(t *testing.T) {
+	service, timer := SystemdUnits(testSchedule())
+
+	for _, want := range []string{
+		"Type=oneshot",
+		"Environment=KK_DOCKER_SUDO=1",
+		"ExecStart=/usr/local/bin/kk update --force --scheduled",
+	} {
+		if !strings.Contains(service, want) {
+			t.Errorf("service missing %q:\n%s", want, service)
+		}
+	}
+	for _, want := range []string{
+		"OnCalendar=*-*-* 02:30:00",
+		"RandomizedDelaySec=7200",
+		"Persistent=false",
+	} {
+		if !strings.Contains(timer, want) {
+			t.Errorf("timer missing %q:\n%s", want, timer)
+		}
+	}
+}
+
+func TestCronLine(t *testing.T) {
+	s := testSchedule()
+	s.Command[0] = "/opt/my tools/kk"
+	got := CronLine(s)
+	want := "30 2 * * * PATH=" + defaultPath + " KK_DOCKER_SUDO=1 '/opt/my tools/kk' update --force --scheduled >/dev/null 2>&1 " + cronMarker
+	if got != want {
+		t.Fatalf("CronLine() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCronLineEscapesPercent(t *testing.T) {
+	s := testSchedule()
+	s.Env = map[string]string{"KK_LABEL": "100%"}
+	got := CronLine(s)
+	if !strings.Contains(got, `KK_LABEL=100\%`) || strings.Count(got, "%") != strings.Count(got, `\%`) {
+		t.Fatalf("CronLine() = %s, want every %% escaped", got)
+	}
+}
+
+func TestMergeCrontab(t *testing.T) {
+	existing := "MAILTO=ops\n\n0 1 * * * backup.sh\n0 3 * * * old kk " + cronMarker + "\n"
+
+	got := MergeCrontab(existing, "", "0 4 * * * new kk "+cronMarker)
+	want := "MAILTO=ops\n\n0 1 * * * backup.sh\n0 4 * * * new kk " + cronMarker + "\n"
+	if got != want {
+		t.Fatalf("MergeCrontab() = %q, want %q", got, want)
+	}
+
+	if got := MergeCrontab(existing, "", ""); got != "MAILTO=ops\n\n0 1 * * * backup.sh\n" {
+		t.Fatalf("MergeCrontab() remove = %q", got)
+	}
+
+	// Each project has its own line.
+	shop := "0 5 * * * shop kk " + cronMarker + "@shop"
+	got = MergeCrontab(existing, "shop", shop)
+	if want := existing + shop + "\n"; got != want {
+		t.Fatalf("MergeCrontab() project = %q, want %q", got, want)
+	}
+	if got = MergeCrontab(got, "shop", ""); got != existing {
+		t.Fatalf("MergeCrontab() project remove = %q, want %q", got, existing)
+	}
+}
+
+type recordedRun struct {
+	stdin string
+	cmd   string
+}
+
+func fakeManager(t *testing.T, crontab string, root bool) (*Manager, *[]recordedRun) {
+	t.Helper()
+	var runs []recordedRun
+	m := &Manager{
+		Run: func(_ context.Context, stdin string, name string, args ...string) (string, error) {
+			cmd := name + " " + strings.Join(args, " ")
+			runs = append(runs, recordedRun{stdin: stdin, cmd: cmd})
+			if cmd == "crontab -l" {
+				if crontab == "" {
+					return "", errors.New("crontab -l: no crontab for user")
+				}
+				return crontab, nil
+			}
+			return "", nil
+		},
+		LookPath: func(string) (string, error) { return "", errors.New("not found") },
+		IsRoot:   root,
+		UnitDir:  t.TempDir(),
+	}
+	return m, &runs
+}
+
+func TestManagerCronInstallOnEmptyCrontab(t *testing.T) {
+	m, runs := fakeManager(t, "", false)
+	if err := m.Install(context.Background(), BackendCron, testSchedule()); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	last := (*runs)[len(*runs)-1]
+	if last.cmd != "crontab -" || !strings.HasSuffix(last.stdin, cronMarker+"\n") {
+		t.Fatalf("last run = %+v", last)
+	}
+}
+
+func TestManagerSystemd(t *testing.T) {
+	m, runs := fakeManager(t, "", false)
+	if err := m.Install(context.Background(), BackendSystemd, testSchedule()); !errors.Is(err, ErrSystemdNeedsRoot) {
+		t.Fatalf("Install() as non-root error = %v", err)
+	}
+
+	m.IsRoot = true
+	if err := m.Install(context.Background(), BackendSystemd, testSchedule()); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.UnitDir, UnitName+".timer")); err != nil {
+		t.Fatalf("timer not written: %v", err)
+	}
+	if got := (*runs)[len(*runs)-1].cmd; got != "systemctl enable --now kk-update.timer" {
+		t.Fatalf("last command = %q", got)
+	}
+
+	shop := testSchedule()
+	shop.Project = "shop"
+	if err := m.Install(context.Background(), BackendSystemd, shop); err != nil {
+		t.Fatalf("Install() project error = %v", err)
+	}
+	if got := (*runs)[len(*runs)-1].cmd; got != "systemctl enable --now kk-update@shop.timer" {
+		t.Fatalf("last command = %q", got)
+	}
+
+	if err := m.Remove(context.Background(), BackendSystemd, ""); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.UnitDir, UnitName+".service")); !os.IsNotExist(err) {
+		t.Fatalf("service should be removed, stat err = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.UnitDir, "kk-update@shop.timer")); err != nil {
+		t.Fatalf("removing the default schedule removed the project's: %v", err)
+	}
+}
+
+func TestManagerService(t *testing.T) {
+	svc := Service{
+		Name:        "kk-exporter",
+		Description: "kk Prometheus exporter",
+		Command:     []string{"/usr/local/bin/kk", "exporter", "--listen", ":9796"},
+		Env:         map[string]string{"HOME": "/root"},
+	}
+
+	m, runs := fakeManager(t, "", false)
+	if err := m.InstallService(context.Background(), svc); !errors.Is(err, ErrServiceNeedsRoot) {
+		t.Fatalf("InstallService() as non-root error = %v", err)
+	}
+
+	m.IsRoot = true
+	if err := m.InstallService(context.Background(), svc); err != nil {
+		t.Fatalf("InstallService() error = %v", err)
+	}
+	unit, err := os.ReadFile(filepath.Join(m.UnitDir, "kk-exporter.service"))
+	if err != nil {
+		t.Fatalf("unit not written: %v", err)
+	}
+	for _, want := range []string{
+		"ExecStart=/usr/local/bin/kk exporter --listen :9796\n",
+		"Environment=HOME=/root\n",
+		"Restart=on-failure\n",
+		"WantedBy=multi-user.target\n",
+	} {
+		if !strings.Contains(string(unit), want) {
+			t.Errorf("unit missing %q:\n%s", want, unit)
+		}
+	}
+	if got := (*runs)[len(*runs)-1].cmd; got != "systemctl restart kk-exporter.service" {
+		t.Fatalf("last command = %q", got)
+	}
+
+	if err := m.RemoveService(context.Background(), "kk-exporter"); err != nil {
+		t.Fatalf("RemoveService() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.UnitDir, "kk-exporter.service")); !os.IsNotExist(err) {
+		t.Fatalf("unit should be removed, stat err = %v", err)
+	}
+	if err := m.RemoveService(context.Background(), "kk-exporter"); err != nil {
+		t.Fatalf("RemoveService() of a missing unit error = %v", err)
+	}
+}
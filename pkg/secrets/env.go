@@ -0,0 +1,82 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SetEnvValues returns the .env content data with the KEY=value line of each
+// key in values replaced, keeping comments and other lines. Keys without a
+// line are appended.
+func SetEnvValues(data []byte, values map[string]string) []byte {
+	lines := strings.Split(string(data), "\n")
+	seen := make(map[string]bool, len(values))
+	for i, line := range lines {
+		key, _, ok := strings.Cut(strings.TrimSpace(line), "=")
+		key = strings.TrimSpace(key)
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		if value, set := values[key]; set {
+			lines[i] = key + "=" + value
+			seen[key] = true
+		}
+	}
+
+	var missing []string
+	for key := range values {
+		if !seen[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	if len(missing) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for _, key := range missing {
+		lines = append(lines, key+"="+values[key])
+	}
+	if len(missing) > 0 {
+		lines = append(lines, "")
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// WriteEnvFile replaces the values in the .env file at path atomically: the
+// new content goes to a temporary file in the same directory, which is
+// synced and renamed over path, so a crash leaves the old file or the new
+// one.
+func WriteEnvFile(path string, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return writeAtomic(path, SetEnvValues(data, values))
+}
+
+func writeAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	// CreateTemp already uses 0600, which .env needs for its secrets.
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		return errors.Join(err, os.Remove(tmp))
+	}
+	return nil
+}
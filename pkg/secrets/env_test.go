@@ -0,0 +1,52 @@
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetEnvValues(t *testing.T) {
+	data := "# Redis\nREDIS_PASSWORD=old\n# DB_PASSWORD=commented\nDB_PASSWORD = old\nTZ=UTC\n"
+	got := string(SetEnvValues([]byte(data), map[string]string{
+		"REDIS_PASSWORD": "new",
+		"DB_PASSWORD":    "newdb",
+		"JWT_SECRET":     "jwt",
+	}))
+	want := "# Redis\nREDIS_PASSWORD=new\n# DB_PASSWORD=commented\nDB_PASSWORD=newdb\nTZ=UTC\nJWT_SECRET=jwt\n"
+	if got != want {
+		t.Errorf("SetEnvValues() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".env")
+	if err := os.WriteFile(path, []byte("REDIS_PASSWORD=old\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteEnvFile(path, map[string]string{"REDIS_PASSWORD": "new"}); err != nil {
+		t.Fatalf("WriteEnvFile() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "REDIS_PASSWORD=new\n" {
+		t.Errorf(".env = %q", data)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf(".env mode = %v, want 0600", info.Mode().Perm())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary file left behind: %v", entries)
+	}
+}
@@ -0,0 +1,253 @@
+// Package secrets rotates the stack credentials inside the running services.
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/backup"
+)
+
+// ServiceExecutor runs a command inside a running service container.
+type ServiceExecutor interface {
+	Exec(ctx context.Context, service string, stdin io.Reader, stdout io.Writer, args ...string) error
+}
+
+// SeaweedFSService is the compose service running the SeaweedFS S3 gateway.
+const SeaweedFSService = "seaweedfs"
+
+// The scripts read the current password from the first stdin line, so no
+// credential shows up in a process list; the commands follow on stdin.
+const (
+	mariadbScript = `read -r MYSQL_PWD; export MYSQL_PWD; exec mariadb -uroot`
+	redisScript   = `read -r REDISCLI_AUTH; export REDISCLI_AUTH; exec redis-cli`
+	weedScript    = `exec weed shell`
+)
+
+// Step changes a credential inside a running service. Undo puts the old
+// value back and is safe to run when the change was only partly applied.
+type Step struct {
+	Name string
+	Do   func(ctx context.Context) error
+	Undo func(ctx context.Context) error
+}
+
+// UndoTimeout bounds a rollback.
+const UndoTimeout = 5 * time.Minute
+
+// UndoContext returns the context to roll back on after work on ctx failed.
+// It is not cancelled with ctx, so a deadline hit by the forward steps does
+// not fail every undo as well.
+func UndoContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), UndoTimeout)
+}
+
+// Rotate runs steps in order. When one fails, it undoes that step and every
+// step before it, in reverse, and returns the failure along with any error
+// met while undoing.
+func Rotate(ctx context.Context, steps []Step) error {
+	for i, step := range steps {
+		if err := step.Do(ctx); err != nil {
+			err = fmt.Errorf("%s: %w", step.Name, err)
+			undoCtx, cancel := UndoContext(ctx)
+			defer cancel()
+			return errors.Join(err, Undo(undoCtx, steps[:i+1]))
+		}
+	}
+	return nil
+}
+
+// Undo reverts steps in reverse order, going on past failures.
+func Undo(ctx context.Context, steps []Step) error {
+	var errs []error
+	for i := len(steps) - 1; i >= 0; i-- {
+		if err := steps[i].Undo(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("undo %s: %w", steps[i].Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DBCredentials are the MariaDB root and application user passwords.
+type DBCredentials struct {
+	RootPassword string
+	Password     string
+}
+
+// MariaDB changes the passwords of root and user with ALTER USER.
+func MariaDB(exec ServiceExecutor, user string, from, to DBCredentials) Step {
+	set := func(ctx context.Context, logins []string, creds DBCredentials) error {
+		sql := fmt.Sprintf("ALTER USER %s@'%%' IDENTIFIED BY %s;\n", sqlQuote(user), sqlQuote(creds.Password)) +
+			fmt.Sprintf("ALTER USER IF EXISTS 'root'@'%%' IDENTIFIED BY %s;\n", sqlQuote(creds.RootPassword)) +
+			fmt.Sprintf("ALTER USER IF EXISTS 'root'@'localhost' IDENTIFIED BY %s;\n", sqlQuote(creds.RootPassword))
+		return withLogin(logins, func(password string) error {
+			return exec.Exec(ctx, backup.DatabaseService, strings.NewReader(password+"\n"+sql), io.Discard, "sh", "-c", mariadbScript)
+		})
+	}
+	return Step{
+		Name: "MariaDB",
+		Do: func(ctx context.Context) error {
+			return set(ctx, []string{from.RootPassword}, to)
+		},
+		// Root may already have the new password.
+		Undo: func(ctx context.Context) error {
+			return set(ctx, []string{to.RootPassword, from.RootPassword}, from)
+		},
+	}
+}
+
+// Redis changes requirepass with CONFIG SET.
+func Redis(exec ServiceExecutor, from, to string) Step {
+	set := func(ctx context.Context, logins []string, password string) error {
+		return withLogin(logins, func(login string) error {
+			var out bytes.Buffer
+			stdin := strings.NewReader(login + "\nCONFIG SET requirepass " + strconv.Quote(password) + "\n")
+			if err := exec.Exec(ctx, backup.RedisService, stdin, &out, "sh", "-c", redisScript); err != nil {
+				return err
+			}
+			// redis-cli exits 0 on error replies.
+			if reply := strings.TrimSpace(out.String()); reply != "OK" {
+				return fmt.Errorf("CONFIG SET requirepass: %s", reply)
+			}
+			return nil
+		})
+	}
+	return Step{
+		Name: "Redis",
+		Do: func(ctx context.Context) error {
+			return set(ctx, []string{from}, to)
+		},
+		Undo: func(ctx context.Context) error {
+			return set(ctx, []string{to, from}, from)
+		},
+	}
+}
+
+// S3Credentials are an S3 access key pair.
+type S3Credentials struct {
+	AccessKey string
+	SecretKey string
+}
+
+// s3Config is the part of the SeaweedFS identity config that s3.configure
+// prints.
+type s3Config struct {
+	Identities []struct {
+		Name        string `json:"name"`
+		Credentials []struct {
+			AccessKey string `json:"accessKey"`
+		} `json:"credentials"`
+	} `json:"identities"`
+}
+
+// identityWith returns the name of the identity holding accessKey.
+func (c s3Config) identityWith(accessKey string) (string, bool) {
+	for _, identity := range c.Identities {
+		for _, cred := range identity.Credentials {
+			if cred.AccessKey == accessKey {
+				return identity.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// SeaweedFSS3 moves the identity holding the old access key to the new key
+// pair with s3.configure in weed shell. When no identity holds the old key,
+// SeaweedFS does not check it (the stack never configured S3 identities), and
+// the step leaves SeaweedFS alone: creating the first identity would switch
+// the gateway from anonymous to authenticated access.
+func SeaweedFSS3(exec ServiceExecutor, from, to S3Credentials) Step {
+	// identity is the name found by Do; Undo has nothing to restore without it.
+	var identity string
+	swap := func(ctx context.Context, add, remove S3Credentials) error {
+		_, err := weedShell(ctx, exec,
+			fmt.Sprintf("s3.configure -user=%s -access_key=%s -secret_key=%s -apply\n", identity, add.AccessKey, add.SecretKey)+
+				fmt.Sprintf("s3.configure -user=%s -access_key=%s -delete -apply\n", identity, remove.AccessKey))
+		return err
+	}
+	return Step{
+		Name: "SeaweedFS S3",
+		Do: func(ctx context.Context) error {
+			out, err := weedShell(ctx, exec, "s3.configure\n")
+			if err != nil {
+				return err
+			}
+			cfg, err := parseS3Config(out)
+			if err != nil {
+				return err
+			}
+			name, ok := cfg.identityWith(from.AccessKey)
+			if !ok {
+				return nil
+			}
+			identity = name
+			return swap(ctx, to, from)
+		},
+		Undo: func(ctx context.Context) error {
+			if identity == "" {
+				return nil
+			}
+			return swap(ctx, from, to)
+		},
+	}
+}
+
+// weedShell runs commands in weed shell and returns what it printed.
+func weedShell(ctx context.Context, exec ServiceExecutor, commands string) (string, error) {
+	var out bytes.Buffer
+	if err := exec.Exec(ctx, SeaweedFSService, strings.NewReader(commands), &out, "sh", "-c", weedScript); err != nil {
+		return "", err
+	}
+	// weed shell prints command errors and exits 0.
+	for _, line := range strings.Split(out.String(), "\n") {
+		if strings.Contains(strings.ToLower(line), "error") {
+			return "", fmt.Errorf("s3.configure: %s", strings.TrimSpace(line))
+		}
+	}
+	return out.String(), nil
+}
+
+// parseS3Config reads the config s3.configure prints, between the shell
+// prompts around it. No config means no identities.
+func parseS3Config(out string) (s3Config, error) {
+	var cfg s3Config
+	start, end := strings.Index(out, "{"), strings.LastIndex(out, "}")
+	if start < 0 || end < start {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(out[start:end+1]), &cfg); err != nil {
+		return cfg, fmt.Errorf("read S3 identities: %w", err)
+	}
+	return cfg, nil
+}
+
+// withLogin runs fn with each distinct non-empty password until one works.
+func withLogin(passwords []string, fn func(password string) error) error {
+	tried := map[string]bool{}
+	var lastErr error
+	for _, password := range passwords {
+		if password == "" || tried[password] {
+			continue
+		}
+		tried[password] = true
+		if lastErr = fn(password); lastErr == nil {
+			return nil
+		}
+	}
+	if lastErr == nil {
+		return errors.New("no current password")
+	}
+	return lastErr
+}
+
+func sqlQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,217 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/backup"
+)
+
+// fakeExec plays the services: it keeps the current password of each and
+// accepts a login only with it.
+type fakeExec struct {
+	passwords map[string]string
+	fail      string // service whose commands fail after login
+	calls     []string
+}
+
+func (f *fakeExec) Exec(_ context.Context, service string, stdin io.Reader, stdout io.Writer, _ ...string) error {
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	login, rest, _ := strings.Cut(string(data), "\n")
+	if service != SeaweedFSService && login != f.passwords[service] {
+		f.calls = append(f.calls, service+" denied")
+		if service == backup.RedisService {
+			_, err = io.WriteString(stdout, "AUTH failed: WRONGPASS\n")
+			return err
+		}
+		return errors.New("Access denied")
+	}
+	f.calls = append(f.calls, service)
+	if service == f.fail {
+		return errors.New("boom")
+	}
+	switch service {
+	case backup.DatabaseService:
+		// The last statement sets root@localhost.
+		f.passwords[service] = between(rest, "'root'@'localhost' IDENTIFIED BY '", "'")
+	case backup.RedisService:
+		f.passwords[service] = between(rest, `requirepass "`, `"`)
+		_, err = io.WriteString(stdout, "OK\n")
+	}
+	return err
+}
+
+func between(s, start, end string) string {
+	_, after, _ := strings.Cut(s, start)
+	value, _, _ := strings.Cut(after, end)
+	return value
+}
+
+func TestRotateUndoesOnFailure(t *testing.T) {
+	exec := &fakeExec{passwords: map[string]string{backup.DatabaseService: "oldroot", backup.RedisService: "oldredis"}, fail: SeaweedFSService}
+	steps := []Step{
+		MariaDB(exec, "kkauto_db", DBCredentials{"oldroot", "olddb"}, DBCredentials{"newroot", "newdb"}),
+		Redis(exec, "oldredis", "newredis"),
+		SeaweedFSS3(exec, S3Credentials{"OLDKEY", "oldsecret"}, S3Credentials{"NEWKEY", "newsecret"}),
+	}
+
+	err := Rotate(context.Background(), steps)
+	if err == nil || !strings.Contains(err.Error(), "SeaweedFS S3: boom") {
+		t.Fatalf("Rotate() error = %v, want the SeaweedFS failure", err)
+	}
+	want := map[string]string{backup.DatabaseService: "oldroot", backup.RedisService: "oldredis"}
+	if !reflect.DeepEqual(exec.passwords, want) {
+		t.Errorf("passwords after rollback = %v, want %v", exec.passwords, want)
+	}
+	// Undo logs in with the new passwords, in reverse order; SeaweedFS failed
+	// before changing anything.
+	wantCalls := []string{"db", "redis", "seaweedfs", "redis", "db"}
+	if !reflect.DeepEqual(exec.calls, wantCalls) {
+		t.Errorf("calls = %v, want %v", exec.calls, wantCalls)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	exec := &fakeExec{passwords: map[string]string{backup.DatabaseService: "oldroot", backup.RedisService: "oldredis"}}
+	steps := []Step{
+		MariaDB(exec, "kkauto_db", DBCredentials{"oldroot", "olddb"}, DBCredentials{"newroot", "newdb"}),
+		Redis(exec, "oldredis", "newredis"),
+	}
+	if err := Rotate(context.Background(), steps); err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	want := map[string]string{backup.DatabaseService: "newroot", backup.RedisService: "newredis"}
+	if !reflect.DeepEqual(exec.passwords, want) {
+		t.Errorf("passwords = %v, want %v", exec.passwords, want)
+	}
+
+	// Undo of a step that never ran falls back to the old login.
+	exec.passwords[backup.RedisService] = "oldredis"
+	if err := Undo(context.Background(), steps[1:]); err != nil {
+		t.Errorf("Undo() error = %v", err)
+	}
+}
+
+func TestRedisRejectsErrorReply(t *testing.T) {
+	exec := &fakeExec{passwords: map[string]string{backup.RedisService: "other"}}
+	err := Redis(exec, "oldredis", "newredis").Do(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("Do() error = %v, want the redis-cli reply", err)
+	}
+}
+
+func TestSQLQuote(t *testing.T) {
+	if got := sqlQuote(`a'b\c`); got != `'a\'b\\c'` {
+		t.Errorf("sqlQuote() = %s", got)
+	}
+}
+
+func TestRotateUndoesAfterDeadline(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var undoErr error
+	step := Step{
+		Name: "slow",
+		Do: func(context.Context) error {
+			cancel()
+			return context.Canceled
+		},
+		Undo: func(ctx context.Context) error {
+			undoErr = ctx.Err()
+			return undoErr
+		},
+	}
+	if err := Rotate(ctx, []Step{step}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Rotate() error = %v, want context.Canceled", err)
+	}
+	if undoErr != nil {
+		t.Errorf("undo ran on a done context: %v", undoErr)
+	}
+}
+
+// fakeWeed plays weed shell with a map of identity to access keys.
+type fakeWeed struct {
+	identities map[string][]string
+	failSwap   bool
+}
+
+func (f *fakeWeed) Exec(_ context.Context, _ string, stdin io.Reader, stdout io.Writer, _ ...string) error {
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if line == "s3.configure" {
+			var b strings.Builder
+			b.WriteString("> {\n  \"identities\": [")
+			for name, keys := range f.identities {
+				b.WriteString(`{"name": "` + name + `", "credentials": [`)
+				for i, key := range keys {
+					if i > 0 {
+						b.WriteString(",")
+					}
+					b.WriteString(`{"accessKey": "` + key + `"}`)
+				}
+				b.WriteString("]}")
+			}
+			b.WriteString("]\n}\n> ")
+			_, err = io.WriteString(stdout, b.String())
+			return err
+		}
+		if f.failSwap {
+			_, err = io.WriteString(stdout, "error: filer unavailable\n")
+			return err
+		}
+		user, key := between(line, "-user=", " "), between(line, "-access_key=", " ")
+		if strings.Contains(line, "-delete") {
+			f.identities[user] = slices.DeleteFunc(f.identities[user], func(k string) bool { return k == key })
+		} else {
+			f.identities[user] = append(f.identities[user], key)
+		}
+	}
+	return nil
+}
+
+func TestSeaweedFSS3(t *testing.T) {
+	from, to := S3Credentials{"OLDKEY", "oldsecret"}, S3Credentials{"NEWKEY", "newsecret"}
+
+	weed := &fakeWeed{identities: map[string][]string{"app": {"OLDKEY"}}}
+	step := SeaweedFSS3(weed, from, to)
+	if err := step.Do(context.Background()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if want := map[string][]string{"app": {"NEWKEY"}}; !reflect.DeepEqual(weed.identities, want) {
+		t.Errorf("identities = %v, want %v", weed.identities, want)
+	}
+	if err := step.Undo(context.Background()); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if want := map[string][]string{"app": {"OLDKEY"}}; !reflect.DeepEqual(weed.identities, want) {
+		t.Errorf("identities after undo = %v, want %v", weed.identities, want)
+	}
+
+	// Without an identity holding the key, S3 stays anonymous.
+	open := &fakeWeed{identities: map[string][]string{}}
+	step = SeaweedFSS3(open, from, to)
+	if err := step.Do(context.Background()); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if err := step.Undo(context.Background()); err != nil {
+		t.Fatalf("Undo() error = %v", err)
+	}
+	if len(open.identities) != 0 {
+		t.Errorf("identities = %v, want none created", open.identities)
+	}
+
+	failing := &fakeWeed{identities: map[string][]string{"app": {"OLDKEY"}}, failSwap: true}
+	if err := SeaweedFSS3(failing, from, to).Do(context.Background()); err == nil || !strings.Contains(err.Error(), "filer unavailable") {
+		t.Errorf("Do() error = %v, want the weed shell error", err)
+	}
+}
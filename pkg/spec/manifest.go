@@ -0,0 +1,141 @@
+package spec
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestDir is the directory in the project where kk records what it last
+// wrote to each generated file: manifest.json with their hashes, and a copy
+// of each under base/ that three-way merges use as the common ancestor.
+const ManifestDir = ".kk"
+
+const manifestName = "manifest.json"
+
+// Manifest records the SHA-256 of each generated file as kk wrote it, so
+// edits made by hand since can be told apart from template changes.
+type Manifest struct {
+	Files map[string]string `json:"files"`
+}
+
+// LoadManifest reads the manifest of projectDir. A project rendered before
+// manifests existed has an empty one.
+func LoadManifest(projectDir string) (*Manifest, error) {
+	m := &Manifest{Files: map[string]string{}}
+	data, err := os.ReadFile(filepath.Join(projectDir, ManifestDir, manifestName))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Join(ManifestDir, manifestName), err)
+	}
+	if m.Files == nil {
+		m.Files = map[string]string{}
+	}
+	return m, nil
+}
+
+// Edited reports whether content differs from what kk last wrote to name.
+// Files kk has no record of are never reported as edited.
+func (m *Manifest) Edited(name string, content []byte) bool {
+	sum, ok := m.Files[name]
+	return ok && sum != hashContent(content)
+}
+
+// BaseDir returns the directory holding the copies of what kk last wrote.
+func BaseDir(projectDir string) string {
+	return filepath.Join(projectDir, ManifestDir, "base")
+}
+
+// LocalEdits returns the generated files in projectDir that were edited by
+// hand since kk wrote them, sorted.
+func LocalEdits(projectDir string) ([]string, error) {
+	m, err := LoadManifest(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	var edited []string
+	for name := range m.Files {
+		data, readErr := os.ReadFile(filepath.Join(projectDir, name))
+		if readErr != nil {
+			if os.IsNotExist(readErr) {
+				continue
+			}
+			return nil, readErr
+		}
+		if m.Edited(name, data) {
+			edited = append(edited, name)
+		}
+	}
+	sort.Strings(edited)
+	return edited, nil
+}
+
+// RewriteBase runs rewrite on the base copies, for changes kk makes to
+// generated files in place (kk update pinning images), so the next plan
+// does not mistake them for local edits. It does nothing when no base
+// copies were recorded.
+func RewriteBase(projectDir string, rewrite func(dir string) error) error {
+	m, err := LoadManifest(projectDir)
+	if err != nil {
+		return err
+	}
+	baseDir := BaseDir(projectDir)
+	if _, err = os.Stat(baseDir); os.IsNotExist(err) {
+		return nil
+	}
+	if err = rewrite(baseDir); err != nil {
+		return err
+	}
+	files := make(map[string][]byte, len(m.Files))
+	for name := range m.Files {
+		data, readErr := os.ReadFile(filepath.Join(baseDir, name))
+		if readErr != nil {
+			return readErr
+		}
+		files[name] = data
+	}
+	return record(projectDir, files)
+}
+
+// record saves files as what kk last wrote, keeping the entries of other
+// files.
+func record(projectDir string, files map[string][]byte) error {
+	m, err := LoadManifest(projectDir)
+	if err != nil {
+		return err
+	}
+	baseDir := BaseDir(projectDir)
+	// The copy of .env holds secrets.
+	if err = os.MkdirAll(baseDir, 0700); err != nil {
+		return err
+	}
+	for name, data := range files {
+		if err = os.WriteFile(filepath.Join(baseDir, name), data, 0600); err != nil {
+			return err
+		}
+		m.Files[name] = hashContent(data)
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(projectDir, ManifestDir, manifestName), append(data, '\n'), 0600)
+}
+
+func readBase(projectDir, name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(BaseDir(projectDir), name))
+}
+
+func hashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,103 @@
+package spec
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// hunk replaces lines [start, end) of the base with lines.
+type hunk struct {
+	start, end int
+	lines      []string
+}
+
+// merge3 applies to ours the changes theirs makes to base, line by line. It
+// returns false when both sides change the same or adjacent lines
+// differently.
+func merge3(base, ours, theirs string) (string, bool) {
+	b := splitLines(base)
+	oursHunks := diffHunks(b, splitLines(ours))
+	theirHunks := diffHunks(b, splitLines(theirs))
+
+	var out []string
+	pos, i, j := 0, 0, 0
+	for i < len(oursHunks) || j < len(theirHunks) {
+		// Start a region at the first hunk and grow it while hunks from
+		// either side touch it.
+		var fromOurs, fromTheirs []hunk
+		var h hunk
+		if j == len(theirHunks) || (i < len(oursHunks) && oursHunks[i].start <= theirHunks[j].start) {
+			h = oursHunks[i]
+			fromOurs = append(fromOurs, h)
+			i++
+		} else {
+			h = theirHunks[j]
+			fromTheirs = append(fromTheirs, h)
+			j++
+		}
+		start, end := h.start, h.end
+		for {
+			if i < len(oursHunks) && oursHunks[i].start <= end {
+				fromOurs = append(fromOurs, oursHunks[i])
+				end = max(end, oursHunks[i].end)
+				i++
+			} else if j < len(theirHunks) && theirHunks[j].start <= end {
+				fromTheirs = append(fromTheirs, theirHunks[j])
+				end = max(end, theirHunks[j].end)
+				j++
+			} else {
+				break
+			}
+		}
+
+		out = append(out, b[pos:start]...)
+		switch {
+		case len(fromTheirs) == 0:
+			out = append(out, applyHunks(b, start, end, fromOurs)...)
+		case len(fromOurs) == 0:
+			out = append(out, applyHunks(b, start, end, fromTheirs)...)
+		default:
+			mine, their := applyHunks(b, start, end, fromOurs), applyHunks(b, start, end, fromTheirs)
+			if !slices.Equal(mine, their) {
+				return "", false
+			}
+			out = append(out, mine...)
+		}
+		pos = end
+	}
+	out = append(out, b[pos:]...)
+	return strings.Join(out, ""), true
+}
+
+func diffHunks(base, side []string) []hunk {
+	var hunks []hunk
+	for _, op := range difflib.NewMatcher(base, side).GetOpCodes() {
+		if op.Tag != 'e' {
+			hunks = append(hunks, hunk{start: op.I1, end: op.I2, lines: side[op.J1:op.J2]})
+		}
+	}
+	return hunks
+}
+
+// applyHunks returns base lines [start, end) with hunks applied.
+func applyHunks(base []string, start, end int, hunks []hunk) []string {
+	var out []string
+	pos := start
+	for _, h := range hunks {
+		out = append(out, base[pos:h.start]...)
+		out = append(out, h.lines...)
+		pos = h.end
+	}
+	return append(out, base[pos:end]...)
+}
+
+// splitLines splits s after each newline, keeping a final unterminated line.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,30 @@
+package spec
+
+import "testing"
+
+func TestMerge3(t *testing.T) {
+	base := "a\nb\nc\nd\ne\n"
+	tests := []struct {
+		name         string
+		ours, theirs string
+		want         string
+		ok           bool
+	}{
+		{"only ours", "a\nB\nc\nd\ne\n", base, "a\nB\nc\nd\ne\n", true},
+		{"only theirs", base, "a\nb\nc\nd\nE\n", "a\nb\nc\nd\nE\n", true},
+		{"separate lines", "a\nB\nc\nd\ne\n", "a\nb\nc\nd\nE\n", "a\nB\nc\nd\nE\n", true},
+		{"insert and delete", "a\nb\nx\nc\nd\ne\n", "b\nc\nd\ne\n", "b\nx\nc\nd\ne\n", true},
+		{"same change", "a\nb\nC\nd\ne\n", "a\nb\nC\nd\ne\n", "a\nb\nC\nd\ne\n", true},
+		{"no trailing newline", "a\nB\nc\nd\ne\n", "a\nb\nc\nd\ne", "a\nB\nc\nd\ne", true},
+		{"conflict", "a\nb\nX\nd\ne\n", "a\nb\nY\nd\ne\n", "", false},
+		{"adjacent changes", "a\nB\nc\nd\ne\n", "a\nb\nC\nd\ne\n", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := merge3(base, tt.ours, tt.theirs)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("merge3() = %q, %t; want %q, %t", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
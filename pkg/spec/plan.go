@@ -0,0 +1,213 @@
+package spec
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/kkauto-net/kk-install/pkg/templates"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+// Change actions.
+const (
+	ActionCreate    = "create"
+	ActionUpdate    = "update"
+	ActionUnchanged = "unchanged"
+	// ActionMerge writes the template changes merged into a file edited by
+	// hand.
+	ActionMerge = "merge"
+	// ActionKeepLocal leaves a file edited by hand as it is and writes the
+	// new template output next to it as <file>.kk-new.
+	ActionKeepLocal = "keep-local"
+)
+
+// NewSuffix is appended to the name of a file kept with ActionKeepLocal for
+// the template output that was not applied.
+const NewSuffix = ".kk-new"
+
+// Change is what applying would do to one generated file.
+type Change struct {
+	File   string `json:"file" yaml:"file"`
+	Action string `json:"action" yaml:"action"`
+	// Edited reports that the file was changed by hand since kk wrote it.
+	Edited bool `json:"edited,omitempty" yaml:"edited,omitempty"`
+	// Conflict reports that the local edits and the template changes touch
+	// the same lines, so they cannot be merged.
+	Conflict bool `json:"conflict,omitempty" yaml:"conflict,omitempty"`
+	// Diff is a unified diff with secret values redacted. It is empty for
+	// unchanged files. For ActionKeepLocal it shows the template changes
+	// that are not applied.
+	Diff string `json:"diff,omitempty" yaml:"diff,omitempty"`
+
+	merged        []byte
+	mergeDiff     string
+	overwriteDiff string
+}
+
+// CanMerge reports whether the local edits and template changes of an
+// edited file merge cleanly.
+func (c *Change) CanMerge() bool {
+	return c.merged != nil
+}
+
+// Merge resolves an edited file by merging the template changes into it.
+func (c *Change) Merge() {
+	if c.CanMerge() {
+		c.Action, c.Diff = ActionMerge, c.mergeDiff
+	}
+}
+
+// KeepLocal resolves an edited file by keeping it as it is.
+func (c *Change) KeepLocal() {
+	c.Action, c.Diff = ActionKeepLocal, c.overwriteDiff
+}
+
+// Overwrite resolves an edited file by replacing it with the template
+// output, dropping the local edits.
+func (c *Change) Overwrite() {
+	c.Action, c.Diff = ActionUpdate, c.overwriteDiff
+}
+
+// Plan compares the files rendered into renderedDir with their counterparts
+// in projectDir, sorted by file name. A file edited by hand since kk wrote
+// it is merged with the template changes when they do not overlap, and
+// kept otherwise.
+func Plan(renderedDir, projectDir string) ([]Change, error) {
+	entries, err := os.ReadDir(renderedDir)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := LoadManifest(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	var changes []Change
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		want, readErr := os.ReadFile(filepath.Join(renderedDir, name))
+		if readErr != nil {
+			return nil, readErr
+		}
+		have, readErr := os.ReadFile(filepath.Join(projectDir, name))
+		change := Change{File: name, Action: ActionUpdate}
+		switch {
+		case os.IsNotExist(readErr):
+			change.Action = ActionCreate
+			have = nil
+		case readErr != nil:
+			return nil, readErr
+		case bytes.Equal(have, want):
+			change.Action = ActionUnchanged
+		}
+		if change.Action != ActionUnchanged {
+			if change.Diff, err = unifiedDiff(name, string(have), string(want)); err != nil {
+				return nil, fmt.Errorf("diff %s: %w", name, err)
+			}
+		}
+		if change.Action == ActionUpdate && manifest.Edited(name, have) {
+			if err = planEdited(&change, projectDir, have, want); err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].File < changes[j].File })
+	return changes, nil
+}
+
+// planEdited turns the update of a file edited by hand into a merge of the
+// template changes, or keeps the file when they overlap.
+func planEdited(c *Change, projectDir string, have, want []byte) error {
+	c.Edited = true
+	c.overwriteDiff = c.Diff
+	base, err := readBase(projectDir, c.File)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	switch {
+	case err == nil && bytes.Equal(base, want):
+		// The template output did not change; only the local edits differ.
+		c.Action, c.Diff = ActionUnchanged, ""
+		return nil
+	case err == nil:
+		if merged, ok := merge3(string(base), string(have), string(want)); ok {
+			if c.mergeDiff, err = unifiedDiff(c.File, string(have), merged); err != nil {
+				return fmt.Errorf("diff %s: %w", c.File, err)
+			}
+			c.merged = []byte(merged)
+			c.Merge()
+			return nil
+		}
+	}
+	c.Conflict = true
+	c.KeepLocal()
+	return nil
+}
+
+// Changed reports whether any change writes a file.
+func Changed(changes []Change) bool {
+	for _, c := range changes {
+		if c.Action != ActionUnchanged {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply writes the changes to projectDir with the permissions kk uses for
+// generated files and records the rendered files in the manifest.
+// Unchanged files are not touched.
+func Apply(changes []Change, renderedDir, projectDir string) error {
+	rendered := make(map[string][]byte, len(changes))
+	for _, c := range changes {
+		data, err := os.ReadFile(filepath.Join(renderedDir, c.File))
+		if err != nil {
+			return err
+		}
+		rendered[c.File] = data
+		path := filepath.Join(projectDir, c.File)
+		switch c.Action {
+		case ActionCreate, ActionUpdate:
+			err = writeGenerated(path, data, c.File)
+		case ActionMerge:
+			err = writeGenerated(path, c.merged, c.File)
+		case ActionKeepLocal:
+			err = writeGenerated(path+NewSuffix, data, c.File)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return record(projectDir, rendered)
+}
+
+func writeGenerated(path string, data []byte, name string) error {
+	mode := templates.FileMode(name)
+	if err := os.WriteFile(path, data, mode); err != nil {
+		return err
+	}
+	// WriteFile keeps the permissions of an existing file.
+	return os.Chmod(path, mode)
+}
+
+func unifiedDiff(name, from, to string) (string, error) {
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: "a/" + name,
+		ToFile:   "b/" + name,
+		Context:  3,
+	})
+	if err != nil {
+		return "", err
+	}
+	return ui.RedactSecrets(diff), nil
+}
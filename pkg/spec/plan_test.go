@@ -0,0 +1,153 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/templates"
+)
+
+func TestPlanAndApply(t *testing.T) {
+	rendered, project := t.TempDir(), t.TempDir()
+	for name, content := range map[string]string{
+		".env":               "TZ=UTC\nDB_PASSWORD=new-password\n",
+		"docker-compose.yml": "services: {}\n",
+		"Caddyfile":          ":80\n",
+	} {
+		if err := os.WriteFile(filepath.Join(rendered, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, content := range map[string]string{
+		".env":               "TZ=UTC\nDB_PASSWORD=old-password\n",
+		"docker-compose.yml": "services: {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(project, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes, err := Plan(rendered, project)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	actions := map[string]string{}
+	for _, c := range changes {
+		actions[c.File] = c.Action
+	}
+	want := map[string]string{".env": ActionUpdate, "Caddyfile": ActionCreate, "docker-compose.yml": ActionUnchanged}
+	for file, action := range want {
+		if actions[file] != action {
+			t.Errorf("%s: action %q, want %q", file, actions[file], action)
+		}
+	}
+	if !Changed(changes) {
+		t.Error("Changed() = false")
+	}
+	envDiff := changes[0].Diff
+	if strings.Contains(envDiff, "password") || !strings.Contains(envDiff, "-DB_PASSWORD=[REDACTED]") {
+		t.Errorf(".env diff leaks or misses the secret change:\n%s", envDiff)
+	}
+
+	if err = Apply(changes, rendered, project); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	info, err := os.Stat(filepath.Join(project, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != templates.EnvFileMode {
+		t.Errorf(".env mode = %v, want %v", info.Mode().Perm(), templates.EnvFileMode)
+	}
+	changes, err = Plan(rendered, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Changed(changes) {
+		t.Errorf("Plan() after Apply() still has changes: %+v", changes)
+	}
+}
+
+func TestPlanMergesLocalEdits(t *testing.T) {
+	rendered, project := t.TempDir(), t.TempDir()
+	write := func(dir, name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const php = "[www]\npm = dynamic\npm.max_children = 5\n\nphp_value[memory_limit] = 256M\n"
+	write(rendered, "kkphp.conf", php)
+	write(rendered, "Caddyfile", ":80 {\n  reverse_proxy kkengine:80\n}\n")
+	changes, err := Plan(rendered, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Apply(changes, rendered, project); err != nil {
+		t.Fatal(err)
+	}
+
+	// The admin tunes kkphp.conf and rewrites the Caddyfile; the templates
+	// then change both files.
+	write(project, "kkphp.conf", "[www]\npm = dynamic\npm.max_children = 50\n\nphp_value[memory_limit] = 256M\n")
+	write(project, "Caddyfile", ":80 {\n  reverse_proxy kkengine:8080\n}\n")
+	write(rendered, "kkphp.conf", "[www]\npm = dynamic\npm.max_children = 5\n\nphp_value[memory_limit] = 512M\n")
+	write(rendered, "Caddyfile", ":80 {\n  reverse_proxy kkengine:9000\n}\n")
+	edited, err := LocalEdits(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edited) != 2 {
+		t.Errorf("LocalEdits() = %v, want both files", edited)
+	}
+
+	changes, err = Plan(rendered, project)
+	if err != nil {
+		t.Fatalf("Plan() error = %v", err)
+	}
+	byFile := map[string]Change{}
+	for _, c := range changes {
+		byFile[c.File] = c
+	}
+	if c := byFile["kkphp.conf"]; c.Action != ActionMerge || !c.Edited || c.Conflict {
+		t.Errorf("kkphp.conf change = %+v, want a clean merge", c)
+	}
+	if c := byFile["Caddyfile"]; c.Action != ActionKeepLocal || !c.Conflict {
+		t.Errorf("Caddyfile change = %+v, want kept with a conflict", c)
+	}
+	if err = Apply(changes, rendered, project); err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+
+	read := func(name string) string {
+		t.Helper()
+		data, readErr := os.ReadFile(filepath.Join(project, name))
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+		return string(data)
+	}
+	if got := read("kkphp.conf"); !strings.Contains(got, "max_children = 50") || !strings.Contains(got, "512M") {
+		t.Errorf("merged kkphp.conf =\n%s", got)
+	}
+	if got := read("Caddyfile"); !strings.Contains(got, "kkengine:8080") {
+		t.Errorf("Caddyfile lost the local edit:\n%s", got)
+	}
+	if got := read("Caddyfile" + NewSuffix); !strings.Contains(got, "kkengine:9000") {
+		t.Errorf("Caddyfile%s =\n%s", NewSuffix, got)
+	}
+
+	// Both edits are now recorded against the new output: planning again
+	// keeps them without asking.
+	changes, err = Plan(rendered, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range changes {
+		if c.Action != ActionUnchanged || !c.Edited {
+			t.Errorf("%s: second plan = %+v, want unchanged with local edits", c.File, c)
+		}
+	}
+}
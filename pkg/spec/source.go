@@ -0,0 +1,89 @@
+package spec
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Source kinds. The zero Source keeps the value already in .env and, for
+// secrets, generates one when there is none.
+const (
+	SourceGenerate = "generate" // Same as the zero Source, spelled out
+	SourceEnv      = "env"      // env:NAME reads an environment variable
+	SourceFile     = "file"     // file:PATH reads a file, e.g. a mounted secret
+)
+
+// Source says where a secret comes from: "generate", "env:NAME" or
+// "file:PATH". Values never appear in kk.yaml itself, so the spec can be
+// committed alongside the playbook that applies it.
+type Source struct {
+	Kind string
+	Ref  string // Variable name or file path
+}
+
+// ParseSource parses a source string.
+func ParseSource(s string) (Source, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == SourceGenerate {
+		return Source{Kind: s}, nil
+	}
+	kind, ref, ok := strings.Cut(s, ":")
+	if !ok || (kind != SourceEnv && kind != SourceFile) || strings.TrimSpace(ref) == "" {
+		return Source{}, fmt.Errorf("invalid source %q (use generate, env:NAME or file:PATH)", s)
+	}
+	return Source{Kind: kind, Ref: strings.TrimSpace(ref)}, nil
+}
+
+// UnmarshalYAML reads a source from its string form.
+func (s *Source) UnmarshalYAML(node *yaml.Node) error {
+	var raw string
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	parsed, err := ParseSource(raw)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// External reports whether the value is read from the environment or a file
+// rather than kept or generated.
+func (s Source) External() bool {
+	return s.Kind == SourceEnv || s.Kind == SourceFile
+}
+
+// String returns the source in its kk.yaml form.
+func (s Source) String() string {
+	if s.External() {
+		return s.Kind + ":" + s.Ref
+	}
+	return s.Kind
+}
+
+// Read returns the value of an external source with surrounding whitespace
+// removed. An unset variable or an empty file is an error.
+func (s Source) Read() (string, error) {
+	var value string
+	switch s.Kind {
+	case SourceEnv:
+		value = os.Getenv(s.Ref)
+	case SourceFile:
+		data, err := os.ReadFile(s.Ref)
+		if err != nil {
+			return "", err
+		}
+		value = string(data)
+	default:
+		return "", fmt.Errorf("source %q has no value to read", s)
+	}
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("%s is empty", s)
+	}
+	return value, nil
+}
@@ -0,0 +1,170 @@
+// Package spec reads kk.yaml, the declarative description of a stack that
+// kk apply renders: which optional services run, the domain, timezone,
+// published ports, resource limits and where the license and secrets come
+// from. Anything the spec leaves out keeps its value from the files already
+// on disk, so applying the same spec twice changes nothing.
+package spec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/kkauto-net/kk-install/pkg/templates"
+)
+
+// FileName is the spec kk apply reads from the current directory by default.
+const FileName = "kk.yaml"
+
+// Spec is the content of kk.yaml.
+type Spec struct {
+	Domain      string               `yaml:"domain"`
+	Timezone    string               `yaml:"timezone"`
+	StackPrefix string               `yaml:"stack_prefix"`
+	Services    Services             `yaml:"services"`
+	Ports       Ports                `yaml:"ports"`
+	DBExposure  string               `yaml:"db_exposure"`
+	Resources   map[string]Resources `yaml:"resources"`
+	License     Source               `yaml:"license"`
+	Secrets     map[string]Source    `yaml:"secrets"`
+}
+
+// Services switches the optional services. Unset switches keep the current
+// state; a new stack has both on, as in kk init.
+type Services struct {
+	SeaweedFS *bool `yaml:"seaweedfs"`
+	Caddy     *bool `yaml:"caddy"`
+}
+
+// Ports are the published host ports. Zero keeps the current port.
+type Ports struct {
+	App   int `yaml:"app"`
+	DB    int `yaml:"db"`
+	HTTP  int `yaml:"http"`
+	HTTPS int `yaml:"https"`
+}
+
+// Resources limits one service, in compose units.
+type Resources struct {
+	CPUs   string `yaml:"cpus"`   // e.g. 1.5
+	Memory string `yaml:"memory"` // e.g. 512m, 2g
+}
+
+// Services that accept resource limits, by compose service name.
+var limitedServices = []string{"kkengine", "db", "redis", "seaweedfs", "caddy"}
+
+// Secret names accepted under secrets:, matching the .env keys they fill.
+var SecretKeys = map[string]string{
+	"jwt_secret":       "JWT_SECRET",
+	"db_password":      "DB_PASSWORD",
+	"db_root_password": "DB_ROOT_PASSWORD",
+	"redis_password":   "REDIS_PASSWORD",
+	"s3_access_key":    "S3_ACCESS_KEY",
+	"s3_secret_key":    "S3_SECRET_KEY",
+}
+
+var memoryPattern = regexp.MustCompile(`^(?i)[0-9]+(\.[0-9]+)?([bkmg]b?)?$`)
+
+// Load reads and validates the spec at path. Unknown keys are errors, so a
+// typo does not silently fall back to a default.
+func Load(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var s Spec
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err = dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err = s.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &s, nil
+}
+
+// Validate checks the values that do not depend on the files on disk.
+func (s *Spec) Validate() error {
+	if s.StackPrefix != "" {
+		if err := templates.ValidateStackPrefix(s.StackPrefix); err != nil {
+			return err
+		}
+	}
+	if _, err := templates.ParseDBExposure(s.DBExposure); err != nil {
+		return err
+	}
+	for _, entry := range []struct {
+		name string
+		port int
+	}{{"app", s.Ports.App}, {"db", s.Ports.DB}, {"http", s.Ports.HTTP}, {"https", s.Ports.HTTPS}} {
+		if entry.port < 0 || entry.port > 65535 {
+			return fmt.Errorf("ports.%s: %d is out of range", entry.name, entry.port)
+		}
+	}
+	for service, r := range s.Resources {
+		if !slices.Contains(limitedServices, service) {
+			return fmt.Errorf("resources.%s: unknown service (use %s)", service, strings.Join(limitedServices, ", "))
+		}
+		if r.CPUs != "" {
+			if cpus, err := strconv.ParseFloat(r.CPUs, 64); err != nil || cpus <= 0 {
+				return fmt.Errorf("resources.%s.cpus: %q is not a positive number", service, r.CPUs)
+			}
+		}
+		if r.Memory != "" && !memoryPattern.MatchString(r.Memory) {
+			return fmt.Errorf("resources.%s.memory: %q is not a size such as 512m or 2g", service, r.Memory)
+		}
+	}
+	if s.License.Kind == SourceGenerate {
+		return errors.New("license: the license cannot be generated (use env: or file:)")
+	}
+	for name := range s.Secrets {
+		if _, ok := SecretKeys[name]; !ok {
+			return fmt.Errorf("secrets.%s: unknown secret", name)
+		}
+	}
+	return nil
+}
+
+// SeaweedFS reports whether SeaweedFS is enabled, current when unset.
+func (s *Spec) SeaweedFS(current bool) bool {
+	if s.Services.SeaweedFS == nil {
+		return current
+	}
+	return *s.Services.SeaweedFS
+}
+
+// Caddy reports whether Caddy is enabled, current when unset.
+func (s *Spec) Caddy(current bool) bool {
+	if s.Services.Caddy == nil {
+		return current
+	}
+	return *s.Services.Caddy
+}
+
+// HostPorts returns the spec's ports; zero fields are left for the caller
+// to fill in.
+func (s *Spec) HostPorts() templates.HostPorts {
+	return templates.HostPorts{App: s.Ports.App, DB: s.Ports.DB, HTTP: s.Ports.HTTP, HTTPS: s.Ports.HTTPS}
+}
+
+// TemplateResources returns the limits in the form templates.Config takes,
+// or nil when the spec has no resources block.
+func (s *Spec) TemplateResources() map[string]templates.Resources {
+	if len(s.Resources) == 0 {
+		return nil
+	}
+	out := make(map[string]templates.Resources, len(s.Resources))
+	for service, r := range s.Resources {
+		out[service] = templates.Resources{CPUs: r.CPUs, Memory: strings.ToLower(r.Memory)}
+	}
+	return out
+}
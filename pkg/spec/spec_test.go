@@ -0,0 +1,111 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/templates"
+)
+
+func writeSpec(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	s, err := Load(writeSpec(t, `domain: example.com
+services:
+  caddy: false
+ports:
+  https: 8443
+db_exposure: localhost
+resources:
+  db: {cpus: 0.5, memory: 1G}
+license: file:/etc/kk/license
+secrets:
+  db_password: env:DB_PASS
+  jwt_secret: generate
+`))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !s.SeaweedFS(true) || s.SeaweedFS(false) || s.Caddy(true) {
+		t.Errorf("services: seaweedfs=%t/%t caddy=%t, want current and false", s.SeaweedFS(true), s.SeaweedFS(false), s.Caddy(true))
+	}
+	if got := s.HostPorts(); got != (templates.HostPorts{HTTPS: 8443}) {
+		t.Errorf("HostPorts() = %+v", got)
+	}
+	if got := s.TemplateResources()["db"]; got != (templates.Resources{CPUs: "0.5", Memory: "1g"}) {
+		t.Errorf("db resources = %+v", got)
+	}
+	if s.License != (Source{Kind: SourceFile, Ref: "/etc/kk/license"}) {
+		t.Errorf("license = %+v", s.License)
+	}
+	if s.Secrets["db_password"] != (Source{Kind: SourceEnv, Ref: "DB_PASS"}) || s.Secrets["jwt_secret"].External() {
+		t.Errorf("secrets = %+v", s.Secrets)
+	}
+}
+
+func TestLoadEmptyUsesDefaults(t *testing.T) {
+	s, err := Load(writeSpec(t, ""))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !s.SeaweedFS(true) || s.Caddy(false) || s.TemplateResources() != nil {
+		t.Errorf("empty spec = %+v", s)
+	}
+}
+
+func TestLoadRejectsInvalidSpecs(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":      "domian: example.com\n",
+		"unknown service":  "resources:\n  mysql: {memory: 1g}\n",
+		"bad cpus":         "resources:\n  db: {cpus: -1}\n",
+		"bad memory":       "resources:\n  db: {memory: lots}\n",
+		"bad port":         "ports:\n  app: 70000\n",
+		"bad exposure":     "db_exposure: everywhere\n",
+		"bad prefix":       "stack_prefix: 'my stack'\n",
+		"bad source":       "secrets:\n  db_password: vault:kk/db\n",
+		"unknown secret":   "secrets:\n  api_token: generate\n",
+		"generate license": "license: generate\n",
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Load(writeSpec(t, content)); err == nil {
+				t.Fatalf("Load(%q) succeeded", content)
+			}
+		})
+	}
+}
+
+func TestSourceRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("  from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KK_SPEC_SECRET", "from-env")
+	t.Setenv("KK_SPEC_EMPTY", "")
+
+	for src, want := range map[Source]string{
+		{Kind: SourceFile, Ref: path}:            "from-file",
+		{Kind: SourceEnv, Ref: "KK_SPEC_SECRET"}: "from-env",
+	} {
+		if got, err := src.Read(); err != nil || got != want {
+			t.Errorf("%s.Read() = %q, %v; want %q", src, got, err, want)
+		}
+	}
+	for _, src := range []Source{
+		{Kind: SourceEnv, Ref: "KK_SPEC_EMPTY"},
+		{Kind: SourceFile, Ref: filepath.Join(t.TempDir(), "missing")},
+		{Kind: SourceGenerate},
+	} {
+		if _, err := src.Read(); err == nil {
+			t.Errorf("%s.Read() succeeded", src)
+		}
+	}
+}
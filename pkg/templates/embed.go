@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 	"text/template"
 )
 
@@ -35,6 +38,198 @@ type Config struct {
 	// S3 (only used when EnableSeaweedFS)
 	S3AccessKey string
 	S3SecretKey string
+
+	// Images: floating reference -> locked digest (from kk.lock)
+	ImagePins map[string]string
+
+	// Naming: containers are <StackPrefix>_<name>, the network <StackPrefix>_net.
+	// Empty means DefaultStackPrefix.
+	StackPrefix string
+
+	// Host ports published by the stack. Zero fields use DefaultHostPorts.
+	Ports HostPorts
+
+	// DBExposure controls whether MariaDB is published on the host.
+	// Empty means DBExposureInternal.
+	DBExposure string
+
+	// Podman renders for Podman, which labels host mounts for SELinux.
+	Podman bool
+
+	// Resources caps services by compose service name (kkengine, db, ...).
+	// Services without an entry are not limited.
+	Resources map[string]Resources
+
+	// OverrideDir holds user templates, named like the embedded ones
+	// (Caddyfile.tmpl, kkphp.conf.tmpl, ...), that replace them. Empty
+	// means none.
+	OverrideDir string
+}
+
+// OverridesDirName is the directory of user templates in a project.
+const OverridesDirName = "overrides"
+
+// outputs maps each template to the file it renders.
+var outputs = map[string]string{
+	"docker-compose.yml": "docker-compose.yml",
+	"env":                ".env",
+	"kkphp.conf":         "kkphp.conf",
+	"Caddyfile":          "Caddyfile",
+	"kkfiler.toml":       "kkfiler.toml",
+}
+
+// Resources are the CPU and memory limits of one service.
+type Resources struct {
+	CPUs   string // e.g. "1.5"
+	Memory string // e.g. "512m", "2g"
+}
+
+// Limits returns the resource limits of a compose service.
+func (c Config) Limits(service string) Resources {
+	return c.Resources[service]
+}
+
+// MariaDB exposure profiles.
+const (
+	DBExposureInternal  = "internal"  // reachable only on the stack network
+	DBExposureLocalhost = "localhost" // published on 127.0.0.1
+	DBExposurePublic    = "public"    // published on all interfaces
+)
+
+// ParseDBExposure validates an exposure profile. Empty means internal.
+func ParseDBExposure(s string) (string, error) {
+	switch s {
+	case "", DBExposureInternal:
+		return DBExposureInternal, nil
+	case DBExposureLocalhost, DBExposurePublic:
+		return s, nil
+	default:
+		return "", fmt.Errorf("invalid database exposure %q (use internal, localhost or public)", s)
+	}
+}
+
+// DBPortMapping returns the MariaDB ports entry for the exposure profile, or
+// "" when the database is not published.
+func (c Config) DBPortMapping() string {
+	switch c.DBExposure {
+	case DBExposureLocalhost:
+		return fmt.Sprintf("127.0.0.1:%d:3306", c.HostPorts().DB)
+	case DBExposurePublic:
+		return fmt.Sprintf("%d:3306", c.HostPorts().DB)
+	default:
+		return ""
+	}
+}
+
+// HostPorts are the host-side ports published by the generated compose file.
+type HostPorts struct {
+	App   int // kkengine API (container port 8019)
+	DB    int // MariaDB (container port 3306)
+	HTTP  int // Caddy HTTP (container port 80)
+	HTTPS int // Caddy HTTPS (container port 443)
+}
+
+// DefaultStackPrefix is the container name prefix of a default install.
+const DefaultStackPrefix = "kkengine"
+
+// DefaultHostPorts are the ports published by a default install.
+var DefaultHostPorts = HostPorts{App: 8019, DB: 3306, HTTP: 80, HTTPS: 443}
+
+var stackPrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateStackPrefix checks that prefix yields valid Docker container names.
+func ValidateStackPrefix(prefix string) error {
+	if !stackPrefixPattern.MatchString(prefix) {
+		return fmt.Errorf("invalid stack prefix %q: use letters, digits, '_', '.' or '-'", prefix)
+	}
+	return nil
+}
+
+// WithDefaults returns p with zero ports replaced by DefaultHostPorts.
+func (p HostPorts) WithDefaults() HostPorts {
+	if p.App == 0 {
+		p.App = DefaultHostPorts.App
+	}
+	if p.DB == 0 {
+		p.DB = DefaultHostPorts.DB
+	}
+	if p.HTTP == 0 {
+		p.HTTP = DefaultHostPorts.HTTP
+	}
+	if p.HTTPS == 0 {
+		p.HTTPS = DefaultHostPorts.HTTPS
+	}
+	return p
+}
+
+// Validate checks that the ports the compose file publishes are in range and
+// no port is used twice. The app port is always published, the database port
+// unless dbExposure is internal, and the HTTP and HTTPS ports with Caddy.
+func (p HostPorts) Validate(dbExposure string, enableCaddy bool) error {
+	type entry struct {
+		name string
+		port int
+	}
+	published := []entry{{"app", p.App}}
+	if dbExposure != "" && dbExposure != DBExposureInternal {
+		published = append(published, entry{"db", p.DB})
+	}
+	if enableCaddy {
+		published = append(published, entry{"http", p.HTTP}, entry{"https", p.HTTPS})
+	}
+	seen := map[int]string{}
+	for _, entry := range published {
+		if entry.port < 1 || entry.port > 65535 {
+			return fmt.Errorf("%s port %d is out of range", entry.name, entry.port)
+		}
+		if other, ok := seen[entry.port]; ok {
+			return fmt.Errorf("%s and %s ports are both %d", other, entry.name, entry.port)
+		}
+		seen[entry.port] = entry.name
+	}
+	return nil
+}
+
+// Prefix returns the stack prefix, defaulting to DefaultStackPrefix.
+func (c Config) Prefix() string {
+	if c.StackPrefix == "" {
+		return DefaultStackPrefix
+	}
+	return c.StackPrefix
+}
+
+// ContainerName returns the container name for a stack component (app, db, ...).
+func (c Config) ContainerName(name string) string {
+	return c.Prefix() + "_" + name
+}
+
+// NetworkName returns the name of the stack's bridge network.
+func (c Config) NetworkName() string {
+	return c.Prefix() + "_net"
+}
+
+// HostPorts returns the published host ports with defaults applied.
+func (c Config) HostPorts() HostPorts {
+	return c.Ports.WithDefaults()
+}
+
+// Image returns ref pinned to its locked digest, or ref unchanged when it is not locked.
+func (c Config) Image(ref string) string {
+	if digest, ok := c.ImagePins[ref]; ok && digest != "" {
+		return ref + "@" + digest
+	}
+	return ref
+}
+
+// EnvFileMode is applied to the rendered .env (owner read/write only)
+const EnvFileMode os.FileMode = 0600
+
+// FileMode returns the permission kk uses for a generated project file.
+func FileMode(outputName string) os.FileMode {
+	if outputName == ".env" {
+		return EnvFileMode
+	}
+	return 0644
 }
 
 // Secret length requirements
@@ -70,9 +265,10 @@ func (c Config) ValidateSecrets() error {
 	return nil
 }
 
-// RenderTemplate renders a single template file
+// RenderTemplate renders a single template file, from cfg.OverrideDir when
+// it has a template of that name
 func RenderTemplate(name string, cfg Config, outputPath string) error {
-	tmplContent, err := templateFS.ReadFile(name + ".tmpl")
+	tmplContent, err := readTemplate(name, cfg.OverrideDir)
 	if err != nil {
 		return err
 	}
@@ -108,20 +304,14 @@ func RenderAll(cfg Config, targetDir string) error {
 		return errors.New("invalid config: " + err.Error())
 	}
 
-	files := map[string]string{
-		"docker-compose.yml": "docker-compose.yml",
-		"env":                ".env",
-		"kkphp.conf":         "kkphp.conf",
-	}
-
-	if cfg.EnableCaddy {
-		files["Caddyfile"] = "Caddyfile"
-	}
-	if cfg.EnableSeaweedFS {
-		files["kkfiler.toml"] = "kkfiler.toml"
+	if err := checkOverrides(cfg.OverrideDir); err != nil {
+		return err
 	}
 
-	for tmplName, outputName := range files {
+	for tmplName, outputName := range outputs {
+		if (tmplName == "Caddyfile" && !cfg.EnableCaddy) || (tmplName == "kkfiler.toml" && !cfg.EnableSeaweedFS) {
+			continue
+		}
 		outputPath := filepath.Join(targetDir, outputName)
 		if err := RenderTemplate(tmplName, cfg, outputPath); err != nil {
 			return err
@@ -130,9 +320,53 @@ func RenderAll(cfg Config, targetDir string) error {
 
 	// Set .env permissions to 0600 (owner read/write only)
 	envPath := filepath.Join(targetDir, ".env")
-	if err := os.Chmod(envPath, 0600); err != nil {
+	if err := os.Chmod(envPath, EnvFileMode); err != nil {
 		return err
 	}
 
 	return nil
 }
+
+func readTemplate(name, overrideDir string) ([]byte, error) {
+	if overrideDir != "" {
+		data, err := os.ReadFile(filepath.Join(overrideDir, name+".tmpl"))
+		if err == nil || !os.IsNotExist(err) {
+			return data, err
+		}
+	}
+	return templateFS.ReadFile(name + ".tmpl")
+}
+
+// checkOverrides rejects files in dir that would not replace any template,
+// such as a misspelt name.
+func checkOverrides(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name, ok := strings.CutSuffix(entry.Name(), ".tmpl")
+		if _, known := outputs[name]; !ok || !known || entry.IsDir() {
+			return fmt.Errorf("%s/%s does not override a template (use %s)", filepath.Base(dir), entry.Name(), overrideNames())
+		}
+	}
+	return nil
+}
+
+func overrideNames() string {
+	names := make([]string, 0, len(outputs))
+	for name := range outputs {
+		names = append(names, name+".tmpl")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"testing"
@@ -306,7 +307,7 @@ func TestRenderedComposePortsMatchValidatorContracts(t *testing.T) {
 }
 
 func TestRenderedEnvDefaultDBPortMatchesMariaDBPublish(t *testing.T) {
-	composeRendered, err := RenderTemplateToString("docker-compose.yml", Config{Domain: "test.com"})
+	composeRendered, err := RenderTemplateToString("docker-compose.yml", Config{Domain: "test.com", DBExposure: DBExposurePublic})
 	if err != nil {
 		t.Fatalf("Failed to render docker-compose.yml: %v", err)
 	}
@@ -365,6 +366,195 @@ func TestRenderedComposeLicenseHostIdentityMounts(t *testing.T) {
 	}
 }
 
+func TestRenderedComposePodman(t *testing.T) {
+	type service struct {
+		Volumes     []string `yaml:"volumes"`
+		SecurityOpt []string `yaml:"security_opt"`
+	}
+	for _, podman := range []bool{false, true} {
+		rendered, err := RenderTemplateToString("docker-compose.yml", Config{Domain: "test.com", Podman: podman})
+		if err != nil {
+			t.Fatalf("Failed to render docker-compose.yml: %v", err)
+		}
+		var compose struct {
+			Services map[string]service `yaml:"services"`
+		}
+		if err := yaml.Unmarshal([]byte(rendered), &compose); err != nil {
+			t.Fatalf("docker-compose.yml has invalid YAML syntax: %v", err)
+		}
+
+		kkengine := compose.Services["kkengine"]
+		if !stringSliceContains(kkengine.Volumes, "/etc/machine-id:/etc/machine-id:ro") {
+			t.Fatalf("podman=%t: kkengine volumes missing /etc/machine-id: %v", podman, kkengine.Volumes)
+		}
+		if got := stringSliceContains(kkengine.SecurityOpt, "label=disable"); got != podman {
+			t.Fatalf("podman=%t: security_opt = %v", podman, kkengine.SecurityOpt)
+		}
+	}
+}
+
+func TestRenderedComposeResources(t *testing.T) {
+	cfg := Config{
+		Domain:      "test.com",
+		EnableCaddy: true,
+		Resources: map[string]Resources{
+			"kkengine": {CPUs: "1.5", Memory: "1g"},
+			"caddy":    {Memory: "128m"},
+		},
+	}
+	rendered, err := RenderTemplateToString("docker-compose.yml", cfg)
+	if err != nil {
+		t.Fatalf("Failed to render docker-compose.yml: %v", err)
+	}
+
+	var compose struct {
+		Services map[string]struct {
+			CPUs     string `yaml:"cpus"`
+			MemLimit string `yaml:"mem_limit"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal([]byte(rendered), &compose); err != nil {
+		t.Fatalf("docker-compose.yml has invalid YAML syntax: %v", err)
+	}
+
+	for service, want := range map[string][2]string{
+		"kkengine": {"1.5", "1g"},
+		"caddy":    {"", "128m"},
+		"db":       {"", ""},
+	} {
+		got := compose.Services[service]
+		if got.CPUs != want[0] || got.MemLimit != want[1] {
+			t.Errorf("%s: cpus=%q mem_limit=%q, want %q %q", service, got.CPUs, got.MemLimit, want[0], want[1])
+		}
+	}
+}
+
+func TestRenderedComposeImagePins(t *testing.T) {
+	cfg := Config{
+		Domain:    "test.com",
+		ImagePins: map[string]string{"redis:alpine": "sha256:abc"},
+	}
+	rendered, err := RenderTemplateToString("docker-compose.yml", cfg)
+	if err != nil {
+		t.Fatalf("Failed to render docker-compose.yml: %v", err)
+	}
+
+	var compose struct {
+		Services map[string]struct {
+			Image string `yaml:"image"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal([]byte(rendered), &compose); err != nil {
+		t.Fatalf("docker-compose.yml has invalid YAML syntax: %v", err)
+	}
+
+	if got := compose.Services["redis"].Image; got != "redis:alpine@sha256:abc" {
+		t.Errorf("redis image = %q, want pinned digest", got)
+	}
+	if got := compose.Services["kkengine"].Image; got != "kkauto/kkengine:latest" {
+		t.Errorf("kkengine image = %q, want floating tag when not locked", got)
+	}
+}
+
+func TestRenderedComposeStackPrefixAndPorts(t *testing.T) {
+	cfg := Config{
+		Domain:      "test.com",
+		EnableCaddy: true,
+		StackPrefix: "staging",
+		Ports:       HostPorts{App: 9019, DB: 13306, HTTP: 8080},
+		DBExposure:  DBExposurePublic,
+	}
+	rendered, err := RenderTemplateToString("docker-compose.yml", cfg)
+	if err != nil {
+		t.Fatalf("Failed to render docker-compose.yml: %v", err)
+	}
+
+	var compose struct {
+		Services map[string]struct {
+			ContainerName string   `yaml:"container_name"`
+			Ports         []string `yaml:"ports"`
+		} `yaml:"services"`
+		Networks map[string]struct {
+			Name string `yaml:"name"`
+		} `yaml:"networks"`
+	}
+	if err := yaml.Unmarshal([]byte(rendered), &compose); err != nil {
+		t.Fatalf("docker-compose.yml has invalid YAML syntax: %v", err)
+	}
+
+	if got := compose.Services["db"].ContainerName; got != "staging_db" {
+		t.Errorf("db container_name = %q, want staging_db", got)
+	}
+	if got := compose.Networks["kkengine_net"].Name; got != "staging_net" {
+		t.Errorf("network name = %q, want staging_net", got)
+	}
+	if port, err := renderedServiceHostPort(rendered, "kkengine", 8019); err != nil || port != 9019 {
+		t.Errorf("kkengine host port = %d, %v; want 9019", port, err)
+	}
+	if port, err := renderedServiceHostPort(rendered, "db", 3306); err != nil || port != 13306 {
+		t.Errorf("db host port = %d, %v; want 13306", port, err)
+	}
+	if port, err := renderedServiceHostPort(rendered, "caddy", 443); err != nil || port != 443 {
+		t.Errorf("caddy https port = %d, %v; want default 443", port, err)
+	}
+}
+
+func TestHostPortsValidate(t *testing.T) {
+	if err := DefaultHostPorts.Validate(DBExposurePublic, true); err != nil {
+		t.Fatalf("default ports invalid: %v", err)
+	}
+	if err := (HostPorts{App: 8019, DB: 8019, HTTP: 80, HTTPS: 443}).Validate(DBExposureLocalhost, true); err == nil {
+		t.Error("duplicate ports should be rejected")
+	}
+	if err := (HostPorts{App: 70000, DB: 3306, HTTP: 80, HTTPS: 443}).Validate(DBExposureInternal, true); err == nil {
+		t.Error("out of range port should be rejected")
+	}
+	// Ports that are not published can not clash.
+	if err := (HostPorts{App: 3306, DB: 3306, HTTP: 80, HTTPS: 443}).Validate(DBExposureInternal, true); err != nil {
+		t.Errorf("app port on the internal database port rejected: %v", err)
+	}
+	if err := (HostPorts{App: 80, DB: 3306, HTTP: 80, HTTPS: 443}).Validate(DBExposureInternal, false); err != nil {
+		t.Errorf("app port on the HTTP port without Caddy rejected: %v", err)
+	}
+	if err := ValidateStackPrefix("-bad"); err == nil {
+		t.Error("prefix starting with '-' should be rejected")
+	}
+}
+
+func TestRenderedComposeDBExposure(t *testing.T) {
+	tests := []struct {
+		exposure string
+		want     []string
+	}{
+		{"", nil},
+		{DBExposureInternal, nil},
+		{DBExposureLocalhost, []string{"127.0.0.1:13306:3306"}},
+		{DBExposurePublic, []string{"13306:3306"}},
+	}
+	for _, tt := range tests {
+		cfg := Config{Domain: "test.com", Ports: HostPorts{DB: 13306}, DBExposure: tt.exposure}
+		rendered, err := RenderTemplateToString("docker-compose.yml", cfg)
+		if err != nil {
+			t.Fatalf("Failed to render docker-compose.yml: %v", err)
+		}
+		var compose struct {
+			Services map[string]struct {
+				Ports []string `yaml:"ports"`
+			} `yaml:"services"`
+		}
+		if err := yaml.Unmarshal([]byte(rendered), &compose); err != nil {
+			t.Fatalf("docker-compose.yml has invalid YAML syntax: %v", err)
+		}
+		if got := compose.Services["db"].Ports; !slices.Equal(got, tt.want) {
+			t.Errorf("exposure %q: db ports = %v, want %v", tt.exposure, got, tt.want)
+		}
+	}
+
+	if _, err := ParseDBExposure("everywhere"); err == nil {
+		t.Error("unknown exposure should be rejected")
+	}
+}
+
 func TestRenderedEnvDoesNotSetLicenseStateOrOfflineTokenKeys(t *testing.T) {
 	rendered, err := RenderTemplateToString("env", Config{
 		Domain:         "test.com",
@@ -613,3 +803,41 @@ func TestValidateSecrets(t *testing.T) {
 		})
 	}
 }
+
+func TestRenderAllUsesOverrides(t *testing.T) {
+	overrides := t.TempDir()
+	if err := os.WriteFile(filepath.Join(overrides, "kkphp.conf.tmpl"), []byte("[www]\npm.max_children = 64\n; {{.Domain}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{
+		Domain:         "test.example.com",
+		JWTSecret:      "test_jwt_secret_32chars_long!!!!",
+		DBPassword:     "test_db_password_16!",
+		DBRootPassword: "test_root_password!",
+		RedisPassword:  "test_redis_pass_16!",
+		OverrideDir:    overrides,
+	}
+
+	target := t.TempDir()
+	if err := RenderAll(cfg, target); err != nil {
+		t.Fatalf("RenderAll() error = %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(target, "kkphp.conf"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[www]\npm.max_children = 64\n; " + cfg.Domain + "\n"; string(got) != want {
+		t.Errorf("kkphp.conf = %q, want %q", got, want)
+	}
+	compose, err := os.ReadFile(filepath.Join(target, "docker-compose.yml"))
+	if err != nil || !strings.Contains(string(compose), "services:") {
+		t.Errorf("docker-compose.yml was not rendered from the embedded template: %v", err)
+	}
+
+	if err = os.WriteFile(filepath.Join(overrides, "caddyfile.tmpl"), []byte(":80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = RenderAll(cfg, t.TempDir()); err == nil || !strings.Contains(err.Error(), "caddyfile.tmpl") {
+		t.Errorf("RenderAll() with a misspelt override error = %v", err)
+	}
+}
@@ -12,14 +12,21 @@ func PrintConfigSummary(cfg *config.Config) {
 		langDisplay = Msg("lang_vietnamese")
 	}
 
-	projectDir := cfg.ProjectDir
+	projectDir := cfg.ActiveProjectDir()
 	if projectDir == "" {
 		projectDir = Msg("config_not_set")
 	}
+	projectName := Msg("project_default")
+	if p, err := cfg.ActiveProject(); err != nil {
+		projectName = SanitizeError(err)
+	} else if p != nil {
+		projectName = p.Name
+	}
 
 	tableData := pterm.TableData{
 		{Msg("col_setting"), Msg("col_value")},
 		{Msg("config_language"), langDisplay},
+		{Msg("config_project"), projectName},
 		{Msg("config_project_dir"), projectDir},
 		{Msg("config_file_path"), config.ConfigPath()},
 	}
@@ -30,3 +37,34 @@ func PrintConfigSummary(cfg *config.Config) {
 		WithBoxed(true).
 		WithData(tableData))
 }
+
+// PrintProjects lists registered projects, marking the active one.
+func PrintProjects(cfg *config.Config) {
+	active, err := cfg.ActiveProject()
+	if err != nil {
+		ShowWarning(SanitizeError(err))
+	}
+
+	tableData := pterm.TableData{
+		{"", Msg("col_project"), Msg("col_directory"), Msg("col_compose_project")},
+	}
+	if cfg.ProjectDir != "" && cfg.CurrentProject == "" {
+		marker := ""
+		if active == nil && config.SelectedProjectName() == "" {
+			marker = pterm.Green("*")
+		}
+		tableData = append(tableData, []string{marker, Msg("project_default"), cfg.ProjectDir, "-"})
+	}
+	for _, p := range cfg.Projects {
+		marker := ""
+		if active != nil && active.Name == p.Name {
+			marker = pterm.Green("*")
+		}
+		tableData = append(tableData, []string{marker, p.Name, p.Dir, p.ComposeProject})
+	}
+
+	renderTable(pterm.DefaultTable.
+		WithHasHeader(true).
+		WithBoxed(true).
+		WithData(tableData))
+}
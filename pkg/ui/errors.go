@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/pterm/pterm"
+
+	"github.com/kkauto-net/kk-install/pkg/redact"
 )
 
 // ErrorBoxMaxWidth is the maximum width for error box content
@@ -124,12 +126,21 @@ func DockerPermissionSuggestion() (suggestion, command string) {
 
 const maxSanitizedErrorLen = 240
 
-// SanitizeError shortens overly long technical error messages for display.
+// RedactedSecret replaces every value masked by RedactSecrets.
+const RedactedSecret = redact.Placeholder
+
+// RedactSecrets masks secret values in s using the rules SanitizeError applies.
+func RedactSecrets(s string) string {
+	return redact.Secrets(s)
+}
+
+// SanitizeError masks secrets in an error message and shortens overly long
+// technical messages for display.
 func SanitizeError(err error) string {
 	if err == nil {
 		return ""
 	}
-	msg := err.Error()
+	msg := RedactSecrets(err.Error())
 	if len(msg) <= maxSanitizedErrorLen {
 		return msg
 	}
@@ -147,10 +158,11 @@ func IsContainerConflictError(err error) bool {
 		strings.Contains(errMsg, "already in use")
 }
 
-// ContainerConflictSuggestion returns suggestion for container conflict error
-func ContainerConflictSuggestion() (suggestion, command string) {
+// ContainerConflictSuggestion returns suggestion for container conflict error,
+// with a cleanup command for the stack's containers and network.
+func ContainerConflictSuggestion(containers []string, network string) (suggestion, command string) {
 	return Msg("container_conflict_suggestion"),
-		"docker rm -f $(docker ps -aq --filter 'name=kkengine_') 2>/dev/null; docker network rm kkengine_net 2>/dev/null"
+		fmt.Sprintf("docker rm -f %s 2>/dev/null; docker network rm %s 2>/dev/null", strings.Join(containers, " "), network)
 }
 
 // IsNetworkConflictError checks if error is Docker network conflict
@@ -18,6 +18,24 @@ func TestSanitizeError(t *testing.T) {
 	}
 }
 
+func TestRedactSecrets(t *testing.T) {
+	tests := map[string]string{
+		"DB_PASSWORD=hunter2 DB_HOSTNAME=db":              "DB_PASSWORD=[REDACTED] DB_HOSTNAME=db",
+		`redis: requirepass token: "abc def"`:             `redis: requirepass token: [REDACTED]`,
+		"dial mysql://kkauto:s3cr3t@db:3306/kkengine":     "dial mysql://kkauto:[REDACTED]@db:3306/kkengine",
+		"license LICENSE-ABCDEF0123456789 rejected":       "license LICENSE-[REDACTED] rejected",
+		"port 3306 already in use by mariadbd (pid 1234)": "port 3306 already in use by mariadbd (pid 1234)",
+	}
+	for in, want := range tests {
+		if got := RedactSecrets(in); got != want {
+			t.Errorf("RedactSecrets(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if got := SanitizeError(errors.New("JWT_SECRET=abc123")); got != "JWT_SECRET=[REDACTED]" {
+		t.Errorf("SanitizeError() = %q, want redacted secret", got)
+	}
+}
+
 func TestShowWarningfUsesMsg(t *testing.T) {
 	original := GetLanguage()
 	defer SetLanguage(original)
@@ -103,7 +103,7 @@ Next steps:
 	"images_up_to_date":         "All images are up to date",
 	"updates_available":         "Updates available:",
 	"confirm_restart":           "Restart services to run the new version?",
-	"update_cancelled":          "Update cancelled. Images downloaded, run 'kk restart' to apply",
+	"update_cancelled":          "Update cancelled. Images downloaded, run 'kk update' again to apply",
 	"confirm_update_recreate":   "Recreate containers with new images?",
 	"update_recreate_cancelled": "Update cancelled. Images downloaded, containers were not recreated",
 	"recreating":                "Recreating with new images...",
@@ -119,11 +119,11 @@ Next steps:
 	"some_running":              "%d/%d services running",
 	"get_status_failed":         "Failed to get status",
 	"start_summary_success":     "All %d services started successfully",
-	"start_summary_partial":     "%d/%d services started\nCheck logs: docker compose logs",
+	"start_summary_partial":     "%d/%d services started\nCheck logs: kk logs",
 	"restart_summary_success":   "All %d services restarted successfully",
-	"restart_summary_partial":   "%d/%d services restarted\nCheck logs: docker compose logs",
+	"restart_summary_partial":   "%d/%d services restarted\nCheck logs: kk logs",
 	"update_summary_success":    "All %d services updated successfully",
-	"update_summary_partial":    "%d/%d services updated\nCheck logs: docker compose logs",
+	"update_summary_partial":    "%d/%d services updated\nCheck logs: kk logs",
 
 	// Table columns
 	"service_status": "Service Status",
@@ -370,6 +370,7 @@ Next steps:
 	"warn_compose_version_read":          "Warning: cannot read Docker Compose version (%s)",
 	"warn_docker_permissions_fix_failed": "Could not fix Docker permissions: %v",
 	"warn_docker_group_add_failed":       "Could not add user to the docker group: %v",
+	"warn_cleanup_failed":                "Cleanup failed: %v",
 
 	// Runtime display
 	"cmd_start_title":                             "kk start",
@@ -377,7 +378,7 @@ Next steps:
 	"cmd_update_title":                            "kk update",
 	"cmd_restart_title":                           "kk restart",
 	"unhealthy_services_hint":                     "%d service(s) unhealthy: %s",
-	"unhealthy_services_logs":                     "View logs: docker compose logs %s",
+	"unhealthy_services_logs":                     "View logs: kk logs %s",
 	"health_failed_detail":                        "%s: %v",
 	"n8n_access_url":                              "Access n8n at: %s",
 	"n8n_running_at":                              "n8n is running at: %s",
@@ -429,6 +430,8 @@ Next steps:
 	"compose_read_error_suggestion":               "Check file permissions",
 	"compose_no_services":                         "No services defined in docker-compose.yml",
 	"compose_no_services_suggestion":              "Run: kk init",
+	"compose_overlay_container_name":              "Service %s in docker-compose.override.yml has no container_name",
+	"compose_overlay_container_name_suggestion":   "Set container_name on every service the overlay adds, so kk can find its container",
 	"caddy_read_error":                            "Cannot read Caddyfile",
 	"caddy_read_error_suggestion":                 "Check file permissions",
 	"caddy_empty":                                 "Caddyfile is empty",
@@ -437,4 +440,339 @@ Next steps:
 	"port_conflict_suggestion":                    "See details below",
 	"compose_syntax_error_suggestion":             "Check YAML: indentation, colons, quotes",
 	"compose_missing":                             "docker-compose.yml file not found",
+
+	// Backup command
+	"cmd_backup_title":              "kk backup",
+	"backup_desc":                   "Snapshot Stack Data & Config",
+	"step_backup_check":             "Check Stack",
+	"step_backup_images":            "Record Image Digests",
+	"step_backup_data":              "Dump Database & Volumes",
+	"step_backup_archive":           "Write Archive",
+	"backup_failed":                 "Backup Failed",
+	"backup_stack_not_running":      "Services not running: %s",
+	"backup_start_stack_suggestion": "Start the stack first so the database and volumes can be captured consistently",
+	"backup_stack_running":          "All services to back up are running",
+	"backup_recording_images":       "Recording image digests...",
+	"backup_images_recorded":        "Image digests recorded",
+	"backup_copying_configs":        "Copying config files...",
+	"backup_configs_copied":         "Config files copied",
+	"backup_dumping_db":             "Dumping MariaDB...",
+	"backup_db_dumped":              "Database dumped",
+	"backup_archiving_volume":       "Archiving volume %s...",
+	"backup_volume_archived":        "Volume %s archived",
+	"backup_writing_archive":        "Writing archive...",
+	"backup_archive_written":        "Archive written",
+	"backup_complete":               "Backup Complete",
+	"backup_summary":                "Archive: %s\nSize: %s\nSHA-256: %s",
+	"backup_check_disk_suggestion":  "Check free disk space and Docker logs, then run kk backup again",
+
+	// Restore command
+	"cmd_restore_title":                  "kk restore",
+	"restore_desc":                       "Rebuild Stack from Backup",
+	"step_restore_verify":                "Verify Archive",
+	"step_restore_stop":                  "Stop Current Stack",
+	"step_restore_files":                 "Restore Config & Volumes",
+	"step_restore_database":              "Import Database",
+	"step_restore_start":                 "Start Services",
+	"restore_failed":                     "Restore Failed",
+	"restore_verifying":                  "Verifying archive checksums...",
+	"restore_verified":                   "Archive verified",
+	"restore_backup_info":                "Backup from %s (kk %s), services: %s",
+	"restore_invalid_archive_suggestion": "Make sure the file is a complete kk backup archive and was copied without corruption",
+	"restore_stack_running":              "The stack in %s is running",
+	"restore_stack_running_suggestion":   "Stop it first, or rerun with --force to stop it and restore over it",
+	"restore_no_existing_stack":          "No existing stack in the target directory",
+	"restore_stopping":                   "Stopping current stack...",
+	"restore_stopped":                    "Current stack stopped",
+	"restore_writing_configs":            "Restoring config files...",
+	"restore_configs_written":            "Config files restored: %s",
+	"restore_restoring_volume":           "Restoring volume %s...",
+	"restore_volume_restored":            "Volume %s restored",
+	"restore_starting_db":                "Starting database...",
+	"restore_db_ready":                   "Database is ready",
+	"restore_db_not_ready":               "Database did not become healthy",
+	"restore_importing_db":               "Importing database dump...",
+	"restore_db_imported":                "Database imported",
+	"restore_no_database":                "Archive has no database dump, skipping",
+	"restore_image_mismatch":             "%s differs from the backup: recorded %s, this host has %s",
+	"restore_images_mismatch_hint":       "The stack will run the images on this host. To match the backup, pull the recorded digests (docker pull <image>@<digest>) and pin them in the compose file",
+	"restore_images_check_failed":        "Cannot compare images with the backup: %v",
+	"restore_complete":                   "Restore Complete",
+	"restore_complete_box":               "Stack restored into %s",
+	"restore_summary_success":            "Restore complete! %d services running",
+	"restore_summary_partial":            "Restore finished: %d/%d services running",
+
+	// Image lockfile (kk.lock)
+	"lock_written":                 "Images pinned by digest in kk.lock",
+	"lock_write_failed":            "Cannot pin images in kk.lock",
+	"lock_write_failed_suggestion": "Check write permission on the project directory, then run kk update again",
+	"lock_read_failed":             "Cannot read kk.lock",
+	"lock_drift_title":             "Images drift from kk.lock",
+	"lock_drift_message":           "docker-compose.yml does not match the digests pinned in kk.lock:\n%s",
+	"lock_drift_locked":            "%s (locked: %s)",
+	"lock_drift_suggestion":        "Run kk update to re-pin images, kk init to re-render from kk.lock, or start anyway with --allow-drift",
+	"lock_drift_allowed":           "Starting despite image drift from kk.lock (--allow-drift)",
+
+	// Update rollback
+	"cmd_rollback_title":         "kk update --rollback",
+	"rollback_desc":              "Return to the previous images",
+	"step_rollback_review":       "Previous Images",
+	"step_rollback_restore":      "Restore Images",
+	"rollback_none":              "No previous image set to roll back to",
+	"rollback_none_suggestion":   "kk update records the running images before replacing them; nothing has been replaced yet",
+	"rollback_recorded_at":       "Images recorded before the update of %s",
+	"rollback_confirm":           "Recreate services on the previous images?",
+	"rollback_cancelled":         "Rollback cancelled",
+	"rollback_retagging":         "Restoring previous image tags...",
+	"rollback_retagged":          "Previous image tags restored",
+	"rollback_failed":            "Rollback failed",
+	"rollback_failed_suggestion": "The previous images may have been pruned. Check with: docker image ls",
+	"rollback_complete":          "Rolled back to the previous images",
+	"rollback_save_failed":       "Cannot record the running images for rollback",
+	"rollback_auto":              "Services not healthy after update: %s. Rolling back to the previous images...",
+	"rollback_auto_done":         "update rolled back: %s did not become healthy",
+	"rollback_summary_success":   "All %d services running on the previous images",
+	"rollback_summary_partial":   "%d/%d services running on the previous images\nCheck logs: kk logs",
+
+	// Update schedule & history
+	"col_time":                          "Time",
+	"col_trigger":                       "Trigger",
+	"col_outcome":                       "Outcome",
+	"col_images":                        "Images",
+	"col_duration":                      "Duration",
+	"update_delay":                      "Waiting %s before updating (maintenance window spread)",
+	"update_locked":                     "Update already running",
+	"update_locked_suggestion":          "Wait for the running kk update to finish, then check the result",
+	"cmd_update_schedule_title":         "kk update schedule",
+	"update_schedule_desc":              "Unattended Updates",
+	"update_schedule_invalid_window":    "Invalid maintenance window",
+	"update_schedule_window_suggestion": "Use HH:MM-HH:MM in server local time, e.g. --window 02:00-04:00",
+	"update_schedule_installing":        "Installing %s schedule...",
+	"update_schedule_installed":         "Unattended updates scheduled daily between %s (%s)",
+	"update_schedule_removing":          "Removing %s schedule...",
+	"update_schedule_removed":           "Unattended updates unscheduled (%s)",
+	"update_schedule_failed":            "Cannot schedule updates",
+	"update_schedule_failed_suggestion": "Run with sudo for a systemd timer, or use --backend cron",
+	"update_schedule_history_hint":      "Each run is recorded; review them with: kk update history",
+	"cmd_update_history_title":          "kk update history",
+	"update_history_desc":               "Update Runs",
+	"update_history_empty":              "No update runs recorded yet",
+	"update_history_failed":             "Cannot read update history",
+
+	// Output format
+	"invalid_output_format":            "Invalid output format",
+	"invalid_output_format_suggestion": "Use --output table, json or yaml",
+
+	// Event stream
+	"invalid_events_flag":            "Invalid event stream options",
+	"invalid_events_flag_suggestion": "Use --events ndjson, optionally with --events-fd N pointing at an open file descriptor",
+
+	// Named projects
+	"config_project":              "Project",
+	"project_default":             "(default)",
+	"col_project":                 "Project",
+	"col_directory":               "Directory",
+	"col_compose_project":         "Compose project",
+	"project_failed":              "Project command failed",
+	"project_add_not_initialized": "Initialize the directory first",
+	"project_added":               "Project '%s' registered",
+	"project_switched":            "Now using project '%s'",
+	"project_removed":             "Project '%s' unregistered (files and containers were kept)",
+	"project_list_empty":          "No projects registered. Run 'kk init' or 'kk project add NAME'",
+	"project_list_hint":           "List registered projects",
+	"invalid_project_name":        "Invalid project name",
+
+	// Stack layout (names & ports)
+	"group_stack_layout": "Container names & ports",
+	"enter_stack_prefix": "Stack prefix",
+	"stack_prefix_desc":  "Containers are named <prefix>_app, <prefix>_db, ... and the network <prefix>_net",
+	"enter_app_port":     "kkengine API host port",
+	"enter_db_port":      "MariaDB host port",
+	"enter_http_port":    "Caddy HTTP host port",
+	"enter_https_port":   "Caddy HTTPS host port",
+	"invalid_port":       "Enter a port between 1 and 65535",
+
+	// Database exposure
+	"select_db_exposure":         "Publish MariaDB on the host?",
+	"db_exposure_desc":           "Internal keeps the database reachable only by the stack's containers",
+	"db_exposure_internal":       "Internal - not published (recommended)",
+	"db_exposure_localhost":      "Localhost - 127.0.0.1 only",
+	"db_exposure_public":         "Public - all interfaces",
+	"db_published_publicly":      "MariaDB port 3306 is published on all interfaces (0.0.0.0)",
+	"db_published_publicly_hint": "Close it: kk init --db-exposure internal (or localhost), then kk start",
+
+	// Logs
+	"logs_failed":          "Failed to read logs",
+	"logs_unknown_service": "Unknown service '%s'",
+	"logs_usage_hint":      "Check the service names and flags",
+	"kk_logs_command":      "kk logs",
+
+	// Doctor
+	"cmd_doctor_title":             "kk doctor",
+	"doctor_desc":                  "Diagnose Install & Write Support Bundle",
+	"step_doctor_checks":           "Running checks",
+	"step_doctor_collect":          "Collecting stack state and logs",
+	"step_doctor_bundle":           "Writing bundle",
+	"doctor_running_checks":        "Checking Docker, ports and disk...",
+	"doctor_checks_done":           "Checks complete",
+	"doctor_collecting":            "Collecting compose status, images and logs...",
+	"doctor_collected":             "Diagnostics collected",
+	"doctor_bundle_written":        "Support bundle written to %s",
+	"doctor_attach_hint":           "Secrets are masked. Attach the bundle to your support ticket.",
+	"doctor_failed":                "Doctor failed",
+	"doctor_check_project":         "Project directory",
+	"doctor_check_compose_version": "Docker Compose version",
+	"doctor_check_docker_group":    "Docker group",
+	"doctor_check_disk":            "Disk space",
+	"doctor_check_port":            "Port %d (%s)",
+	"doctor_check_compose_ps":      "docker compose ps",
+	"doctor_check_service":         "Service %s",
+	"doctor_check_logs":            "Container logs",
+
+	// Status watch
+	"status_watch_hint":      "Watching Docker events. Press Ctrl+C to stop.",
+	"status_watch_unhealthy": "service %s is unhealthy",
+	"col_restarts":           "Restarts",
+	"col_oom_kills":          "OOM kills",
+	"col_changed":            "Changed",
+
+	// Exporter
+	"exporter_failed":                    "Cannot start the metrics exporter",
+	"exporter_listen_suggestion":         "Check that the --listen address is valid and the port is free",
+	"exporter_serving":                   "Serving Prometheus metrics at %s (Ctrl+C to stop)",
+	"exporter_write_failed":              "Cannot send metrics: %v",
+	"cmd_exporter_install_title":         "kk exporter install",
+	"exporter_install_desc":              "Metrics Exporter Service",
+	"exporter_installing":                "Installing the kk-exporter systemd service...",
+	"exporter_installed":                 "Exporter running on %s and enabled at boot",
+	"exporter_installed_hint":            "Check it with: systemctl status %s",
+	"exporter_removing":                  "Removing the kk-exporter systemd service...",
+	"exporter_removed":                   "Exporter service removed",
+	"exporter_install_failed":            "Cannot install the exporter service",
+	"exporter_install_failed_suggestion": "Run with sudo on a host that uses systemd",
+
+	// Notifications
+	"cmd_notify_test_title":    "kk notify test",
+	"notify_test_desc":         "Test Notifications",
+	"notify_config_invalid":    "Invalid notification settings",
+	"notify_config_suggestion": "Add targets under 'notifications' in ~/.kk/config.yaml (see kk notify --help)",
+	"notify_none_configured":   "No notification targets configured",
+	"notify_sending":           "Sending test notification to %s (%s)...",
+	"notify_sent":              "Delivered to %s",
+	"notify_send_failed":       "Delivery to %s failed: %s",
+	"notify_failed":            "Cannot send notification: %s",
+
+	// Guard
+	"guard_failed":                    "Cannot start kk guard",
+	"guard_audit_suggestion":          "Check that ~/.kk is writable",
+	"guard_audit_failed":              "Cannot write the guard audit log: %s",
+	"guard_output_failed":             "Cannot write the guard output: %s",
+	"guard_watching":                  "Guarding %d services; actions are logged to %s (Ctrl+C to stop)",
+	"guard_log_empty":                 "kk guard has not recorded any actions yet",
+	"cmd_guard_install_title":         "kk guard install",
+	"guard_install_desc":              "Auto-Heal Service",
+	"guard_installing":                "Installing the kk-guard systemd service...",
+	"guard_installed":                 "kk guard running and enabled at boot",
+	"guard_installed_hint":            "Check it with: systemctl status %s",
+	"guard_removing":                  "Removing the kk-guard systemd service...",
+	"guard_removed":                   "kk guard service removed",
+	"guard_install_failed":            "Cannot install the kk guard service",
+	"guard_install_failed_suggestion": "Run with sudo on a host that uses systemd",
+
+	// Docker engine target
+	"invalid_docker_target":            "Invalid Docker engine",
+	"invalid_docker_target_suggestion": "Use either --docker-host (unix://, tcp:// or ssh://user@host) or --context with an existing docker context.",
+	"warn_remote_port_check":           "Could not check ports on the remote Docker host: %v",
+	"doctor_check_engine":              "Docker engine",
+	"doctor_engine_local":              "local",
+
+	// Podman
+	"select_runtime":                        "Container runtime",
+	"select_runtime_desc":                   "Podman runs the stack through its Docker-compatible API socket (for hosts where Docker is not allowed).",
+	"runtime_docker":                        "Docker",
+	"runtime_podman":                        "Podman",
+	"podman_not_installed":                  "Podman is not installed",
+	"podman_not_installed_suggestion":       "Install podman and podman-compose: sudo dnf install -y podman podman-compose",
+	"podman_socket_not_running":             "Podman API socket is not answering (%s)",
+	"podman_socket_not_running_suggestion":  "Enable it: systemctl --user enable --now podman.socket (as root: sudo systemctl enable --now podman.socket)",
+	"podman_socket_stopped":                 "Podman API socket is not running",
+	"podman_compose_not_found":              "Neither podman compose nor podman-compose works",
+	"podman_compose_not_found_suggestion":   "Install podman-compose: sudo dnf install -y podman-compose",
+	"podman_install_unsupported":            "No supported package manager (dnf, yum or apt-get) to install Podman",
+	"podman_install_unsupported_suggestion": "Install podman and podman-compose manually, then run kk init again.",
+	"podman_start_failed":                   "Failed to enable the Podman API socket",
+	"podman_start_failed_suggestion":        "Rootless users need a systemd user session: run sudo loginctl enable-linger $USER and log in again.",
+	"podman_rootless_ports":                 "Rootless Podman cannot publish port %s: unprivileged ports start at %d",
+	"podman_rootless_ports_suggestion":      "Allow it: echo net.ipv4.ip_unprivileged_port_start=%d | sudo tee /etc/sysctl.d/99-kk.conf && sudo sysctl --system, or pick ports above 1024 with kk init --http-port/--https-port.",
+	"podman_not_ready":                      "Podman is not ready",
+	"ask_install_podman":                    "Install Podman now?",
+	"ask_install_podman_desc":               "Installs podman and podman-compose with the system package manager (requires sudo).",
+	"installing_podman":                     "Installing Podman...",
+	"podman_installed":                      "Podman installed",
+	"ask_start_podman":                      "Enable the Podman API socket now?",
+	"starting_podman":                       "Enabling the Podman API socket...",
+	"podman_started":                        "Podman API socket enabled",
+	"doctor_check_runtime":                  "Container runtime",
+
+	// Apply
+	"apply_desc":                       "Apply the stack described in kk.yaml",
+	"apply_spec_invalid":               "Invalid kk.yaml",
+	"apply_spec_invalid_suggestion":    "Fix kk.yaml and run kk apply again. Unknown keys are rejected; secrets take generate, env:NAME or file:PATH.",
+	"apply_license_missing":            "No license key: kk.yaml has no license source and .env has no LICENSE_KEY",
+	"apply_license_missing_suggestion": "Add license: file:/path/to/license (or env:NAME) to kk.yaml",
+	"apply_file_create":                "(new)",
+	"apply_file_update":                "(changed)",
+	"apply_file_unchanged":             "(unchanged)",
+	"apply_no_changes":                 "Stack files match kk.yaml, nothing to change",
+	"apply_dry_run":                    "Dry run: %d file(s) would change, nothing was written",
+	"apply_done":                       "Applied kk.yaml: %d file(s) written",
+	"apply_next_step":                  "Run kk start to recreate the services whose configuration changed",
+
+	// Config set/get/edit
+	"config_unknown_key":         "Unknown setting: %s",
+	"config_key_hint":            "Run 'kk config get' to list the settings",
+	"config_read_only":           "%s is read-only",
+	"config_change_failed":       "Cannot change configuration",
+	"config_no_changes":          "No settings changed",
+	"config_saved":               "%s set to %s",
+	"config_confirm_write":       "Write these changes?",
+	"config_cancelled":           "Cancelled, nothing was written",
+	"config_confirm_restart":     "Restart %s to apply the changes?",
+	"config_restart_hint":        "Restart %s to apply the changes: kk restart",
+	"config_restarting":          "Restarting %s...",
+	"config_restarted":           "Restarted %s",
+	"config_restart_failed_hint": "The files were written; restart the stack to apply them",
+	"config_edit_header":         "# kk settings: change the values, save and close the editor to apply.",
+	"config_editor_hint":         "Set $EDITOR to the editor to use",
+
+	// Local edits of generated files
+	"apply_file_merge":      "(edited by hand, template changes merged in)",
+	"apply_file_keep_local": "(edited by hand, template changes not applied)",
+	"apply_file_edited":     "(unchanged, edited by hand)",
+	"local_edit_prompt":     "%s was edited by hand since kk wrote it. What should kk do?",
+	"local_edit_merge":      "Merge the template changes into my edits",
+	"local_edit_keep":       "Keep my version, save the new output as %s",
+	"local_edit_overwrite":  "Overwrite with the new output (a backup is kept)",
+	"local_edit_kept":       "%s keeps your edits; the new template output is in %s to merge by hand",
+
+	// Secrets rotate
+	"secrets_rotate_desc":       "Rotate stack credentials",
+	"secrets_none_selected":     "No secrets selected",
+	"secrets_select_hint":       "Choose secrets with --db, --redis, --jwt, --s3 or --all",
+	"secrets_external":          "secrets.%s is read from %s in kk.yaml",
+	"secrets_external_hint":     "Change the value at its source and run kk apply",
+	"secrets_missing":           "%s is not set in .env",
+	"secrets_stack_not_running": "The stack is not running, so the new credentials cannot be applied to the services",
+	"secrets_start_hint":        "Start the stack, then rotate again",
+	"secrets_confirm":           "Rotate %s?",
+	"secrets_confirm_recreate":  "This recreates %s.",
+	"secrets_applying":          "Applying new credentials to the services...",
+	"secrets_applied":           "New credentials applied to the services",
+	"secrets_rotate_failed":     "Secret Rotation Failed",
+	"secrets_rolled_back":       "The old credentials were restored; check the services with kk status",
+	"secrets_rotated":           "Rotated %s",
+	"secrets_apply_on_start":    "The stack is stopped; the new values take effect on the next kk start",
+
+	// Config file backups
+	"config_backup_failed": "Cannot backup existing files: %v",
 }
@@ -103,7 +103,7 @@ Bước tiếp theo:
 	"images_up_to_date":         "Tất cả images đã là phiên bản mới nhất",
 	"updates_available":         "Có cập nhật:",
 	"confirm_restart":           "Khởi động lại services để chạy phiên bản mới?",
-	"update_cancelled":          "Hủy cập nhật. Images đã được tải, chạy 'kk restart' để áp dụng",
+	"update_cancelled":          "Hủy cập nhật. Images đã được tải, chạy lại 'kk update' để áp dụng",
 	"confirm_update_recreate":   "Tạo lại containers với images mới?",
 	"update_recreate_cancelled": "Hủy cập nhật. Images đã được tải, containers chưa được tạo lại",
 	"recreating":                "Đang khởi động lại với images mới...",
@@ -119,11 +119,11 @@ Bước tiếp theo:
 	"some_running":              "%d/%d dịch vụ đang chạy",
 	"get_status_failed":         "Không lấy được trạng thái",
 	"start_summary_success":     "Tất cả %d dịch vụ đã khởi động thành công",
-	"start_summary_partial":     "%d/%d dịch vụ đã khởi động\nKiểm tra logs: docker compose logs",
+	"start_summary_partial":     "%d/%d dịch vụ đã khởi động\nKiểm tra logs: kk logs",
 	"restart_summary_success":   "Tất cả %d dịch vụ đã khởi động lại thành công",
-	"restart_summary_partial":   "%d/%d dịch vụ đã khởi động lại\nKiểm tra logs: docker compose logs",
+	"restart_summary_partial":   "%d/%d dịch vụ đã khởi động lại\nKiểm tra logs: kk logs",
 	"update_summary_success":    "Tất cả %d dịch vụ đã cập nhật thành công",
-	"update_summary_partial":    "%d/%d dịch vụ đã cập nhật\nKiểm tra logs: docker compose logs",
+	"update_summary_partial":    "%d/%d dịch vụ đã cập nhật\nKiểm tra logs: kk logs",
 
 	// Table columns
 	"service_status": "Trạng thái dịch vụ",
@@ -370,6 +370,7 @@ Bước tiếp theo:
 	"warn_compose_version_read":          "Cảnh báo: không đọc được phiên bản Docker Compose (%s)",
 	"warn_docker_permissions_fix_failed": "Không sửa được quyền Docker: %v",
 	"warn_docker_group_add_failed":       "Không thêm user vào nhóm docker: %v",
+	"warn_cleanup_failed":                "Dọn dẹp thất bại: %v",
 
 	// Runtime display
 	"cmd_start_title":                             "kk start",
@@ -377,7 +378,7 @@ Bước tiếp theo:
 	"cmd_update_title":                            "kk update",
 	"cmd_restart_title":                           "kk restart",
 	"unhealthy_services_hint":                     "%d dịch vụ không khỏe: %s",
-	"unhealthy_services_logs":                     "Xem logs: docker compose logs %s",
+	"unhealthy_services_logs":                     "Xem logs: kk logs %s",
 	"health_failed_detail":                        "%s: %v",
 	"n8n_access_url":                              "Truy cập n8n tại: %s",
 	"n8n_running_at":                              "n8n đang chạy tại: %s",
@@ -415,28 +416,365 @@ Bước tiếp theo:
 	"docker_sudo_password_hint":                   "Nhập mật khẩu sudo khi được hỏi (thường chỉ một lần).",
 
 	// Validator UserError keys
-	"docker_permission_denied":            "Không có quyền truy cập Docker",
-	"docker_permission_denied_suggestion": "Thêm user vào nhóm docker hoặc dùng sudo",
-	"compose_not_found":                   "Không tìm thấy Docker Compose",
-	"compose_not_found_suggestion":        "Cài Docker Compose v2",
-	"compose_version_old":                 "Docker Compose quá cũ (%s), cần >= v2.0",
-	"compose_version_old_suggestion":      "Cập nhật Docker Compose: https://docs.docker.com/compose/install/",
-	"env_stat_error":                      "Không đọc được thông tin file .env",
-	"env_stat_error_suggestion":           "Kiểm tra quyền truy cập file",
-	"env_parse_error":                     "Không phân tích được file .env",
-	"env_parse_error_suggestion":          "Kiểm tra cú pháp file .env",
-	"env_missing_suggestion":              "Chạy: kk init",
-	"env_missing_vars_suggestion":         "Thêm vào .env: %s",
-	"compose_read_error":                  "Không đọc được docker-compose.yml",
-	"compose_read_error_suggestion":       "Kiểm tra quyền file",
-	"compose_no_services":                 "Không có dịch vụ trong docker-compose.yml",
-	"compose_no_services_suggestion":      "Chạy: kk init",
-	"caddy_read_error":                    "Không đọc được Caddyfile",
-	"caddy_read_error_suggestion":         "Kiểm tra quyền file",
-	"caddy_empty":                         "Caddyfile trống",
-	"caddy_empty_suggestion":              "Chạy: kk init",
-	"port_conflict":                       "Phát hiện xung đột cổng",
-	"port_conflict_suggestion":            "Xem chi tiết bên dưới",
-	"compose_syntax_error_suggestion":     "Kiểm tra YAML: indentation, colons, quotes",
-	"compose_missing":                     "Không tìm thấy file docker-compose.yml",
+	"docker_permission_denied":                  "Không có quyền truy cập Docker",
+	"docker_permission_denied_suggestion":       "Thêm user vào nhóm docker hoặc dùng sudo",
+	"compose_not_found":                         "Không tìm thấy Docker Compose",
+	"compose_not_found_suggestion":              "Cài Docker Compose v2",
+	"compose_version_old":                       "Docker Compose quá cũ (%s), cần >= v2.0",
+	"compose_version_old_suggestion":            "Cập nhật Docker Compose: https://docs.docker.com/compose/install/",
+	"env_stat_error":                            "Không đọc được thông tin file .env",
+	"env_stat_error_suggestion":                 "Kiểm tra quyền truy cập file",
+	"env_parse_error":                           "Không phân tích được file .env",
+	"env_parse_error_suggestion":                "Kiểm tra cú pháp file .env",
+	"env_missing_suggestion":                    "Chạy: kk init",
+	"env_missing_vars_suggestion":               "Thêm vào .env: %s",
+	"compose_read_error":                        "Không đọc được docker-compose.yml",
+	"compose_read_error_suggestion":             "Kiểm tra quyền file",
+	"compose_no_services":                       "Không có dịch vụ trong docker-compose.yml",
+	"compose_no_services_suggestion":            "Chạy: kk init",
+	"compose_overlay_container_name":            "Dịch vụ %s trong docker-compose.override.yml chưa có container_name",
+	"compose_overlay_container_name_suggestion": "Đặt container_name cho mọi dịch vụ mà overlay thêm vào để kk tìm được container",
+	"caddy_read_error":                          "Không đọc được Caddyfile",
+	"caddy_read_error_suggestion":               "Kiểm tra quyền file",
+	"caddy_empty":                               "Caddyfile trống",
+	"caddy_empty_suggestion":                    "Chạy: kk init",
+	"port_conflict":                             "Phát hiện xung đột cổng",
+	"port_conflict_suggestion":                  "Xem chi tiết bên dưới",
+	"compose_syntax_error_suggestion":           "Kiểm tra YAML: indentation, colons, quotes",
+	"compose_missing":                           "Không tìm thấy file docker-compose.yml",
+
+	// Backup command
+	"cmd_backup_title":              "kk backup",
+	"backup_desc":                   "Sao lưu dữ liệu & cấu hình",
+	"step_backup_check":             "Kiểm tra stack",
+	"step_backup_images":            "Ghi nhận image digest",
+	"step_backup_data":              "Sao lưu database & volume",
+	"step_backup_archive":           "Ghi file lưu trữ",
+	"backup_failed":                 "Sao lưu thất bại",
+	"backup_stack_not_running":      "Dịch vụ chưa chạy: %s",
+	"backup_start_stack_suggestion": "Hãy khởi động stack trước để sao lưu database và volume nhất quán",
+	"backup_stack_running":          "Tất cả dịch vụ cần sao lưu đang chạy",
+	"backup_recording_images":       "Đang ghi nhận image digest...",
+	"backup_images_recorded":        "Đã ghi nhận image digest",
+	"backup_copying_configs":        "Đang sao chép file cấu hình...",
+	"backup_configs_copied":         "Đã sao chép file cấu hình",
+	"backup_dumping_db":             "Đang dump MariaDB...",
+	"backup_db_dumped":              "Đã dump database",
+	"backup_archiving_volume":       "Đang sao lưu volume %s...",
+	"backup_volume_archived":        "Đã sao lưu volume %s",
+	"backup_writing_archive":        "Đang ghi file lưu trữ...",
+	"backup_archive_written":        "Đã ghi file lưu trữ",
+	"backup_complete":               "Sao lưu hoàn tất",
+	"backup_summary":                "File: %s\nDung lượng: %s\nSHA-256: %s",
+	"backup_check_disk_suggestion":  "Kiểm tra dung lượng đĩa và log Docker, rồi chạy lại kk backup",
+
+	// Restore command
+	"cmd_restore_title":                  "kk restore",
+	"restore_desc":                       "Khôi phục stack từ bản sao lưu",
+	"step_restore_verify":                "Kiểm tra file sao lưu",
+	"step_restore_stop":                  "Dừng stack hiện tại",
+	"step_restore_files":                 "Khôi phục cấu hình & volume",
+	"step_restore_database":              "Nhập database",
+	"step_restore_start":                 "Khởi động dịch vụ",
+	"restore_failed":                     "Khôi phục thất bại",
+	"restore_verifying":                  "Đang kiểm tra checksum...",
+	"restore_verified":                   "File sao lưu hợp lệ",
+	"restore_backup_info":                "Bản sao lưu lúc %s (kk %s), dịch vụ: %s",
+	"restore_invalid_archive_suggestion": "Đảm bảo file là bản sao lưu kk đầy đủ và không bị hỏng khi sao chép",
+	"restore_stack_running":              "Stack trong %s đang chạy",
+	"restore_stack_running_suggestion":   "Hãy dừng stack trước, hoặc chạy lại với --force để dừng và ghi đè",
+	"restore_no_existing_stack":          "Thư mục đích chưa có stack",
+	"restore_stopping":                   "Đang dừng stack hiện tại...",
+	"restore_stopped":                    "Đã dừng stack hiện tại",
+	"restore_writing_configs":            "Đang khôi phục file cấu hình...",
+	"restore_configs_written":            "Đã khôi phục file cấu hình: %s",
+	"restore_restoring_volume":           "Đang khôi phục volume %s...",
+	"restore_volume_restored":            "Đã khôi phục volume %s",
+	"restore_starting_db":                "Đang khởi động database...",
+	"restore_db_ready":                   "Database đã sẵn sàng",
+	"restore_db_not_ready":               "Database không sẵn sàng",
+	"restore_importing_db":               "Đang nhập dump database...",
+	"restore_db_imported":                "Đã nhập database",
+	"restore_no_database":                "Bản sao lưu không có dump database, bỏ qua",
+	"restore_image_mismatch":             "%s khác với bản sao lưu: đã ghi %s, máy này có %s",
+	"restore_images_mismatch_hint":       "Stack sẽ chạy image có trên máy này. Để khớp bản sao lưu, hãy pull các digest đã ghi (docker pull <image>@<digest>) và ghim chúng trong file compose",
+	"restore_images_check_failed":        "Không thể so sánh image với bản sao lưu: %v",
+	"restore_complete":                   "Khôi phục hoàn tất",
+	"restore_complete_box":               "Đã khôi phục stack vào %s",
+	"restore_summary_success":            "Khôi phục hoàn tất! %d dịch vụ đang chạy",
+	"restore_summary_partial":            "Khôi phục xong: %d/%d dịch vụ đang chạy",
+
+	// Image lockfile (kk.lock)
+	"lock_written":                 "Đã ghim images theo digest trong kk.lock",
+	"lock_write_failed":            "Không thể ghim images trong kk.lock",
+	"lock_write_failed_suggestion": "Kiểm tra quyền ghi thư mục dự án, sau đó chạy lại kk update",
+	"lock_read_failed":             "Không thể đọc kk.lock",
+	"lock_drift_title":             "Images lệch khỏi kk.lock",
+	"lock_drift_message":           "docker-compose.yml không khớp với digest đã ghim trong kk.lock:\n%s",
+	"lock_drift_locked":            "%s (đã khóa: %s)",
+	"lock_drift_suggestion":        "Chạy kk update để ghim lại images, kk init để tạo lại từ kk.lock, hoặc vẫn khởi động với --allow-drift",
+	"lock_drift_allowed":           "Vẫn khởi động dù images lệch khỏi kk.lock (--allow-drift)",
+
+	// Update rollback
+	"cmd_rollback_title":         "kk update --rollback",
+	"rollback_desc":              "Quay lại images trước đó",
+	"step_rollback_review":       "Images trước đó",
+	"step_rollback_restore":      "Khôi phục images",
+	"rollback_none":              "Không có bộ images trước đó để quay lại",
+	"rollback_none_suggestion":   "kk update ghi lại images đang chạy trước khi thay thế; chưa có image nào bị thay thế",
+	"rollback_recorded_at":       "Images được ghi lại trước lần cập nhật lúc %s",
+	"rollback_confirm":           "Khởi tạo lại dịch vụ với images trước đó?",
+	"rollback_cancelled":         "Đã hủy quay lại",
+	"rollback_retagging":         "Đang khôi phục tag images trước đó...",
+	"rollback_retagged":          "Đã khôi phục tag images trước đó",
+	"rollback_failed":            "Quay lại thất bại",
+	"rollback_failed_suggestion": "Images trước đó có thể đã bị xóa. Kiểm tra: docker image ls",
+	"rollback_complete":          "Đã quay lại images trước đó",
+	"rollback_save_failed":       "Không thể ghi lại images đang chạy để quay lại",
+	"rollback_auto":              "Dịch vụ không khỏe sau khi cập nhật: %s. Đang quay lại images trước đó...",
+	"rollback_auto_done":         "đã quay lại bản trước: %s không khỏe sau cập nhật",
+	"rollback_summary_success":   "Tất cả %d dịch vụ đang chạy images trước đó",
+	"rollback_summary_partial":   "%d/%d dịch vụ đang chạy images trước đó\nXem logs: kk logs",
+
+	// Update schedule & history
+	"col_time":                          "Thời gian",
+	"col_trigger":                       "Kích hoạt",
+	"col_outcome":                       "Kết quả",
+	"col_images":                        "Images",
+	"col_duration":                      "Thời lượng",
+	"update_delay":                      "Chờ %s trước khi cập nhật (phân tán trong khung bảo trì)",
+	"update_locked":                     "Đang có một lần cập nhật khác",
+	"update_locked_suggestion":          "Chờ kk update đang chạy hoàn tất, sau đó xem kết quả",
+	"cmd_update_schedule_title":         "kk update schedule",
+	"update_schedule_desc":              "Cập nhật tự động",
+	"update_schedule_invalid_window":    "Khung bảo trì không hợp lệ",
+	"update_schedule_window_suggestion": "Dùng HH:MM-HH:MM theo giờ máy chủ, ví dụ --window 02:00-04:00",
+	"update_schedule_installing":        "Đang cài đặt lịch %s...",
+	"update_schedule_installed":         "Đã lên lịch cập nhật tự động hằng ngày trong khung %s (%s)",
+	"update_schedule_removing":          "Đang gỡ lịch %s...",
+	"update_schedule_removed":           "Đã gỡ lịch cập nhật tự động (%s)",
+	"update_schedule_failed":            "Không thể lên lịch cập nhật",
+	"update_schedule_failed_suggestion": "Chạy với sudo để dùng systemd timer, hoặc dùng --backend cron",
+	"update_schedule_history_hint":      "Mỗi lần chạy đều được ghi lại; xem bằng: kk update history",
+	"cmd_update_history_title":          "kk update history",
+	"update_history_desc":               "Lịch sử cập nhật",
+	"update_history_empty":              "Chưa có lần cập nhật nào được ghi lại",
+	"update_history_failed":             "Không thể đọc lịch sử cập nhật",
+
+	// Output format
+	"invalid_output_format":            "Định dạng đầu ra không hợp lệ",
+	"invalid_output_format_suggestion": "Dùng --output table, json hoặc yaml",
+
+	// Event stream
+	"invalid_events_flag":            "Tùy chọn luồng sự kiện không hợp lệ",
+	"invalid_events_flag_suggestion": "Dùng --events ndjson, có thể kèm --events-fd N trỏ tới một file descriptor đang mở",
+
+	// Named projects
+	"config_project":              "Dự án",
+	"project_default":             "(mặc định)",
+	"col_project":                 "Dự án",
+	"col_directory":               "Thư mục",
+	"col_compose_project":         "Compose project",
+	"project_failed":              "Lệnh dự án thất bại",
+	"project_add_not_initialized": "Hãy khởi tạo thư mục trước",
+	"project_added":               "Đã đăng ký dự án '%s'",
+	"project_switched":            "Đang dùng dự án '%s'",
+	"project_removed":             "Đã hủy đăng ký dự án '%s' (giữ nguyên file và container)",
+	"project_list_empty":          "Chưa có dự án nào. Chạy 'kk init' hoặc 'kk project add NAME'",
+	"project_list_hint":           "Xem các dự án đã đăng ký",
+	"invalid_project_name":        "Tên dự án không hợp lệ",
+
+	// Stack layout (names & ports)
+	"group_stack_layout": "Tên container & cổng",
+	"enter_stack_prefix": "Tiền tố stack",
+	"stack_prefix_desc":  "Container có tên <prefix>_app, <prefix>_db, ... và mạng <prefix>_net",
+	"enter_app_port":     "Cổng host cho kkengine API",
+	"enter_db_port":      "Cổng host cho MariaDB",
+	"enter_http_port":    "Cổng host cho Caddy HTTP",
+	"enter_https_port":   "Cổng host cho Caddy HTTPS",
+	"invalid_port":       "Nhập cổng từ 1 đến 65535",
+
+	// Database exposure
+	"select_db_exposure":         "Mở cổng MariaDB ra máy chủ?",
+	"db_exposure_desc":           "Internal chỉ cho phép các container trong stack truy cập cơ sở dữ liệu",
+	"db_exposure_internal":       "Internal - không mở cổng (khuyến nghị)",
+	"db_exposure_localhost":      "Localhost - chỉ 127.0.0.1",
+	"db_exposure_public":         "Public - mọi giao diện mạng",
+	"db_published_publicly":      "Cổng MariaDB 3306 đang mở trên mọi giao diện mạng (0.0.0.0)",
+	"db_published_publicly_hint": "Đóng cổng: kk init --db-exposure internal (hoặc localhost), sau đó kk start",
+
+	// Logs
+	"logs_failed":          "Không thể đọc logs",
+	"logs_unknown_service": "Dịch vụ '%s' không tồn tại",
+	"logs_usage_hint":      "Kiểm tra tên dịch vụ và các tham số",
+	"kk_logs_command":      "kk logs",
+
+	// Doctor
+	"cmd_doctor_title":             "kk doctor",
+	"doctor_desc":                  "Chẩn đoán cài đặt & tạo gói hỗ trợ",
+	"step_doctor_checks":           "Đang kiểm tra",
+	"step_doctor_collect":          "Thu thập trạng thái stack và logs",
+	"step_doctor_bundle":           "Đang ghi gói chẩn đoán",
+	"doctor_running_checks":        "Đang kiểm tra Docker, cổng và ổ đĩa...",
+	"doctor_checks_done":           "Đã kiểm tra xong",
+	"doctor_collecting":            "Đang thu thập trạng thái compose, images và logs...",
+	"doctor_collected":             "Đã thu thập thông tin chẩn đoán",
+	"doctor_bundle_written":        "Đã ghi gói hỗ trợ vào %s",
+	"doctor_attach_hint":           "Các giá trị bí mật đã được che. Đính kèm gói này vào yêu cầu hỗ trợ.",
+	"doctor_failed":                "Chẩn đoán thất bại",
+	"doctor_check_project":         "Thư mục dự án",
+	"doctor_check_compose_version": "Phiên bản Docker Compose",
+	"doctor_check_docker_group":    "Nhóm docker",
+	"doctor_check_disk":            "Dung lượng ổ đĩa",
+	"doctor_check_port":            "Cổng %d (%s)",
+	"doctor_check_compose_ps":      "docker compose ps",
+	"doctor_check_service":         "Dịch vụ %s",
+	"doctor_check_logs":            "Logs container",
+
+	// Status watch
+	"status_watch_hint":      "Đang theo dõi sự kiện Docker. Nhấn Ctrl+C để dừng.",
+	"status_watch_unhealthy": "dịch vụ %s không khỏe",
+	"col_restarts":           "Khởi động lại",
+	"col_oom_kills":          "Bị OOM kill",
+	"col_changed":            "Thay đổi lúc",
+
+	// Exporter
+	"exporter_failed":                    "Không thể khởi động exporter metrics",
+	"exporter_listen_suggestion":         "Kiểm tra địa chỉ --listen hợp lệ và cổng chưa bị dùng",
+	"exporter_serving":                   "Đang phục vụ metrics Prometheus tại %s (Ctrl+C để dừng)",
+	"exporter_write_failed":              "Không gửi được metrics: %v",
+	"cmd_exporter_install_title":         "kk exporter install",
+	"exporter_install_desc":              "Dịch vụ exporter metrics",
+	"exporter_installing":                "Đang cài dịch vụ systemd kk-exporter...",
+	"exporter_installed":                 "Exporter đang chạy tại %s và tự khởi động cùng hệ thống",
+	"exporter_installed_hint":            "Kiểm tra bằng: systemctl status %s",
+	"exporter_removing":                  "Đang gỡ dịch vụ systemd kk-exporter...",
+	"exporter_removed":                   "Đã gỡ dịch vụ exporter",
+	"exporter_install_failed":            "Không thể cài dịch vụ exporter",
+	"exporter_install_failed_suggestion": "Chạy với sudo trên máy dùng systemd",
+
+	// Notifications
+	"cmd_notify_test_title":    "kk notify test",
+	"notify_test_desc":         "Kiểm tra thông báo",
+	"notify_config_invalid":    "Cấu hình thông báo không hợp lệ",
+	"notify_config_suggestion": "Thêm target trong mục 'notifications' của ~/.kk/config.yaml (xem kk notify --help)",
+	"notify_none_configured":   "Chưa cấu hình target thông báo nào",
+	"notify_sending":           "Đang gửi thông báo thử tới %s (%s)...",
+	"notify_sent":              "Đã gửi tới %s",
+	"notify_send_failed":       "Gửi tới %s thất bại: %s",
+	"notify_failed":            "Không gửi được thông báo: %s",
+
+	// Guard
+	"guard_failed":                    "Không thể khởi động kk guard",
+	"guard_audit_suggestion":          "Kiểm tra quyền ghi thư mục ~/.kk",
+	"guard_audit_failed":              "Không ghi được nhật ký guard: %s",
+	"guard_output_failed":             "Không ghi được kết quả guard: %s",
+	"guard_watching":                  "Đang bảo vệ %d dịch vụ; hành động được ghi vào %s (Ctrl+C để dừng)",
+	"guard_log_empty":                 "kk guard chưa ghi nhận hành động nào",
+	"cmd_guard_install_title":         "kk guard install",
+	"guard_install_desc":              "Dịch vụ tự phục hồi",
+	"guard_installing":                "Đang cài dịch vụ systemd kk-guard...",
+	"guard_installed":                 "kk guard đang chạy và tự khởi động cùng hệ thống",
+	"guard_installed_hint":            "Kiểm tra bằng: systemctl status %s",
+	"guard_removing":                  "Đang gỡ dịch vụ systemd kk-guard...",
+	"guard_removed":                   "Đã gỡ dịch vụ kk guard",
+	"guard_install_failed":            "Không thể cài dịch vụ kk guard",
+	"guard_install_failed_suggestion": "Chạy với sudo trên máy dùng systemd",
+
+	// Docker engine target
+	"invalid_docker_target":            "Docker engine không hợp lệ",
+	"invalid_docker_target_suggestion": "Dùng --docker-host (unix://, tcp:// hoặc ssh://user@host) hoặc --context với một docker context có sẵn.",
+	"warn_remote_port_check":           "Không kiểm tra được port trên Docker host từ xa: %v",
+	"doctor_check_engine":              "Docker engine",
+	"doctor_engine_local":              "máy cục bộ",
+
+	// Podman
+	"select_runtime":                        "Container runtime",
+	"select_runtime_desc":                   "Podman chạy stack qua socket API tương thích Docker (cho máy không được phép dùng Docker).",
+	"runtime_docker":                        "Docker",
+	"runtime_podman":                        "Podman",
+	"podman_not_installed":                  "Podman chưa được cài đặt",
+	"podman_not_installed_suggestion":       "Cài podman và podman-compose: sudo dnf install -y podman podman-compose",
+	"podman_socket_not_running":             "Socket API của Podman không phản hồi (%s)",
+	"podman_socket_not_running_suggestion":  "Bật socket: systemctl --user enable --now podman.socket (với root: sudo systemctl enable --now podman.socket)",
+	"podman_socket_stopped":                 "Socket API của Podman chưa chạy",
+	"podman_compose_not_found":              "Không dùng được podman compose hay podman-compose",
+	"podman_compose_not_found_suggestion":   "Cài podman-compose: sudo dnf install -y podman-compose",
+	"podman_install_unsupported":            "Không có trình quản lý gói được hỗ trợ (dnf, yum hoặc apt-get) để cài Podman",
+	"podman_install_unsupported_suggestion": "Cài podman và podman-compose thủ công rồi chạy lại kk init.",
+	"podman_start_failed":                   "Không bật được socket API của Podman",
+	"podman_start_failed_suggestion":        "Người dùng rootless cần systemd user session: chạy sudo loginctl enable-linger $USER rồi đăng nhập lại.",
+	"podman_rootless_ports":                 "Podman rootless không publish được port %s: port không đặc quyền bắt đầu từ %d",
+	"podman_rootless_ports_suggestion":      "Cho phép: echo net.ipv4.ip_unprivileged_port_start=%d | sudo tee /etc/sysctl.d/99-kk.conf && sudo sysctl --system, hoặc chọn port trên 1024 với kk init --http-port/--https-port.",
+	"podman_not_ready":                      "Podman chưa sẵn sàng",
+	"ask_install_podman":                    "Cài đặt Podman ngay?",
+	"ask_install_podman_desc":               "Cài podman và podman-compose bằng trình quản lý gói của hệ thống (cần sudo).",
+	"installing_podman":                     "Đang cài đặt Podman...",
+	"podman_installed":                      "Đã cài đặt Podman",
+	"ask_start_podman":                      "Bật socket API của Podman ngay?",
+	"starting_podman":                       "Đang bật socket API của Podman...",
+	"podman_started":                        "Đã bật socket API của Podman",
+	"doctor_check_runtime":                  "Container runtime",
+
+	// Apply
+	"apply_desc":                       "Áp dụng stack được mô tả trong kk.yaml",
+	"apply_spec_invalid":               "kk.yaml không hợp lệ",
+	"apply_spec_invalid_suggestion":    "Sửa kk.yaml rồi chạy lại kk apply. Khóa không xác định sẽ bị từ chối; secrets nhận generate, env:NAME hoặc file:PATH.",
+	"apply_license_missing":            "Không có license key: kk.yaml không có nguồn license và .env không có LICENSE_KEY",
+	"apply_license_missing_suggestion": "Thêm license: file:/duong/dan/license (hoặc env:NAME) vào kk.yaml",
+	"apply_file_create":                "(mới)",
+	"apply_file_update":                "(thay đổi)",
+	"apply_file_unchanged":             "(không đổi)",
+	"apply_no_changes":                 "Các file của stack đã khớp kk.yaml, không có gì thay đổi",
+	"apply_dry_run":                    "Chạy thử: %d file sẽ thay đổi, chưa ghi gì",
+	"apply_done":                       "Đã áp dụng kk.yaml: đã ghi %d file",
+	"apply_next_step":                  "Chạy kk start để tạo lại các dịch vụ có cấu hình thay đổi",
+
+	// Config set/get/edit
+	"config_unknown_key":         "Thiết lập không xác định: %s",
+	"config_key_hint":            "Chạy 'kk config get' để xem danh sách thiết lập",
+	"config_read_only":           "%s chỉ đọc, không thể thay đổi",
+	"config_change_failed":       "Không thể thay đổi cấu hình",
+	"config_no_changes":          "Không có thiết lập nào thay đổi",
+	"config_saved":               "Đã đặt %s = %s",
+	"config_confirm_write":       "Ghi các thay đổi này?",
+	"config_cancelled":           "Đã hủy, không ghi gì",
+	"config_confirm_restart":     "Khởi động lại %s để áp dụng thay đổi?",
+	"config_restart_hint":        "Khởi động lại %s để áp dụng thay đổi: kk restart",
+	"config_restarting":          "Đang khởi động lại %s...",
+	"config_restarted":           "Đã khởi động lại %s",
+	"config_restart_failed_hint": "Các tệp đã được ghi; hãy khởi động lại stack để áp dụng",
+	"config_edit_header":         "# Thiết lập kk: sửa giá trị, lưu và đóng trình soạn thảo để áp dụng.",
+	"config_editor_hint":         "Đặt $EDITOR thành trình soạn thảo muốn dùng",
+
+	// Local edits of generated files
+	"apply_file_merge":      "(đã sửa tay, đã gộp thay đổi từ template)",
+	"apply_file_keep_local": "(đã sửa tay, chưa áp dụng thay đổi từ template)",
+	"apply_file_edited":     "(không đổi, đã sửa tay)",
+	"local_edit_prompt":     "%s đã được sửa tay sau khi kk tạo. kk nên làm gì?",
+	"local_edit_merge":      "Gộp thay đổi từ template vào bản đã sửa",
+	"local_edit_keep":       "Giữ bản của tôi, lưu bản mới thành %s",
+	"local_edit_overwrite":  "Ghi đè bằng bản mới (có sao lưu)",
+	"local_edit_kept":       "%s giữ nguyên bản đã sửa; bản mới từ template nằm ở %s để gộp thủ công",
+
+	// Secrets rotate
+	"secrets_rotate_desc":       "Thay đổi thông tin xác thực của stack",
+	"secrets_none_selected":     "Chưa chọn secret nào",
+	"secrets_select_hint":       "Chọn secret bằng --db, --redis, --jwt, --s3 hoặc --all",
+	"secrets_external":          "secrets.%s được đọc từ %s trong kk.yaml",
+	"secrets_external_hint":     "Thay đổi giá trị tại nguồn rồi chạy kk apply",
+	"secrets_missing":           "%s chưa được đặt trong .env",
+	"secrets_stack_not_running": "Stack chưa chạy nên không thể áp dụng thông tin xác thực mới cho các dịch vụ",
+	"secrets_start_hint":        "Khởi động stack rồi thực hiện lại",
+	"secrets_confirm":           "Thay đổi %s?",
+	"secrets_confirm_recreate":  "Thao tác này sẽ tạo lại %s.",
+	"secrets_applying":          "Đang áp dụng thông tin xác thực mới cho các dịch vụ...",
+	"secrets_applied":           "Đã áp dụng thông tin xác thực mới cho các dịch vụ",
+	"secrets_rotate_failed":     "Thay đổi secret thất bại",
+	"secrets_rolled_back":       "Đã khôi phục thông tin xác thực cũ; kiểm tra dịch vụ bằng kk status",
+	"secrets_rotated":           "Đã thay đổi %s",
+	"secrets_apply_on_start":    "Stack đang dừng; giá trị mới sẽ có hiệu lực ở lần kk start tiếp theo",
+
+	// Config file backups
+	"config_backup_failed": "Không thể sao lưu các tệp hiện có: %v",
 }
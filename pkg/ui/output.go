@@ -0,0 +1,84 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pterm/pterm"
+	"gopkg.in/yaml.v3"
+)
+
+// OutputFormat selects how commands print their results.
+type OutputFormat string
+
+const (
+	OutputTable OutputFormat = "table"
+	OutputJSON  OutputFormat = "json"
+	OutputYAML  OutputFormat = "yaml"
+)
+
+var currentOutput = OutputTable
+
+// ParseOutputFormat validates a --output value.
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch f := OutputFormat(s); f {
+	case OutputTable, OutputJSON, OutputYAML:
+		return f, nil
+	case "":
+		return OutputTable, nil
+	default:
+		return "", fmt.Errorf("invalid output format %q (use table, json or yaml)", s)
+	}
+}
+
+// SetOutputFormat sets the output format. In structured modes pterm output
+// (banners, warnings, boxed errors) moves to stderr so stdout stays parseable.
+func SetOutputFormat(f OutputFormat) {
+	currentOutput = f
+	if IsStructuredOutput() {
+		pterm.SetDefaultOutput(os.Stderr)
+	} else {
+		pterm.SetDefaultOutput(os.Stdout)
+	}
+}
+
+// GetOutputFormat returns the current output format.
+func GetOutputFormat() OutputFormat {
+	return currentOutput
+}
+
+// IsStructuredOutput reports whether results are printed as JSON or YAML.
+func IsStructuredOutput() bool {
+	return currentOutput == OutputJSON || currentOutput == OutputYAML
+}
+
+// WriteStructured encodes v to w in the current structured format.
+func WriteStructured(w io.Writer, v any) error {
+	switch currentOutput {
+	case OutputYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+}
+
+// WriteStructuredRecord encodes v as one record of a stream: a single JSON
+// line, or a YAML document starting with "---".
+func WriteStructuredRecord(w io.Writer, v any) error {
+	if currentOutput == OutputYAML {
+		if _, err := io.WriteString(w, "---\n"); err != nil {
+			return err
+		}
+		return WriteStructured(w, v)
+	}
+	return json.NewEncoder(w).Encode(v)
+}
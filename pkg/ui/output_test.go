@@ -0,0 +1,57 @@
+package ui
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, s := range []string{"table", "json", "yaml", ""} {
+		_, err := ParseOutputFormat(s)
+		assert.NoError(t, err, s)
+	}
+	_, err := ParseOutputFormat("xml")
+	assert.Error(t, err)
+}
+
+func TestWriteStructured(t *testing.T) {
+	defer SetOutputFormat(OutputTable)
+	v := []struct {
+		Name string `json:"name" yaml:"name"`
+	}{{Name: "db"}}
+
+	SetOutputFormat(OutputJSON)
+	assert.True(t, IsStructuredOutput())
+	var buf bytes.Buffer
+	require.NoError(t, WriteStructured(&buf, v))
+	assert.Equal(t, "[\n  {\n    \"name\": \"db\"\n  }\n]\n", buf.String())
+
+	SetOutputFormat(OutputYAML)
+	buf.Reset()
+	require.NoError(t, WriteStructured(&buf, v))
+	assert.Equal(t, "- name: db\n", buf.String())
+
+	SetOutputFormat(OutputTable)
+	assert.False(t, IsStructuredOutput())
+}
+
+func TestWriteStructuredRecord(t *testing.T) {
+	defer SetOutputFormat(OutputTable)
+	v := struct {
+		Name string `json:"name" yaml:"name"`
+	}{Name: "db"}
+
+	SetOutputFormat(OutputJSON)
+	var buf bytes.Buffer
+	require.NoError(t, WriteStructuredRecord(&buf, v))
+	require.NoError(t, WriteStructuredRecord(&buf, v))
+	assert.Equal(t, "{\"name\":\"db\"}\n{\"name\":\"db\"}\n", buf.String())
+
+	SetOutputFormat(OutputYAML)
+	buf.Reset()
+	require.NoError(t, WriteStructuredRecord(&buf, v))
+	assert.Equal(t, "---\nname: db\n", buf.String())
+}
@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/pterm/pterm"
+
+	"github.com/kkauto-net/kk-install/pkg/events"
 )
 
 // SimpleSpinner provides basic spinner animation for progress indication.
@@ -96,6 +98,7 @@ func ShowServiceProgress(serviceName, status string) {
 
 // ShowStepHeader displays a step progress indicator (e.g., "Step 1/4: Title").
 func ShowStepHeader(current, total int, title string) {
+	events.StepStarted(current, total, title)
 	stepText := fmt.Sprintf("Step %d/%d", current, total)
 	pterm.DefaultSection.
 		WithLevel(2).
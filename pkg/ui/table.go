@@ -1,6 +1,7 @@
 package ui
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -45,6 +46,48 @@ func PrintUpdatesTable(updates []ImageUpdate) {
 		WithData(tableData))
 }
 
+// UpdateHistoryEntry is one kk update run for display.
+type UpdateHistoryEntry struct {
+	StartedAt string
+	Trigger   string
+	Outcome   string
+	Images    int
+	Health    string
+	Duration  string
+	Error     string
+}
+
+// PrintUpdateHistory displays past kk update runs, newest first, as a boxed table.
+func PrintUpdateHistory(entries []UpdateHistoryEntry) {
+	tableData := pterm.TableData{
+		{Msg("col_time"), Msg("col_trigger"), Msg("col_outcome"), Msg("col_images"), Msg("col_health"), Msg("col_duration")},
+	}
+
+	for _, e := range entries {
+		outcome := e.Outcome
+		switch e.Outcome {
+		case "updated", "up-to-date":
+			outcome = pterm.Green(outcome)
+		case "failed", "rolled-back":
+			outcome = pterm.Red(outcome)
+		case "skipped", "cancelled":
+			outcome = pterm.Yellow(outcome)
+		}
+		tableData = append(tableData, []string{e.StartedAt, e.Trigger, outcome, fmt.Sprintf("%d", e.Images), formatHealth(e.Health), e.Duration})
+	}
+
+	renderTable(pterm.DefaultTable.
+		WithHasHeader(true).
+		WithBoxed(true).
+		WithData(tableData))
+
+	for _, e := range entries {
+		if e.Error != "" {
+			pterm.Println(pterm.Gray(e.StartedAt+": ") + SanitizeError(errors.New(e.Error)))
+		}
+	}
+}
+
 func renderTable(table *pterm.TablePrinter) {
 	if err := table.Render(); err != nil {
 		pterm.Warning.Printfln("failed to render table: %v", err)
@@ -60,6 +103,20 @@ func srenderTable(table *pterm.TablePrinter) string {
 	return strings.TrimSuffix(tableStr, "\n")
 }
 
+// FormatSize renders a byte count with a binary unit (e.g. "12.3 MiB").
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
 func truncateDigest(digest string, maxLen int) string {
 	if len(digest) > maxLen {
 		return digest[:maxLen] + "..."
@@ -180,17 +237,50 @@ func PrintAccessInfo(statuses []monitor.ServiceStatus, domain string) {
 	}
 }
 
-func getServiceURL(name, _ string) string {
+// getServiceURL builds an access URL from the host ports docker reports for
+// a service (e.g. "0.0.0.0:9019->8019/tcp"). Unpublished services get none.
+func getServiceURL(name, ports string) string {
 	switch name {
 	case "kkengine":
-		return "http://localhost:8019"
+		if port := publishedHostPort(ports, 8019); port != "" {
+			return "http://localhost:" + port
+		}
 	case "db":
-		return "localhost:3306"
+		if port := publishedHostPort(ports, 3306); port != "" {
+			return "localhost:" + port
+		}
 	case "caddy":
-		return "http://localhost (HTTPS: https://localhost)"
-	default:
-		return ""
+		httpPort, httpsPort := publishedHostPort(ports, 80), publishedHostPort(ports, 443)
+		if httpPort == "" || httpsPort == "" {
+			return ""
+		}
+		return fmt.Sprintf("%s (HTTPS: %s)", hostURL("http", httpPort, "80"), hostURL("https", httpsPort, "443"))
+	}
+	return ""
+}
+
+// publishedHostPort returns the host port mapped to containerPort in a docker
+// ports string, or "" when it is not published.
+func publishedHostPort(ports string, containerPort int) string {
+	suffix := fmt.Sprintf("->%d/", containerPort)
+	for _, mapping := range strings.Split(ports, ",") {
+		mapping = strings.TrimSpace(mapping)
+		host, _, ok := strings.Cut(mapping, suffix)
+		if !ok {
+			continue
+		}
+		if i := strings.LastIndex(host, ":"); i >= 0 {
+			return host[i+1:]
+		}
+	}
+	return ""
+}
+
+func hostURL(scheme, port, defaultPort string) string {
+	if port == defaultPort {
+		return scheme + "://localhost"
 	}
+	return scheme + "://localhost:" + port
 }
 
 // PrintCommandResult displays service status table with command-specific title and summary.
@@ -28,3 +28,34 @@ func TestPrintAccessInfo(t *testing.T) {
 	}
 	PrintAccessInfo(statuses, "example.com")
 }
+
+func TestFormatSize(t *testing.T) {
+	tests := map[int64]string{
+		512:                    "512 B",
+		2048:                   "2.0 KiB",
+		5 * 1024 * 1024:        "5.0 MiB",
+		3 * 1024 * 1024 * 1024: "3.0 GiB",
+	}
+	for in, want := range tests {
+		if got := FormatSize(in); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestGetServiceURL(t *testing.T) {
+	tests := []struct {
+		name, ports, want string
+	}{
+		{"kkengine", "0.0.0.0:9019->8019/tcp, [::]:9019->8019/tcp", "http://localhost:9019"},
+		{"db", "127.0.0.1:13306->3306/tcp", "localhost:13306"},
+		{"db", "3306/tcp", ""},
+		{"caddy", "0.0.0.0:80->80/tcp, 0.0.0.0:8443->443/tcp", "http://localhost (HTTPS: https://localhost:8443)"},
+		{"redis", "6379/tcp", ""},
+	}
+	for _, tt := range tests {
+		if got := getServiceURL(tt.name, tt.ports); got != tt.want {
+			t.Errorf("getServiceURL(%q, %q) = %q, want %q", tt.name, tt.ports, got, tt.want)
+		}
+	}
+}
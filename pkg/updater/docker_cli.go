@@ -0,0 +1,116 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/kkauto-net/kk-install/pkg/engine"
+)
+
+var execCommand = exec.CommandContext
+
+// dockerSudo reports whether image operations go through sudo docker. Only
+// root may reach the local socket then, and the API client can not use sudo.
+// sudo would drop DOCKER_HOST/DOCKER_CONTEXT, so remote targets stay on the
+// API.
+func dockerSudo() bool {
+	return os.Getenv("KK_DOCKER_SUDO") == "1" && !engine.CurrentTarget().Remote()
+}
+
+type dockerImageInspect struct {
+	ID          string   `json:"Id"`
+	RepoDigests []string `json:"RepoDigests"`
+}
+
+type dockerContainerInspect struct {
+	Image string `json:"Image"`
+}
+
+func inspectImageCLI(ctx context.Context, image string) (ImageIdentity, error) {
+	stdout, stderr, err := runDocker(ctx, "image", "inspect", image)
+	if err != nil {
+		if isNotFoundOutput(stderr) {
+			return missingImageIdentity(image), nil
+		}
+		return missingImageIdentity(image), fmt.Errorf("inspect image %s: %w", image, cliError(stderr, err))
+	}
+
+	var inspected []dockerImageInspect
+	if err = json.Unmarshal(stdout, &inspected); err != nil {
+		return missingImageIdentity(image), fmt.Errorf("parse image inspect %s: %w", image, err)
+	}
+	if len(inspected) == 0 {
+		return missingImageIdentity(image), fmt.Errorf("inspect image %s returned no data", image)
+	}
+
+	value, source := inspectIdentityValue(inspected[0].ID, inspected[0].RepoDigests)
+	if value == "" {
+		return missingImageIdentity(image), fmt.Errorf("image %s has no repo digest or image ID", image)
+	}
+	return ImageIdentity{Image: image, Value: value, ID: inspected[0].ID, Present: true, Source: source}, nil
+}
+
+func inspectContainerCLI(ctx context.Context, container string) (ContainerIdentity, error) {
+	stdout, stderr, err := runDocker(ctx, "container", "inspect", container)
+	if err != nil {
+		if isNotFoundOutput(stderr) {
+			return ContainerIdentity{Container: container}, nil
+		}
+		return ContainerIdentity{Container: container}, fmt.Errorf("inspect container %s: %w", container, cliError(stderr, err))
+	}
+
+	var inspected []dockerContainerInspect
+	if err = json.Unmarshal(stdout, &inspected); err != nil {
+		return ContainerIdentity{Container: container}, fmt.Errorf("parse container inspect %s: %w", container, err)
+	}
+	if len(inspected) == 0 || inspected[0].Image == "" {
+		return ContainerIdentity{Container: container}, fmt.Errorf("container %s has no image ID", container)
+	}
+	return ContainerIdentity{Container: container, ImageID: inspected[0].Image, Present: true}, nil
+}
+
+func pullImageCLI(ctx context.Context, image string) error {
+	if _, stderr, err := runDocker(ctx, "image", "pull", "--quiet", image); err != nil {
+		return fmt.Errorf("pull image %s: %w", image, cliError(stderr, err))
+	}
+	return nil
+}
+
+func tagImageCLI(ctx context.Context, source, target string) error {
+	if _, stderr, err := runDocker(ctx, "image", "tag", source, target); err != nil {
+		return fmt.Errorf("tag image %s: %w", target, cliError(stderr, err))
+	}
+	return nil
+}
+
+// runDocker runs sudo docker with args and returns its output.
+func runDocker(ctx context.Context, args ...string) ([]byte, string, error) {
+	cmd := execCommand(ctx, "sudo", append([]string{"docker"}, args...)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	return stdout.Bytes(), stderr.String(), err
+}
+
+// cliError prefers what docker printed over the bare exit status.
+func cliError(stderr string, err error) error {
+	if message := strings.TrimSpace(stderr); message != "" {
+		return errors.New(message)
+	}
+	return err
+}
+
+func isNotFoundOutput(stderr string) bool {
+	lower := strings.ToLower(stderr)
+	return strings.Contains(lower, "no such image") ||
+		strings.Contains(lower, "no such container") ||
+		strings.Contains(lower, "no such object") ||
+		strings.Contains(lower, "not found")
+}
@@ -1,128 +1,134 @@
 package updater
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"os/exec"
-	"sort"
-	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+
+	"github.com/kkauto-net/kk-install/pkg/engine"
 )
 
-type DockerImageInspector struct {
-	execCommand func(context.Context, string, ...string) *exec.Cmd
+// EngineClient is the Docker Engine API subset DockerImageInspector uses.
+type EngineClient interface {
+	engine.ImagePuller
+	ImageInspect(ctx context.Context, imageID string, opts ...client.ImageInspectOption) (image.InspectResponse, error)
+	ImageTag(ctx context.Context, source, target string) error
+	ContainerInspect(ctx context.Context, containerID string) (container.InspectResponse, error)
 }
 
-type dockerImageInspect struct {
-	ID          string   `json:"Id"`
-	RepoDigests []string `json:"RepoDigests"`
+// DockerImageInspector resolves and moves image identities through the
+// Docker Engine API, or through sudo docker under KK_DOCKER_SUDO=1.
+type DockerImageInspector struct {
+	client func() (EngineClient, error)
+	sudo   func() bool
 }
 
-type dockerContainerInspect struct {
-	Image string `json:"Image"`
+func NewDockerImageInspector() *DockerImageInspector {
+	return &DockerImageInspector{
+		client: func() (EngineClient, error) { return engine.Shared() },
+		sudo:   dockerSudo,
+	}
 }
 
-func NewDockerImageInspector() *DockerImageInspector {
-	return &DockerImageInspector{execCommand: exec.CommandContext}
+// NewDockerImageInspectorWithClient returns an inspector using cli.
+func NewDockerImageInspectorWithClient(cli EngineClient) *DockerImageInspector {
+	return &DockerImageInspector{client: func() (EngineClient, error) { return cli, nil }}
 }
 
 func (i *DockerImageInspector) Inspect(ctx context.Context, image string) (ImageIdentity, error) {
-	cmd := i.execCommand(ctx, "docker", "image", "inspect", image)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		if isImageNotFound(stderr.String()) {
-			return missingImageIdentity(image), nil
-		}
-		message := strings.TrimSpace(stderr.String())
-		if message == "" {
-			return missingImageIdentity(image), fmt.Errorf("inspect image %s: %w", image, err)
-		}
-		return missingImageIdentity(image), fmt.Errorf("inspect image %s: %s", image, message)
+	if i.useSudo() {
+		return inspectImageCLI(ctx, image)
 	}
-
-	var inspected []dockerImageInspect
-	if err := json.Unmarshal(stdout.Bytes(), &inspected); err != nil {
-		return missingImageIdentity(image), fmt.Errorf("parse image inspect %s: %w", image, err)
+	cli, err := i.client()
+	if err != nil {
+		return missingImageIdentity(image), err
 	}
-	if len(inspected) == 0 {
-		return missingImageIdentity(image), fmt.Errorf("inspect image %s returned no data", image)
+	inspected, err := cli.ImageInspect(ctx, image)
+	if err != nil {
+		if engine.IsNotFound(err) {
+			return missingImageIdentity(image), nil
+		}
+		return missingImageIdentity(image), fmt.Errorf("inspect image %s: %w", image, err)
 	}
 
-	value, source := inspectIdentityValue(inspected[0])
+	value, source := inspectIdentityValue(inspected.ID, inspected.RepoDigests)
 	if value == "" {
 		return missingImageIdentity(image), fmt.Errorf("image %s has no repo digest or image ID", image)
 	}
 
-	return ImageIdentity{Image: image, Value: value, ID: inspected[0].ID, Present: true, Source: source}, nil
+	return ImageIdentity{Image: image, Value: value, ID: inspected.ID, Present: true, Source: source}, nil
 }
 
 func (i *DockerImageInspector) InspectContainer(ctx context.Context, container string) (ContainerIdentity, error) {
-	cmd := i.execCommand(ctx, "docker", "container", "inspect", container)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	if err := cmd.Run(); err != nil {
-		if isImageNotFound(stderr.String()) {
+	if i.useSudo() {
+		return inspectContainerCLI(ctx, container)
+	}
+	cli, err := i.client()
+	if err != nil {
+		return ContainerIdentity{Container: container}, err
+	}
+	inspected, err := cli.ContainerInspect(ctx, container)
+	if err != nil {
+		if engine.IsNotFound(err) {
 			return ContainerIdentity{Container: container}, nil
 		}
-		message := strings.TrimSpace(stderr.String())
-		if message == "" {
-			return ContainerIdentity{Container: container}, fmt.Errorf("inspect container %s: %w", container, err)
-		}
-		return ContainerIdentity{Container: container}, fmt.Errorf("inspect container %s: %s", container, message)
+		return ContainerIdentity{Container: container}, fmt.Errorf("inspect container %s: %w", container, err)
 	}
-
-	var inspected []dockerContainerInspect
-	if err := json.Unmarshal(stdout.Bytes(), &inspected); err != nil {
-		return ContainerIdentity{Container: container}, fmt.Errorf("parse container inspect %s: %w", container, err)
-	}
-	if len(inspected) == 0 || inspected[0].Image == "" {
+	if inspected.ContainerJSONBase == nil || inspected.Image == "" {
 		return ContainerIdentity{Container: container}, fmt.Errorf("container %s has no image ID", container)
 	}
 
-	return ContainerIdentity{Container: container, ImageID: inspected[0].Image, Present: true}, nil
+	return ContainerIdentity{Container: container, ImageID: inspected.Image, Present: true}, nil
 }
 
-func inspectIdentityValue(inspected dockerImageInspect) (string, string) {
-	if digest := firstRepoDigest(inspected.RepoDigests); digest != "" {
-		return digest, IdentitySourceRepoDigest
+// Pull fetches image from its registry. Used instead of compose pull when the
+// compose file references digests, so the floating tag can be re-resolved.
+func (i *DockerImageInspector) Pull(ctx context.Context, image string) error {
+	if i.useSudo() {
+		return pullImageCLI(ctx, image)
 	}
-	if inspected.ID != "" {
-		return inspected.ID, IdentitySourceImageID
+	cli, err := i.client()
+	if err != nil {
+		return err
 	}
-	return "", ""
+	if err = engine.PullImage(ctx, cli, image); err != nil {
+		return fmt.Errorf("pull image %s: %w", image, err)
+	}
+	return nil
 }
 
-func firstRepoDigest(repoDigests []string) string {
-	sorted := append([]string(nil), repoDigests...)
-	sort.Strings(sorted)
-
-	for _, repoDigest := range sorted {
-		_, digest, found := strings.Cut(repoDigest, "@")
-		if found && digest != "" {
-			return digest
-		}
-		if repoDigest != "" {
-			return repoDigest
-		}
+// Tag points target at the local image source (an image ID or reference).
+func (i *DockerImageInspector) Tag(ctx context.Context, source, target string) error {
+	if i.useSudo() {
+		return tagImageCLI(ctx, source, target)
+	}
+	cli, err := i.client()
+	if err != nil {
+		return err
+	}
+	if err = cli.ImageTag(ctx, source, target); err != nil {
+		return fmt.Errorf("tag image %s: %w", target, err)
 	}
+	return nil
+}
 
-	return ""
+func (i *DockerImageInspector) useSudo() bool {
+	return i.sudo != nil && i.sudo()
 }
 
-func missingImageIdentity(image string) ImageIdentity {
-	return ImageIdentity{Image: image, Value: IdentityNotPresent, Present: false, Source: IdentitySourceMissing}
+func inspectIdentityValue(id string, repoDigests []string) (string, string) {
+	if digest := engine.RepoDigest(repoDigests); digest != "" {
+		return digest, IdentitySourceRepoDigest
+	}
+	if id != "" {
+		return id, IdentitySourceImageID
+	}
+	return "", ""
 }
 
-func isImageNotFound(stderr string) bool {
-	lower := strings.ToLower(stderr)
-	return strings.Contains(lower, "no such image") ||
-		strings.Contains(lower, "no such container") ||
-		strings.Contains(lower, "no such object") ||
-		strings.Contains(lower, "not found")
+func missingImageIdentity(image string) ImageIdentity {
+	return ImageIdentity{Image: image, Value: IdentityNotPresent, Present: false, Source: IdentitySourceMissing}
 }
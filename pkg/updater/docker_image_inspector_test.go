@@ -0,0 +1,148 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"testing"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+)
+
+type fakeEngine struct {
+	images     map[string]image.InspectResponse
+	containers map[string]string // name -> image ID
+	pullStream string
+	tagged     []string
+}
+
+func (f *fakeEngine) ImageInspect(_ context.Context, ref string, _ ...client.ImageInspectOption) (image.InspectResponse, error) {
+	if img, ok := f.images[ref]; ok {
+		return img, nil
+	}
+	return image.InspectResponse{}, fmt.Errorf("No such image: %s: %w", ref, cerrdefs.ErrNotFound)
+}
+
+func (f *fakeEngine) ContainerInspect(_ context.Context, name string) (container.InspectResponse, error) {
+	if id, ok := f.containers[name]; ok {
+		return container.InspectResponse{ContainerJSONBase: &container.ContainerJSONBase{Image: id}}, nil
+	}
+	return container.InspectResponse{}, fmt.Errorf("No such container: %s: %w", name, cerrdefs.ErrNotFound)
+}
+
+func (f *fakeEngine) ImagePull(_ context.Context, _ string, _ image.PullOptions) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(f.pullStream)), nil
+}
+
+func (f *fakeEngine) ImageTag(_ context.Context, source, target string) error {
+	f.tagged = append(f.tagged, source+" "+target)
+	return nil
+}
+
+func TestDockerImageInspector(t *testing.T) {
+	cli := &fakeEngine{
+		images: map[string]image.InspectResponse{
+			"redis:alpine": {ID: "sha256:redisid", RepoDigests: []string{"redis@sha256:abc"}},
+		},
+		containers: map[string]string{"kkengine_redis": "sha256:redisid"},
+		pullStream: `{"status":"Pulling from library/redis"}` + "\n" + `{"errorDetail":{"message":"manifest unknown"},"error":"manifest unknown"}` + "\n",
+	}
+	inspector := NewDockerImageInspectorWithClient(cli)
+	ctx := context.Background()
+
+	identity, err := inspector.Inspect(ctx, "redis:alpine")
+	if err != nil || identity.Value != "sha256:abc" || !identity.Present {
+		t.Fatalf("Inspect() = %+v, %v", identity, err)
+	}
+	identity, err = inspector.Inspect(ctx, "missing:latest")
+	if err != nil || identity.Present || identity.Source != IdentitySourceMissing {
+		t.Fatalf("Inspect(missing) = %+v, %v", identity, err)
+	}
+
+	ci, err := inspector.InspectContainer(ctx, "kkengine_redis")
+	if err != nil || ci.ImageID != "sha256:redisid" {
+		t.Fatalf("InspectContainer() = %+v, %v", ci, err)
+	}
+	ci, err = inspector.InspectContainer(ctx, "kkengine_gone")
+	if err != nil || ci.Present {
+		t.Fatalf("InspectContainer(gone) = %+v, %v", ci, err)
+	}
+
+	err = inspector.Pull(ctx, "redis:alpine")
+	if err == nil || !strings.Contains(err.Error(), "manifest unknown") {
+		t.Fatalf("Pull() error = %v, want the stream error", err)
+	}
+
+	if err := inspector.Tag(ctx, "sha256:redisid", "redis:alpine"); err != nil {
+		t.Fatalf("Tag() error = %v", err)
+	}
+	if len(cli.tagged) != 1 || cli.tagged[0] != "sha256:redisid redis:alpine" {
+		t.Fatalf("tagged = %v", cli.tagged)
+	}
+}
+
+func TestDockerImageInspectorClientError(t *testing.T) {
+	inspector := &DockerImageInspector{client: func() (EngineClient, error) { return nil, errors.New("no daemon") }}
+	if _, err := inspector.Inspect(context.Background(), "redis:alpine"); err == nil {
+		t.Fatal("Inspect() expected the client error")
+	}
+}
+
+func TestDockerImageInspectorSudo(t *testing.T) {
+	t.Setenv("KK_DOCKER_SUDO", "1")
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+	var calls []string
+	old := execCommand
+	execCommand = func(ctx context.Context, name string, args ...string) *exec.Cmd {
+		calls = append(calls, strings.Join(append([]string{name}, args...), " "))
+		switch strings.Join(args, " ") {
+		case "docker image inspect redis:alpine":
+			return exec.CommandContext(ctx, "echo", `[{"Id":"sha256:redisid","RepoDigests":["redis@sha256:abc"]}]`)
+		case "docker container inspect kkengine_redis":
+			return exec.CommandContext(ctx, "echo", `[{"Image":"sha256:redisid"}]`)
+		case "docker image inspect missing:latest":
+			return exec.CommandContext(ctx, "sh", "-c", "echo 'Error: No such image: missing:latest' >&2; exit 1")
+		}
+		return exec.CommandContext(ctx, "true")
+	}
+	t.Cleanup(func() { execCommand = old })
+	inspector := NewDockerImageInspector()
+	ctx := context.Background()
+
+	identity, err := inspector.Inspect(ctx, "redis:alpine")
+	if err != nil || identity.Value != "sha256:abc" || identity.ID != "sha256:redisid" {
+		t.Fatalf("Inspect() = %+v, %v", identity, err)
+	}
+	identity, err = inspector.Inspect(ctx, "missing:latest")
+	if err != nil || identity.Present {
+		t.Fatalf("Inspect(missing) = %+v, %v", identity, err)
+	}
+	ctr, err := inspector.InspectContainer(ctx, "kkengine_redis")
+	if err != nil || ctr.ImageID != "sha256:redisid" {
+		t.Fatalf("InspectContainer() = %+v, %v", ctr, err)
+	}
+	if err = inspector.Pull(ctx, "redis:alpine"); err != nil {
+		t.Fatalf("Pull() error = %v", err)
+	}
+	if err = inspector.Tag(ctx, "sha256:redisid", "redis:alpine"); err != nil {
+		t.Fatalf("Tag() error = %v", err)
+	}
+
+	want := []string{
+		"sudo docker image inspect redis:alpine",
+		"sudo docker image inspect missing:latest",
+		"sudo docker container inspect kkengine_redis",
+		"sudo docker image pull --quiet redis:alpine",
+		"sudo docker image tag sha256:redisid redis:alpine",
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("commands =\n%s\nwant\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+}
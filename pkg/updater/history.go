@@ -0,0 +1,179 @@
+package updater
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/redact"
+)
+
+// Run outcomes recorded in the update history.
+const (
+	OutcomeUpToDate   = "up-to-date"
+	OutcomeUpdated    = "updated"
+	OutcomeRolledBack = "rolled-back"
+	OutcomeCancelled  = "cancelled"
+	OutcomeSkipped    = "skipped"
+	OutcomeFailed     = "failed"
+)
+
+// Health results recorded in the update history.
+const (
+	HealthHealthy   = "healthy"
+	HealthUnhealthy = "unhealthy"
+	HealthUnknown   = "unknown"
+)
+
+// Run triggers recorded in the update history.
+const (
+	TriggerManual    = "manual"
+	TriggerScheduled = "scheduled"
+)
+
+// MaxHistoryRecords bounds the number of records kept on disk.
+const MaxHistoryRecords = 100
+
+const historyExt = ".json"
+
+// RunRecord is the result of one kk update run.
+type RunRecord struct {
+	StartedAt       time.Time      `json:"started_at"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	ProjectDir      string         `json:"project_dir"`
+	Trigger         string         `json:"trigger"`
+	Outcome         string         `json:"outcome"`
+	ImagesChanged   []ChangedImage `json:"images_changed"`
+	Health          string         `json:"health"`
+	Unhealthy       []string       `json:"unhealthy_services,omitempty"`
+	Error           string         `json:"error,omitempty"`
+}
+
+// ChangedImage is an image replaced by an update run.
+type ChangedImage struct {
+	Image string `json:"image"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// ChangedImages converts detected updates into history entries.
+func ChangedImages(updates []ImageUpdate) []ChangedImage {
+	changed := make([]ChangedImage, len(updates))
+	for i, u := range updates {
+		changed[i] = ChangedImage{Image: u.Image, From: u.OldDigest, To: u.NewDigest}
+	}
+	return changed
+}
+
+// Finish sets the duration, and the failed outcome and error when err is not nil.
+func (r *RunRecord) Finish(now time.Time, err error) {
+	r.DurationSeconds = now.Sub(r.StartedAt).Round(time.Millisecond).Seconds()
+	if err != nil {
+		r.Error = redact.Error(err)
+		if r.Outcome == "" {
+			r.Outcome = OutcomeFailed
+		}
+	}
+	if r.Health == "" {
+		r.Health = HealthUnknown
+	}
+	if r.ImagesChanged == nil {
+		r.ImagesChanged = []ChangedImage{}
+	}
+}
+
+// WriteRecord stores r as a JSON file in dir and prunes the oldest records
+// beyond MaxHistoryRecords.
+func WriteRecord(dir string, r *RunRecord) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	name := "update-" + r.StartedAt.UTC().Format("20060102T150405.000Z") + historyExt
+	if err := os.WriteFile(filepath.Join(dir, name), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	names, err := recordNames(dir)
+	if err != nil {
+		return err
+	}
+	for len(names) > MaxHistoryRecords {
+		if err := os.Remove(filepath.Join(dir, names[0])); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		names = names[1:]
+	}
+	return nil
+}
+
+// ReadRecords returns up to limit records from dir, newest first (limit <= 0 means all).
+// Unreadable files are skipped.
+func ReadRecords(dir string, limit int) ([]RunRecord, error) {
+	names, err := recordNames(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []RunRecord
+	for i := len(names) - 1; i >= 0; i-- {
+		if limit > 0 && len(records) >= limit {
+			break
+		}
+		data, err := os.ReadFile(filepath.Join(dir, names[i]))
+		if err != nil {
+			continue
+		}
+		var r RunRecord
+		if err := json.Unmarshal(data, &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// LastSuccessful returns the newest record from records (newest first) of
+// the stack in projectDir whose run finished without error, whether or not
+// images changed.
+func LastSuccessful(records []RunRecord, projectDir string) (RunRecord, bool) {
+	for _, r := range records {
+		if filepath.Clean(r.ProjectDir) != filepath.Clean(projectDir) {
+			continue
+		}
+		if r.Outcome == OutcomeUpdated || r.Outcome == OutcomeUpToDate {
+			return r, true
+		}
+	}
+	return RunRecord{}, false
+}
+
+// FinishedAt returns when the run ended.
+func (r RunRecord) FinishedAt() time.Time {
+	return r.StartedAt.Add(time.Duration(r.DurationSeconds * float64(time.Second)))
+}
+
+// recordNames lists record files, oldest first (names sort by timestamp).
+func recordNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), "update-") && strings.HasSuffix(e.Name(), historyExt) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -25,6 +25,10 @@ type ImageInspector interface {
 	Inspect(ctx context.Context, image string) (ImageIdentity, error)
 }
 
+type ImagePuller interface {
+	Pull(ctx context.Context, image string) error
+}
+
 type ContainerIdentity struct {
 	Container string
 	ImageID   string
@@ -60,6 +64,26 @@ func SnapshotImages(ctx context.Context, images []string, inspector ImageInspect
 	return snapshot, nil
 }
 
+// PullImages pulls the floating tag of every image, ignoring any digest pin.
+func PullImages(ctx context.Context, images []string, puller ImagePuller) error {
+	if puller == nil {
+		return fmt.Errorf("image puller is nil")
+	}
+
+	seen := make(map[string]bool, len(images))
+	for _, image := range images {
+		base := BaseImage(image)
+		if seen[base] {
+			continue
+		}
+		seen[base] = true
+		if err := puller.Pull(ctx, base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func CompareSnapshots(before, after map[string]ImageIdentity) ([]ImageUpdate, error) {
 	images := sortedSnapshotImages(before, after)
 	updates := make([]ImageUpdate, 0)
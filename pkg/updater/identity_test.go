@@ -26,6 +26,26 @@ func (f fakeContainerInspector) InspectContainer(_ context.Context, container st
 	return identity, nil
 }
 
+type fakePuller struct {
+	pulled []string
+}
+
+func (f *fakePuller) Pull(_ context.Context, image string) error {
+	f.pulled = append(f.pulled, image)
+	return nil
+}
+
+func TestPullImagesIgnoresPins(t *testing.T) {
+	puller := &fakePuller{}
+	images := []string{"redis:alpine@sha256:old", "redis:alpine", "mariadb:10.6"}
+	if err := PullImages(context.Background(), images, puller); err != nil {
+		t.Fatalf("PullImages() error = %v", err)
+	}
+	if len(puller.pulled) != 2 || puller.pulled[0] != "redis:alpine" || puller.pulled[1] != "mariadb:10.6" {
+		t.Fatalf("pulled = %v, want [redis:alpine mariadb:10.6]", puller.pulled)
+	}
+}
+
 func TestSnapshotImages(t *testing.T) {
 	inspector := fakeInspector{
 		"redis:7-alpine": {Value: "sha256:abc", Present: true, Source: IdentitySourceRepoDigest},
@@ -188,18 +208,15 @@ func TestMergeImageUpdates(t *testing.T) {
 }
 
 func TestInspectIdentityValue(t *testing.T) {
-	value, source := inspectIdentityValue(dockerImageInspect{
-		ID: "sha256:imageid",
-		RepoDigests: []string{
-			"example/app@sha256:bbb",
-			"example/app@sha256:aaa",
-		},
+	value, source := inspectIdentityValue("sha256:imageid", []string{
+		"example/app@sha256:bbb",
+		"example/app@sha256:aaa",
 	})
 	if value != "sha256:aaa" || source != IdentitySourceRepoDigest {
 		t.Fatalf("inspectIdentityValue() = %q, %q", value, source)
 	}
 
-	value, source = inspectIdentityValue(dockerImageInspect{ID: "sha256:imageid"})
+	value, source = inspectIdentityValue("sha256:imageid", nil)
 	if value != "sha256:imageid" || source != IdentitySourceImageID {
 		t.Fatalf("inspectIdentityValue() fallback = %q, %q", value, source)
 	}
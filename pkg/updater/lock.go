@@ -0,0 +1,154 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// LockFileName is the image lockfile written into the project directory.
+	LockFileName = "kk.lock"
+
+	lockVersion = 1
+)
+
+// Lockfile pins every stack image to the repo digest resolved by the last kk update.
+type Lockfile struct {
+	Version     int           `yaml:"version"`
+	GeneratedAt time.Time     `yaml:"generated_at"`
+	Images      []LockedImage `yaml:"images"`
+}
+
+// LockedImage maps a floating image tag to the digest it resolved to.
+type LockedImage struct {
+	Image  string `yaml:"image"`  // Floating reference, e.g. redis:alpine
+	Digest string `yaml:"digest"` // Repo digest, e.g. sha256:...
+}
+
+// ImageDrift is a compose image reference that does not match the lockfile.
+type ImageDrift struct {
+	Image  string // Reference found in docker-compose.yml
+	Locked string // Pinned reference from kk.lock
+}
+
+// NewLockfile builds a lockfile from an image snapshot. Only images with a repo
+// digest can be pinned; locally built images (image ID only) are skipped.
+func NewLockfile(snapshot map[string]ImageIdentity, now time.Time) *Lockfile {
+	lock := &Lockfile{Version: lockVersion, GeneratedAt: now.UTC()}
+	for image, identity := range snapshot {
+		if !identity.Present || identity.Source != IdentitySourceRepoDigest {
+			continue
+		}
+		lock.Images = append(lock.Images, LockedImage{Image: BaseImage(image), Digest: identity.Value})
+	}
+	sort.Slice(lock.Images, func(i, j int) bool {
+		return lock.Images[i].Image < lock.Images[j].Image
+	})
+	return lock
+}
+
+// LoadLock reads kk.lock from dir. Returns nil, nil when no lockfile exists.
+func LoadLock(dir string) (*Lockfile, error) {
+	data, err := os.ReadFile(filepath.Join(dir, LockFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var lock Lockfile
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", LockFileName, err)
+	}
+	if lock.Version > lockVersion {
+		return nil, fmt.Errorf("%s version %d is newer than this kk supports (%d)", LockFileName, lock.Version, lockVersion)
+	}
+	return &lock, nil
+}
+
+// Save writes the lockfile into dir.
+func (l *Lockfile) Save(dir string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	header := "# Generated by kk update. Pins stack images to exact digests; do not edit by hand.\n"
+	return os.WriteFile(filepath.Join(dir, LockFileName), append([]byte(header), data...), 0644)
+}
+
+// Digests returns the locked digest per floating image reference.
+func (l *Lockfile) Digests() map[string]string {
+	digests := make(map[string]string, len(l.Images))
+	for _, img := range l.Images {
+		digests[img.Image] = img.Digest
+	}
+	return digests
+}
+
+// Equal reports whether both lockfiles pin the same images to the same digests.
+func (l *Lockfile) Equal(other *Lockfile) bool {
+	if l == nil || other == nil {
+		return l == other
+	}
+	a, b := l.Digests(), other.Digests()
+	if len(a) != len(b) {
+		return false
+	}
+	for image, digest := range a {
+		if b[image] != digest {
+			return false
+		}
+	}
+	return true
+}
+
+// Ref returns the pinned reference for image, or image unchanged when it is not locked.
+func (l *Lockfile) Ref(image string) string {
+	base := BaseImage(image)
+	if digest, ok := l.Digests()[base]; ok {
+		return PinnedRef(base, digest)
+	}
+	return image
+}
+
+// Drift lists compose image references that are not pinned to the locked
+// digest. Images without a lock entry are skipped: the lock never pins
+// locally built images, and kk update has not seen images added since.
+func (l *Lockfile) Drift(images []string) []ImageDrift {
+	digests := l.Digests()
+	var drift []ImageDrift
+	for _, image := range images {
+		base := BaseImage(image)
+		digest, ok := digests[base]
+		if !ok {
+			continue
+		}
+		if want := PinnedRef(base, digest); image != want {
+			drift = append(drift, ImageDrift{Image: image, Locked: want})
+		}
+	}
+	return drift
+}
+
+// BaseImage strips a digest pin, e.g. redis:alpine@sha256:abc -> redis:alpine.
+func BaseImage(ref string) string {
+	base, _, _ := strings.Cut(ref, "@")
+	return base
+}
+
+// PinnedRef returns image@digest. The tag is kept for readability; Docker resolves the digest.
+func PinnedRef(image, digest string) string {
+	return BaseImage(image) + "@" + digest
+}
+
+// IsPinned reports whether ref carries a digest.
+func IsPinned(ref string) bool {
+	return strings.Contains(ref, "@")
+}
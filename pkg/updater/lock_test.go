@@ -0,0 +1,85 @@
+package updater
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewLockfileAndRoundTrip(t *testing.T) {
+	lock := NewLockfile(map[string]ImageIdentity{
+		"redis:alpine":           {Value: "sha256:redis", Present: true, Source: IdentitySourceRepoDigest},
+		"kkauto/kkengine:latest": {Value: "sha256:app", Present: true, Source: IdentitySourceRepoDigest},
+		"local/build:dev":        {Value: "sha256:imageid", Present: true, Source: IdentitySourceImageID},
+		"missing:latest":         {Value: IdentityNotPresent, Source: IdentitySourceMissing},
+	}, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+
+	want := []LockedImage{
+		{Image: "kkauto/kkengine:latest", Digest: "sha256:app"},
+		{Image: "redis:alpine", Digest: "sha256:redis"},
+	}
+	if !reflect.DeepEqual(lock.Images, want) {
+		t.Fatalf("Images = %#v, want %#v", lock.Images, want)
+	}
+
+	dir := t.TempDir()
+	if err := lock.Save(dir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadLock(dir)
+	if err != nil {
+		t.Fatalf("LoadLock() error = %v", err)
+	}
+	if !lock.Equal(loaded) {
+		t.Fatalf("loaded lock = %#v, want %#v", loaded, lock)
+	}
+}
+
+func TestLoadLockMissingAndNewerVersion(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := LoadLock(dir)
+	if err != nil || lock != nil {
+		t.Fatalf("LoadLock() without file = %v, %v", lock, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, LockFileName), []byte("version: 99\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLock(dir); err == nil {
+		t.Fatal("LoadLock() expected version error")
+	}
+}
+
+func TestLockfileRefAndDrift(t *testing.T) {
+	lock := &Lockfile{Images: []LockedImage{{Image: "redis:alpine", Digest: "sha256:aaa"}}}
+
+	if got := lock.Ref("redis:alpine"); got != "redis:alpine@sha256:aaa" {
+		t.Fatalf("Ref() = %q", got)
+	}
+	if got := lock.Ref("redis:alpine@sha256:old"); got != "redis:alpine@sha256:aaa" {
+		t.Fatalf("Ref() on pinned = %q", got)
+	}
+	if got := lock.Ref("mariadb:10.6"); got != "mariadb:10.6" {
+		t.Fatalf("Ref() unlocked = %q", got)
+	}
+
+	// A local build and an image added by the overlay have no lock entry.
+	drift := lock.Drift([]string{"redis:alpine@sha256:aaa", "redis:alpine", "kkengine-local:dev", "boky/postfix:latest"})
+	want := []ImageDrift{
+		{Image: "redis:alpine", Locked: "redis:alpine@sha256:aaa"},
+	}
+	if !reflect.DeepEqual(drift, want) {
+		t.Fatalf("Drift() = %#v, want %#v", drift, want)
+	}
+}
+
+func TestBaseImage(t *testing.T) {
+	if got := BaseImage("redis:alpine@sha256:abc"); got != "redis:alpine" {
+		t.Fatalf("BaseImage() = %q", got)
+	}
+	if !IsPinned("redis@sha256:abc") || IsPinned("redis:alpine") {
+		t.Fatal("IsPinned() mismatch")
+	}
+}
@@ -0,0 +1,135 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RollbackFileName records the image set that was running before the last kk update.
+const RollbackFileName = ".kk-rollback.yml"
+
+// ErrNoRollbackPoint is returned when no pre-update image set has been recorded.
+var ErrNoRollbackPoint = errors.New("no previous image set recorded; kk update has not replaced any images yet")
+
+// RollbackPoint is the pre-update image set of a project.
+type RollbackPoint struct {
+	CreatedAt time.Time       `yaml:"created_at"`
+	Images    []RollbackImage `yaml:"images"`
+	Lock      *Lockfile       `yaml:"lock,omitempty"` // kk.lock before the update, nil when there was none
+}
+
+// RollbackImage is one image as it was before the update.
+type RollbackImage struct {
+	Image  string `yaml:"image"`            // Floating reference, e.g. redis:alpine
+	Ref    string `yaml:"ref"`              // Reference used in docker-compose.yml, possibly pinned
+	ID     string `yaml:"id,omitempty"`     // Local image ID, empty when the image was not present
+	Digest string `yaml:"digest,omitempty"` // Identity value shown to the user
+}
+
+// ImageTagger points a tag at an existing local image.
+type ImageTagger interface {
+	Tag(ctx context.Context, source, target string) error
+}
+
+// NewRollbackPoint captures the pre-update state. refs are the compose image
+// references (pinned or not); before is the snapshot of their floating tags.
+func NewRollbackPoint(refs []string, before map[string]ImageIdentity, lock *Lockfile, now time.Time) *RollbackPoint {
+	point := &RollbackPoint{CreatedAt: now.UTC(), Lock: lock}
+	seen := make(map[string]bool, len(refs))
+	for _, ref := range refs {
+		base := BaseImage(ref)
+		if seen[base] {
+			continue
+		}
+		seen[base] = true
+
+		image := RollbackImage{Image: base, Ref: ref}
+		if identity, ok := before[base]; ok && identity.Present {
+			image.ID = identity.ID
+			image.Digest = identity.Value
+		}
+		point.Images = append(point.Images, image)
+	}
+	sort.Slice(point.Images, func(i, j int) bool {
+		return point.Images[i].Image < point.Images[j].Image
+	})
+	return point
+}
+
+// LoadRollbackPoint reads the rollback file from dir. Returns ErrNoRollbackPoint when absent.
+func LoadRollbackPoint(dir string) (*RollbackPoint, error) {
+	data, err := os.ReadFile(filepath.Join(dir, RollbackFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNoRollbackPoint
+		}
+		return nil, err
+	}
+	var point RollbackPoint
+	if err := yaml.Unmarshal(data, &point); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", RollbackFileName, err)
+	}
+	return &point, nil
+}
+
+// Save writes the rollback point into dir.
+func (p *RollbackPoint) Save(dir string) error {
+	data, err := yaml.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, RollbackFileName), data, 0644)
+}
+
+// Refs returns the pre-update compose reference per floating image.
+func (p *RollbackPoint) Refs() map[string]string {
+	refs := make(map[string]string, len(p.Images))
+	for _, img := range p.Images {
+		refs[img.Image] = img.Ref
+	}
+	return refs
+}
+
+// Retag points every floating tag back at the image ID it had before the update.
+// Images that were not present before the update are left alone.
+func (p *RollbackPoint) Retag(ctx context.Context, tagger ImageTagger) error {
+	if tagger == nil {
+		return fmt.Errorf("image tagger is nil")
+	}
+	for _, img := range p.Images {
+		if img.ID == "" {
+			continue
+		}
+		if err := tagger.Tag(ctx, img.ID, img.Image); err != nil {
+			return fmt.Errorf("restore %s to %s: %w", img.Image, shortID(img.ID), err)
+		}
+	}
+	return nil
+}
+
+// RestoreLock puts the pre-update kk.lock back in dir, removing it when there was none.
+func (p *RollbackPoint) RestoreLock(dir string) error {
+	if p.Lock == nil {
+		err := os.Remove(filepath.Join(dir, LockFileName))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	return p.Lock.Save(dir)
+}
+
+func shortID(id string) string {
+	const n = len("sha256:") + 12
+	if len(id) > n {
+		return id[:n]
+	}
+	return id
+}
@@ -0,0 +1,88 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type fakeTagger struct {
+	tags []string
+}
+
+func (f *fakeTagger) Tag(_ context.Context, source, target string) error {
+	f.tags = append(f.tags, source+" "+target)
+	return nil
+}
+
+func TestRollbackPointRoundTrip(t *testing.T) {
+	before := map[string]ImageIdentity{
+		"redis:alpine":           {Value: "sha256:redisdigest", ID: "sha256:redisid", Present: true, Source: IdentitySourceRepoDigest},
+		"kkauto/kkengine:latest": {Value: IdentityNotPresent, Source: IdentitySourceMissing},
+	}
+	lock := &Lockfile{Version: lockVersion, Images: []LockedImage{{Image: "redis:alpine", Digest: "sha256:redisdigest"}}}
+	point := NewRollbackPoint([]string{"redis:alpine@sha256:redisdigest", "kkauto/kkengine:latest"}, before, lock, time.Now())
+
+	want := []RollbackImage{
+		{Image: "kkauto/kkengine:latest", Ref: "kkauto/kkengine:latest"},
+		{Image: "redis:alpine", Ref: "redis:alpine@sha256:redisdigest", ID: "sha256:redisid", Digest: "sha256:redisdigest"},
+	}
+	if !reflect.DeepEqual(point.Images, want) {
+		t.Fatalf("Images = %#v, want %#v", point.Images, want)
+	}
+
+	dir := t.TempDir()
+	if err := point.Save(dir); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	loaded, err := LoadRollbackPoint(dir)
+	if err != nil {
+		t.Fatalf("LoadRollbackPoint() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Images, point.Images) || !loaded.Lock.Equal(lock) {
+		t.Fatalf("loaded = %#v", loaded)
+	}
+
+	tagger := &fakeTagger{}
+	if err := loaded.Retag(context.Background(), tagger); err != nil {
+		t.Fatalf("Retag() error = %v", err)
+	}
+	if !reflect.DeepEqual(tagger.tags, []string{"sha256:redisid redis:alpine"}) {
+		t.Fatalf("tags = %v", tagger.tags)
+	}
+}
+
+func TestLoadRollbackPointMissing(t *testing.T) {
+	if _, err := LoadRollbackPoint(t.TempDir()); !errors.Is(err, ErrNoRollbackPoint) {
+		t.Fatalf("LoadRollbackPoint() error = %v, want ErrNoRollbackPoint", err)
+	}
+}
+
+func TestRollbackPointRestoreLock(t *testing.T) {
+	dir := t.TempDir()
+	current := &Lockfile{Version: lockVersion, Images: []LockedImage{{Image: "redis:alpine", Digest: "sha256:new"}}}
+	if err := current.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// No lock before the update: the new one is removed
+	if err := (&RollbackPoint{}).RestoreLock(dir); err != nil {
+		t.Fatalf("RestoreLock() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, LockFileName)); !os.IsNotExist(err) {
+		t.Fatalf("kk.lock should be removed, stat err = %v", err)
+	}
+
+	previous := &Lockfile{Version: lockVersion, Images: []LockedImage{{Image: "redis:alpine", Digest: "sha256:old"}}}
+	if err := (&RollbackPoint{Lock: previous}).RestoreLock(dir); err != nil {
+		t.Fatalf("RestoreLock() error = %v", err)
+	}
+	loaded, err := LoadLock(dir)
+	if err != nil || !loaded.Equal(previous) {
+		t.Fatalf("LoadLock() = %#v, %v", loaded, err)
+	}
+}
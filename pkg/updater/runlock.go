@@ -0,0 +1,63 @@
+package updater
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// ErrUpdateRunning is returned when another kk update holds the run lock.
+var ErrUpdateRunning = errors.New("another kk update is already running")
+
+// RunLock is an exclusive lock held for the duration of an update run.
+type RunLock struct {
+	file *os.File
+}
+
+// AcquireRunLock takes a non-blocking exclusive lock on path. The lock is
+// released by the kernel if the process dies, so a stale file never blocks a run.
+func AcquireRunLock(path string) (*RunLock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			err = ErrUpdateRunning
+			if holder, readErr := os.ReadFile(path); readErr == nil {
+				if pid := strings.TrimSpace(string(holder)); pid != "" {
+					err = fmt.Errorf("%w (pid %s)", ErrUpdateRunning, pid)
+				}
+			}
+		}
+		return nil, errors.Join(err, f.Close())
+	}
+
+	// Record the holder for the error message above
+	if err = f.Truncate(0); err == nil {
+		_, err = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	if err != nil {
+		return nil, errors.Join(err, f.Close())
+	}
+	return &RunLock{file: f}, nil
+}
+
+// Release drops the lock.
+func (l *RunLock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	truncateErr := l.file.Truncate(0)
+	unlockErr := syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+	closeErr := l.file.Close()
+	l.file = nil
+	return errors.Join(truncateErr, unlockErr, closeErr)
+}
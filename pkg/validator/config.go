@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"gopkg.in/yaml.v3"
+
+	composepkg "github.com/kkauto-net/kk-install/pkg/compose"
 )
 
 // ValidateDockerCompose checks docker-compose.yml syntax
@@ -34,6 +37,36 @@ func ValidateDockerCompose(dir string) error {
 	if _, ok := compose["services"]; !ok {
 		return &UserError{Key: "compose_no_services"}
 	}
+	services, _ := compose["services"].(map[string]interface{})
+
+	// The user's overlay must be valid YAML. Services it adds need a
+	// container_name: kk finds containers by name, and compose would name
+	// them after the project instead.
+	overlay, err := os.ReadFile(filepath.Join(dir, composepkg.OverrideFileName))
+	if err == nil {
+		var layer map[string]interface{}
+		if yamlErr := yaml.Unmarshal(overlay, &layer); yamlErr != nil {
+			return &UserError{
+				Key:     ErrComposeSyntax,
+				Message: fmt.Sprintf("%s: %s: %v", ErrComposeSyntax, composepkg.OverrideFileName, yamlErr),
+			}
+		}
+		added, _ := layer["services"].(map[string]interface{})
+		names := make([]string, 0, len(added))
+		for name := range added {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, inBase := services[name]; inBase {
+				continue
+			}
+			svc, _ := added[name].(map[string]interface{})
+			if containerName, _ := svc["container_name"].(string); containerName == "" {
+				return &UserError{Key: "compose_overlay_container_name", Args: []any{name}}
+			}
+		}
+	}
 
 	return nil
 }
@@ -52,6 +52,36 @@ networks:
 			}
 		}
 	})
+
+	t.Run("Overlay service without container_name", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		base := `services:
+  db:
+    image: mariadb:10.6
+    container_name: kkengine_db`
+		writeTestFile(t, filepath.Join(tmpDir, "docker-compose.yml"), []byte(base), 0644)
+		overlay := `services:
+  db:
+    mem_limit: 1g
+  mailrelay:
+    image: boky/postfix
+    ports:
+      - target: 25
+        published: 2525`
+		writeTestFile(t, filepath.Join(tmpDir, "docker-compose.override.yml"), []byte(overlay), 0644)
+
+		err := ValidateDockerCompose(tmpDir)
+		ue, ok := err.(*UserError)
+		if !ok || ue.Key != "compose_overlay_container_name" || len(ue.Args) != 1 || ue.Args[0] != "mailrelay" {
+			t.Fatalf("Expected compose_overlay_container_name for mailrelay, got %v", err)
+		}
+
+		overlay += "\n    container_name: kkengine_mailrelay"
+		writeTestFile(t, filepath.Join(tmpDir, "docker-compose.override.yml"), []byte(overlay), 0644)
+		if err = ValidateDockerCompose(tmpDir); err != nil {
+			t.Errorf("Expected overlay with container_name to pass, got %v", err)
+		}
+	})
 }
 
 func TestValidateCaddyfile(t *testing.T) {
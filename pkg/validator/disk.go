@@ -11,8 +11,13 @@ const MinDiskSpaceGB = 5
 
 var statfsCaller = syscall.Statfs
 
-// CheckDiskSpace verifies sufficient disk space
+// CheckDiskSpace verifies sufficient disk space. With a remote engine it
+// reports the engine's data root instead of path.
 func CheckDiskSpace(path string) (float64, error) {
+	if engineIsRemote() {
+		return remoteDiskSpace()
+	}
+
 	var stat syscall.Statfs_t
 	if err := statfsCaller(path, &stat); err != nil {
 		return 0, fmt.Errorf("khong kiem tra duoc disk: %w", err)
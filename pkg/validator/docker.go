@@ -45,8 +45,11 @@ func NewDockerValidator() *DockerValidator {
 	}
 }
 
-// CheckDockerInstalled verifies docker binary exists
+// CheckDockerInstalled verifies docker binary exists (podman with Podman)
 func (v *DockerValidator) CheckDockerInstalled() error {
+	if usesPodman() {
+		return v.checkPodmanInstalled()
+	}
 	_, err := v.LookPath("docker")
 	if err != nil {
 		return &UserError{Key: "docker_not_installed"}
@@ -54,8 +57,12 @@ func (v *DockerValidator) CheckDockerInstalled() error {
 	return nil
 }
 
-// CheckDockerDaemon verifies docker daemon is running
+// CheckDockerDaemon verifies docker daemon is running (Podman's API socket
+// with Podman)
 func (v *DockerValidator) CheckDockerDaemon() error {
+	if usesPodman() {
+		return v.checkPodmanSocket()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -77,8 +84,12 @@ func (v *DockerValidator) CheckDockerDaemon() error {
 	return nil
 }
 
-// CheckComposeVersion verifies Docker Compose v2.0+ is installed
+// CheckComposeVersion verifies Docker Compose v2.0+ is installed (podman
+// compose or podman-compose with Podman)
 func (v *DockerValidator) CheckComposeVersion() error {
+	if usesPodman() {
+		return v.checkPodmanCompose()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -165,6 +176,9 @@ func UserErrorKey(err error) string {
 
 // EnsureDockerReady validates Docker installation, daemon, and Compose.
 func (v *DockerValidator) EnsureDockerReady(opts EnsureDockerOptions) error {
+	if usesPodman() {
+		return v.ensurePodmanReady(opts)
+	}
 	maxRetries := opts.maxRetries()
 
 	if err := v.CheckDockerInstalled(); err != nil {
@@ -180,7 +194,12 @@ func (v *DockerValidator) EnsureDockerReady(opts EnsureDockerOptions) error {
 		}
 	}
 
-	if err := v.ensureDaemonReady(opts, maxRetries); err != nil {
+	// kk can neither start a remote engine nor fix its permissions.
+	if engineIsRemote() {
+		if err := v.CheckDockerDaemon(); err != nil {
+			return err
+		}
+	} else if err := v.ensureDaemonReady(opts, maxRetries); err != nil {
 		return err
 	}
 
@@ -491,8 +510,12 @@ func ensureSudoAccess(v *DockerValidator, parentCtx context.Context) error {
 	return nil
 }
 
-// InstallDocker attempts to install Docker using the official convenience script.
+// InstallDocker attempts to install Docker using the official convenience
+// script, or installs Podman from the distribution's packages.
 func (v *DockerValidator) InstallDocker() error {
+	if usesPodman() {
+		return v.installPodman()
+	}
 	if err := v.checkInstallPrerequisites(); err != nil {
 		return err
 	}
@@ -578,8 +601,11 @@ func (v *DockerValidator) configureDockerGroupAfterInstall() {
 	}
 }
 
-// StartDockerDaemon attempts to start the Docker daemon
+// StartDockerDaemon attempts to start the Docker daemon, or Podman's API socket
 func (v *DockerValidator) StartDockerDaemon() error {
+	if usesPodman() {
+		return v.startPodmanSocket()
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
@@ -48,8 +48,30 @@ func (v *DockerValidator) HasDockerGroupRunner() bool {
 	return false
 }
 
+// DockerGroupState describes how kk reaches the docker daemon.
+type DockerGroupState struct {
+	User          string `json:"user"`
+	InDockerGroup bool   `json:"in_docker_group"`
+	GroupRunner   bool   `json:"group_runner"`  // sg or newgrp is available
+	SudoFallback  bool   `json:"sudo_fallback"` // KK_DOCKER_SUDO=1
+}
+
+// DockerGroupState reports the docker group membership of the current user
+// and how docker commands are run, for diagnostics.
+func (v *DockerValidator) DockerGroupState() DockerGroupState {
+	return DockerGroupState{
+		User:          strings.TrimSpace(os.Getenv("USER")),
+		InDockerGroup: v.isUserInDockerGroup(),
+		GroupRunner:   v.HasDockerGroupRunner(),
+		SudoFallback:  dockerUsesSudo(),
+	}
+}
+
+// dockerUsesSudo reports whether docker runs through sudo. sudo would drop
+// DOCKER_HOST and DOCKER_CONTEXT, and a remote engine's permissions are not
+// the local docker group's anyway.
 func dockerUsesSudo() bool {
-	return os.Getenv("KK_DOCKER_SUDO") == "1"
+	return os.Getenv("KK_DOCKER_SUDO") == "1" && !engineIsRemote()
 }
 
 func (v *DockerValidator) canAccessDockerWithSudo() bool {
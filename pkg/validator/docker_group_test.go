@@ -123,3 +123,19 @@ func TestRunCommandWithDockerGroupMissingRunner(t *testing.T) {
 		t.Fatal("RunCommandWithDockerGroup() expected error when sg/newgrp missing")
 	}
 }
+
+func TestDockerGroupState(t *testing.T) {
+	v := &DockerValidator{
+		LookPath: mockLookPathNotFound,
+		CommandContext: func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+			return exec.Command("sh", "-c", "printf 'docker:x:999:alice,bob'")
+		},
+	}
+	t.Setenv("USER", "bob")
+	t.Setenv("KK_DOCKER_SUDO", "1")
+
+	want := DockerGroupState{User: "bob", InDockerGroup: true, SudoFallback: true}
+	if got := v.DockerGroupState(); got != want {
+		t.Fatalf("DockerGroupState() = %+v, want %+v", got, want)
+	}
+}
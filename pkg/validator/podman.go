@@ -0,0 +1,203 @@
+package validator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/engine"
+	"github.com/kkauto-net/kk-install/pkg/ui"
+)
+
+// Podman runs without a daemon, so the "daemon" kk needs is Podman's
+// Docker-compatible API socket (the podman.socket unit), and there is no
+// docker group: rootless Podman runs as the user, rootful as root.
+
+func usesPodman() bool {
+	return engine.CurrentRuntime() == engine.RuntimePodman
+}
+
+func (v *DockerValidator) checkPodmanInstalled() error {
+	if _, err := v.LookPath("podman"); err != nil {
+		return &UserError{Key: "podman_not_installed"}
+	}
+	return nil
+}
+
+// checkPodmanSocket verifies the API socket kk uses answers.
+func (v *DockerValidator) checkPodmanSocket() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	url := os.Getenv("DOCKER_HOST")
+	if url == "" {
+		url = "unix://" + engine.PodmanSocket()
+	}
+	cmd := v.CommandContext(ctx, "podman", "--url", url, "version")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return &UserError{Key: "podman_socket_not_running", Args: []any{url}, Detail: string(output)}
+	}
+	return nil
+}
+
+// checkPodmanCompose verifies podman compose or podman-compose works. Their
+// versions do not follow Docker Compose's, so only presence is checked.
+func (v *DockerValidator) checkPodmanCompose() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if v.CommandContext(ctx, "podman", "compose", "version").Run() == nil {
+		return nil
+	}
+	if v.CommandContext(ctx, "podman-compose", "version").Run() == nil {
+		return nil
+	}
+	return &UserError{Key: "podman_compose_not_found"}
+}
+
+func (v *DockerValidator) ensurePodmanReady(opts EnsureDockerOptions) error {
+	maxRetries := opts.maxRetries()
+
+	if err := v.checkPodmanInstalled(); err != nil {
+		approved, approveErr := opts.approveInstall()
+		if approveErr != nil {
+			return approveErr
+		}
+		if !approved {
+			return err
+		}
+		if installErr := v.installDockerWithRetry(maxRetries, opts.Install); installErr != nil {
+			return installErr
+		}
+	}
+
+	if err := v.checkPodmanSocket(); err != nil {
+		// kk cannot start a remote engine's socket.
+		if engineIsRemote() {
+			return err
+		}
+		approved, approveErr := opts.approveStart()
+		if approveErr != nil {
+			return approveErr
+		}
+		if !approved {
+			return err
+		}
+		if startErr := v.startDockerDaemonWithRetry(maxRetries, opts.Start); startErr != nil {
+			return startErr
+		}
+		if err = v.checkPodmanSocket(); err != nil {
+			return err
+		}
+	}
+
+	return v.checkPodmanCompose()
+}
+
+// installPodman installs podman and podman-compose with the distribution's
+// package manager.
+func (v *DockerValidator) installPodman() error {
+	if _, err := v.LookPath("sudo"); err != nil {
+		return &UserError{Key: "docker_install_err_sudo_missing"}
+	}
+	var script string
+	switch {
+	case v.hasCommand("dnf"):
+		script = "sudo dnf install -y podman podman-compose"
+	case v.hasCommand("yum"):
+		script = "sudo yum install -y podman podman-compose"
+	case v.hasCommand("apt-get"):
+		script = "sudo apt-get update && sudo apt-get install -y podman podman-compose"
+	default:
+		return &UserError{Key: "podman_install_unsupported"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dockerInstallTimeout())
+	defer cancel()
+
+	if err := ensureSudoAccess(v, ctx); err != nil {
+		return err
+	}
+	if !isInteractiveTTY() {
+		fmt.Println()
+	}
+
+	cmd := v.CommandContext(ctx, "sh", "-c", script)
+	var stderr bytes.Buffer
+	attachCommandIO(cmd, &stderr)
+	if err := cmd.Run(); err != nil {
+		output := stderr.String()
+		if output == "" {
+			output = err.Error()
+		}
+		userErr := classifyDockerInstallFailure(output, err)
+		userErr.Detail = output
+		return userErr
+	}
+	return nil
+}
+
+// startPodmanSocket enables Podman's API socket: the user unit when rootless,
+// the system unit otherwise.
+func (v *DockerValidator) startPodmanSocket() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	name, args := "systemctl", []string{"--user", "enable", "--now", "podman.socket"}
+	if !engine.Rootless() {
+		name, args = "sudo", []string{"systemctl", "enable", "--now", "podman.socket"}
+	}
+	cmd := v.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	attachCommandIO(cmd, &stderr)
+	if err := cmd.Run(); err != nil {
+		return &UserError{Key: "podman_start_failed", Detail: stderr.String()}
+	}
+	return nil
+}
+
+func (v *DockerValidator) hasCommand(name string) bool {
+	_, err := v.LookPath(name)
+	return err == nil
+}
+
+var unprivilegedPortStart = func() (int, error) {
+	data, err := os.ReadFile("/proc/sys/net/ipv4/ip_unprivileged_port_start")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(data)))
+}
+
+// CheckRootlessPorts reports stack ports rootless Podman cannot publish:
+// users may only bind ports from net.ipv4.ip_unprivileged_port_start (1024
+// unless lowered). It passes for Docker, rootful Podman and remote engines.
+func CheckRootlessPorts(ports []StackPort) error {
+	if !usesPodman() || !engine.Rootless() || engineIsRemote() {
+		return nil
+	}
+	start, err := unprivilegedPortStart()
+	if err != nil {
+		return nil
+	}
+	var blocked []string
+	lowest := start
+	for _, p := range ports {
+		if p.Port < start {
+			blocked = append(blocked, fmt.Sprintf("%d (%s)", p.Port, p.Name))
+			lowest = min(lowest, p.Port)
+		}
+	}
+	if len(blocked) == 0 {
+		return nil
+	}
+	return &UserError{
+		Key:        "podman_rootless_ports",
+		Args:       []any{strings.Join(blocked, ", "), start},
+		Suggestion: ui.MsgF("podman_rootless_ports_suggestion", lowest),
+	}
+}
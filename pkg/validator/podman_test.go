@@ -0,0 +1,116 @@
+package validator
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/engine"
+)
+
+func TestEnsurePodmanReady(t *testing.T) {
+	t.Setenv(engine.RuntimeEnv, string(engine.RuntimePodman))
+	t.Setenv("DOCKER_HOST", "unix:///run/user/1000/podman/podman.sock")
+
+	socketUp := false
+	var commands []string
+	v := &DockerValidator{
+		LookPath: func(file string) (string, error) {
+			if file == "podman" {
+				return "/usr/bin/podman", nil
+			}
+			return "", os.ErrNotExist
+		},
+		CommandContext: func(ctx context.Context, name string, arg ...string) *exec.Cmd {
+			command := strings.Join(append([]string{name}, arg...), " ")
+			commands = append(commands, command)
+			switch {
+			case strings.HasPrefix(command, "podman --url"):
+				if !socketUp {
+					return exec.Command("false")
+				}
+			case command == "podman compose version":
+				return exec.Command("false")
+			}
+			return exec.Command("true")
+		},
+	}
+
+	err := v.EnsureDockerReady(EnsureDockerOptions{})
+	if UserErrorKey(err) != "podman_socket_not_running" {
+		t.Fatalf("EnsureDockerReady() error = %v, want podman_socket_not_running", err)
+	}
+
+	started := false
+	err = v.EnsureDockerReady(EnsureDockerOptions{
+		AutoFix: true,
+		Start:   func() error { started, socketUp = true, true; return nil },
+	})
+	if err != nil || !started {
+		t.Fatalf("EnsureDockerReady(AutoFix) = %v, started = %t", err, started)
+	}
+	if commands[len(commands)-1] != "podman-compose version" {
+		t.Fatalf("last command = %q, want the podman-compose fallback", commands[len(commands)-1])
+	}
+	for _, command := range commands {
+		if strings.Contains(command, "docker") || strings.Contains(command, "usermod") {
+			t.Fatalf("Podman checks ran %q", command)
+		}
+	}
+}
+
+func TestInstallPodmanUsesPackageManager(t *testing.T) {
+	t.Setenv(engine.RuntimeEnv, string(engine.RuntimePodman))
+	v := &DockerValidator{
+		LookPath:       func(file string) (string, error) { return "", errors.New("not found") },
+		CommandContext: mockCommandContextSuccess,
+	}
+	if err := v.InstallDocker(); UserErrorKey(err) != "docker_install_err_sudo_missing" {
+		t.Fatalf("InstallDocker() error = %v, want sudo missing", err)
+	}
+
+	v.LookPath = func(file string) (string, error) {
+		if file == "sudo" {
+			return "/usr/bin/sudo", nil
+		}
+		return "", errors.New("not found")
+	}
+	if err := v.InstallDocker(); UserErrorKey(err) != "podman_install_unsupported" {
+		t.Fatalf("InstallDocker() error = %v, want podman_install_unsupported", err)
+	}
+}
+
+func TestCheckRootlessPorts(t *testing.T) {
+	if !engine.Rootless() {
+		t.Skip("rootless check only applies to non-root users")
+	}
+	original := unprivilegedPortStart
+	t.Cleanup(func() { unprivilegedPortStart = original })
+	unprivilegedPortStart = func() (int, error) { return 1024, nil }
+	ports := []StackPort{{Name: "kkengine", Port: 8019}, {Name: "Caddy HTTP", Port: 80}, {Name: "Caddy HTTPS", Port: 443}}
+
+	t.Setenv(engine.RuntimeEnv, string(engine.RuntimeDocker))
+	if err := CheckRootlessPorts(ports); err != nil {
+		t.Fatalf("CheckRootlessPorts(docker) = %v, want nil", err)
+	}
+
+	t.Setenv(engine.RuntimeEnv, string(engine.RuntimePodman))
+	err := CheckRootlessPorts(ports)
+	if UserErrorKey(err) != "podman_rootless_ports" {
+		t.Fatalf("CheckRootlessPorts(podman) = %v", err)
+	}
+	if msg := UserErrorMessage(err); !strings.Contains(msg, "80 (Caddy HTTP), 443 (Caddy HTTPS)") {
+		t.Fatalf("message = %q", msg)
+	}
+	if suggestion := UserErrorSuggestion(err); !strings.Contains(suggestion, "ip_unprivileged_port_start=80") {
+		t.Fatalf("suggestion = %q", suggestion)
+	}
+
+	unprivilegedPortStart = func() (int, error) { return 80, nil }
+	if err := CheckRootlessPorts(ports); err != nil {
+		t.Fatalf("CheckRootlessPorts() with lowered start = %v", err)
+	}
+}
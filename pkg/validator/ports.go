@@ -2,12 +2,22 @@ package validator
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"net"
 	"os"
 	"os/exec"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/engine"
+	"github.com/kkauto-net/kk-install/pkg/ui"
 )
 
 type PortStatus struct {
@@ -18,9 +28,16 @@ type PortStatus struct {
 	UsedByKKEngine bool // true if port is used by kkengine container
 }
 
-// RequiredPorts defines ports needed by kkengine stack
+// StackPort is a host port the stack publishes.
+type StackPort struct {
+	Name string
+	Port int
+}
+
+// RequiredPorts defines ports needed by a default kkengine stack. MariaDB is
+// not published by default, so 3306 is only checked when the compose file
+// binds it.
 var RequiredPorts = map[string]int{
-	"MariaDB":  3306,
 	"kkengine": 8019,
 }
 
@@ -42,7 +59,6 @@ func CheckPort(port int) PortStatus {
 			status.InUse = true
 			status.PID = pid
 			status.Process = process
-			status.UsedByKKEngine = isPortUsedByKKEngine(port)
 		}
 		return status
 	}
@@ -55,7 +71,6 @@ func CheckPort(port int) PortStatus {
 		pid, process := findProcessUsingPort(port)
 		status.PID = pid
 		status.Process = process
-		status.UsedByKKEngine = isPortUsedByKKEngine(port)
 		return status
 	}
 	if closeErr := listener.Close(); closeErr != nil {
@@ -65,17 +80,39 @@ func CheckPort(port int) PortStatus {
 	return status
 }
 
-// isPortUsedByKKEngine checks if port is being used by a kkengine container
-func isPortUsedByKKEngine(port int) bool {
-	// Use docker ps to check if any kkengine_ container is using this port
-	cmd := exec.Command("docker", "ps", "--filter", "name=kkengine_", "--format", "{{.Ports}}")
-	output, err := cmd.Output()
+// isPortUsedByKKEngine checks if port is published by one of the stack's containers
+func isPortUsedByKKEngine(port int, containers []string) bool {
+	if len(containers) == 0 {
+		return false
+	}
+	cli, err := engine.Shared()
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	running, err := cli.ContainerList(ctx, container.ListOptions{})
 	if err != nil {
 		return false
 	}
+	return portPublishedBy(running, port, containers)
+}
 
-	portStr := fmt.Sprintf(":%d->", port)
-	return strings.Contains(string(output), portStr)
+// portPublishedBy reports whether one of containers publishes port on the host.
+func portPublishedBy(running []container.Summary, port int, containers []string) bool {
+	for _, c := range running {
+		if !slices.ContainsFunc(c.Names, func(name string) bool {
+			return slices.Contains(containers, strings.TrimPrefix(name, "/"))
+		}) {
+			continue
+		}
+		for _, p := range c.Ports {
+			if int(p.PublicPort) == port {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // checkPortWithSS uses ss command to check if port is in use (works without root)
@@ -113,30 +150,58 @@ func checkPortWithSS(port int) (inUse bool, pid int, process string) {
 	return false, 0, ""
 }
 
-// CheckAllPorts validates all required ports
-func CheckAllPorts(includeCaddy bool) ([]PortStatus, error) {
+// DefaultStackPorts returns the ports published by a default install.
+func DefaultStackPorts(includeCaddy bool) []StackPort {
+	var ports []StackPort
+	for name, port := range RequiredPorts {
+		ports = append(ports, StackPort{Name: name, Port: port})
+	}
+	if includeCaddy {
+		for name, port := range OptionalPorts {
+			ports = append(ports, StackPort{Name: name, Port: port})
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i].Port < ports[j].Port })
+	return ports
+}
+
+// StackPortsFromCompose returns the host ports published by the compose file.
+func StackPortsFromCompose(composeFile *compose.ComposeFile) []StackPort {
+	var ports []StackPort
+	for _, service := range composeFile.GetServiceNames() {
+		for _, p := range composeFile.GetPublishedPorts(service) {
+			ports = append(ports, StackPort{Name: service, Port: p.HostPort})
+		}
+	}
+	return ports
+}
+
+// CheckAllPorts checks that every stack port is free. Ports already
+// published by one of containers (the stack itself) are not conflicts.
+// With a remote engine the ports are checked on the engine's host.
+func CheckAllPorts(ports []StackPort, containers []string) ([]PortStatus, error) {
 	var results []PortStatus
 	var conflicts []string
 
-	// Check required ports
-	for name, port := range RequiredPorts {
-		status := CheckPort(port)
-		results = append(results, status)
-		// Only report conflict if port is NOT used by our own kkengine containers
-		if status.InUse && !status.UsedByKKEngine {
-			conflicts = append(conflicts, formatPortConflict(name, status))
+	check := CheckPort
+	if engineIsRemote() {
+		listening, err := remoteListeningPorts()
+		if err != nil {
+			// Can't determine - assume available, as checkPortWithSS does
+			ui.ShowWarningf(ui.Msg("warn_remote_port_check"), err)
 		}
+		check = func(port int) PortStatus { return PortStatus{Port: port, InUse: listening[port]} }
 	}
 
-	// Check optional Caddy ports if enabled
-	if includeCaddy {
-		for name, port := range OptionalPorts {
-			status := CheckPort(port)
-			results = append(results, status)
-			// Only report conflict if port is NOT used by our own kkengine containers
-			if status.InUse && !status.UsedByKKEngine {
-				conflicts = append(conflicts, formatPortConflict(name, status))
-			}
+	for _, p := range ports {
+		status := check(p.Port)
+		if status.InUse {
+			status.UsedByKKEngine = isPortUsedByKKEngine(p.Port, containers)
+		}
+		results = append(results, status)
+		// Only report conflict if port is NOT used by our own kkengine containers
+		if status.InUse && !status.UsedByKKEngine {
+			conflicts = append(conflicts, formatPortConflict(p.Name, status))
 		}
 	}
 
@@ -1,7 +1,12 @@
 package validator
 
 import (
+	"slices"
 	"testing"
+
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/kkauto-net/kk-install/pkg/compose"
 )
 
 func TestCheckPort(t *testing.T) {
@@ -16,26 +21,52 @@ func TestCheckPort(t *testing.T) {
 
 func TestCheckAllPorts(t *testing.T) {
 	t.Run("Check all ports without Caddy", func(t *testing.T) {
-		results, err := CheckAllPorts(false)
+		results, err := CheckAllPorts(DefaultStackPorts(false), nil)
 		if err != nil {
 			t.Logf("CheckAllPorts returned environment-dependent conflict: %v", err)
 		}
-		if len(results) < 2 {
-			t.Errorf("Expected at least 2 port checks, got %d", len(results))
+		if len(results) < 1 {
+			t.Errorf("Expected at least 1 port check, got %d", len(results))
 		}
 	})
 
 	t.Run("Check all ports with Caddy", func(t *testing.T) {
-		results, err := CheckAllPorts(true)
+		results, err := CheckAllPorts(DefaultStackPorts(true), nil)
 		if err != nil {
 			t.Logf("CheckAllPorts returned environment-dependent conflict: %v", err)
 		}
-		if len(results) < 4 {
-			t.Errorf("Expected at least 4 port checks, got %d", len(results))
+		if len(results) < 3 {
+			t.Errorf("Expected at least 3 port checks, got %d", len(results))
 		}
 	})
 }
 
+func TestStackPortsFromCompose(t *testing.T) {
+	composeFile := &compose.ComposeFile{Services: map[string]compose.Service{
+		"db":       {Ports: []string{"127.0.0.1:13306:3306"}},
+		"kkengine": {Ports: []string{"9019:8019"}},
+		"redis":    {},
+	}}
+	got := StackPortsFromCompose(composeFile)
+	want := []StackPort{{Name: "db", Port: 13306}, {Name: "kkengine", Port: 9019}}
+	if !slices.Equal(got, want) {
+		t.Fatalf("StackPortsFromCompose() = %v, want %v", got, want)
+	}
+}
+
+func TestPortPublishedBy(t *testing.T) {
+	ps := []container.Summary{
+		{Names: []string{"/staging_db"}, Ports: []container.Port{{IP: "0.0.0.0", PublicPort: 13306, PrivatePort: 3306, Type: "tcp"}}},
+		{Names: []string{"/other_db"}, Ports: []container.Port{{IP: "0.0.0.0", PublicPort: 3306, PrivatePort: 3306, Type: "tcp"}}},
+	}
+	if !portPublishedBy(ps, 13306, []string{"staging_db"}) {
+		t.Error("13306 should belong to staging_db")
+	}
+	if portPublishedBy(ps, 3306, []string{"staging_db"}) {
+		t.Error("3306 belongs to another stack")
+	}
+}
+
 func TestFormatPortConflict(t *testing.T) {
 	tests := []struct {
 		name     string
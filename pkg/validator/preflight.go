@@ -1,8 +1,11 @@
 package validator
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/kkauto-net/kk-install/pkg/compose"
+	"github.com/kkauto-net/kk-install/pkg/events"
 	"github.com/kkauto-net/kk-install/pkg/ui"
 	"github.com/pterm/pterm"
 )
@@ -14,6 +17,11 @@ type PreflightResult struct {
 	Warning    string
 	Fix        string // Fix suggestion for the error
 	FixCommand string // Command to run to fix the error
+
+	// Ports and PortStatuses are the stack ports the port check looked at
+	// and their state, in the same order. Only the port check sets them.
+	Ports        []StackPort
+	PortStatuses []PortStatus
 }
 
 // RunPreflight executes all validation checks
@@ -26,13 +34,7 @@ func RunPreflight(dir string, includeCaddy bool) ([]PreflightResult, error) {
 
 	// 1. Docker installed
 	err := dockerValidator.CheckDockerInstalled()
-	results = append(results, PreflightResult{
-		CheckName:  ui.Msg("preflight_check_docker_installed"),
-		Passed:     err == nil,
-		Error:      err,
-		Fix:        ui.Msg("preflight_fix_install_docker"),
-		FixCommand: "https://docs.docker.com/get-docker/",
-	})
+	results = append(results, dockerInstalledResult(err))
 	if err != nil {
 		hasBlockingError = true
 	}
@@ -40,26 +42,29 @@ func RunPreflight(dir string, includeCaddy bool) ([]PreflightResult, error) {
 	// 2. Docker daemon running (only if installed)
 	if !hasBlockingError {
 		err = dockerValidator.CheckDockerDaemon()
-		results = append(results, PreflightResult{
-			CheckName:  ui.Msg("preflight_check_docker_daemon"),
-			Passed:     err == nil,
-			Error:      err,
-			Fix:        ui.Msg("preflight_fix_start_docker"),
-			FixCommand: "systemctl start docker",
-		})
+		results = append(results, dockerDaemonResult(err))
 		if err != nil {
 			hasBlockingError = true
 		}
 	}
 
 	// 3. Port conflicts
-	_, err = CheckAllPorts(includeCaddy)
+	ports, containers := DefaultStackPorts(includeCaddy), []string(nil)
+	if composeFile, parseErr := compose.ParseComposeFile(dir); parseErr == nil {
+		ports, containers = StackPortsFromCompose(composeFile), composeFile.GetContainerNames()
+	}
+	portStatuses, err := CheckAllPorts(ports, containers)
+	if err == nil {
+		err = CheckRootlessPorts(ports)
+	}
 	results = append(results, PreflightResult{
-		CheckName:  ui.Msg("preflight_check_ports"),
-		Passed:     err == nil,
-		Error:      err,
-		Fix:        ui.Msg("preflight_fix_stop_conflicting"),
-		FixCommand: "",
+		CheckName:    ui.Msg("preflight_check_ports"),
+		Passed:       err == nil,
+		Error:        err,
+		Fix:          ui.Msg("preflight_fix_stop_conflicting"),
+		FixCommand:   "",
+		Ports:        ports,
+		PortStatuses: portStatuses,
 	})
 	if err != nil {
 		hasBlockingError = true
@@ -129,6 +134,55 @@ func RunPreflight(dir string, includeCaddy bool) ([]PreflightResult, error) {
 	return results, nil
 }
 
+// DockerPreflight returns the Docker checks of RunPreflight for err, the
+// outcome of getting Docker ready some other way (nil when it is ready), as
+// kk init does. A missing Docker fails the installed check; any other error
+// fails the daemon check.
+func DockerPreflight(err error) []PreflightResult {
+	var userErr *UserError
+	if errors.As(err, &userErr) && userErr.Key == ErrDockerNotInstalled {
+		return []PreflightResult{dockerInstalledResult(err)}
+	}
+	return []PreflightResult{dockerInstalledResult(nil), dockerDaemonResult(err)}
+}
+
+func dockerInstalledResult(err error) PreflightResult {
+	return PreflightResult{
+		CheckName:  ui.Msg("preflight_check_docker_installed"),
+		Passed:     err == nil,
+		Error:      err,
+		Fix:        ui.Msg("preflight_fix_install_docker"),
+		FixCommand: "https://docs.docker.com/get-docker/",
+	}
+}
+
+func dockerDaemonResult(err error) PreflightResult {
+	return PreflightResult{
+		CheckName:  ui.Msg("preflight_check_docker_daemon"),
+		Passed:     err == nil,
+		Error:      err,
+		Fix:        ui.Msg("preflight_fix_start_docker"),
+		FixCommand: "systemctl start docker",
+	}
+}
+
+// EmitPreflightResults reports each result on the event stream, if enabled.
+func EmitPreflightResults(results []PreflightResult) {
+	for _, r := range results {
+		p := events.Preflight{
+			Check:      r.CheckName,
+			Passed:     r.Passed,
+			Warning:    r.Warning,
+			Fix:        r.Fix,
+			FixCommand: r.FixCommand,
+		}
+		if r.Error != nil {
+			p.Error = ui.RedactSecrets(r.Error.Error())
+		}
+		events.PreflightResult(p)
+	}
+}
+
 // PrintPreflightResults displays preflight check results as pterm table
 func PrintPreflightResults(results []PreflightResult) {
 	tableData := pterm.TableData{
@@ -1,8 +1,11 @@
 package validator
 
 import (
+	"fmt"
 	"path/filepath"
 	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/ui"
 )
 
 func TestRunPreflight(t *testing.T) {
@@ -65,6 +68,27 @@ func TestRunPreflight(t *testing.T) {
 			t.Error("Expected Caddyfile check when Caddy enabled")
 		}
 	})
+
+	t.Run("Port check keeps per-port results", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		composeContent := "services:\n  app:\n    image: kkengine:latest\n    ports:\n      - \"18019:8019\""
+		writeTestFile(t, filepath.Join(tmpDir, "docker-compose.yml"), []byte(composeContent), 0644)
+
+		results, err := RunPreflight(tmpDir, false)
+		if err != nil {
+			t.Logf("RunPreflight returned environment-dependent error: %v", err)
+		}
+		for _, r := range results {
+			if r.CheckName != ui.Msg("preflight_check_ports") {
+				continue
+			}
+			if len(r.Ports) != 1 || r.Ports[0].Port != 18019 || len(r.PortStatuses) != 1 || r.PortStatuses[0].Port != 18019 {
+				t.Fatalf("port check = %+v %+v, want 18019", r.Ports, r.PortStatuses)
+			}
+			return
+		}
+		t.Fatal("Expected a port check")
+	})
 }
 
 func TestPrintPreflightResults(t *testing.T) {
@@ -78,3 +102,29 @@ func TestPrintPreflightResults(t *testing.T) {
 		PrintPreflightResults(results)
 	})
 }
+
+func TestDockerPreflight(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		passed []bool
+	}{
+		{name: "ready", err: nil, passed: []bool{true, true}},
+		{name: "not installed", err: &UserError{Key: ErrDockerNotInstalled}, passed: []bool{false}},
+		{name: "wrapped not installed", err: fmt.Errorf("%w: re-exec failed", &UserError{Key: ErrDockerNotInstalled}), passed: []bool{false}},
+		{name: "daemon down", err: &UserError{Key: "docker_daemon_wait_timeout"}, passed: []bool{true, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := DockerPreflight(tt.err)
+			if len(results) != len(tt.passed) {
+				t.Fatalf("DockerPreflight() returned %d results, want %d", len(results), len(tt.passed))
+			}
+			for i, r := range results {
+				if r.Passed != tt.passed[i] || (r.Error != nil) == r.Passed {
+					t.Errorf("result %d = %+v, want passed %v", i, r, tt.passed[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package validator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kkauto-net/kk-install/pkg/engine"
+)
+
+// With a remote engine (--docker-host, --context) the stack's ports and
+// volumes live on the engine's host, so the checks below read that host
+// through a probe container instead of the machine kk runs on.
+
+const remoteProbeTimeout = 2 * time.Minute
+
+var (
+	engineIsRemote = func() bool { return engine.CurrentTarget().Remote() }
+	runEngineProbe = func(ctx context.Context, p engine.Probe) (string, error) {
+		cli, err := engine.Shared()
+		if err != nil {
+			return "", err
+		}
+		return engine.RunProbe(ctx, cli, p)
+	}
+	engineDataRoot = func(ctx context.Context) (string, error) {
+		cli, err := engine.Shared()
+		if err != nil {
+			return "", err
+		}
+		info, err := cli.Info(ctx)
+		if err != nil {
+			return "", err
+		}
+		return info.DockerRootDir, nil
+	}
+)
+
+// remoteListeningPorts returns the TCP ports listening on the engine's host.
+func remoteListeningPorts() (map[int]bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteProbeTimeout)
+	defer cancel()
+	out, err := runEngineProbe(ctx, engine.Probe{
+		Cmd:         []string{"cat", "/proc/net/tcp", "/proc/net/tcp6"},
+		HostNetwork: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseListeningPorts(out), nil
+}
+
+// parseListeningPorts reads /proc/net/tcp{,6} lines: the local address is
+// hex ip:port and state 0A is LISTEN.
+func parseListeningPorts(procNet string) map[int]bool {
+	ports := make(map[int]bool)
+	for _, line := range strings.Split(procNet, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != "0A" {
+			continue
+		}
+		_, hexPort, ok := strings.Cut(fields[1], ":")
+		if !ok {
+			continue
+		}
+		if port, err := strconv.ParseInt(hexPort, 16, 32); err == nil {
+			ports[int(port)] = true
+		}
+	}
+	return ports
+}
+
+// remoteDiskSpace returns the free space in GB where the engine keeps its
+// images and volumes.
+func remoteDiskSpace() (float64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), remoteProbeTimeout)
+	defer cancel()
+	root, err := engineDataRoot(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("khong kiem tra duoc disk: %w", err)
+	}
+	out, err := runEngineProbe(ctx, engine.Probe{
+		Cmd:   []string{"df", "-Pk", "/data"},
+		Binds: []string{root + ":/data:ro"},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("khong kiem tra duoc disk: %w", err)
+	}
+	return parseDfAvailableGB(out)
+}
+
+// parseDfAvailableGB reads the Available column (KB) of df -Pk output.
+func parseDfAvailableGB(out string) (float64, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	fields := strings.Fields(lines[len(lines)-1])
+	if len(lines) < 2 || len(fields) < 4 {
+		return 0, fmt.Errorf("khong doc duoc df: %q", out)
+	}
+	kb, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil {
+		return 0, fmt.Errorf("khong doc duoc df: %q", out)
+	}
+	return kb / (1024 * 1024), nil
+}
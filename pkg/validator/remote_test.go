@@ -0,0 +1,76 @@
+package validator
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/kkauto-net/kk-install/pkg/engine"
+)
+
+// withRemoteEngine makes the checks run against a fake remote engine whose
+// probes return the output for their command.
+func withRemoteEngine(t *testing.T, outputs map[string]string) *[]engine.Probe {
+	t.Helper()
+	var probes []engine.Probe
+	originalRemote, originalProbe, originalRoot := engineIsRemote, runEngineProbe, engineDataRoot
+	t.Cleanup(func() { engineIsRemote, runEngineProbe, engineDataRoot = originalRemote, originalProbe, originalRoot })
+
+	engineIsRemote = func() bool { return true }
+	runEngineProbe = func(_ context.Context, p engine.Probe) (string, error) {
+		probes = append(probes, p)
+		return outputs[p.Cmd[0]], nil
+	}
+	engineDataRoot = func(context.Context) (string, error) { return "/srv/docker", nil }
+	return &probes
+}
+
+const procNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1F53 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1001 1 0 100 0 0 10 0
+   1: 0100007F:0050 0100007F:C350 01 00000000:00000000 00:00000000 00000000     0        0 1002 1 0 100 0 0 10 0
+  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:01BB 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1003 1 0 100 0 0 10 0
+`
+
+func TestParseListeningPorts(t *testing.T) {
+	ports := parseListeningPorts(procNetTCP)
+	if !ports[8019] || !ports[443] {
+		t.Fatalf("parseListeningPorts() = %v, want 8019 and 443", ports)
+	}
+	if ports[80] {
+		t.Fatal("an established connection on 80 is not a listener")
+	}
+}
+
+func TestCheckAllPortsRemote(t *testing.T) {
+	probes := withRemoteEngine(t, map[string]string{"cat": procNetTCP})
+
+	results, err := CheckAllPorts([]StackPort{{Name: "kkengine", Port: 8019}, {Name: "Caddy HTTP", Port: 80}}, nil)
+	if err == nil || UserErrorKey(err) != "port_conflict" {
+		t.Fatalf("CheckAllPorts() error = %v, want a conflict on 8019", err)
+	}
+	if !results[0].InUse || results[1].InUse {
+		t.Fatalf("results = %+v", results)
+	}
+	if len(*probes) != 1 || !(*probes)[0].HostNetwork {
+		t.Fatalf("probes = %+v, want one host-network probe", *probes)
+	}
+}
+
+func TestCheckDiskSpaceRemote(t *testing.T) {
+	probes := withRemoteEngine(t, map[string]string{
+		"df": "Filesystem           1024-blocks      Used Available Capacity Mounted on\n/dev/sda1             41152736  30000000  10485760  74% /data\n",
+	})
+
+	gb, err := CheckDiskSpace("/local/project")
+	if err != nil || gb != 10 {
+		t.Fatalf("CheckDiskSpace() = %v, %v, want 10 GB", gb, err)
+	}
+	if !slices.Equal((*probes)[0].Binds, []string{"/srv/docker:/data:ro"}) {
+		t.Fatalf("binds = %v, want the engine's data root", (*probes)[0].Binds)
+	}
+
+	if _, err = parseDfAvailableGB("df: /data: No such file or directory"); err == nil {
+		t.Fatal("parseDfAvailableGB() accepted output without a data line")
+	}
+}